@@ -0,0 +1,25 @@
+package auth
+
+import "github.com/spf13/cobra"
+
+func newAPIKeyCommand() *cobra.Command {
+	var (
+		name string
+		role string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "apikey",
+		Short: "Generate a gateway API key",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return authAPIKeyCmd(cmd.OutOrStdout(), name, role)
+		},
+	}
+
+	cmd.Flags().StringVarP(&name, "name", "n", "", "Name recorded in the audit log for this key")
+	cmd.Flags().StringVarP(&role, "role", "r", "admin", "Role from gateway.auth.roles granted to this key")
+	_ = cmd.MarkFlagRequired("name")
+
+	return cmd
+}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/api"
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+)
+
+func TestNewAPIKeySubcommand(t *testing.T) {
+	cmd := newAPIKeyCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "Generate a gateway API key", cmd.Short)
+
+	assert.NotNil(t, cmd.Flags().Lookup("role"))
+
+	nameFlag := cmd.Flags().Lookup("name")
+	require.NotNil(t, nameFlag)
+
+	val, found := nameFlag.Annotations[cobra.BashCompOneRequiredFlag]
+	require.True(t, found)
+	assert.Equal(t, "true", val[0])
+}
+
+func TestAuthAPIKeyCmd(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, authAPIKeyCmd(&out, "ci", "dispatch"))
+
+	lines := strings.SplitN(out.String(), "\n", 2)
+	key, ok := strings.CutPrefix(lines[0], "API key: ")
+	require.True(t, ok, "unexpected output %q", out.String())
+
+	snippet := lines[1][strings.Index(lines[1], "{"):]
+	var entry config.GatewayAPIKey
+	require.NoError(t, json.Unmarshal([]byte(snippet), &entry))
+
+	assert.Equal(t, "ci", entry.Name)
+	assert.Equal(t, "dispatch", entry.Role)
+	assert.Equal(t, api.HashAPIKey(key), entry.Hash)
+}
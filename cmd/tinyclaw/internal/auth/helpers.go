@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/tinyland-inc/tinyclaw/cmd/tinyclaw/internal"
+	"github.com/tinyland-inc/tinyclaw/pkg/api"
 	"github.com/tinyland-inc/tinyclaw/pkg/auth"
 	"github.com/tinyland-inc/tinyclaw/pkg/config"
 	"github.com/tinyland-inc/tinyclaw/pkg/providers"
@@ -438,3 +439,24 @@ func isAnthropicModel(model string) bool {
 	return model == "anthropic" ||
 		strings.HasPrefix(model, "anthropic/")
 }
+
+func authAPIKeyCmd(w io.Writer, name, role string) error {
+	key, err := api.GenerateAPIKey()
+	if err != nil {
+		return err
+	}
+
+	entry, err := json.MarshalIndent(config.GatewayAPIKey{
+		Name: name,
+		Hash: api.HashAPIKey(key),
+		Role: role,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "API key: %s\n", key)
+	fmt.Fprintln(w, "The key is shown only once. Add this entry to gateway.auth.api_keys:")
+	fmt.Fprintln(w, string(entry))
+	return nil
+}
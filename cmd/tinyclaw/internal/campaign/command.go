@@ -0,0 +1,49 @@
+package campaign
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tinyland-inc/tinyclaw/cmd/tinyclaw/internal"
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+)
+
+func NewCampaignCommand() *cobra.Command {
+	var cfg *config.Config
+
+	cmd := &cobra.Command{
+		Use:   "campaign",
+		Short: "Run and inspect campaigns",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return cmd.Help()
+		},
+		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+			var err error
+			cfg, err = internal.LoadConfig()
+			if err != nil {
+				return fmt.Errorf("error loading config: %w", err)
+			}
+			return nil
+		},
+	}
+
+	configFn := func() (*config.Config, error) {
+		if cfg == nil {
+			return nil, errors.New("config is not loaded")
+		}
+		return cfg, nil
+	}
+
+	cmd.AddCommand(
+		newRunCommand(configFn),
+		newListCommand(configFn),
+		newStatusCommand(configFn),
+		newStopCommand(configFn),
+		newLogsCommand(configFn),
+	)
+
+	return cmd
+}
@@ -0,0 +1,232 @@
+package campaign
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/cmd/tinyclaw/internal"
+	"github.com/tinyland-inc/tinyclaw/pkg/agent"
+	"github.com/tinyland-inc/tinyclaw/pkg/bus"
+	"github.com/tinyland-inc/tinyclaw/pkg/campaign"
+	"github.com/tinyland-inc/tinyclaw/pkg/campaign/adapters"
+	"github.com/tinyland-inc/tinyclaw/pkg/campaign/sinks"
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+)
+
+// followInterval is how often `campaign run` checks for finished steps.
+const followInterval = 500 * time.Millisecond
+
+// openStore opens the campaign store the gateway also uses. Commands that
+// only inspect it query the store directly, leaving retention to runners.
+func openStore(cfg *config.Config) *campaign.Store {
+	return campaign.NewStore(filepath.Join(cfg.WorkspacePath(), "campaigns"))
+}
+
+func campaignRunCmd(cfg *config.Config, path string, dryRun bool) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	def, err := campaign.LoadDefinition(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	provider, modelID, err := providers.CreateProvider(cfg)
+	if err != nil {
+		return fmt.Errorf("error creating provider: %w", err)
+	}
+	if modelID != "" {
+		cfg.Agents.Defaults.ModelName = modelID
+	}
+	agentLoop := agent.NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+
+	runner, err := campaign.NewRunnerWithStore(openStore(cfg), campaign.DefaultRetention())
+	if err != nil {
+		return err
+	}
+	runner.RegisterAdapter("tinyclaw", adapters.NewTinyClawAdapter(agentLoop))
+	// Channel reports need the gateway's channels.
+	sinks.Register(runner, nil, cfg.Campaigns.Feedback)
+	if err := runner.Check(def); err != nil {
+		return fmt.Errorf("invalid campaign %s: %w", path, err)
+	}
+	if dryRun {
+		fmt.Printf("✓ %s (%s) is valid: %d step(s), %d target(s)\n",
+			def.Name, def.ID, len(def.Steps), len(def.Targets))
+		return nil
+	}
+
+	exec, err := runner.Start(context.Background(), def)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s Started %s (execution %s), Ctrl+C to stop\n\n", internal.Logo, def.Name, exec.ID)
+	return followExecution(ctx, runner, exec.ID)
+}
+
+// followExecution prints the steps of a running execution as they finish,
+// stopping the campaign when ctx is canceled.
+func followExecution(ctx context.Context, runner *campaign.Runner, id string) error {
+	ticker := time.NewTicker(followInterval)
+	defer ticker.Stop()
+
+	printed := 0
+	for {
+		select {
+		case <-ctx.Done():
+			if err := runner.Stop(id); err == nil {
+				fmt.Println("\n⏹ Kill switch activated")
+			}
+			ctx = context.Background()
+		case <-ticker.C:
+		}
+
+		exec, err := runner.GetStatus(id)
+		if err != nil {
+			return err
+		}
+		for _, res := range exec.Results[printed:] {
+			printStep(res)
+		}
+		printed = len(exec.Results)
+		if exec.Status == campaign.StatusRunning || feedbackPending(exec) {
+			continue
+		}
+
+		fmt.Println()
+		printSummary(exec)
+		if exec.Status != campaign.StatusCompleted {
+			return fmt.Errorf("campaign %s", exec.Status)
+		}
+		return nil
+	}
+}
+
+// feedbackPending reports whether the report of a finished execution is
+// still being delivered.
+func feedbackPending(exec *campaign.Execution) bool {
+	policy := exec.Definition.Feedback
+	return policy != "" && policy != campaign.FeedbackNone && exec.Feedback == nil
+}
+
+func campaignListCmd(cfg *config.Config, q campaign.Query) error {
+	execs, err := openStore(cfg).Query(q)
+	if err != nil {
+		return err
+	}
+	if len(execs) == 0 {
+		fmt.Println("No campaign executions.")
+		return nil
+	}
+
+	fmt.Println("\nCampaign Executions:")
+	fmt.Println("--------------------")
+	for _, exec := range execs {
+		s := exec.Summary()
+		fmt.Printf("  %s (%s)\n", s.Name, s.ID)
+		fmt.Printf("    Status: %s, %d/%d steps\n", s.Status, s.StepsDone, s.Steps)
+		fmt.Printf("    Started: %s\n", s.StartTime.Local().Format("2006-01-02 15:04"))
+	}
+	return nil
+}
+
+func campaignStatusCmd(cfg *config.Config, id string) error {
+	exec, err := openStore(cfg).Get(id)
+	if err != nil {
+		return err
+	}
+
+	printSummary(exec)
+	if len(exec.Results) > 0 {
+		fmt.Println("\nSteps:")
+		for _, res := range exec.Results {
+			printStep(res)
+		}
+	}
+	return nil
+}
+
+func campaignStopCmd(cfg *config.Config, id string) error {
+	store := openStore(cfg)
+	exec, err := store.Get(id)
+	if err != nil {
+		return err
+	}
+	if exec.Status != campaign.StatusRunning {
+		return fmt.Errorf("campaign %q is not running (status: %s)", exec.ID, exec.Status)
+	}
+	if err := store.RequestStop(exec.ID); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Stop requested for %s; the process running it stops it within a few seconds\n", exec.ID)
+	return nil
+}
+
+func campaignLogsCmd(cfg *config.Config, id, step string) error {
+	exec, err := openStore(cfg).Get(id)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, res := range exec.Results {
+		if step != "" && res.StepName != step {
+			continue
+		}
+		found = true
+		fmt.Printf("=== %s (%s) ===\n", res.StepName, res.Status)
+		if res.Error != "" {
+			fmt.Printf("Error: %s\n", res.Error)
+		}
+		if res.Output != "" {
+			fmt.Println(strings.TrimRight(res.Output, "\n"))
+		}
+		fmt.Println()
+	}
+	if !found {
+		if step != "" {
+			return fmt.Errorf("execution %s has no result for step %q", exec.ID, step)
+		}
+		return errors.New("no step has finished yet")
+	}
+	return nil
+}
+
+func printSummary(exec *campaign.Execution) {
+	s := exec.Summary()
+	fmt.Printf("%s (%s)\n", s.Name, s.ID)
+	fmt.Printf("  Status: %s, %d/%d steps\n", s.Status, s.StepsDone, s.Steps)
+	fmt.Printf("  Started: %s\n", s.StartTime.Local().Format(time.DateTime))
+	if !s.EndTime.IsZero() {
+		fmt.Printf("  Duration: %s\n", s.EndTime.Sub(s.StartTime).Round(time.Second))
+	}
+	fmt.Printf("  Usage: %d iteration(s), %d tool call(s), %d¢\n", s.Iterations, s.ToolCalls, s.SpentCents)
+	if s.Error != "" {
+		fmt.Printf("  Error: %s\n", s.Error)
+	}
+	switch fb := exec.Feedback; {
+	case fb == nil:
+	case fb.Error != "":
+		fmt.Printf("  Feedback (%s) failed: %s\n", fb.Policy, fb.Error)
+	default:
+		fmt.Printf("  Feedback (%s): %s\n", fb.Policy, fb.Ref)
+	}
+}
+
+func printStep(res campaign.StepResult) {
+	switch res.Status {
+	case campaign.StepSkipped:
+		fmt.Printf("  ↷ %s skipped: %s\n", res.StepName, res.Error)
+	case campaign.StepFailed:
+		fmt.Printf("  ✗ %s failed after %d attempt(s): %s\n", res.StepName, res.Attempts, res.Error)
+	default:
+		fmt.Printf("  ✓ %s (%s, %d tool call(s))\n", res.StepName, res.Duration.Round(time.Second), res.ToolCalls)
+	}
+}
@@ -0,0 +1,66 @@
+package campaign
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/campaign"
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+)
+
+func TestNewRunSubcommand(t *testing.T) {
+	cmd := newRunCommand(func() (*config.Config, error) { return nil, nil })
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "run <file>", cmd.Use)
+	assert.True(t, cmd.HasExample())
+	assert.NotNil(t, cmd.Flags().Lookup("dry-run"))
+	assert.Error(t, cmd.Args(cmd, nil))
+}
+
+func TestCampaignCommands(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Workspace = t.TempDir()
+
+	store := campaign.NewStore(filepath.Join(cfg.WorkspacePath(), "campaigns"))
+	exec := &campaign.Execution{
+		ID:         "audit-1",
+		CampaignID: "audit",
+		Definition: &campaign.Definition{
+			ID:    "audit",
+			Name:  "Audit",
+			Steps: []campaign.Step{{Name: "scan"}, {Name: "report"}},
+		},
+		Status:    campaign.StatusRunning,
+		StartTime: time.Now(),
+	}
+	require.NoError(t, store.Create(exec))
+	require.NoError(t, store.Append(exec, &campaign.StepResult{
+		StepName: "scan", Status: campaign.StepSucceeded, Output: "no findings",
+	}))
+
+	require.NoError(t, campaignListCmd(cfg, campaign.Query{Limit: 20}))
+	require.NoError(t, campaignStatusCmd(cfg, "audit"))
+	require.NoError(t, campaignLogsCmd(cfg, "audit-1", ""))
+	require.Error(t, campaignLogsCmd(cfg, "audit-1", "report"))
+	require.Error(t, campaignStatusCmd(cfg, "missing"))
+
+	require.NoError(t, campaignStopCmd(cfg, "audit"))
+	_, err := os.Stat(filepath.Join(cfg.WorkspacePath(), "campaigns", "audit-1.stop"))
+	require.NoError(t, err, "stop request was not filed")
+}
+
+func TestCampaignRun_InvalidDefinition(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Workspace = t.TempDir()
+
+	path := filepath.Join(t.TempDir(), "broken.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"id": "broken", "stpes": []}`), 0o644))
+	require.ErrorContains(t, campaignRunCmd(cfg, path, true), "stpes")
+}
@@ -0,0 +1,32 @@
+package campaign
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/campaign"
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+)
+
+func newListCommand(configFn func() (*config.Config, error)) *cobra.Command {
+	var q campaign.Query
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List campaign executions, newest first",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			cfg, err := configFn()
+			if err != nil {
+				return err
+			}
+			return campaignListCmd(cfg, q)
+		},
+	}
+
+	cmd.Flags().StringVar(&q.CampaignID, "campaign", "", "Only list executions of this campaign")
+	cmd.Flags().StringVar(&q.Tag, "tag", "", "Only list campaigns with this tag")
+	cmd.Flags().StringVar((*string)(&q.Status), "status", "", "Only list executions with this status")
+	cmd.Flags().IntVar(&q.Limit, "limit", 20, "Maximum number of executions (0 for all)")
+
+	return cmd
+}
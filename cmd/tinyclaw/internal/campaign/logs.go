@@ -0,0 +1,28 @@
+package campaign
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+)
+
+func newLogsCommand(configFn func() (*config.Config, error)) *cobra.Command {
+	var step string
+
+	cmd := &cobra.Command{
+		Use:   "logs <id>",
+		Short: "Print the step outputs of a campaign execution",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			cfg, err := configFn()
+			if err != nil {
+				return err
+			}
+			return campaignLogsCmd(cfg, args[0], step)
+		},
+	}
+
+	cmd.Flags().StringVar(&step, "step", "", "Only print this step")
+
+	return cmd
+}
@@ -0,0 +1,30 @@
+package campaign
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+)
+
+func newRunCommand(configFn func() (*config.Config, error)) *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "run <file>",
+		Short: "Run a campaign definition (JSON or Dhall)",
+		Args:  cobra.ExactArgs(1),
+		Example: `tinyclaw campaign run dhall/examples/campaigns/code-review.dhall
+tinyclaw campaign run --dry-run audit.json`,
+		RunE: func(_ *cobra.Command, args []string) error {
+			cfg, err := configFn()
+			if err != nil {
+				return err
+			}
+			return campaignRunCmd(cfg, args[0], dryRun)
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Validate the definition without running it")
+
+	return cmd
+}
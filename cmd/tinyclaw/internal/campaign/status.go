@@ -0,0 +1,25 @@
+package campaign
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+)
+
+func newStatusCommand(configFn func() (*config.Config, error)) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status <id>",
+		Short: "Show the progress of a campaign execution",
+		Long:  "Show the progress of a campaign execution. <id> is an execution ID or a campaign ID, which selects its running or latest execution.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			cfg, err := configFn()
+			if err != nil {
+				return err
+			}
+			return campaignStatusCmd(cfg, args[0])
+		},
+	}
+
+	return cmd
+}
@@ -0,0 +1,26 @@
+package campaign
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+)
+
+func newStopCommand(configFn func() (*config.Config, error)) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stop <id>",
+		Short: "Activate the kill switch of a running campaign",
+		Long: "Activate the kill switch of a running campaign. The gateway or `campaign run` process " +
+			"executing it stops it within a few seconds, aborting in-flight tool calls.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			cfg, err := configFn()
+			if err != nil {
+				return err
+			}
+			return campaignStopCmd(cfg, args[0])
+		},
+	}
+
+	return cmd
+}
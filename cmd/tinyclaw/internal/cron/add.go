@@ -3,38 +3,85 @@ package cron
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/tinyland-inc/tinyclaw/pkg/cron"
 )
 
-func newAddCommand(storePath func() string) *cobra.Command {
+func newAddCommand(storePath, defaultTZ func() string) *cobra.Command {
 	var (
 		name    string
 		message string
 		every   int64
 		cronExp string
+		phrase  string
+		tz      string
 		deliver bool
 		channel string
 		to      string
+		preview int
+		dryRun  bool
+
+		maxAttempts  int
+		retryBackoff int64
+		overlap      string
 	)
 
 	cmd := &cobra.Command{
 		Use:   "add",
 		Short: "Add a new scheduled job",
 		Args:  cobra.NoArgs,
+		Example: `tinyclaw cron add -n standup -m "Post the standup agenda" -s "every weekday at 9am" --tz Europe/Berlin
+tinyclaw cron add -n backup -m "Check the backups" -c "0 3 * * *" --dry-run`,
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			if every <= 0 && cronExp == "" {
-				return errors.New("either --every or --cron must be specified")
+			if every <= 0 && cronExp == "" && phrase == "" {
+				return errors.New("one of --every, --cron or --schedule must be specified")
+			}
+			if tz == "" {
+				tz = defaultTZ()
+			}
+			loc, err := (cron.CronSchedule{TZ: tz}).Location()
+			if err != nil {
+				return err
+			}
+
+			if !cron.ValidOverlap(overlap) {
+				return fmt.Errorf("--overlap must be %s, %s or %s", cron.OverlapSkip, cron.OverlapQueue, cron.OverlapAllow)
+			}
+			if maxAttempts < 0 || retryBackoff < 0 {
+				return errors.New("--max-attempts and --retry-backoff must not be negative")
+			}
+			if preview < 0 {
+				return errors.New("--preview must not be negative")
 			}
 
 			var schedule cron.CronSchedule
-			if every > 0 {
+			switch {
+			case every > 0:
 				everyMS := every * 1000
 				schedule = cron.CronSchedule{Kind: "every", EveryMS: &everyMS}
-			} else {
-				schedule = cron.CronSchedule{Kind: "cron", Expr: cronExp}
+			case cronExp != "":
+				schedule = cron.CronSchedule{Kind: "cron", Expr: cronExp, TZ: tz}
+			default:
+				if schedule, err = cron.ParseSchedule(phrase, time.Now(), loc); err != nil {
+					return err
+				}
+			}
+
+			// Compute at least one run so that --preview 0 still rejects
+			// schedules that never fire.
+			runs, err := cron.NextRuns(schedule, time.Now(), max(preview, 1))
+			if err != nil {
+				return fmt.Errorf("invalid schedule: %w", err)
+			}
+			if len(runs) == 0 && schedule.Kind == "at" {
+				return errors.New("the schedule is in the past")
+			}
+			printNextRuns(runs[:min(preview, len(runs))])
+			if dryRun {
+				return nil
 			}
 
 			cs := cron.NewCronService(storePath(), nil)
@@ -42,6 +89,13 @@ func newAddCommand(storePath func() string) *cobra.Command {
 			if err != nil {
 				return fmt.Errorf("error adding job: %w", err)
 			}
+			if maxAttempts > 0 || retryBackoff > 0 || overlap != "" {
+				job.Retry = cron.RetryPolicy{MaxAttempts: maxAttempts, BackoffMS: retryBackoff * 1000}
+				job.Overlap = overlap
+				if err := cs.UpdateJob(job); err != nil {
+					return fmt.Errorf("error adding job: %w", err)
+				}
+			}
 
 			fmt.Printf("✓ Added job '%s' (%s)\n", job.Name, job.ID)
 
@@ -53,13 +107,20 @@ func newAddCommand(storePath func() string) *cobra.Command {
 	cmd.Flags().StringVarP(&message, "message", "m", "", "Message for agent")
 	cmd.Flags().Int64VarP(&every, "every", "e", 0, "Run every N seconds")
 	cmd.Flags().StringVarP(&cronExp, "cron", "c", "", "Cron expression (e.g. '0 9 * * *')")
+	cmd.Flags().StringVarP(&phrase, "schedule", "s", "", "Schedule phrase (e.g. 'every weekday at 9am', 'tomorrow 18:30')")
+	cmd.Flags().StringVar(&tz, "tz", "", "IANA timezone of --cron and --schedule (default: session.timezone, else local)")
+	cmd.Flags().IntVar(&preview, "preview", 5, "Number of upcoming runs to show")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the upcoming runs without adding the job")
 	cmd.Flags().BoolVarP(&deliver, "deliver", "d", false, "Deliver response to channel")
 	cmd.Flags().StringVar(&to, "to", "", "Recipient for delivery")
 	cmd.Flags().StringVar(&channel, "channel", "", "Channel for delivery")
+	cmd.Flags().IntVar(&maxAttempts, "max-attempts", 0, "Attempts per run before it counts as failed (default 1)")
+	cmd.Flags().Int64Var(&retryBackoff, "retry-backoff", 0, "Seconds before the first retry, doubling for later ones (default 30)")
+	cmd.Flags().StringVar(&overlap, "overlap", "", "When a run is still going: skip, queue or allow (default skip)")
 
 	_ = cmd.MarkFlagRequired("name")
 	_ = cmd.MarkFlagRequired("message")
-	cmd.MarkFlagsMutuallyExclusive("every", "cron")
+	cmd.MarkFlagsMutuallyExclusive("every", "cron", "schedule")
 
 	return cmd
 }
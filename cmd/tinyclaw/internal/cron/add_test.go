@@ -1,6 +1,7 @@
 package cron
 
 import (
+	"path/filepath"
 	"testing"
 
 	"github.com/spf13/cobra"
@@ -10,7 +11,7 @@ import (
 
 func TestNewAddSubcommand(t *testing.T) {
 	fn := func() string { return "" }
-	cmd := newAddCommand(fn)
+	cmd := newAddCommand(fn, fn)
 
 	require.NotNil(t, cmd)
 
@@ -24,6 +25,13 @@ func TestNewAddSubcommand(t *testing.T) {
 	assert.NotNil(t, cmd.Flags().Lookup("deliver"))
 	assert.NotNil(t, cmd.Flags().Lookup("to"))
 	assert.NotNil(t, cmd.Flags().Lookup("channel"))
+	assert.NotNil(t, cmd.Flags().Lookup("max-attempts"))
+	assert.NotNil(t, cmd.Flags().Lookup("retry-backoff"))
+	assert.NotNil(t, cmd.Flags().Lookup("overlap"))
+	assert.NotNil(t, cmd.Flags().Lookup("schedule"))
+	assert.NotNil(t, cmd.Flags().Lookup("tz"))
+	assert.NotNil(t, cmd.Flags().Lookup("preview"))
+	assert.NotNil(t, cmd.Flags().Lookup("dry-run"))
 
 	nameFlag := cmd.Flags().Lookup("name")
 	require.NotNil(t, nameFlag)
@@ -43,7 +51,7 @@ func TestNewAddSubcommand(t *testing.T) {
 }
 
 func TestNewAddCommandEveryAndCronMutuallyExclusive(t *testing.T) {
-	cmd := newAddCommand(func() string { return "testing" })
+	cmd := newAddCommand(func() string { return "testing" }, func() string { return "" })
 
 	cmd.SetArgs([]string{
 		"--name", "job",
@@ -55,3 +63,56 @@ func TestNewAddCommandEveryAndCronMutuallyExclusive(t *testing.T) {
 	err := cmd.Execute()
 	require.Error(t, err)
 }
+
+func TestNewAddCommandDryRunDoesNotSave(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "jobs.json")
+	cmd := newAddCommand(func() string { return storePath }, func() string { return "Europe/Berlin" })
+
+	cmd.SetArgs([]string{
+		"--name", "standup",
+		"--message", "hello",
+		"--schedule", "every weekday at 9am",
+		"--dry-run",
+	})
+
+	require.NoError(t, cmd.Execute())
+	assert.NoFileExists(t, storePath)
+}
+
+func TestNewAddCommandRejectsUnknownTimezone(t *testing.T) {
+	cmd := newAddCommand(func() string { return filepath.Join(t.TempDir(), "jobs.json") }, func() string { return "" })
+
+	cmd.SetArgs([]string{
+		"--name", "job",
+		"--message", "hello",
+		"--cron", "0 9 * * *",
+		"--tz", "Mars/Olympus",
+	})
+
+	require.Error(t, cmd.Execute())
+}
+
+func TestNewAddCommandPreview(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{name: "no preview of a future one-time job", args: []string{"--schedule", "in 2 hours", "--preview", "0"}},
+		{name: "no preview of tomorrow", args: []string{"--schedule", "tomorrow 18:30", "--preview", "0"}},
+		{name: "negative preview", args: []string{"--cron", "0 9 * * *", "--preview", "-1"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storePath := filepath.Join(t.TempDir(), "jobs.json")
+			cmd := newAddCommand(func() string { return storePath }, func() string { return "UTC" })
+			cmd.SetArgs(append([]string{"--name", "job", "--message", "hello", "--dry-run"}, tt.args...))
+
+			if tt.wantErr {
+				assert.Error(t, cmd.Execute())
+			} else {
+				assert.NoError(t, cmd.Execute())
+			}
+		})
+	}
+}
@@ -10,7 +10,7 @@ import (
 )
 
 func NewCronCommand() *cobra.Command {
-	var storePath string
+	var storePath, timezone string
 
 	cmd := &cobra.Command{
 		Use:     "cron",
@@ -28,16 +28,18 @@ func NewCronCommand() *cobra.Command {
 				return fmt.Errorf("error loading config: %w", err)
 			}
 			storePath = filepath.Join(cfg.WorkspacePath(), "cron", "jobs.json")
+			timezone = cfg.Session.Timezone
 			return nil
 		},
 	}
 
 	cmd.AddCommand(
 		newListCommand(func() string { return storePath }),
-		newAddCommand(func() string { return storePath }),
+		newAddCommand(func() string { return storePath }, func() string { return timezone }),
 		newRemoveCommand(func() string { return storePath }),
 		newEnableCommand(func() string { return storePath }),
 		newDisableCommand(func() string { return storePath }),
+		newHistoryCommand(func() string { return storePath }),
 	)
 
 	return cmd
@@ -2,6 +2,7 @@ package cron
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/tinyland-inc/tinyclaw/pkg/cron"
@@ -23,6 +24,8 @@ func cronListCmd(storePath string) {
 		switch {
 		case job.Schedule.Kind == "every" && job.Schedule.EveryMS != nil:
 			schedule = fmt.Sprintf("every %ds", *job.Schedule.EveryMS/1000)
+		case job.Schedule.Kind == "cron" && job.Schedule.TZ != "":
+			schedule = job.Schedule.Expr + " (" + job.Schedule.TZ + ")"
 		case job.Schedule.Kind == "cron":
 			schedule = job.Schedule.Expr
 		default:
@@ -32,7 +35,10 @@ func cronListCmd(storePath string) {
 		nextRun := "scheduled"
 		if job.State.NextRunAtMS != nil {
 			nextTime := time.UnixMilli(*job.State.NextRunAtMS)
-			nextRun = nextTime.Format("2006-01-02 15:04")
+			if loc, err := job.Schedule.Location(); err == nil {
+				nextTime = nextTime.In(loc)
+			}
+			nextRun = nextTime.Format("2006-01-02 15:04 MST")
 		}
 
 		status := "enabled"
@@ -44,6 +50,57 @@ func cronListCmd(storePath string) {
 		fmt.Printf("    Schedule: %s\n", schedule)
 		fmt.Printf("    Status: %s\n", status)
 		fmt.Printf("    Next run: %s\n", nextRun)
+		if job.State.LastStatus != "" {
+			fmt.Printf("    Last run: %s", job.State.LastStatus)
+			if job.State.ConsecutiveFailures > 1 {
+				fmt.Printf(" (%d failures in a row)", job.State.ConsecutiveFailures)
+			}
+			fmt.Println()
+		}
+	}
+}
+
+func cronHistoryCmd(storePath, jobID string, limit int) error {
+	cs := cron.NewCronService(storePath, nil)
+	runs, err := cs.History(jobID, limit)
+	if err != nil {
+		return fmt.Errorf("error reading history: %w", err)
+	}
+	if len(runs) == 0 {
+		fmt.Printf("No runs recorded for job %s.\n", jobID)
+		return nil
+	}
+
+	fmt.Printf("\nRuns of %s (%s), most recent first:\n", runs[0].JobName, jobID)
+	fmt.Println("----------------")
+	for _, run := range runs {
+		started := time.UnixMilli(run.StartedAtMS)
+		fmt.Printf("  %s  %-7s %s", started.Format(time.DateTime), run.Status,
+			(time.Duration(run.DurationMS) * time.Millisecond).Round(time.Millisecond))
+		if run.Attempts > 1 {
+			fmt.Printf(", %d attempts", run.Attempts)
+		}
+		if run.Tokens > 0 {
+			fmt.Printf(", %d tokens", run.Tokens)
+		}
+		fmt.Println()
+		if run.Error != "" {
+			fmt.Printf("    Error: %s\n", run.Error)
+		}
+		if run.Output != "" {
+			fmt.Printf("    Output: %s\n", strings.ReplaceAll(strings.TrimSpace(run.Output), "\n", "\n            "))
+		}
+	}
+	return nil
+}
+
+func printNextRuns(runs []time.Time) {
+	if len(runs) == 0 {
+		return
+	}
+	fmt.Println("Next runs:")
+	for _, r := range runs {
+		fmt.Printf("  %s\n", r.Format("Mon 2006-01-02 15:04 MST"))
 	}
 }
 
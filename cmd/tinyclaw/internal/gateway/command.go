@@ -8,6 +8,7 @@ func NewGatewayCommand() *cobra.Command {
 	var debug bool
 	var verified bool
 	var legacy bool
+	var allowUnauthenticated bool
 
 	cmd := &cobra.Command{
 		Use:     "gateway",
@@ -19,13 +20,15 @@ func NewGatewayCommand() *cobra.Command {
 			if legacy {
 				mode = GatewayModeLegacy
 			}
-			return gatewayCmd(debug, mode)
+			return gatewayCmd(debug, mode, allowUnauthenticated)
 		},
 	}
 
 	cmd.Flags().BoolVarP(&debug, "debug", "d", false, "Enable debug logging")
 	cmd.Flags().BoolVar(&verified, "verified", false, "Use F*-verified core for message processing (default)")
 	cmd.Flags().BoolVar(&legacy, "legacy", false, "Use legacy Go agent loop")
+	cmd.Flags().BoolVar(&allowUnauthenticated, "allow-unauthenticated", false,
+		"Serve the API on a non-loopback host without gateway.auth, e.g. behind an authenticating proxy")
 
 	return cmd
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -16,6 +17,9 @@ import (
 	"github.com/tinyland-inc/tinyclaw/pkg/aperture"
 	"github.com/tinyland-inc/tinyclaw/pkg/api"
 	"github.com/tinyland-inc/tinyclaw/pkg/bus"
+	"github.com/tinyland-inc/tinyclaw/pkg/campaign"
+	"github.com/tinyland-inc/tinyclaw/pkg/campaign/adapters"
+	"github.com/tinyland-inc/tinyclaw/pkg/campaign/sinks"
 	"github.com/tinyland-inc/tinyclaw/pkg/channels"
 	"github.com/tinyland-inc/tinyclaw/pkg/config"
 	"github.com/tinyland-inc/tinyclaw/pkg/core"
@@ -24,10 +28,13 @@ import (
 	"github.com/tinyland-inc/tinyclaw/pkg/health"
 	"github.com/tinyland-inc/tinyclaw/pkg/heartbeat"
 	"github.com/tinyland-inc/tinyclaw/pkg/logger"
+	"github.com/tinyland-inc/tinyclaw/pkg/metrics"
 	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+	"github.com/tinyland-inc/tinyclaw/pkg/providers/transport"
 	"github.com/tinyland-inc/tinyclaw/pkg/state"
 	tailscaleint "github.com/tinyland-inc/tinyclaw/pkg/tailscale"
 	"github.com/tinyland-inc/tinyclaw/pkg/tools"
+	"github.com/tinyland-inc/tinyclaw/pkg/tracing"
 	"github.com/tinyland-inc/tinyclaw/pkg/voice"
 )
 
@@ -42,7 +49,7 @@ const (
 )
 
 //nolint:funlen,gocognit,gocyclo,maintidx,nestif // complex gateway setup: orchestrates many subsystems
-func gatewayCmd(debug bool, mode GatewayMode) error {
+func gatewayCmd(debug bool, mode GatewayMode, allowUnauthenticated bool) error {
 	if debug {
 		logger.SetLevel(logger.DEBUG)
 		fmt.Println("🔍 Debug mode enabled")
@@ -56,6 +63,9 @@ func gatewayCmd(debug bool, mode GatewayMode) error {
 	if err != nil {
 		return fmt.Errorf("error loading config: %w", err)
 	}
+	if err := checkGatewayAuth(cfg, allowUnauthenticated); err != nil {
+		return err
+	}
 
 	provider, modelID, err := providers.CreateProvider(cfg)
 	if err != nil {
@@ -178,14 +188,27 @@ func gatewayCmd(debug bool, mode GatewayMode) error {
 		if err != nil {
 			fmt.Printf("Warning: Aperture client init failed: %v\n", err)
 		} else {
-			meterStore = aperture.NewMeterStore()
-			apertureClient.SetEventHandler(func(event aperture.UsageEvent) {
-				meterStore.Record("default", "default", event)
-			})
+			meterStore, err = aperture.NewPersistentMeterStore(filepath.Join(cfg.WorkspacePath(), "metering.json"))
+			if err != nil {
+				fmt.Printf("Warning: Aperture meter store unavailable, metering in memory only: %v\n", err)
+				meterStore = aperture.NewMeterStore()
+			}
+			apertureClient.SetEventHandler(meterStore.HandleAttributedEvent(apertureClient))
+			// Providers look the wrapper up per request, so this also
+			// covers the provider created above.
+			transport.SetWrapper(apertureClient.WrapTransport)
 			fmt.Println("Aperture proxy enabled")
 		}
 	}
 
+	stopTracing, err := setupTracing(cfg)
+	if err != nil {
+		fmt.Printf("Warning: tracing init failed: %v\n", err)
+		stopTracing = func() {}
+	} else if cfg.Tracing.Enabled {
+		fmt.Println("Tracing enabled")
+	}
+
 	var transcriber *voice.GroqTranscriber
 	groqAPIKey := cfg.Providers.Groq.APIKey
 	if groqAPIKey == "" {
@@ -259,7 +282,38 @@ func gatewayCmd(debug bool, mode GatewayMode) error {
 	}
 
 	healthServer := health.NewServer(cfg.Gateway.Host, cfg.Gateway.Port)
+	agentLoop.RegisterMetrics(metrics.Default)
 	apiHandlers := api.NewHandlers(agentLoop)
+	if cfg.Gateway.Auth.Enabled {
+		authenticator, err := api.NewAuthenticator(cfg.Gateway.Auth)
+		if err != nil {
+			return fmt.Errorf("invalid gateway auth config: %w", err)
+		}
+		if len(cfg.Gateway.Auth.TailnetUsers) > 0 {
+			if tsServer == nil {
+				return errors.New("gateway.auth.tailnet_users needs a running Tailscale node, which failed to start")
+			}
+			authenticator.SetIdentityResolver(tsServer)
+		}
+		apiHandlers.SetAuthenticator(authenticator)
+		fmt.Printf("✓ API authentication enabled (%d keys)\n", len(cfg.Gateway.Auth.APIKeys))
+	} else if !isLoopbackHost(cfg.Gateway.Host) {
+		fmt.Printf("Warning: gateway API on %s has no authentication (--allow-unauthenticated)\n", cfg.Gateway.Host)
+	}
+	jobManager, err := api.NewJobManager(agentLoop, filepath.Join(cfg.WorkspacePath(), "jobs"))
+	if err != nil {
+		return fmt.Errorf("error creating job manager: %w", err)
+	}
+	apiHandlers.SetJobManager(jobManager)
+	if campaignRunner, err := setupCampaigns(ctx, agentLoop, msgBus, cfg); err != nil {
+		fmt.Printf("Warning: campaigns unavailable: %v\n", err)
+	} else {
+		apiHandlers.SetCampaignRunner(campaignRunner)
+	}
+	if meterStore != nil {
+		apiHandlers.SetMeterStore(meterStore)
+		healthServer.HandleFunc(apertureClient.WebhookPath(), apertureClient.WebhookHandler().ServeHTTP)
+	}
 	apiHandlers.Register(healthServer)
 	go func() {
 		if err := healthServer.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -267,8 +321,13 @@ func gatewayCmd(debug bool, mode GatewayMode) error {
 		}
 	}()
 	fmt.Printf("✓ Health + API endpoints available at http://%s:%d\n", cfg.Gateway.Host, cfg.Gateway.Port)
+	fmt.Printf("✓ OpenAI-compatible API available at http://%s:%d/v1\n", cfg.Gateway.Host, cfg.Gateway.Port)
 
 	go agentLoop.Run(ctx)
+	if cfg.Memory.Consolidation.Enabled {
+		go agentLoop.RunMemoryConsolidation(ctx)
+		fmt.Println("✓ Memory consolidation scheduled")
+	}
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt)
@@ -284,8 +343,6 @@ func gatewayCmd(debug bool, mode GatewayMode) error {
 	if tsServer != nil {
 		tsServer.Stop()
 	}
-	_ = apertureClient // client has no Stop method, just nil check
-	_ = meterStore     // metrics are in-memory, no cleanup needed
 	cancel()
 	healthServer.Stop(context.Background())
 	deviceService.Stop()
@@ -293,11 +350,51 @@ func gatewayCmd(debug bool, mode GatewayMode) error {
 	cronService.Stop()
 	agentLoop.Stop()
 	channelManager.StopAll(ctx)
+	stopTracing()
 	fmt.Println("✓ Gateway stopped")
 
 	return nil
 }
 
+// setupTracing installs the default tracer described by cfg.Tracing. The
+// returned function flushes pending spans and stops the tracer.
+func setupTracing(cfg *config.Config) (func(), error) {
+	if !cfg.Tracing.Enabled {
+		return func() {}, nil
+	}
+
+	var exporter tracing.Exporter
+	switch cfg.Tracing.Exporter {
+	case "", "otlp":
+		exporter = tracing.NewOTLPExporter(tracing.OTLPConfig{
+			Endpoint:    cfg.Tracing.Endpoint,
+			Headers:     cfg.Tracing.Headers,
+			ServiceName: cfg.Tracing.ServiceName,
+		})
+	case "jsonl":
+		path := cfg.Tracing.FilePath
+		if path == "" {
+			path = filepath.Join(cfg.WorkspacePath(), "traces", "spans.jsonl")
+		}
+		e, err := tracing.NewJSONLExporter(path)
+		if err != nil {
+			return nil, err
+		}
+		exporter = e
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q (want otlp or jsonl)", cfg.Tracing.Exporter)
+	}
+
+	tracer := tracing.NewTracer(exporter)
+	tracing.SetDefault(tracer)
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		tracing.SetDefault(nil)
+		_ = tracer.Shutdown(ctx)
+	}, nil
+}
+
 func setupCronTool(
 	agentLoop *agent.AgentLoop,
 	msgBus *bus.MessageBus,
@@ -315,11 +412,86 @@ func setupCronTool(
 	cronTool := tools.NewCronTool(cronService, agentLoop, msgBus, workspace, restrict, execTimeout, cfg)
 	agentLoop.RegisterTool(cronTool)
 
-	// Set the onJob handler
-	cronService.SetOnJob(func(job *cron.CronJob) (string, error) {
-		result := cronTool.ExecuteJob(context.Background(), job)
-		return result, nil
+	// Set the onJob handler, metering the agent's tokens for the run history
+	cronService.SetOnJob(func(ctx context.Context, job *cron.CronJob) (string, error) {
+		runCtx := ctx
+		ctx = agent.WithTaskHooks(ctx, &agent.TaskHooks{
+			OnUsage: func(_ string, usage providers.UsageInfo, _ float64) {
+				cron.AddUsage(runCtx, usage.TotalTokens)
+			},
+		})
+		return cronTool.ExecuteJob(ctx, job)
 	})
 
+	if notify := cfg.Tools.Cron; notify.FailureChannel != "" && notify.FailureChatID != "" {
+		cronService.SetOnFailure(func(job cron.CronJob, run cron.RunRecord) {
+			msgBus.PublishOutbound(bus.OutboundMessage{
+				Channel: notify.FailureChannel,
+				ChatID:  notify.FailureChatID,
+				Content: fmt.Sprintf("⚠️ Scheduled job %q (%s) failed after %d attempt(s), %d failure(s) in a row: %s",
+					job.Name, job.ID, run.Attempts, job.State.ConsecutiveFailures, run.Error),
+			})
+		})
+	}
+
 	return cronService
 }
+
+// setupCampaigns creates the persistent campaign runner, dispatching to the
+// agent loop and delivering reports to the configured sinks, and resumes
+// the executions the last shutdown interrupted.
+func setupCampaigns(
+	ctx context.Context,
+	agentLoop *agent.AgentLoop,
+	msgBus *bus.MessageBus,
+	cfg *config.Config,
+) (*campaign.Runner, error) {
+	store := campaign.NewStore(filepath.Join(cfg.WorkspacePath(), "campaigns"))
+	runner, err := campaign.NewRunnerWithStore(store, campaign.DefaultRetention())
+	if err != nil {
+		return nil, err
+	}
+	runner.RegisterAdapter("tinyclaw", adapters.NewTinyClawAdapter(agentLoop))
+	sinks.Register(runner, msgBus, cfg.Campaigns.Feedback)
+	if resumed := runner.Resume(ctx); len(resumed) > 0 {
+		fmt.Printf("✓ Resumed %d campaign execution(s)\n", len(resumed))
+	}
+	return runner, nil
+}
+
+// checkGatewayAuth refuses to serve the API, which can run tools such as
+// exec, on a non-loopback host without gateway.auth unless
+// allowUnauthenticated is set, as `mcp serve --http` does. It also refuses
+// auth settings that cannot work in this build, so that they fail at
+// startup rather than rejecting every request.
+func checkGatewayAuth(cfg *config.Config, allowUnauthenticated bool) error {
+	auth := cfg.Gateway.Auth
+	if !auth.Enabled {
+		if !allowUnauthenticated && !isLoopbackHost(cfg.Gateway.Host) {
+			return fmt.Errorf("refusing to serve the gateway API on %s without gateway.auth: "+
+				"enable gateway.auth, bind a loopback host such as 127.0.0.1 or pass --allow-unauthenticated",
+				cfg.Gateway.Host)
+		}
+		return nil
+	}
+	if len(auth.TailnetUsers) == 0 {
+		return nil
+	}
+	if !cfg.Tailscale.Enabled {
+		return errors.New("gateway.auth.tailnet_users requires tailscale.enabled")
+	}
+	if !tailscaleint.WhoIsAvailable() {
+		return errors.New("gateway.auth.tailnet_users is not supported by this build: " +
+			"Tailscale identities need tsnet WhoIs; authenticate tailnet clients with gateway.auth.api_keys instead")
+	}
+	return nil
+}
+
+// isLoopbackHost reports whether the gateway only listens on loopback.
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,35 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+)
+
+func TestCheckGatewayAuth_TailnetUsers(t *testing.T) {
+	cfg := config.DefaultConfig()
+	assert.NoError(t, checkGatewayAuth(cfg, false))
+
+	cfg.Gateway.Auth.Enabled = true
+	cfg.Gateway.Auth.TailnetUsers = map[string]string{"alice@example.com": "admin"}
+	assert.ErrorContains(t, checkGatewayAuth(cfg, false), "tailscale.enabled")
+
+	// Without tsnet WhoIs every tailnet request would be rejected.
+	cfg.Tailscale.Enabled = true
+	assert.ErrorContains(t, checkGatewayAuth(cfg, false), "not supported by this build")
+}
+
+func TestCheckGatewayAuth_NonLoopbackHost(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Gateway.Host = "127.0.0.1"
+	assert.NoError(t, checkGatewayAuth(cfg, false))
+
+	cfg.Gateway.Host = "0.0.0.0"
+	assert.ErrorContains(t, checkGatewayAuth(cfg, false), "without gateway.auth")
+	assert.NoError(t, checkGatewayAuth(cfg, true))
+
+	cfg.Gateway.Auth.Enabled = true
+	assert.NoError(t, checkGatewayAuth(cfg, false))
+}
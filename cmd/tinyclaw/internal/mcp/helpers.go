@@ -0,0 +1,170 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/cmd/tinyclaw/internal"
+	"github.com/tinyland-inc/tinyclaw/pkg/agent"
+	"github.com/tinyland-inc/tinyclaw/pkg/aperture"
+	"github.com/tinyland-inc/tinyclaw/pkg/api"
+	"github.com/tinyland-inc/tinyclaw/pkg/bus"
+	"github.com/tinyland-inc/tinyclaw/pkg/logger"
+	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+	"github.com/tinyland-inc/tinyclaw/pkg/tools"
+)
+
+func mcpServeCmd(agentID, httpAddr string, debug bool) error {
+	if debug {
+		logger.SetLevel(logger.DEBUG)
+	}
+
+	cfg, err := internal.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("error loading config: %w", err)
+	}
+
+	provider, modelID, err := providers.CreateProvider(cfg)
+	if err != nil {
+		return fmt.Errorf("error creating provider: %w", err)
+	}
+	if modelID != "" {
+		cfg.Agents.Defaults.ModelName = modelID
+	}
+
+	msgBus := bus.NewMessageBus()
+	agentLoop := agent.NewAgentLoop(cfg, msgBus, provider)
+
+	var target *agent.AgentInstance
+	if agentID == "" {
+		target = agentLoop.GetRegistry().GetDefaultAgent()
+	} else {
+		var ok bool
+		target, ok = agentLoop.GetAgent(agentID)
+		if !ok {
+			return fmt.Errorf("agent %q not found", agentID)
+		}
+	}
+	if target == nil {
+		return errors.New("no agent configured")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// There is no channel manager here: log outbound messages (from the
+	// message tool, for example) instead of letting the bus fill up.
+	go drainOutbound(ctx, msgBus)
+
+	// Publish the agent's tools plus a synthetic ask_agent tool. The served
+	// registry is separate so the agent never sees ask_agent itself.
+	served := tools.NewToolRegistry()
+	for _, name := range target.Tools.List() {
+		if tool, ok := target.Tools.Get(name); ok {
+			served.Register(tool)
+		}
+	}
+	served.Register(tools.NewAskAgentTool(target.ID, agentLoop.ProcessDirectWithChannel))
+
+	server := tools.NewMCPServer("tinyclaw-"+target.ID, internal.GetVersion(), served)
+	server.SetContext("mcp", target.ID)
+
+	cerbos := aperture.NewCerbosClient(aperture.CerbosConfig{
+		Enabled: cfg.Aperture.CerbosURL != "",
+		PDPURL:  cfg.Aperture.CerbosURL,
+	})
+	server.SetToolPolicy(func(ctx context.Context, toolName string) error {
+		decision, err := cerbos.CheckToolAccess(ctx, aperture.ToolAccessRequest{
+			AgentID:  target.ID,
+			Channel:  "mcp",
+			ToolName: toolName,
+			Action:   "execute",
+		})
+		if err != nil {
+			return err
+		}
+		if !decision.Allowed {
+			return fmt.Errorf("denied by policy %s: %s", decision.PolicyID, decision.Reason)
+		}
+		return nil
+	})
+
+	var handler http.Handler = server
+	if httpAddr != "" {
+		if cfg.Gateway.Auth.Enabled {
+			authenticator, err := api.NewAuthenticator(cfg.Gateway.Auth)
+			if err != nil {
+				return fmt.Errorf("gateway auth: %w", err)
+			}
+			handler = authenticator.Protect(target.ID, server)
+		} else if !isLoopback(httpAddr) {
+			return fmt.Errorf("refusing to serve MCP on %s without gateway.auth: "+
+				"enable gateway.auth or bind a loopback address such as 127.0.0.1", httpAddr)
+		}
+	}
+
+	logger.InfoCF("mcp", "MCP server ready", map[string]any{
+		"agent_id": target.ID,
+		"tools":    served.Count(),
+		"http":     httpAddr,
+	})
+
+	if httpAddr == "" {
+		return server.ServeStdio(ctx, os.Stdin, os.Stdout)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", handler)
+	httpServer := &http.Server{
+		Addr:              httpAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+
+	go func() {
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, os.Interrupt)
+		<-sigChan
+		cancel()
+		_ = httpServer.Shutdown(context.Background())
+	}()
+
+	fmt.Fprintf(os.Stderr, "✓ MCP server for agent %q listening on http://%s/mcp\n", target.ID, httpAddr)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("mcp http server: %w", err)
+	}
+	return nil
+}
+
+// isLoopback reports whether addr only listens on the loopback interface.
+// An empty host listens on every interface.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func drainOutbound(ctx context.Context, msgBus *bus.MessageBus) {
+	for {
+		msg, ok := msgBus.SubscribeOutbound(ctx)
+		if !ok {
+			return
+		}
+		logger.InfoCF("mcp", "Dropping outbound message (no channels in MCP mode)", map[string]any{
+			"channel": msg.Channel,
+			"chat_id": msg.ChatID,
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package mcp
+
+import "github.com/spf13/cobra"
+
+func newServeCommand() *cobra.Command {
+	var (
+		agentID  string
+		httpAddr string
+		debug    bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve an agent's tools over MCP (stdio or HTTP)",
+		Args:  cobra.NoArgs,
+		Example: `tinyclaw mcp serve
+tinyclaw mcp serve --agent research --http 127.0.0.1:18795`,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return mcpServeCmd(agentID, httpAddr, debug)
+		},
+	}
+
+	cmd.Flags().StringVarP(&agentID, "agent", "a", "", "Agent whose tools to publish (default: default agent)")
+	cmd.Flags().StringVar(&httpAddr, "http", "", "Serve JSON-RPC over HTTP on this address instead of stdio (non-loopback addresses require gateway.auth)")
+	cmd.Flags().BoolVarP(&debug, "debug", "d", false, "Enable debug logging")
+
+	return cmd
+}
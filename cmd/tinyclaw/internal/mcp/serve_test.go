@@ -0,0 +1,38 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewServeSubcommand(t *testing.T) {
+	cmd := newServeCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "serve", cmd.Use)
+	assert.Equal(t, "Serve an agent's tools over MCP (stdio or HTTP)", cmd.Short)
+
+	assert.True(t, cmd.HasExample())
+	assert.True(t, cmd.HasFlags())
+
+	assert.NotNil(t, cmd.Flags().Lookup("agent"))
+	assert.NotNil(t, cmd.Flags().Lookup("http"))
+	assert.NotNil(t, cmd.Flags().Lookup("debug"))
+}
+
+func TestIsLoopback(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"127.0.0.1:18795": true,
+		"localhost:18795": true,
+		"[::1]:18795":     true,
+		":18795":          false,
+		"0.0.0.0:18795":   false,
+		"10.0.0.5:18795":  false,
+		"18795":           false,
+	} {
+		assert.Equal(t, want, isLoopback(addr), addr)
+	}
+}
@@ -0,0 +1,34 @@
+package memory
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+)
+
+func newConsolidateCommand(configFn func() (*config.Config, error)) *cobra.Command {
+	var (
+		agentID string
+		dryRun  bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "consolidate",
+		Short: "Distill old daily notes into MEMORY.md",
+		Args:  cobra.NoArgs,
+		Example: `tinyclaw memory consolidate --dry-run
+tinyclaw memory consolidate --agent main`,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			cfg, err := configFn()
+			if err != nil {
+				return err
+			}
+			return memoryConsolidateCmd(cfg, agentID, dryRun)
+		},
+	}
+
+	cmd.Flags().StringVar(&agentID, "agent", "", "Only consolidate this agent's memory")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the changes as a diff without applying them")
+
+	return cmd
+}
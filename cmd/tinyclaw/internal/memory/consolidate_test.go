@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/agent"
+	"github.com/tinyland-inc/tinyclaw/pkg/bus"
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+)
+
+type factProvider struct{}
+
+func (factProvider) Chat(
+	context.Context, []providers.Message, []providers.ToolDefinition, string, map[string]any,
+) (*providers.LLMResponse, error) {
+	return &providers.LLMResponse{Content: `{"facts": ["The user's cat is called Miso."]}`}, nil
+}
+
+func (factProvider) GetDefaultModel() string { return "mock-model" }
+
+func TestNewConsolidateSubcommand(t *testing.T) {
+	cmd := newConsolidateCommand(func() (*config.Config, error) { return nil, nil })
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "consolidate", cmd.Use)
+	assert.Equal(t, "Distill old daily notes into MEMORY.md", cmd.Short)
+	assert.True(t, cmd.HasExample())
+
+	assert.NotNil(t, cmd.Flags().Lookup("agent"))
+	assert.NotNil(t, cmd.Flags().Lookup("dry-run"))
+}
+
+func TestConsolidate(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Workspace = t.TempDir()
+	al := agent.NewAgentLoop(cfg, bus.NewMessageBus(), factProvider{})
+
+	old := time.Now().AddDate(0, 0, -30).Format("20060102")
+	note := filepath.Join(cfg.Agents.Defaults.Workspace, "memory", old[:6], old+".md")
+	require.NoError(t, os.MkdirAll(filepath.Dir(note), 0o755))
+	require.NoError(t, os.WriteFile(note, []byte("Adopted a cat, Miso."), 0o644))
+
+	require.NoError(t, consolidate(al, "", true))
+	assert.FileExists(t, note)
+
+	require.NoError(t, consolidate(al, "main", false))
+	assert.NoFileExists(t, note)
+	data, err := os.ReadFile(filepath.Join(cfg.Agents.Defaults.Workspace, "memory", "MEMORY.md"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "Miso")
+
+	require.Error(t, consolidate(al, "nobody", true))
+}
@@ -0,0 +1,67 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/agent"
+	"github.com/tinyland-inc/tinyclaw/pkg/bus"
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+	"github.com/tinyland-inc/tinyclaw/pkg/routing"
+)
+
+func memoryConsolidateCmd(cfg *config.Config, agentID string, dryRun bool) error {
+	provider, modelID, err := providers.CreateProvider(cfg)
+	if err != nil {
+		return fmt.Errorf("error creating provider: %w", err)
+	}
+	if modelID != "" {
+		cfg.Agents.Defaults.ModelName = modelID
+	}
+	return consolidate(agent.NewAgentLoop(cfg, bus.NewMessageBus(), provider), agentID, dryRun)
+}
+
+func consolidate(al *agent.AgentLoop, agentID string, dryRun bool) error {
+	ids := al.ListAgentIDs()
+	slices.Sort(ids)
+	if agentID != "" {
+		agentID = routing.NormalizeAgentID(agentID)
+		if !slices.Contains(ids, agentID) {
+			return fmt.Errorf("agent %q not found", agentID)
+		}
+		ids = []string{agentID}
+	}
+
+	// Agents sharing a workspace share a memory directory.
+	seen := make(map[string]bool)
+
+	for _, id := range ids {
+		instance, _ := al.GetAgent(id)
+		if seen[instance.Workspace] {
+			continue
+		}
+		seen[instance.Workspace] = true
+
+		report, err := al.ConsolidateMemory(context.Background(), id, dryRun)
+		if err != nil {
+			return fmt.Errorf("agent %s: %w", id, err)
+		}
+		if len(report.Notes) == 0 {
+			fmt.Printf("✓ %s: no daily notes old enough to consolidate\n", id)
+			continue
+		}
+
+		verb := "consolidated"
+		if dryRun {
+			verb = "would consolidate"
+		}
+		fmt.Printf("✓ %s: %s %d note(s): %d new fact(s), %d duplicate(s), %d contradiction(s) to review\n",
+			id, verb, len(report.Notes), len(report.Facts), len(report.Duplicates), len(report.Contradictions))
+		if dryRun {
+			fmt.Printf("\n%s\n", report.Diff)
+		}
+	}
+	return nil
+}
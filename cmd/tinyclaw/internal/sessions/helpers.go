@@ -0,0 +1,142 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/agent"
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+	"github.com/tinyland-inc/tinyclaw/pkg/routing"
+	"github.com/tinyland-inc/tinyclaw/pkg/session"
+)
+
+func sessionsMigrateCmd(cfg *config.Config, from, to, agentID string) error {
+	for _, backend := range []string{from, to} {
+		if err := (&config.SessionConfig{Store: backend}).Validate(); err != nil {
+			return err
+		}
+	}
+	if normalizeBackend(from) == normalizeBackend(to) {
+		return fmt.Errorf("--from and --to are both %q", normalizeBackend(to))
+	}
+
+	ids, dirs, err := agentDirs(cfg, agentID)
+	if err != nil {
+		return err
+	}
+
+	// Agents sharing a workspace share a sessions directory.
+	seen := make(map[string]bool)
+
+	total := 0
+	for _, id := range ids {
+		dir := dirs[id]
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+
+		n, err := migrateDir(dir, from, to)
+		if err != nil {
+			return fmt.Errorf("agent %s: %w", id, err)
+		}
+		fmt.Printf("✓ %s: migrated %d session(s) in %s\n", id, n, dir)
+		total += n
+	}
+
+	fmt.Printf("\nMigrated %d session(s) from %s to %s.\n", total, normalizeBackend(from), normalizeBackend(to))
+	if normalizeBackend(cfg.Session.Store) != normalizeBackend(to) {
+		fmt.Printf("Set \"session\": {\"store\": %q} in your config to use them.\n", to)
+	}
+	fmt.Println("The source sessions were left in place.")
+	return nil
+}
+
+func migrateDir(dir, from, to string) (int, error) {
+	src, err := session.OpenStore(from, dir)
+	if err != nil {
+		return 0, fmt.Errorf("opening %s store (is the gateway running?): %w", normalizeBackend(from), err)
+	}
+	defer src.Close()
+
+	dst, err := session.OpenStore(to, dir)
+	if err != nil {
+		return 0, fmt.Errorf("opening %s store (is the gateway running?): %w", normalizeBackend(to), err)
+	}
+
+	n, err := session.Migrate(src, dst)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+func sessionsSearchCmd(cfg *config.Config, query, agentID string, limit int) error {
+	if limit <= 0 {
+		return errors.New("--limit must be positive")
+	}
+	ids, dirs, err := agentDirs(cfg, agentID)
+	if err != nil {
+		return err
+	}
+
+	index := session.NewSearchIndex()
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		dir := dirs[id]
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+
+		store, err := session.OpenStore(cfg.Session.Store, dir)
+		if err != nil {
+			return fmt.Errorf("agent %s: opening session store (is the gateway running?): %w", id, err)
+		}
+		sm := session.NewSessionManagerWithStore(store)
+		defer sm.Close()
+		index.Attach(id, sm)
+	}
+
+	hits, err := index.Search(context.Background(), query, session.SearchOptions{Limit: limit})
+	if err != nil {
+		return err
+	}
+	if len(hits) == 0 {
+		fmt.Printf("No messages found for %q.\n", query)
+		return nil
+	}
+	for _, h := range hits {
+		fmt.Printf("%s  %s/%s  %s #%s\n", h.Time.Format("2006-01-02 15:04"), h.AgentID, h.SessionKey, h.Role, h.MessageID)
+		fmt.Printf("    %s\n\n", h.Snippet)
+	}
+	return nil
+}
+
+// agentDirs returns the sorted agent IDs and their session directories,
+// limited to agentID when set.
+func agentDirs(cfg *config.Config, agentID string) ([]string, map[string]string, error) {
+	dirs := agent.SessionDirs(cfg)
+	if agentID != "" {
+		dir, ok := dirs[routing.NormalizeAgentID(agentID)]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown agent %q", agentID)
+		}
+		dirs = map[string]string{routing.NormalizeAgentID(agentID): dir}
+	}
+	ids := make([]string, 0, len(dirs))
+	for id := range dirs {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids, dirs, nil
+}
+
+func normalizeBackend(backend string) string {
+	if backend == "" {
+		return config.SessionStoreJSON
+	}
+	return backend
+}
@@ -0,0 +1,38 @@
+package sessions
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+)
+
+func newMigrateCommand(configFn func() (*config.Config, error)) *cobra.Command {
+	var (
+		from    string
+		to      string
+		agentID string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Copy sessions between storage backends",
+		Args:  cobra.NoArgs,
+		Example: `tinyclaw sessions migrate --to bolt
+tinyclaw sessions migrate --from bolt --to json --agent main`,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			cfg, err := configFn()
+			if err != nil {
+				return err
+			}
+			return sessionsMigrateCmd(cfg, from, to, agentID)
+		},
+	}
+
+	cmd.Flags().StringVar(&from, "from", config.SessionStoreJSON, "Backend to read sessions from (json or bolt)")
+	cmd.Flags().StringVar(&to, "to", "", "Backend to write sessions to (json or bolt)")
+	cmd.Flags().StringVar(&agentID, "agent", "", "Only migrate this agent's sessions")
+
+	_ = cmd.MarkFlagRequired("to")
+
+	return cmd
+}
@@ -0,0 +1,60 @@
+package sessions
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+	"github.com/tinyland-inc/tinyclaw/pkg/session"
+)
+
+func TestNewMigrateSubcommand(t *testing.T) {
+	cmd := newMigrateCommand(func() (*config.Config, error) { return nil, nil })
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "migrate", cmd.Use)
+	assert.Equal(t, "Copy sessions between storage backends", cmd.Short)
+	assert.True(t, cmd.HasExample())
+
+	assert.NotNil(t, cmd.Flags().Lookup("from"))
+	assert.NotNil(t, cmd.Flags().Lookup("agent"))
+
+	toFlag := cmd.Flags().Lookup("to")
+	require.NotNil(t, toFlag)
+	val, found := toFlag.Annotations[cobra.BashCompOneRequiredFlag]
+	require.True(t, found)
+	assert.Equal(t, "true", val[0])
+}
+
+func TestMigrateDir(t *testing.T) {
+	dir := t.TempDir()
+
+	sm := session.NewSessionManager(dir)
+	sm.AddMessage("telegram:1", "user", "hello")
+	sm.AddMessage("telegram:1", "assistant", "hi")
+	require.NoError(t, sm.Save("telegram:1"))
+
+	n, err := migrateDir(dir, config.SessionStoreJSON, config.SessionStoreBolt)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	store, err := session.OpenStore(config.SessionStoreBolt, dir)
+	require.NoError(t, err)
+	defer store.Close()
+
+	sess, err := store.Load("telegram:1")
+	require.NoError(t, err)
+	assert.Len(t, sess.Messages, 2)
+}
+
+func TestSessionsMigrateRejectsSameBackend(t *testing.T) {
+	err := sessionsMigrateCmd(config.DefaultConfig(), "", config.SessionStoreJSON, "")
+	require.Error(t, err)
+
+	err = sessionsMigrateCmd(config.DefaultConfig(), config.SessionStoreJSON, "sqlite", "")
+	require.Error(t, err)
+}
@@ -0,0 +1,36 @@
+package sessions
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+)
+
+func newSearchCommand(configFn func() (*config.Config, error)) *cobra.Command {
+	var (
+		agentID string
+		limit   int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "search <query>",
+		Short: "Search the messages of past conversations",
+		Args:  cobra.MinimumNArgs(1),
+		Example: `tinyclaw sessions search kubernetes migration
+tinyclaw sessions search --agent main --limit 20 "billing database"`,
+		RunE: func(_ *cobra.Command, args []string) error {
+			cfg, err := configFn()
+			if err != nil {
+				return err
+			}
+			return sessionsSearchCmd(cfg, strings.Join(args, " "), agentID, limit)
+		},
+	}
+
+	cmd.Flags().StringVar(&agentID, "agent", "", "Only search this agent's sessions")
+	cmd.Flags().IntVar(&limit, "limit", 10, "Maximum number of results")
+
+	return cmd
+}
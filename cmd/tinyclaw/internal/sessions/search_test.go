@@ -0,0 +1,39 @@
+package sessions
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+	"github.com/tinyland-inc/tinyclaw/pkg/session"
+)
+
+func TestNewSearchSubcommand(t *testing.T) {
+	cmd := newSearchCommand(func() (*config.Config, error) { return nil, nil })
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "search <query>", cmd.Use)
+	assert.Equal(t, "Search the messages of past conversations", cmd.Short)
+	assert.True(t, cmd.HasExample())
+
+	assert.NotNil(t, cmd.Flags().Lookup("agent"))
+	assert.NotNil(t, cmd.Flags().Lookup("limit"))
+	assert.Error(t, cmd.Args(cmd, nil))
+}
+
+func TestSessionsSearch(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Workspace = t.TempDir()
+
+	sm := session.NewSessionManager(filepath.Join(cfg.Agents.Defaults.Workspace, "sessions"))
+	sm.AddMessage("telegram:1", "user", "we picked Postgres for billing")
+	require.NoError(t, sm.Save("telegram:1"))
+
+	require.NoError(t, sessionsSearchCmd(cfg, "postgres", "", 5))
+	require.Error(t, sessionsSearchCmd(cfg, "postgres", "nobody", 5))
+	require.Error(t, sessionsSearchCmd(cfg, "postgres", "", 0))
+}
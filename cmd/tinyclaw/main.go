@@ -15,10 +15,14 @@ import (
 	"github.com/tinyland-inc/tinyclaw/cmd/tinyclaw/internal"
 	"github.com/tinyland-inc/tinyclaw/cmd/tinyclaw/internal/agent"
 	"github.com/tinyland-inc/tinyclaw/cmd/tinyclaw/internal/auth"
+	"github.com/tinyland-inc/tinyclaw/cmd/tinyclaw/internal/campaign"
 	"github.com/tinyland-inc/tinyclaw/cmd/tinyclaw/internal/cron"
 	"github.com/tinyland-inc/tinyclaw/cmd/tinyclaw/internal/gateway"
+	"github.com/tinyland-inc/tinyclaw/cmd/tinyclaw/internal/mcp"
+	"github.com/tinyland-inc/tinyclaw/cmd/tinyclaw/internal/memory"
 	"github.com/tinyland-inc/tinyclaw/cmd/tinyclaw/internal/migrate"
 	"github.com/tinyland-inc/tinyclaw/cmd/tinyclaw/internal/onboard"
+	"github.com/tinyland-inc/tinyclaw/cmd/tinyclaw/internal/sessions"
 	"github.com/tinyland-inc/tinyclaw/cmd/tinyclaw/internal/skills"
 	"github.com/tinyland-inc/tinyclaw/cmd/tinyclaw/internal/status"
 	"github.com/tinyland-inc/tinyclaw/cmd/tinyclaw/internal/version"
@@ -37,10 +41,14 @@ func NewTinyclawCommand() *cobra.Command {
 		onboard.NewOnboardCommand(),
 		agent.NewAgentCommand(),
 		auth.NewAuthCommand(),
+		campaign.NewCampaignCommand(),
 		gateway.NewGatewayCommand(),
+		mcp.NewMCPCommand(),
+		memory.NewMemoryCommand(),
 		status.NewStatusCommand(),
 		cron.NewCronCommand(),
 		migrate.NewMigrateCommand(),
+		sessions.NewSessionsCommand(),
 		skills.NewSkillsCommand(),
 		version.NewVersionCommand(),
 	)
@@ -35,10 +35,14 @@ func TestNewTinyclawCommand(t *testing.T) {
 	allowedCommands := []string{
 		"agent",
 		"auth",
+		"campaign",
 		"cron",
 		"gateway",
+		"mcp",
+		"memory",
 		"migrate",
 		"onboard",
+		"sessions",
 		"skills",
 		"status",
 		"version",
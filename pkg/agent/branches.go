@@ -0,0 +1,167 @@
+package agent
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/bus"
+	"github.com/tinyland-inc/tinyclaw/pkg/routing"
+	"github.com/tinyland-inc/tinyclaw/pkg/session"
+)
+
+// SessionBranches lists the branches of a session, the active one first.
+// It returns the ID of the agent that owns the session.
+func (al *AgentLoop) SessionBranches(agentID, key string) ([]session.Branch, string, error) {
+	agent, ok := al.sessionOwner(agentID, key)
+	if !ok {
+		return nil, "", session.ErrNotFound
+	}
+	branches, err := agent.Sessions.Branches(key)
+	return branches, agent.ID, err
+}
+
+// RewindSession makes messageID the last message of the session's active
+// branch, keeping the later messages as an inactive branch. It returns how
+// many messages left the active branch.
+func (al *AgentLoop) RewindSession(agentID, key, messageID string) (int, error) {
+	return al.editSession(agentID, key, func(sm *session.SessionManager) (int, error) {
+		return sm.Rewind(key, messageID)
+	})
+}
+
+// RewindSessionTurns rewinds the active branch to before its turns-th last
+// user message.
+func (al *AgentLoop) RewindSessionTurns(agentID, key string, turns int) (int, error) {
+	return al.editSession(agentID, key, func(sm *session.SessionManager) (int, error) {
+		return sm.RewindTurns(key, turns)
+	})
+}
+
+// CheckoutSession makes the branch ending at messageID the active one.
+func (al *AgentLoop) CheckoutSession(agentID, key, messageID string) error {
+	_, err := al.editSession(agentID, key, func(sm *session.SessionManager) (int, error) {
+		return 0, sm.Checkout(key, messageID)
+	})
+	return err
+}
+
+// ForkSession copies a session's active branch up to messageID (all of it
+// when empty) into the new session newKey of the same agent.
+func (al *AgentLoop) ForkSession(agentID, key, newKey, messageID string) error {
+	agent, ok := al.sessionOwner(agentID, key)
+	if !ok {
+		return session.ErrNotFound
+	}
+	if parsed := routing.ParseAgentSessionKey(newKey); parsed != nil && parsed.AgentID != agent.ID {
+		return fmt.Errorf("cannot fork a session of agent %s into agent %s", agent.ID, parsed.AgentID)
+	}
+	if err := agent.Sessions.Fork(key, newKey, messageID); err != nil {
+		return err
+	}
+	return agent.Sessions.Save(newKey)
+}
+
+func (al *AgentLoop) editSession(
+	agentID, key string,
+	edit func(*session.SessionManager) (int, error),
+) (int, error) {
+	agent, ok := al.sessionOwner(agentID, key)
+	if !ok {
+		return 0, session.ErrNotFound
+	}
+	n, err := edit(agent.Sessions)
+	if err != nil {
+		return 0, err
+	}
+	return n, agent.Sessions.Save(key)
+}
+
+// branchCommand handles the /rewind, /branches, /branch and /fork chat
+// commands on the sender's own session.
+func (al *AgentLoop) branchCommand(msg bus.InboundMessage, cmd string, args []string) string {
+	agent, key, _ := al.resolveRoute(msg)
+	if agent == nil || !agent.Sessions.Has(key) {
+		return "No conversation to work with yet"
+	}
+
+	switch cmd {
+	case "/rewind":
+		var (
+			moved int
+			err   error
+		)
+		switch {
+		case len(args) == 0:
+			moved, err = al.RewindSessionTurns(agent.ID, key, 1)
+		case len(args) == 2 && args[0] == "to":
+			moved, err = al.RewindSession(agent.ID, key, args[1])
+		default:
+			turns, convErr := strconv.Atoi(args[0])
+			if convErr != nil || len(args) != 1 {
+				return "Usage: /rewind [turns] | /rewind to <message-id>"
+			}
+			moved, err = al.RewindSessionTurns(agent.ID, key, turns)
+		}
+		if err != nil {
+			return "Rewind failed: " + err.Error()
+		}
+		if moved == 0 {
+			return "Nothing to rewind"
+		}
+		return fmt.Sprintf("Rewound %d message(s). The previous thread is kept; use /branches to switch back.", moved)
+
+	case "/branches":
+		branches, err := agent.Sessions.Branches(key)
+		if err != nil {
+			return "Listing branches failed: " + err.Error()
+		}
+		if len(branches) == 0 {
+			return "No branches yet"
+		}
+		var sb strings.Builder
+		sb.WriteString("Branches (switch with /branch <leaf-id>):\n")
+		for _, b := range branches {
+			marker := " "
+			if b.Active {
+				marker = "*"
+			}
+			fork := ""
+			if !b.Active {
+				fork = "forked at start, "
+				if b.ForkID != "" {
+					fork = "forked after " + b.ForkID + ", "
+				}
+			}
+			fmt.Fprintf(&sb, "%s %s: %s%d messages — %s\n", marker, b.LeafID, fork, b.Messages, b.Preview)
+		}
+		return strings.TrimRight(sb.String(), "\n")
+
+	case "/branch":
+		if len(args) != 1 {
+			return "Usage: /branch <leaf-id>"
+		}
+		if err := al.CheckoutSession(agent.ID, key, args[0]); err != nil {
+			return "Switching branch failed: " + err.Error()
+		}
+		return "Switched to branch " + args[0]
+
+	case "/fork":
+		if len(args) < 1 || len(args) > 2 {
+			return "Usage: /fork <name> [message-id]"
+		}
+		newKey := args[0]
+		if !strings.Contains(newKey, ":") {
+			newKey = "agent:" + agent.ID + ":" + newKey
+		}
+		messageID := ""
+		if len(args) == 2 {
+			messageID = args[1]
+		}
+		if err := al.ForkSession(agent.ID, key, newKey, messageID); err != nil {
+			return "Fork failed: " + err.Error()
+		}
+		return "Forked this conversation into session " + newKey
+	}
+	return ""
+}
@@ -0,0 +1,51 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestBranchCommands(t *testing.T) {
+	al, _ := newBudgetLoop(t)
+	ctx := context.Background()
+	const key = "agent:main:branchy"
+
+	for _, q := range []string{"first", "second"} {
+		if _, err := al.ProcessDirect(ctx, q, key); err != nil {
+			t.Fatalf("ProcessDirect: %v", err)
+		}
+	}
+	agent := al.registry.GetDefaultAgent()
+
+	reply, err := al.ProcessDirect(ctx, "/rewind", key)
+	if err != nil || !strings.Contains(reply, "Rewound 2 message(s)") {
+		t.Fatalf("/rewind = %q, %v", reply, err)
+	}
+	if got := len(agent.Sessions.GetHistory(key)); got != 2 {
+		t.Fatalf("history after /rewind = %d messages, want 2", got)
+	}
+
+	reply, _ = al.ProcessDirect(ctx, "/branches", key)
+	if !strings.Contains(reply, "* 2: 2 messages") || !strings.Contains(reply, "4: forked after 2, 4 messages") {
+		t.Fatalf("/branches =\n%s", reply)
+	}
+
+	if reply, _ = al.ProcessDirect(ctx, "/branch 4", key); reply != "Switched to branch 4" {
+		t.Fatalf("/branch = %q", reply)
+	}
+	if got := len(agent.Sessions.GetHistory(key)); got != 4 {
+		t.Errorf("history after /branch = %d messages, want 4", got)
+	}
+
+	reply, _ = al.ProcessDirect(ctx, "/fork experiment 2", key)
+	if !strings.Contains(reply, "agent:main:experiment") {
+		t.Fatalf("/fork = %q", reply)
+	}
+	if got := len(agent.Sessions.GetHistory("agent:main:experiment")); got != 2 {
+		t.Errorf("forked history = %d messages, want 2", got)
+	}
+	if err := al.ForkSession("", key, "agent:other:x", ""); err == nil {
+		t.Error("forking into another agent's key should fail")
+	}
+}
@@ -0,0 +1,430 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/aperture"
+	"github.com/tinyland-inc/tinyclaw/pkg/bus"
+	"github.com/tinyland-inc/tinyclaw/pkg/logger"
+	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+	"github.com/tinyland-inc/tinyclaw/pkg/session"
+	"github.com/tinyland-inc/tinyclaw/pkg/utils"
+)
+
+const (
+	// compactKeepMessages is how many recent messages summarization at
+	// least keeps verbatim.
+	compactKeepMessages = 4
+
+	// Transcript limits, in runes, for one message and one tool result.
+	compactMessageRunes    = 2000
+	compactToolResultRunes = 500
+
+	// forceFactRunes and forceMaxFacts bound the tool facts emergency
+	// compression keeps without a model.
+	forceFactRunes = 200
+	forceMaxFacts  = 20
+)
+
+const compactionInstructions = `Update the running summary of a conversation with the conversation segment below.
+Reply with exactly these markdown sections:
+
+## Summary
+What was discussed and done, concisely.
+
+## Facts from tools
+Concrete facts learned from tool calls and results (paths, IDs, names, values, errors), one per line. Keep identifiers exact.
+
+## Open tasks
+Tasks the user asked for that are not finished yet, one per line. Remove tasks the segment completes.
+
+## Decisions
+Decisions and preferences agreed with the user, one per line.
+
+Write "- none" under an empty section. Keep everything from the running summary that is still true.`
+
+// summarizeSession replaces the older turns of a session with a structured
+// summary, keeping the last turns and pinned messages verbatim. Tool calls
+// are condensed with their results into facts, and the summary carries the
+// open tasks and decisions forward. The session is left unchanged when the
+// model fails.
+func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+	defer cancel()
+	ctx = aperture.WithAttribution(ctx, aperture.Attribution{AgentID: agent.ID, SessionKey: sessionKey})
+	ctx = providers.WithSessionKey(ctx, sessionKey)
+
+	snap, ok := agent.Sessions.Snapshot(sessionKey)
+	if !ok {
+		return
+	}
+	cut := compactionCut(snap.Messages)
+	if cut <= 0 {
+		return
+	}
+	keep := snap.PinnedKept(0, cut-1)
+
+	var older []providers.Message
+	for i, m := range snap.Messages[:cut] {
+		if !keep[i] {
+			older = append(older, m)
+		}
+	}
+	if len(older) == 0 {
+		return
+	}
+
+	// Summarize in batches of whole turns, each batch updating the summary
+	// the previous one produced.
+	batchChars := agent.ContextWindow
+	if batchChars <= 0 {
+		batchChars = 32000
+	}
+	summary := snap.Summary
+	for _, batch := range transcriptBatches(older, batchChars) {
+		next, err := al.summarizeBatch(ctx, agent, batch, summary)
+		if err != nil || strings.TrimSpace(next) == "" {
+			logger.WarnCF("agent", "Session summarization failed", map[string]any{
+				"session_key": sessionKey,
+				"error":       fmt.Sprint(err),
+			})
+			return
+		}
+		summary = strings.TrimSpace(next)
+	}
+
+	removed, err := agent.Sessions.Compact(sessionKey, snap.MessageIDs[0], snap.MessageIDs[cut-1], summary)
+	if err != nil {
+		// The conversation moved on in a way that invalidates the range,
+		// e.g. a rewind; the next threshold crossing tries again.
+		logger.WarnCF("agent", "Session compaction skipped", map[string]any{
+			"session_key": sessionKey,
+			"error":       err.Error(),
+		})
+		return
+	}
+	agent.Sessions.Save(sessionKey)
+
+	logger.InfoCF("agent", "Session summarized", map[string]any{
+		"session_key": sessionKey,
+		"removed":     removed,
+		"pinned_kept": len(keep),
+	})
+}
+
+// compactionCut returns the turn start before which summarization may
+// replace history: the last one leaving at least compactKeepMessages
+// messages. It is 0 when there is nothing to summarize.
+func compactionCut(history []providers.Message) int {
+	cut := 0
+	for _, i := range session.TurnStarts(history) {
+		if i <= len(history)-compactKeepMessages {
+			cut = i
+		}
+	}
+	return cut
+}
+
+// summarizeBatch folds a transcript segment into the running summary.
+func (al *AgentLoop) summarizeBatch(
+	ctx context.Context,
+	agent *AgentInstance,
+	transcript string,
+	existingSummary string,
+) (string, error) {
+	var sb strings.Builder
+	sb.WriteString(compactionInstructions)
+	sb.WriteString("\n\nRUNNING SUMMARY:\n")
+	if existingSummary == "" {
+		sb.WriteString("(empty)")
+	} else {
+		sb.WriteString(existingSummary)
+	}
+	sb.WriteString("\n\nCONVERSATION SEGMENT:\n")
+	sb.WriteString(transcript)
+
+	response, err := agent.Provider.Chat(
+		ctx,
+		[]providers.Message{{Role: "user", Content: sb.String()}},
+		nil,
+		agent.Model,
+		map[string]any{
+			"max_tokens":       1024,
+			"temperature":      0.3,
+			"prompt_cache_key": agent.ID,
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+	return response.Content, nil
+}
+
+// transcriptBatches renders msgs as a summarization transcript split into
+// batches of about maxChars. Batches only break between turns, so a tool
+// call always travels with its result.
+func transcriptBatches(msgs []providers.Message, maxChars int) []string {
+	var (
+		batches []string
+		batch   strings.Builder
+		turn    strings.Builder
+	)
+	toolNames := make(map[string]string)
+	flushTurn := func() {
+		if batch.Len() > 0 && batch.Len()+turn.Len() > maxChars {
+			batches = append(batches, batch.String())
+			batch.Reset()
+		}
+		batch.WriteString(turn.String())
+		turn.Reset()
+	}
+
+	for _, m := range msgs {
+		if m.Role == "user" && turn.Len() > 0 {
+			flushTurn()
+		}
+		switch m.Role {
+		case "user":
+			fmt.Fprintf(&turn, "User: %s\n", utils.Truncate(m.Content, compactMessageRunes))
+		case "assistant":
+			if strings.TrimSpace(m.Content) != "" {
+				fmt.Fprintf(&turn, "Assistant: %s\n", utils.Truncate(m.Content, compactMessageRunes))
+			}
+			for _, tc := range m.ToolCalls {
+				name, args := toolCallText(tc)
+				toolNames[tc.ID] = name
+				fmt.Fprintf(&turn, "Assistant called %s(%s)\n", name, utils.Truncate(args, compactToolResultRunes))
+			}
+		case "tool":
+			name := toolNames[m.ToolCallID]
+			if name == "" {
+				name = "tool"
+			}
+			fmt.Fprintf(&turn, "-> %s result: %s\n", name, utils.Truncate(m.Content, compactToolResultRunes))
+		default:
+			fmt.Fprintf(&turn, "%s: %s\n", m.Role, utils.Truncate(m.Content, compactMessageRunes))
+		}
+	}
+	if turn.Len() > 0 {
+		flushTurn()
+	}
+	if batch.Len() > 0 {
+		batches = append(batches, batch.String())
+	}
+	return batches
+}
+
+// toolCallText returns the name and JSON arguments of a tool call as
+// stored in session history.
+func toolCallText(tc providers.ToolCall) (string, string) {
+	name, args := tc.Name, ""
+	if tc.Function != nil {
+		if name == "" {
+			name = tc.Function.Name
+		}
+		args = tc.Function.Arguments
+	}
+	if args == "" && len(tc.Arguments) > 0 {
+		if data, err := json.Marshal(tc.Arguments); err == nil {
+			args = string(data)
+		}
+	}
+	return name, args
+}
+
+// forceCompression aggressively reduces context when the limit is hit,
+// without calling the model. It drops the older half of the history on a
+// turn boundary, keeping pinned messages, and records a note and the
+// dropped tool results as facts in the session summary.
+func (al *AgentLoop) forceCompression(agent *AgentInstance, sessionKey string) {
+	snap, ok := agent.Sessions.Snapshot(sessionKey)
+	if !ok || len(snap.Messages) <= compactKeepMessages {
+		return
+	}
+	history := snap.Messages
+
+	// Prefer the turn start closest to the middle. A single long turn keeps
+	// its user request and drops tool rounds up to an assistant message.
+	mid := len(history) / 2
+	cut := 0
+	for _, i := range session.TurnStarts(history) {
+		if i > 0 && (cut == 0 || abs(i-mid) < abs(cut-mid)) {
+			cut = i
+		}
+	}
+	from, through := 0, cut-1
+	if cut == 0 {
+		for j := mid; j < len(history); j++ {
+			if history[j].Role == "assistant" {
+				from, through = 1, j-1
+				break
+			}
+		}
+	}
+	if through < from {
+		return
+	}
+
+	keep := snap.PinnedKept(from, through)
+	var facts []string
+	for i := from; i <= through; i++ {
+		if !keep[i] {
+			facts = append(facts, toolFacts(history, i)...)
+		}
+	}
+	if len(facts) > forceMaxFacts {
+		facts = facts[len(facts)-forceMaxFacts:]
+	}
+
+	dropped := through - from + 1 - len(keep)
+	var sb strings.Builder
+	if snap.Summary != "" {
+		sb.WriteString(snap.Summary)
+		sb.WriteString("\n\n")
+	}
+	fmt.Fprintf(&sb, "[Emergency compression dropped %d older messages due to the context limit]", dropped)
+	if len(facts) > 0 {
+		sb.WriteString("\nFacts from dropped tool results:\n")
+		sb.WriteString(strings.Join(facts, "\n"))
+	}
+
+	removed, err := agent.Sessions.Compact(sessionKey, snap.MessageIDs[from], snap.MessageIDs[through], sb.String())
+	if err != nil {
+		logger.ErrorCF("agent", "Forced compression failed", map[string]any{
+			"session_key": sessionKey,
+			"error":       err.Error(),
+		})
+		return
+	}
+	agent.Sessions.Save(sessionKey)
+
+	logger.WarnCF("agent", "Forced compression executed", map[string]any{
+		"session_key":  sessionKey,
+		"dropped_msgs": removed,
+		"new_count":    len(history) - removed,
+	})
+}
+
+// toolFacts condenses the tool results answering the tool calls of
+// history[i] into one line each.
+func toolFacts(history []providers.Message, i int) []string {
+	var facts []string
+	for _, tc := range history[i].ToolCalls {
+		name, args := toolCallText(tc)
+		for _, m := range history[i+1:] {
+			if m.Role != "tool" {
+				break
+			}
+			if m.ToolCallID == tc.ID {
+				facts = append(facts, fmt.Sprintf("- %s(%s) -> %s",
+					name, utils.Truncate(args, forceFactRunes), utils.Truncate(oneLine(m.Content), forceFactRunes)))
+				break
+			}
+		}
+	}
+	return facts
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// PinMessage keeps a message of the session verbatim through compaction.
+func (al *AgentLoop) PinMessage(agentID, key, messageID string) error {
+	_, err := al.editSession(agentID, key, func(sm *session.SessionManager) (int, error) {
+		return 0, sm.Pin(key, messageID)
+	})
+	return err
+}
+
+// UnpinMessage lets compaction summarize a pinned message again.
+func (al *AgentLoop) UnpinMessage(agentID, key, messageID string) error {
+	_, err := al.editSession(agentID, key, func(sm *session.SessionManager) (int, error) {
+		return 0, sm.Unpin(key, messageID)
+	})
+	return err
+}
+
+// pinCommand handles the /pin, /unpin and /pins chat commands on the
+// sender's own session.
+func (al *AgentLoop) pinCommand(msg bus.InboundMessage, cmd string, args []string) string {
+	agent, key, _ := al.resolveRoute(msg)
+	if agent == nil || !agent.Sessions.Has(key) {
+		return "No conversation to work with yet"
+	}
+	snap, _ := agent.Sessions.Snapshot(key)
+
+	switch cmd {
+	case "/pin":
+		ids := args
+		if len(ids) == 0 {
+			ids = lastExchange(snap)
+			if len(ids) == 0 {
+				return "Nothing to pin yet"
+			}
+		}
+		for _, id := range ids {
+			if err := al.PinMessage(agent.ID, key, id); err != nil {
+				return "Pin failed: " + err.Error()
+			}
+		}
+		return fmt.Sprintf("Pinned message(s) %s; they are kept verbatim when the conversation is compacted.",
+			strings.Join(ids, ", "))
+
+	case "/unpin":
+		if len(args) == 0 {
+			return "Usage: /unpin <message-id>..."
+		}
+		for _, id := range args {
+			if err := al.UnpinMessage(agent.ID, key, id); err != nil {
+				return "Unpin failed: " + err.Error()
+			}
+		}
+		return "Unpinned message(s) " + strings.Join(args, ", ")
+
+	case "/pins":
+		if len(snap.Pinned) == 0 {
+			return "No pinned messages"
+		}
+		var sb strings.Builder
+		sb.WriteString("Pinned messages (unpin with /unpin <id>):\n")
+		for i, id := range snap.MessageIDs {
+			if snap.IsPinned(id) {
+				m := snap.Messages[i]
+				fmt.Fprintf(&sb, "%s %s: %s\n", id, m.Role, utils.Truncate(oneLine(m.Content), 80))
+			}
+		}
+		return strings.TrimRight(sb.String(), "\n")
+	}
+	return ""
+}
+
+// lastExchange returns the IDs of the latest user message and the last
+// assistant reply after it.
+func lastExchange(s session.Session) []string {
+	for i := len(s.Messages) - 1; i >= 0; i-- {
+		if s.Messages[i].Role != "user" {
+			continue
+		}
+		ids := []string{s.MessageIDs[i]}
+		for j := len(s.Messages) - 1; j > i; j-- {
+			if s.Messages[j].Role == "assistant" && len(s.Messages[j].ToolCalls) == 0 {
+				ids = append(ids, s.MessageIDs[j])
+				break
+			}
+		}
+		return ids
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/bus"
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+)
+
+// summaryProvider records the prompts it receives and replies with a fixed
+// structured summary.
+type summaryProvider struct {
+	prompts []string
+}
+
+func (p *summaryProvider) Chat(
+	_ context.Context,
+	messages []providers.Message,
+	_ []providers.ToolDefinition,
+	_ string,
+	_ map[string]any,
+) (*providers.LLMResponse, error) {
+	p.prompts = append(p.prompts, messages[len(messages)-1].Content)
+	return &providers.LLMResponse{
+		Content: "## Summary\nDeployed build 42.\n\n## Facts from tools\n- build 42 is live\n\n" +
+			"## Open tasks\n- update the changelog\n\n## Decisions\n- answer in French",
+	}, nil
+}
+
+func (p *summaryProvider) GetDefaultModel() string { return "gpt-4o" }
+
+func newCompactionLoop(t *testing.T, p providers.LLMProvider) (*AgentLoop, *AgentInstance) {
+	t.Helper()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "gpt-4o",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), p)
+	return al, al.registry.GetDefaultAgent()
+}
+
+// addToolTurn adds a user request answered through one exec call.
+func addToolTurn(agent *AgentInstance, key, request, result string) {
+	agent.Sessions.AddMessage(key, "user", request)
+	agent.Sessions.AddFullMessage(key, providers.Message{
+		Role: "assistant",
+		ToolCalls: []providers.ToolCall{{
+			ID:       "call-" + request,
+			Name:     "exec",
+			Function: &providers.FunctionCall{Name: "exec", Arguments: `{"command":"deploy"}`},
+		}},
+	})
+	agent.Sessions.AddFullMessage(key, providers.Message{Role: "tool", Content: result, ToolCallID: "call-" + request})
+	agent.Sessions.AddMessage(key, "assistant", "done: "+request)
+}
+
+func TestSummarizeSession_KeepsPinsAndToolFacts(t *testing.T) {
+	p := &summaryProvider{}
+	al, agent := newCompactionLoop(t, p)
+	const key = "agent:main:compact"
+
+	agent.Sessions.AddMessage(key, "user", "always answer in French") // 1
+	agent.Sessions.AddMessage(key, "assistant", "d'accord")           // 2
+	addToolTurn(agent, key, "deploy", "build 42 is live")             // 3-6
+	addToolTurn(agent, key, "status", "all green")                    // 7-10
+	if err := al.PinMessage("", key, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	al.summarizeSession(agent, key)
+
+	if len(p.prompts) != 1 {
+		t.Fatalf("summarizer called %d times", len(p.prompts))
+	}
+	prompt := p.prompts[0]
+	for _, want := range []string{"## Open tasks", `Assistant called exec({"command":"deploy"})`, "-> exec result: build 42 is live"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt misses %q:\n%s", want, prompt)
+		}
+	}
+	if strings.Contains(prompt, "always answer in French") || strings.Contains(prompt, "all green") {
+		t.Errorf("prompt includes pinned or recent messages:\n%s", prompt)
+	}
+
+	history := agent.Sessions.GetHistory(key)
+	if len(history) != 5 || history[0].Content != "always answer in French" || history[1].Content != "status" {
+		t.Errorf("history after summarization = %+v", history)
+	}
+	if summary := agent.Sessions.GetSummary(key); !strings.Contains(summary, "- update the changelog") {
+		t.Errorf("summary = %q", summary)
+	}
+}
+
+func TestForceCompression_SplitsOnTurnsAndKeepsToolFacts(t *testing.T) {
+	al, agent := newCompactionLoop(t, &summaryProvider{})
+	const key = "agent:main:force"
+
+	addToolTurn(agent, key, "deploy", "build 42 is live") // 1-4
+	addToolTurn(agent, key, "status", "all green")        // 5-8
+	agent.Sessions.AddMessage(key, "user", "and now?")    // 9
+
+	al.forceCompression(agent, key)
+
+	history := agent.Sessions.GetHistory(key)
+	if len(history) != 5 || history[0].Role != "user" || history[0].Content != "status" {
+		t.Fatalf("history after compression = %+v", history)
+	}
+	summary := agent.Sessions.GetSummary(key)
+	if !strings.Contains(summary, "dropped 4 older messages") ||
+		!strings.Contains(summary, `- exec({"command":"deploy"}) -> build 42 is live`) {
+		t.Errorf("summary = %q", summary)
+	}
+}
+
+func TestForceCompression_SingleTurnKeepsRequest(t *testing.T) {
+	al, agent := newCompactionLoop(t, &summaryProvider{})
+	const key = "agent:main:single"
+
+	addToolTurn(agent, key, "deploy", "build 42 is live")
+	for range 2 {
+		agent.Sessions.AddFullMessage(key, providers.Message{
+			Role:      "assistant",
+			ToolCalls: []providers.ToolCall{{ID: "again", Name: "exec"}},
+		})
+		agent.Sessions.AddFullMessage(key, providers.Message{Role: "tool", Content: "ok", ToolCallID: "again"})
+	}
+
+	al.forceCompression(agent, key)
+
+	history := agent.Sessions.GetHistory(key)
+	if len(history) >= 8 || history[0].Content != "deploy" || history[1].Role != "assistant" {
+		t.Errorf("history after compression = %+v", history)
+	}
+}
+
+func TestPinCommands(t *testing.T) {
+	al, _ := newBudgetLoop(t)
+	ctx := context.Background()
+	const key = "agent:main:pins"
+
+	if _, err := al.ProcessDirect(ctx, "remember the launch code", key); err != nil {
+		t.Fatal(err)
+	}
+	reply, _ := al.ProcessDirect(ctx, "/pin", key)
+	if !strings.Contains(reply, "Pinned message(s) 1, 2") {
+		t.Fatalf("/pin = %q", reply)
+	}
+	reply, _ = al.ProcessDirect(ctx, "/pins", key)
+	if !strings.Contains(reply, "1 user: remember the launch code") {
+		t.Errorf("/pins = %q", reply)
+	}
+	if reply, _ = al.ProcessDirect(ctx, "/unpin 2", key); reply != "Unpinned message(s) 2" {
+		t.Errorf("/unpin = %q", reply)
+	}
+	if reply, _ = al.ProcessDirect(ctx, "/unpin 2", key); !strings.Contains(reply, "Unpin failed") {
+		t.Errorf("second /unpin = %q", reply)
+	}
+	if reply, _ = al.ProcessDirect(ctx, "/pin 99", key); !strings.Contains(reply, "Pin failed") {
+		t.Errorf("/pin of an unknown message = %q", reply)
+	}
+}
@@ -0,0 +1,154 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/aperture"
+	"github.com/tinyland-inc/tinyclaw/pkg/logger"
+	"github.com/tinyland-inc/tinyclaw/pkg/memory"
+	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+)
+
+const (
+	defaultConsolidationIntervalHours = 24
+	defaultConsolidationMinAgeDays    = 7
+
+	// consolidationSessionKey attributes consolidation LLM usage.
+	consolidationSessionKey = "memory:consolidation"
+)
+
+// ConsolidateMemory distills the agent's daily notes older than the
+// configured age into MEMORY.md with the consolidation model. With dryRun
+// nothing is changed and the report carries the diff that would be applied.
+func (al *AgentLoop) ConsolidateMemory(ctx context.Context, agentID string, dryRun bool) (*memory.ConsolidationReport, error) {
+	agent, ok := al.registry.GetAgent(agentID)
+	if !ok {
+		return nil, fmt.Errorf("agent %q not found", agentID)
+	}
+	store := agent.ContextBuilder.memory.Index()
+	if store == nil {
+		return nil, errors.New("memory store is not available")
+	}
+
+	cc := al.cfg.Memory.Consolidation
+	minAge := cc.MinAgeDays
+	if minAge <= 0 {
+		minAge = defaultConsolidationMinAgeDays
+	}
+
+	opts := processOptions{SessionKey: consolidationSessionKey}
+	ctx = aperture.WithAttribution(ctx, aperture.Attribution{AgentID: agent.ID, SessionKey: consolidationSessionKey})
+	ctx = providers.WithSessionKey(ctx, consolidationSessionKey)
+
+	summarize := func(ctx context.Context, prompt string) (string, error) {
+		model := cc.Model
+		if model == "" {
+			model = agent.Model
+		}
+		budgetModel, err := al.checkBudget(agent, opts)
+		if err != nil {
+			return "", err
+		}
+		if budgetModel != "" {
+			model = budgetModel
+		}
+		resp, err := agent.Provider.Chat(
+			ctx,
+			[]providers.Message{{Role: "user", Content: prompt}},
+			nil,
+			model,
+			map[string]any{
+				"max_tokens":  2048,
+				"temperature": 0.2,
+			},
+		)
+		if err != nil {
+			return "", err
+		}
+		al.recordCost(ctx, agent.ID, opts, primaryProvider(agent), model, resp)
+		return resp.Content, nil
+	}
+
+	return store.Consolidate(ctx, summarize, memory.ConsolidateOptions{
+		Before: time.Now().AddDate(0, 0, -minAge),
+		DryRun: dryRun,
+	})
+}
+
+// RunMemoryConsolidation consolidates every agent's memory on the
+// configured interval until ctx is done. It returns at once when
+// consolidation is disabled.
+func (al *AgentLoop) RunMemoryConsolidation(ctx context.Context) {
+	cc := al.cfg.Memory.Consolidation
+	if !cc.Enabled {
+		return
+	}
+	interval := time.Duration(cc.IntervalHours) * time.Hour
+	if interval <= 0 {
+		interval = defaultConsolidationIntervalHours * time.Hour
+	}
+
+	logger.InfoCF("memory", "Memory consolidation scheduled", map[string]any{
+		"interval_hours": interval.Hours(),
+		"dry_run":        cc.DryRun,
+	})
+
+	// First run shortly after startup, so a gateway restarted more often
+	// than the interval still consolidates.
+	timer := time.NewTimer(time.Minute)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			al.consolidateAll(ctx, cc.DryRun)
+			timer.Reset(interval)
+		}
+	}
+}
+
+// consolidateAll runs one consolidation per memory directory; agents
+// sharing a workspace are consolidated once.
+func (al *AgentLoop) consolidateAll(ctx context.Context, dryRun bool) {
+	done := make(map[*memory.Store]bool)
+	for _, id := range al.registry.ListAgentIDs() {
+		agent, ok := al.registry.GetAgent(id)
+		if !ok {
+			continue
+		}
+		store := agent.ContextBuilder.memory.Index()
+		if store == nil || done[store] {
+			continue
+		}
+		done[store] = true
+
+		report, err := al.ConsolidateMemory(ctx, id, dryRun)
+		if err != nil {
+			logger.ErrorCF("memory", "Memory consolidation failed", map[string]any{
+				"agent_id": id,
+				"error":    err.Error(),
+			})
+			continue
+		}
+		if len(report.Notes) == 0 {
+			continue
+		}
+		fields := map[string]any{
+			"agent_id":       id,
+			"notes":          len(report.Notes),
+			"facts":          len(report.Facts),
+			"duplicates":     len(report.Duplicates),
+			"contradictions": len(report.Contradictions),
+		}
+		if dryRun {
+			fields["diff"] = report.Diff
+			logger.InfoCF("memory", "Memory consolidation dry run", fields)
+			continue
+		}
+		logger.InfoCF("memory", "Memory consolidated", fields)
+	}
+}
@@ -0,0 +1,81 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/bus"
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+)
+
+// consolidationProvider replies with a fixed consolidation and remembers
+// the model it was asked for.
+type consolidationProvider struct {
+	model string
+}
+
+func (p *consolidationProvider) Chat(
+	_ context.Context,
+	_ []providers.Message,
+	_ []providers.ToolDefinition,
+	model string,
+	_ map[string]any,
+) (*providers.LLMResponse, error) {
+	p.model = model
+	return &providers.LLMResponse{Content: `{"facts": ["The user's dentist is Dr. Ortiz."]}`}, nil
+}
+
+func (p *consolidationProvider) GetDefaultModel() string { return "gpt-4o" }
+
+func TestConsolidateMemory(t *testing.T) {
+	workspace := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{Workspace: workspace, Model: "gpt-4o", MaxTokens: 4096},
+		},
+		Memory: config.MemoryConfig{
+			Consolidation: config.MemoryConsolidationConfig{Enabled: true, Model: "gpt-4o-mini", MinAgeDays: 3},
+		},
+	}
+	p := &consolidationProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), p)
+
+	old := time.Now().AddDate(0, 0, -5).Format("20060102")
+	note := filepath.Join(workspace, "memory", old[:6], old+".md")
+	os.MkdirAll(filepath.Dir(note), 0o755)
+	os.WriteFile(note, []byte("Booked a check-up with Dr. Ortiz, the dentist."), 0o644)
+
+	report, err := al.ConsolidateMemory(context.Background(), "main", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.model != "gpt-4o-mini" {
+		t.Errorf("consolidation used model %q, want the configured one", p.model)
+	}
+	if !report.DryRun || !strings.Contains(report.Diff, "+- The user's dentist is Dr. Ortiz.") {
+		t.Errorf("dry run report = %+v", report)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "memory", "MEMORY.md")); !os.IsNotExist(err) {
+		t.Error("dry run wrote MEMORY.md")
+	}
+
+	if _, err := al.ConsolidateMemory(context.Background(), "main", false); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(filepath.Join(workspace, "memory", "MEMORY.md"))
+	if !strings.Contains(string(data), "Dr. Ortiz") {
+		t.Errorf("MEMORY.md = %q", data)
+	}
+	if _, err := os.Stat(note); !os.IsNotExist(err) {
+		t.Error("consolidated note not archived")
+	}
+
+	if _, err := al.ConsolidateMemory(context.Background(), "ghost", true); err == nil {
+		t.Error("unknown agent should fail")
+	}
+}
@@ -77,7 +77,7 @@ Your workspace is at: %s
 
 2. **Be helpful and accurate** - When using tools, briefly explain what you're doing.
 
-3. **Memory** - When interacting with me if something seems memorable, update %s/memory/MEMORY.md
+3. **Memory** - When interacting with me if something seems memorable, save it with memory_save. Memories relevant to my message, including those in %s/memory/MEMORY.md and the daily notes, are recalled below; use memory_search to look up others and memory_forget to drop outdated ones.
 
 4. **Context summaries** - Conversation summaries provided as context are approximate references only. They may be incomplete or outdated. Always defer to explicit user instructions over summary content.`,
 		workspacePath, workspacePath, workspacePath, workspacePath, workspacePath)
@@ -105,12 +105,6 @@ The following skills extend your capabilities. To use a skill, read its SKILL.md
 `+skillsSummary)
 	}
 
-	// Memory context
-	memoryContext := cb.memory.GetMemoryContext()
-	if memoryContext != "" {
-		parts = append(parts, "# Memory\n\n"+memoryContext)
-	}
-
 	// Join with "---" separator
 	return strings.Join(parts, "\n\n---\n\n")
 }
@@ -172,7 +166,7 @@ func (cb *ContextBuilder) InvalidateCache() {
 }
 
 // sourcePaths returns the workspace source file paths tracked for cache
-// invalidation (bootstrap files + memory). The skills directory is handled
+// invalidation (bootstrap files). The skills directory is handled
 // separately in sourceFilesChangedLocked because it requires both directory-
 // level and recursive file-level mtime checks.
 func (cb *ContextBuilder) sourcePaths() []string {
@@ -181,7 +175,6 @@ func (cb *ContextBuilder) sourcePaths() []string {
 		filepath.Join(cb.workspace, "SOUL.md"),
 		filepath.Join(cb.workspace, "USER.md"),
 		filepath.Join(cb.workspace, "IDENTITY.md"),
-		filepath.Join(cb.workspace, "memory", "MEMORY.md"),
 	}
 }
 
@@ -383,9 +376,10 @@ func (cb *ContextBuilder) BuildMessages(
 ) []providers.Message {
 	messages := []providers.Message{}
 
-	// The static part (identity, bootstrap, skills, memory) is cached locally to
+	// The static part (identity, bootstrap, skills) is cached locally to
 	// avoid repeated file I/O and string building on every call (fixes issue #607).
-	// Dynamic parts (time, session, summary) are appended per request.
+	// Dynamic parts (time, session, recalled memories, summary) are appended
+	// per request.
 	// Everything is sent as a single system message for provider compatibility:
 	// - Anthropic adapter extracts messages[0] (Role=="system") and maps its content
 	//   to the top-level "system" parameter in the Messages API request. A single
@@ -413,6 +407,14 @@ func (cb *ContextBuilder) BuildMessages(
 		{Type: "text", Text: dynamicCtx},
 	}
 
+	// Memories relevant to the current message, rather than all of them.
+	memoryCtx := cb.memory.GetMemoryContext(currentMessage)
+	if memoryCtx != "" {
+		memoryText := "# Memory\n\n" + memoryCtx
+		stringParts = append(stringParts, memoryText)
+		contentBlocks = append(contentBlocks, providers.ContentBlock{Type: "text", Text: memoryText})
+	}
+
 	if summary != "" {
 		summaryText := fmt.Sprintf(
 			"CONTEXT_SUMMARY: The following is an approximate summary of prior conversation "+
@@ -436,6 +438,7 @@ func (cb *ContextBuilder) BuildMessages(
 			"static_chars":  len(staticPrompt),
 			"dynamic_chars": len(dynamicCtx),
 			"total_chars":   len(fullSystemPrompt),
+			"memory_chars":  len(memoryCtx),
 			"has_summary":   summary != "",
 			"cached":        isCached,
 		})
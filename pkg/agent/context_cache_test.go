@@ -122,8 +122,8 @@ func TestSingleSystemMessage(t *testing.T) {
 
 // TestMtimeAutoInvalidation verifies that the cache detects source file changes
 // via mtime without requiring explicit InvalidateCache().
-// Fix: original implementation had no auto-invalidation — edits to bootstrap files,
-// memory, or skills were invisible until process restart.
+// Fix: original implementation had no auto-invalidation — edits to bootstrap files
+// or skills were invisible until process restart.
 func TestMtimeAutoInvalidation(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -139,13 +139,6 @@ func TestMtimeAutoInvalidation(t *testing.T) {
 			contentV2:  "# Updated Identity",
 			checkField: "Updated Identity",
 		},
-		{
-			name:       "memory file change",
-			file:       "memory/MEMORY.md",
-			contentV1:  "# Memory\nUser likes Go.",
-			contentV2:  "# Memory\nUser likes Rust.",
-			checkField: "User likes Rust",
-		},
 	}
 
 	for _, tt := range tests {
@@ -275,12 +268,6 @@ func TestNewFileCreationInvalidatesCache(t *testing.T) {
 			content:    "# Soul\nBe kind and helpful.",
 			checkField: "Be kind and helpful",
 		},
-		{
-			name:       "new memory file",
-			file:       "memory/MEMORY.md",
-			content:    "# Memory\nUser prefers dark mode.",
-			checkField: "User prefers dark mode",
-		},
 	}
 
 	for _, tt := range tests {
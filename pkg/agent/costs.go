@@ -0,0 +1,205 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/billing"
+	"github.com/tinyland-inc/tinyclaw/pkg/bus"
+	"github.com/tinyland-inc/tinyclaw/pkg/constants"
+	"github.com/tinyland-inc/tinyclaw/pkg/logger"
+	"github.com/tinyland-inc/tinyclaw/pkg/metrics"
+	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+)
+
+// recordCost prices a successful call, reports it to the task hooks on ctx
+// and adds it to the ledger.
+func (al *AgentLoop) recordCost(
+	ctx context.Context,
+	agentID string,
+	opts processOptions,
+	provider, model string,
+	resp *providers.LLMResponse,
+) {
+	if resp == nil || resp.Usage == nil {
+		return
+	}
+
+	u := resp.Usage
+	var cost float64
+	if price, ok := al.prices.Lookup(model); ok {
+		cost = price.Cost(u.PromptTokens, u.CompletionTokens, u.CachedTokens)
+		metrics.LLMCost.Add(cost, provider, model, agentID)
+	} else {
+		logger.DebugCF("billing", "No price for model, recording tokens only", map[string]any{"model": model})
+	}
+	taskHooksFrom(ctx).usage(model, resp, cost)
+	if al.ledger == nil {
+		return
+	}
+
+	err := al.ledger.Record(billing.Entry{
+		Time:         time.Now(),
+		AgentID:      agentID,
+		SessionKey:   opts.SessionKey,
+		SenderID:     opts.SenderID,
+		Model:        model,
+		InputTokens:  u.PromptTokens,
+		OutputTokens: u.CompletionTokens,
+		CachedTokens: u.CachedTokens,
+		CostUSD:      cost,
+	})
+	if err != nil {
+		logger.WarnCF("billing", "Failed to record usage", map[string]any{"error": err.Error()})
+	}
+}
+
+// checkBudget runs the budget check before an LLM call. It returns the
+// model to use instead of the agent's own (empty for no change) or an error
+// wrapping billing.ErrBudgetExceeded. Soft-limit warnings are logged and
+// sent once to the chat.
+func (al *AgentLoop) checkBudget(agent *AgentInstance, opts processOptions) (string, error) {
+	if al.budget == nil {
+		return "", nil
+	}
+
+	d := al.budget.Check(billing.Subject{
+		AgentID:    agent.ID,
+		SessionKey: opts.SessionKey,
+		SenderID:   opts.SenderID,
+	})
+	for _, w := range d.Warnings {
+		logger.WarnCF("billing", "Budget warning", map[string]any{"agent_id": agent.ID, "warning": w})
+		if opts.Channel != "" && opts.ChatID != "" && !constants.IsInternalChannel(opts.Channel) {
+			al.bus.PublishOutbound(bus.OutboundMessage{
+				Channel: opts.Channel,
+				ChatID:  opts.ChatID,
+				Content: "⚠️ Budget warning: " + w,
+			})
+		}
+	}
+	if d.Err != nil {
+		logger.WarnCF("billing", "LLM call refused by budget", map[string]any{
+			"agent_id": agent.ID,
+			"error":    d.Err.Error(),
+		})
+		return "", d.Err
+	}
+	if d.Model != "" {
+		logger.InfoCF("billing", "Budget reached, downgrading model", map[string]any{
+			"agent_id": agent.ID,
+			"model":    d.Model,
+		})
+	}
+	return d.Model, nil
+}
+
+// downgradeTarget is the provider a budget downgrade switches to.
+type downgradeTarget struct {
+	provider providers.LLMProvider
+	protocol string
+	modelID  string
+}
+
+// downgradeTo resolves a budget's downgrade model through model_list to its
+// own provider and model ID. Providers are created once and reused, and
+// share the main provider's rate limits.
+func (al *AgentLoop) downgradeTo(modelName string) (*downgradeTarget, error) {
+	al.downgradeMu.Lock()
+	defer al.downgradeMu.Unlock()
+	if t, ok := al.downgrades[modelName]; ok {
+		return t, nil
+	}
+	entries := al.cfg.ModelConfigs(modelName)
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("budget downgrade model %q is not in model_list", modelName)
+	}
+	provider, modelID, err := providers.CreateProviderWithRateLimits(al.cfg, modelName, al.rateLimits)
+	if err != nil {
+		return nil, fmt.Errorf("budget downgrade model %q: %w", modelName, err)
+	}
+	if b := providers.BalancerOf(provider); b != nil {
+		b.UseCooldownTracker(al.cooldown)
+	}
+	protocol, _ := providers.ExtractProtocol(entries[0].Model)
+	t := &downgradeTarget{provider: provider, protocol: protocol, modelID: modelID}
+	al.downgrades[modelName] = t
+	return t, nil
+}
+
+// usageReport renders the /usage command output.
+func (al *AgentLoop) usageReport(msg bus.InboundMessage, args []string) string {
+	if al.ledger == nil {
+		return "Usage tracking is not available"
+	}
+
+	periods := []billing.Period{billing.PeriodDay, billing.PeriodMonth}
+	if len(args) > 0 {
+		switch billing.Period(args[0]) {
+		case billing.PeriodDay, billing.PeriodMonth:
+			periods = []billing.Period{billing.Period(args[0])}
+		default:
+			return "Usage: /usage [day|month]"
+		}
+	}
+
+	now := time.Now()
+	var sb strings.Builder
+	for i, p := range periods {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		b := al.ledger.Breakdown(p, now)
+		title := "Today (" + now.Format("2006-01-02") + ")"
+		if p == billing.PeriodMonth {
+			title = "This month (" + now.Format("2006-01") + ")"
+		}
+		fmt.Fprintf(&sb, "%s: %s\n", title, formatTotals(b.Total))
+		if msg.SenderID != "" {
+			fmt.Fprintf(&sb, "  You: %s\n", formatTotals(b.Get(billing.ScopeSender, msg.SenderID)))
+		}
+		for _, id := range sortedKeys(b.Agents) {
+			fmt.Fprintf(&sb, "  Agent %s: %s\n", id, formatTotals(*b.Agents[id]))
+		}
+		for _, m := range sortedKeys(b.Models) {
+			fmt.Fprintf(&sb, "  Model %s: %s\n", m, formatTotals(*b.Models[m]))
+		}
+	}
+
+	if al.budget != nil {
+		agent := al.registry.GetDefaultAgent()
+		subject := billing.Subject{SenderID: msg.SenderID}
+		if agent != nil {
+			subject.AgentID = agent.ID
+		}
+		if statuses := al.budget.Status(subject); len(statuses) > 0 {
+			sb.WriteString("\nBudgets:\n")
+			for _, st := range statuses {
+				limit := st.Rule.HardUSD
+				kind := "hard"
+				if limit <= 0 {
+					limit, kind = st.Rule.SoftUSD, "soft"
+				}
+				fmt.Fprintf(&sb, "  %s: $%.2f of $%.2f (%s)\n", st.Label(), st.SpentUSD, limit, kind)
+			}
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func formatTotals(t billing.Totals) string {
+	return fmt.Sprintf("$%.4f over %d calls (%d in / %d out tokens)",
+		t.CostUSD, t.Calls, t.InputTokens, t.OutputTokens)
+}
+
+func sortedKeys(m map[string]*billing.Totals) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
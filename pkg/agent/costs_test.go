@@ -0,0 +1,140 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/billing"
+	"github.com/tinyland-inc/tinyclaw/pkg/bus"
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+)
+
+// usageProvider answers every call with one million prompt tokens and
+// remembers the models it was asked for.
+type usageProvider struct {
+	models []string
+}
+
+func (p *usageProvider) Chat(
+	_ context.Context,
+	_ []providers.Message,
+	_ []providers.ToolDefinition,
+	model string,
+	_ map[string]any,
+) (*providers.LLMResponse, error) {
+	p.models = append(p.models, model)
+	return &providers.LLMResponse{
+		Content: "ok",
+		Usage:   &providers.UsageInfo{PromptTokens: 1_000_000, TotalTokens: 1_000_000},
+	}, nil
+}
+
+func (p *usageProvider) GetDefaultModel() string { return "gpt-4o" }
+
+func newBudgetLoop(t *testing.T, rules ...config.BudgetRule) (*AgentLoop, *usageProvider) {
+	t.Helper()
+	return newBudgetLoopWithModels(t, nil, rules...)
+}
+
+func newBudgetLoopWithModels(t *testing.T, models []config.ModelConfig, rules ...config.BudgetRule) (*AgentLoop, *usageProvider) {
+	t.Helper()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "gpt-4o",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		ModelList: models,
+		Budgets:   config.BudgetsConfig{Enabled: true, Rules: rules},
+	}
+	p := &usageProvider{}
+	return NewAgentLoop(cfg, bus.NewMessageBus(), p), p
+}
+
+func TestBudget_HardLimitRefuses(t *testing.T) {
+	// gpt-4o input is $2.50 per million tokens, so each call costs $2.50.
+	al, _ := newBudgetLoop(t, config.BudgetRule{Scope: "agent", Period: "day", HardUSD: 4})
+
+	for i := range 2 {
+		if _, err := al.ProcessDirect(context.Background(), "hi", "budget-session"); err != nil {
+			t.Fatalf("call %d: %v", i+1, err)
+		}
+	}
+	_, err := al.ProcessDirect(context.Background(), "hi", "budget-session")
+	if !errors.Is(err, billing.ErrBudgetExceeded) {
+		t.Fatalf("third call: err = %v, want ErrBudgetExceeded", err)
+	}
+}
+
+func TestBudget_HardLimitDowngrades(t *testing.T) {
+	// The downgrade model has its own endpoint in model_list.
+	var downgraded []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		downgraded = append(downgraded, r.URL.Path+" "+req.Model)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`)
+	}))
+	defer srv.Close()
+
+	models := []config.ModelConfig{{ModelName: "cheap", Model: "openai/gpt-4o-mini", APIBase: srv.URL, APIKey: "k"}}
+	al, p := newBudgetLoopWithModels(t, models, config.BudgetRule{
+		Scope: "global", Period: "month", HardUSD: 2, Action: "downgrade", DowngradeModel: "cheap",
+	})
+
+	for range 2 {
+		if _, err := al.ProcessDirect(context.Background(), "hi", "budget-session"); err != nil {
+			t.Fatalf("ProcessDirect: %v", err)
+		}
+	}
+	if len(p.models) != 1 || p.models[0] != "gpt-4o" {
+		t.Errorf("agent provider models = %v, want [gpt-4o]", p.models)
+	}
+	if len(downgraded) != 1 || downgraded[0] != "/chat/completions gpt-4o-mini" {
+		t.Errorf("downgrade endpoint calls = %v", downgraded)
+	}
+}
+
+func TestBudget_UnknownDowngradeModelDisablesBudgets(t *testing.T) {
+	al, _ := newBudgetLoop(t, config.BudgetRule{
+		Scope: "global", Period: "month", HardUSD: 2, Action: "downgrade", DowngradeModel: "gpt-4o-mini",
+	})
+	if al.budget != nil {
+		t.Error("budget with a downgrade_model outside model_list was accepted")
+	}
+}
+
+func TestUsageCommand(t *testing.T) {
+	al, _ := newBudgetLoop(t, config.BudgetRule{Scope: "sender", Period: "day", SoftUSD: 10})
+
+	if _, err := al.ProcessDirect(context.Background(), "hi", "usage-session"); err != nil {
+		t.Fatalf("ProcessDirect: %v", err)
+	}
+
+	report, handled := al.handleCommand(context.Background(), bus.InboundMessage{
+		Channel:  "cli",
+		SenderID: "cron",
+		Content:  "/usage day",
+	})
+	if !handled {
+		t.Fatal("/usage not handled")
+	}
+	for _, want := range []string{"Today", "$2.5000 over 1 calls", "Agent main", "Model gpt-4o", "daily sender budget for cron: $2.50 of $10.00"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report missing %q:\n%s", want, report)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/tinyland-inc/tinyclaw/pkg/config"
+	"github.com/tinyland-inc/tinyclaw/pkg/logger"
 	"github.com/tinyland-inc/tinyclaw/pkg/providers"
 	"github.com/tinyland-inc/tinyclaw/pkg/routing"
 	"github.com/tinyland-inc/tinyclaw/pkg/session"
@@ -55,10 +56,17 @@ func NewAgentInstance(
 	toolsRegistry.Register(tools.NewEditFileTool(workspace, restrict))
 	toolsRegistry.Register(tools.NewAppendFileTool(workspace, restrict))
 
-	sessionsDir := filepath.Join(workspace, "sessions")
-	sessionsManager := session.NewSessionManager(sessionsDir)
+	sessionsManager := newSessionManager(cfg, filepath.Join(workspace, "sessions"))
 
 	contextBuilder := NewContextBuilder(workspace)
+	if cfg != nil {
+		contextBuilder.memory.Configure(cfg.Memory)
+	}
+	if store := contextBuilder.memory.Index(); store != nil {
+		toolsRegistry.Register(tools.NewMemorySaveTool(store))
+		toolsRegistry.Register(tools.NewMemorySearchTool(store, contextBuilder.memory.fuzzy))
+		toolsRegistry.Register(tools.NewMemoryForgetTool(store))
+	}
 
 	agentID := routing.DefaultAgentID
 	agentName := ""
@@ -114,6 +122,41 @@ func NewAgentInstance(
 	}
 }
 
+// newSessionManager opens the configured session store in dir. If the store
+// cannot be opened the agent keeps its sessions in memory only.
+func newSessionManager(cfg *config.Config, dir string) *session.SessionManager {
+	var backend string
+	if cfg != nil {
+		backend = cfg.Session.Store
+	}
+	store, err := session.OpenStore(backend, dir)
+	if err != nil {
+		logger.ErrorCF("agent", "Failed to open session store, sessions will not be saved", map[string]any{
+			"store": backend,
+			"path":  dir,
+			"error": err.Error(),
+		})
+		return session.NewSessionManagerWithStore(nil)
+	}
+	return session.NewSessionManagerWithStore(store)
+}
+
+// SessionDirs returns the sessions directory of every configured agent,
+// keyed by agent ID. Agents sharing a workspace share a directory.
+func SessionDirs(cfg *config.Config) map[string]string {
+	agentConfigs := cfg.Agents.List
+	if len(agentConfigs) == 0 {
+		agentConfigs = []config.AgentConfig{{ID: routing.DefaultAgentID, Default: true}}
+	}
+	dirs := make(map[string]string, len(agentConfigs))
+	for i := range agentConfigs {
+		ac := &agentConfigs[i]
+		workspace := resolveAgentWorkspace(ac, &cfg.Agents.Defaults)
+		dirs[routing.NormalizeAgentID(ac.ID)] = filepath.Join(workspace, "sessions")
+	}
+	return dirs
+}
+
 // resolveAgentWorkspace determines the workspace directory for an agent.
 func resolveAgentWorkspace(agentCfg *config.AgentConfig, defaults *config.AgentDefaults) string {
 	if agentCfg != nil && strings.TrimSpace(agentCfg.Workspace) != "" {
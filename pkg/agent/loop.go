@@ -10,12 +10,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"path/filepath"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 	"unicode/utf8"
 
+	"github.com/tinyland-inc/tinyclaw/pkg/aperture"
+	"github.com/tinyland-inc/tinyclaw/pkg/billing"
 	"github.com/tinyland-inc/tinyclaw/pkg/bus"
 	"github.com/tinyland-inc/tinyclaw/pkg/channels"
 	"github.com/tinyland-inc/tinyclaw/pkg/config"
@@ -23,9 +26,11 @@ import (
 	"github.com/tinyland-inc/tinyclaw/pkg/logger"
 	"github.com/tinyland-inc/tinyclaw/pkg/providers"
 	"github.com/tinyland-inc/tinyclaw/pkg/routing"
+	"github.com/tinyland-inc/tinyclaw/pkg/session"
 	"github.com/tinyland-inc/tinyclaw/pkg/skills"
 	"github.com/tinyland-inc/tinyclaw/pkg/state"
 	"github.com/tinyland-inc/tinyclaw/pkg/tools"
+	"github.com/tinyland-inc/tinyclaw/pkg/tracing"
 	"github.com/tinyland-inc/tinyclaw/pkg/utils"
 )
 
@@ -37,12 +42,21 @@ type AgentLoop struct {
 	running        atomic.Bool
 	summarizing    sync.Map
 	fallback       *providers.FallbackChain
+	cooldown       *providers.CooldownTracker
+	prices         *billing.PriceTable
+	ledger         *billing.Ledger
+	budget         *billing.Budget
+	rateLimits     *providers.RateLimiters
+	downgradeMu    sync.Mutex
+	downgrades     map[string]*downgradeTarget
+	search         *session.SearchIndex
 	channelManager *channels.Manager
 }
 
 // processOptions configures how a message is processed
 type processOptions struct {
 	SessionKey      string // Session identifier for history/context
+	SenderID        string // Sender the message came from, for usage accounting and tools
 	Channel         string // Target channel for tool execution
 	ChatID          string // Target chat ID for tool execution
 	UserMessage     string // User message content (may include prefix)
@@ -58,25 +72,53 @@ func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers
 	// Register shared tools to all agents
 	registerSharedTools(cfg, msgBus, registry, provider)
 
-	// Set up shared fallback chain
+	// Set up shared fallback chain. Load-balanced providers record endpoint
+	// health in the same tracker.
 	cooldown := providers.NewCooldownTracker()
 	fallbackChain := providers.NewFallbackChain(cooldown)
+	for _, agentID := range registry.ListAgentIDs() {
+		if agent, ok := registry.GetAgent(agentID); ok {
+			if b := providers.BalancerOf(agent.Provider); b != nil {
+				b.UseCooldownTracker(cooldown)
+			}
+		}
+	}
 
 	// Create state manager using default agent's workspace for channel recording
 	defaultAgent := registry.GetDefaultAgent()
 	var stateManager *state.Manager
+	var ledger *billing.Ledger
 	if defaultAgent != nil {
 		stateManager = state.NewManager(defaultAgent.Workspace)
+		ledger = billing.NewLedger(filepath.Join(defaultAgent.Workspace, "usage"))
+	}
+
+	var budget *billing.Budget
+	if cfg.Budgets.Enabled && ledger != nil {
+		var err error
+		if budget, err = billing.NewBudget(cfg.Budgets.Rules, cfg.ModelList, ledger); err != nil {
+			logger.ErrorCF("billing", "Invalid budget configuration, budgets disabled",
+				map[string]any{"error": err.Error()})
+		}
 	}
 
-	return &AgentLoop{
+	al := &AgentLoop{
 		bus:         msgBus,
 		cfg:         cfg,
 		registry:    registry,
 		state:       stateManager,
 		summarizing: sync.Map{},
 		fallback:    fallbackChain,
+		cooldown:    cooldown,
+		prices:      billing.NewPriceTable(cfg.ModelList),
+		ledger:      ledger,
+		budget:      budget,
+		rateLimits:  providers.RateLimitsOf(provider),
+		downgrades:  make(map[string]*downgradeTarget),
+		search:      session.NewSearchIndex(),
 	}
+	al.registerSessionSearch()
+	return al
 }
 
 // registerSharedTools registers tools that are shared across all agents (web, message, spawn).
@@ -252,6 +294,18 @@ func (al *AgentLoop) ProcessDirectWithChannel(
 	return al.processMessage(ctx, msg)
 }
 
+// RouteAgentID returns the ID of the agent ProcessDirectWithChannel would
+// run the message on.
+func (al *AgentLoop) RouteAgentID(sessionKey, channel, chatID string) string {
+	agent, _, _ := al.resolveRoute(bus.InboundMessage{
+		Channel:    channel,
+		SenderID:   "cron",
+		ChatID:     chatID,
+		SessionKey: sessionKey,
+	})
+	return agent.ID
+}
+
 // ProcessHeartbeat processes a heartbeat request without session history.
 // Each heartbeat is independent and doesn't accumulate context.
 func (al *AgentLoop) ProcessHeartbeat(ctx context.Context, content, channel, chatID string) (string, error) {
@@ -268,7 +322,23 @@ func (al *AgentLoop) ProcessHeartbeat(ctx context.Context, content, channel, cha
 	})
 }
 
-func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage) (string, error) {
+func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage) (_ string, err error) {
+	// Continue a trace handed over in metadata (e.g. a subagent announcing
+	// its result), otherwise start a new one for this message.
+	if sc, ok := tracing.ParseTraceparent(msg.Metadata["traceparent"]); ok {
+		ctx = tracing.ContextWithRemoteParent(ctx, sc)
+	}
+	ctx, span := tracing.Start(ctx, "agent.message", map[string]any{
+		"channel":     msg.Channel,
+		"chat_id":     msg.ChatID,
+		"sender_id":   msg.SenderID,
+		"session_key": msg.SessionKey,
+	})
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
 	// Add message preview to log (show full content for error messages)
 	var logContent string
 	if strings.Contains(msg.Content, "Error:") || strings.Contains(msg.Content, "error") {
@@ -295,25 +365,15 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 	}
 
 	// Route to determine agent and session key
-	route := al.registry.ResolveRoute(routing.RouteInput{
-		Channel:    msg.Channel,
-		AccountID:  msg.Metadata["account_id"],
-		Peer:       extractPeer(msg),
-		ParentPeer: extractParentPeer(msg),
-		GuildID:    msg.Metadata["guild_id"],
-		TeamID:     msg.Metadata["team_id"],
-	})
-
-	agent, ok := al.registry.GetAgent(route.AgentID)
-	if !ok {
-		agent = al.registry.GetDefaultAgent()
-	}
+	_, routeSpan := tracing.Start(ctx, "agent.route", nil)
+	agent, sessionKey, route := al.resolveRoute(msg)
 
-	// Use routed session key, but honor pre-set agent-scoped keys (for ProcessDirect/cron)
-	sessionKey := route.SessionKey
-	if msg.SessionKey != "" && strings.HasPrefix(msg.SessionKey, "agent:") {
-		sessionKey = msg.SessionKey
-	}
+	routeSpan.SetAttrs(map[string]any{
+		"agent_id":    agent.ID,
+		"session_key": sessionKey,
+		"matched_by":  route.MatchedBy,
+	})
+	routeSpan.End()
 
 	logger.InfoCF("agent", "Routed message",
 		map[string]any{
@@ -322,8 +382,10 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 			"matched_by":  route.MatchedBy,
 		})
 
+	ctx = withRequester(ctx, msg.Channel, extractPeer(msg))
 	return al.runAgentLoop(ctx, agent, processOptions{
 		SessionKey:      sessionKey,
+		SenderID:        msg.SenderID,
 		Channel:         msg.Channel,
 		ChatID:          msg.ChatID,
 		UserMessage:     msg.Content,
@@ -390,7 +452,23 @@ func (al *AgentLoop) processSystemMessage(ctx context.Context, msg bus.InboundMe
 }
 
 // runAgentLoop is the core message processing logic.
-func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opts processOptions) (string, error) {
+func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opts processOptions) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "agent.run", map[string]any{
+		"agent_id":    agent.ID,
+		"session_key": opts.SessionKey,
+		"channel":     opts.Channel,
+		"model":       agent.Model,
+	})
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Attribute the provider requests of this run to the agent and session
+	// for Aperture metering, and keep them on one endpoint when balanced.
+	ctx = aperture.WithAttribution(ctx, aperture.Attribution{AgentID: agent.ID, SessionKey: opts.SessionKey})
+	ctx = providers.WithSessionKey(ctx, opts.SessionKey)
+
 	// 0. Record last channel for heartbeat notifications (skip internal channels)
 	if opts.Channel != "" && opts.ChatID != "" {
 		// Don't record internal channels (cli, system, subagent)
@@ -403,7 +481,7 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 	}
 
 	// 1. Update tool contexts
-	al.updateToolContexts(agent, opts.Channel, opts.ChatID)
+	al.updateToolContexts(agent, opts.Channel, opts.ChatID, opts.SenderID)
 
 	// 2. Build messages (skip history for heartbeat)
 	var history []providers.Message
@@ -423,6 +501,7 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 
 	// 3. Save user message to session
 	agent.Sessions.AddMessage(opts.SessionKey, "user", opts.UserMessage)
+	agent.Sessions.SetChannel(opts.SessionKey, opts.Channel)
 
 	// 4. Run LLM iteration loop
 	finalContent, iteration, err := al.runLLMIteration(ctx, agent, messages, opts)
@@ -485,10 +564,18 @@ func (al *AgentLoop) runLLMIteration(
 ) (string, int, error) {
 	iteration := 0
 	var finalContent string
+	hooks := taskHooksFrom(ctx)
 
 	for iteration < agent.MaxIterations {
 		iteration++
 
+		if err := ctx.Err(); err != nil {
+			return "", iteration, err
+		}
+		if err := hooks.beforeIteration(); err != nil {
+			return "", iteration, err
+		}
+
 		logger.DebugCF("agent", "LLM iteration",
 			map[string]any{
 				"agent_id":  agent.ID,
@@ -497,7 +584,7 @@ func (al *AgentLoop) runLLMIteration(
 			})
 
 		// Build tool definitions
-		providerToolDefs := agent.Tools.ToProviderDefs()
+		providerToolDefs := hooks.filterTools(agent.Tools.ToProviderDefs())
 
 		// Log LLM request details
 		logger.DebugCF("agent", "LLM request",
@@ -520,21 +607,52 @@ func (al *AgentLoop) runLLMIteration(
 				"tools_json":    formatToolsForLog(providerToolDefs),
 			})
 
+		// Enforce spending budgets before calling the provider.
+		budgetModel, err := al.checkBudget(agent, opts)
+		if err != nil {
+			return "", iteration, err
+		}
+		var downgrade *downgradeTarget
+		if budgetModel != "" {
+			if downgrade, err = al.downgradeTo(budgetModel); err != nil {
+				return "", iteration, err
+			}
+		}
+
 		// Call LLM with fallback chain if candidates are configured.
 		var response *providers.LLMResponse
-		var err error
 
 		callLLM := func() (*providers.LLMResponse, error) {
-			if len(agent.Candidates) > 1 && al.fallback != nil {
-				fbResult, fbErr := al.fallback.Execute(ctx, agent.Candidates,
+			if len(agent.Candidates) > 1 && al.fallback != nil && downgrade == nil {
+				fbCtx, fbSpan := tracing.Start(ctx, "llm.fallback", map[string]any{
+					"agent_id":   agent.ID,
+					"candidates": len(agent.Candidates),
+				})
+				fbResult, fbErr := al.fallback.Execute(fbCtx, agent.Candidates,
 					func(ctx context.Context, provider, model string) (*providers.LLMResponse, error) {
-						return agent.Provider.Chat(ctx, messages, providerToolDefs, model, map[string]any{
+						ctx, span := startLLMSpan(ctx, agent.ID, provider, model, iteration)
+						start := time.Now()
+						resp, err := agent.Provider.Chat(ctx, messages, providerToolDefs, model, map[string]any{
 							"max_tokens":       agent.MaxTokens,
 							"temperature":      agent.Temperature,
 							"prompt_cache_key": agent.ID,
 						})
+						observeLLMCall(span, agent.ID, provider, model, start, resp, err)
+						if err == nil {
+							al.recordCost(ctx, agent.ID, opts, provider, model, resp)
+						}
+						return resp, err
 					},
 				)
+				fbSpan.RecordError(fbErr)
+				if fbResult != nil {
+					fbSpan.SetAttrs(map[string]any{
+						"provider": fbResult.Provider,
+						"model":    fbResult.Model,
+						"attempts": len(fbResult.Attempts) + 1,
+					})
+				}
+				fbSpan.End()
 				if fbErr != nil {
 					return nil, fbErr
 				}
@@ -545,11 +663,23 @@ func (al *AgentLoop) runLLMIteration(
 				}
 				return fbResult.Response, nil
 			}
-			return agent.Provider.Chat(ctx, messages, providerToolDefs, agent.Model, map[string]any{
+			llm, provider, model := agent.Provider, primaryProvider(agent), agent.Model
+			if downgrade != nil {
+				llm, provider, model = downgrade.provider, downgrade.protocol, downgrade.modelID
+			}
+			ctx, span := startLLMSpan(ctx, agent.ID, provider, model, iteration)
+			start := time.Now()
+			resp, err := llm.Chat(ctx, messages, providerToolDefs, model, map[string]any{
 				"max_tokens":       agent.MaxTokens,
 				"temperature":      agent.Temperature,
 				"prompt_cache_key": agent.ID,
 			})
+			observeLLMCall(span, agent.ID, provider, model, start, resp, err)
+			al.recordRateLimit(provider, model, resp, err)
+			if err == nil {
+				al.recordCost(ctx, agent.ID, opts, provider, model, resp)
+			}
+			return resp, err
 		}
 
 		// Retry loop for context/token errors
@@ -668,6 +798,10 @@ func (al *AgentLoop) runLLMIteration(
 		// Save assistant message with tool calls to session
 		agent.Sessions.AddFullMessage(opts.SessionKey, assistantMsg)
 
+		if response.Content != "" {
+			ReportProgress(ctx, ProgressEvent{Kind: ProgressAssistant, Iteration: iteration, Content: response.Content})
+		}
+
 		// Execute tool calls
 		for _, tc := range normalizedToolCalls {
 			argsJSON, err := json.Marshal(tc.Arguments)
@@ -681,6 +815,12 @@ func (al *AgentLoop) runLLMIteration(
 					"tool":      tc.Name,
 					"iteration": iteration,
 				})
+			ReportProgress(ctx, ProgressEvent{
+				Kind:      ProgressToolCall,
+				Iteration: iteration,
+				Tool:      tc.Name,
+				Content:   argsPreview,
+			})
 
 			// Create async callback for tools that implement AsyncTool
 			// NOTE: Following openclaw's design, async tools do NOT send results directly to users.
@@ -698,14 +838,23 @@ func (al *AgentLoop) runLLMIteration(
 				}
 			}
 
-			toolResult := agent.Tools.ExecuteWithContext(
-				ctx,
-				tc.Name,
-				tc.Arguments,
-				opts.Channel,
-				opts.ChatID,
-				asyncCallback,
-			)
+			// A canceled run or a task restriction still answers the call, so
+			// the history keeps every tool call paired with a result.
+			var toolResult *tools.ToolResult
+			if err := ctx.Err(); err != nil {
+				toolResult = tools.ErrorResult("tool call canceled: " + err.Error()).WithError(err)
+			} else if err := hooks.allowTool(tc.Name); err != nil {
+				toolResult = tools.ErrorResult(err.Error()).WithError(err)
+			} else {
+				toolResult = agent.Tools.ExecuteWithContext(
+					ctx,
+					tc.Name,
+					tc.Arguments,
+					opts.Channel,
+					opts.ChatID,
+					asyncCallback,
+				)
+			}
 
 			// Send ForUser content to user immediately if not Silent
 			if !toolResult.Silent && toolResult.ForUser != "" && opts.SendResponse {
@@ -726,6 +875,13 @@ func (al *AgentLoop) runLLMIteration(
 			if contentForLLM == "" && toolResult.Err != nil {
 				contentForLLM = toolResult.Err.Error()
 			}
+			ReportProgress(ctx, ProgressEvent{
+				Kind:      ProgressToolResult,
+				Iteration: iteration,
+				Tool:      tc.Name,
+				Content:   utils.Truncate(contentForLLM, 200),
+				IsError:   toolResult.IsError,
+			})
 
 			toolResultMsg := providers.Message{
 				Role:       "tool",
@@ -742,8 +898,9 @@ func (al *AgentLoop) runLLMIteration(
 	return finalContent, iteration, nil
 }
 
-// updateToolContexts updates the context for tools that need channel/chatID info.
-func (al *AgentLoop) updateToolContexts(agent *AgentInstance, channel, chatID string) {
+// updateToolContexts updates the context for tools that need channel/chatID
+// or sender info.
+func (al *AgentLoop) updateToolContexts(agent *AgentInstance, channel, chatID, senderID string) {
 	// Use ContextualTool interface instead of type assertions
 	if tool, ok := agent.Tools.Get("message"); ok {
 		if mt, ok := tool.(tools.ContextualTool); ok {
@@ -760,6 +917,11 @@ func (al *AgentLoop) updateToolContexts(agent *AgentInstance, channel, chatID st
 			st.SetContext(channel, chatID)
 		}
 	}
+	if tool, ok := agent.Tools.Get("cron"); ok {
+		if ct, ok := tool.(tools.SenderAwareTool); ok {
+			ct.SetSender(senderID)
+		}
+	}
 }
 
 // maybeSummarize triggers summarization if the session history exceeds thresholds.
@@ -786,59 +948,6 @@ func (al *AgentLoop) maybeSummarize(agent *AgentInstance, sessionKey, channel, c
 	}
 }
 
-// forceCompression aggressively reduces context when the limit is hit.
-// It drops the oldest 50% of messages (keeping system prompt and last user message).
-func (al *AgentLoop) forceCompression(agent *AgentInstance, sessionKey string) {
-	history := agent.Sessions.GetHistory(sessionKey)
-	if len(history) <= 4 {
-		return
-	}
-
-	// Keep system prompt (usually [0]) and the very last message (user's trigger)
-	// We want to drop the oldest half of the *conversation*
-	// Assuming [0] is system, [1:] is conversation
-	conversation := history[1 : len(history)-1]
-	if len(conversation) == 0 {
-		return
-	}
-
-	// Helper to find the mid-point of the conversation
-	mid := len(conversation) / 2
-
-	// New history structure:
-	// 1. System Prompt (with compression note appended)
-	// 2. Second half of conversation
-	// 3. Last message
-
-	droppedCount := mid
-	keptConversation := conversation[mid:]
-
-	newHistory := make([]providers.Message, 0, 1+len(keptConversation)+1)
-
-	// Append compression note to the original system prompt instead of adding a new system message
-	// This avoids having two consecutive system messages which some APIs (like Zhipu) reject
-	compressionNote := fmt.Sprintf(
-		"\n\n[System Note: Emergency compression dropped %d oldest messages due to context limit]",
-		droppedCount,
-	)
-	enhancedSystemPrompt := history[0]
-	enhancedSystemPrompt.Content += compressionNote
-	newHistory = append(newHistory, enhancedSystemPrompt)
-
-	newHistory = append(newHistory, keptConversation...)
-	newHistory = append(newHistory, history[len(history)-1]) // Last message
-
-	// Update session
-	agent.Sessions.SetHistory(sessionKey, newHistory)
-	agent.Sessions.Save(sessionKey)
-
-	logger.WarnCF("agent", "Forced compression executed", map[string]any{
-		"session_key":  sessionKey,
-		"dropped_msgs": droppedCount,
-		"new_count":    len(newHistory),
-	})
-}
-
 // GetStartupInfo returns information about loaded tools and skills for logging.
 func (al *AgentLoop) GetStartupInfo() map[string]any {
 	info := make(map[string]any)
@@ -864,9 +973,34 @@ func (al *AgentLoop) GetStartupInfo() map[string]any {
 		"ids":   al.registry.ListAgentIDs(),
 	}
 
+	if status := al.providerStatus(); len(status) > 0 {
+		info["providers"] = status
+	}
+	if b := providers.BalancerOf(agent.Provider); b != nil {
+		info["load_balancing"] = map[string]any{
+			"model":     b.ModelName(),
+			"endpoints": b.Status(),
+		}
+	}
+
 	return info
 }
 
+// GetAgent returns the agent instance for the given ID.
+func (al *AgentLoop) GetAgent(agentID string) (*AgentInstance, bool) {
+	return al.registry.GetAgent(agentID)
+}
+
+// ListAgentIDs returns all registered agent IDs.
+func (al *AgentLoop) ListAgentIDs() []string {
+	return al.registry.ListAgentIDs()
+}
+
+// GetRegistry returns the agent registry backing this loop.
+func (al *AgentLoop) GetRegistry() *AgentRegistry {
+	return al.registry
+}
+
 // GetToolDefinitions returns the schema definitions for all registered tools.
 func (al *AgentLoop) GetToolDefinitions() []map[string]any {
 	agent := al.registry.GetDefaultAgent()
@@ -927,125 +1061,6 @@ func formatToolsForLog(toolDefs []providers.ToolDefinition) string {
 	return sb.String()
 }
 
-// summarizeSession summarizes the conversation history for a session.
-func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
-	defer cancel()
-
-	history := agent.Sessions.GetHistory(sessionKey)
-	summary := agent.Sessions.GetSummary(sessionKey)
-
-	// Keep last 4 messages for continuity
-	if len(history) <= 4 {
-		return
-	}
-
-	toSummarize := history[:len(history)-4]
-
-	// Oversized Message Guard
-	maxMessageTokens := agent.ContextWindow / 2
-	validMessages := make([]providers.Message, 0)
-	omitted := false
-
-	for _, m := range toSummarize {
-		if m.Role != "user" && m.Role != "assistant" {
-			continue
-		}
-		msgTokens := len(m.Content) / 2
-		if msgTokens > maxMessageTokens {
-			omitted = true
-			continue
-		}
-		validMessages = append(validMessages, m)
-	}
-
-	if len(validMessages) == 0 {
-		return
-	}
-
-	// Multi-Part Summarization
-	var finalSummary string
-	if len(validMessages) > 10 {
-		mid := len(validMessages) / 2
-		part1 := validMessages[:mid]
-		part2 := validMessages[mid:]
-
-		s1, _ := al.summarizeBatch(ctx, agent, part1, "")
-		s2, _ := al.summarizeBatch(ctx, agent, part2, "")
-
-		mergePrompt := fmt.Sprintf(
-			"Merge these two conversation summaries into one cohesive summary:\n\n1: %s\n\n2: %s",
-			s1,
-			s2,
-		)
-		resp, err := agent.Provider.Chat(
-			ctx,
-			[]providers.Message{{Role: "user", Content: mergePrompt}},
-			nil,
-			agent.Model,
-			map[string]any{
-				"max_tokens":       1024,
-				"temperature":      0.3,
-				"prompt_cache_key": agent.ID,
-			},
-		)
-		if err == nil {
-			finalSummary = resp.Content
-		} else {
-			finalSummary = s1 + " " + s2
-		}
-	} else {
-		finalSummary, _ = al.summarizeBatch(ctx, agent, validMessages, summary)
-	}
-
-	if omitted && finalSummary != "" {
-		finalSummary += "\n[Note: Some oversized messages were omitted from this summary for efficiency.]"
-	}
-
-	if finalSummary != "" {
-		agent.Sessions.SetSummary(sessionKey, finalSummary)
-		agent.Sessions.TruncateHistory(sessionKey, 4)
-		agent.Sessions.Save(sessionKey)
-	}
-}
-
-// summarizeBatch summarizes a batch of messages.
-func (al *AgentLoop) summarizeBatch(
-	ctx context.Context,
-	agent *AgentInstance,
-	batch []providers.Message,
-	existingSummary string,
-) (string, error) {
-	var sb strings.Builder
-	sb.WriteString("Provide a concise summary of this conversation segment, preserving core context and key points.\n")
-	if existingSummary != "" {
-		sb.WriteString("Existing context: ")
-		sb.WriteString(existingSummary)
-		sb.WriteString("\n")
-	}
-	sb.WriteString("\nCONVERSATION:\n")
-	for _, m := range batch {
-		fmt.Fprintf(&sb, "%s: %s\n", m.Role, m.Content)
-	}
-	prompt := sb.String()
-
-	response, err := agent.Provider.Chat(
-		ctx,
-		[]providers.Message{{Role: "user", Content: prompt}},
-		nil,
-		agent.Model,
-		map[string]any{
-			"max_tokens":       1024,
-			"temperature":      0.3,
-			"prompt_cache_key": agent.ID,
-		},
-	)
-	if err != nil {
-		return "", err
-	}
-	return response.Content, nil
-}
-
 // estimateTokens estimates the number of tokens in a message list.
 // Uses a safe heuristic of 2.5 characters per token to account for CJK and other
 // overheads better than the previous 3 chars/token.
@@ -1074,6 +1089,15 @@ func (al *AgentLoop) handleCommand(_ context.Context, msg bus.InboundMessage) (s
 	args := parts[1:]
 
 	switch cmd {
+	case "/usage":
+		return al.usageReport(msg, args), true
+
+	case "/rewind", "/branches", "/branch", "/fork":
+		return al.branchCommand(msg, cmd, args), true
+
+	case "/pin", "/unpin", "/pins":
+		return al.pinCommand(msg, cmd, args), true
+
 	case "/show":
 		if len(args) < 1 {
 			return "Usage: /show [model|channel|agents]", true
@@ -1149,6 +1173,38 @@ func (al *AgentLoop) handleCommand(_ context.Context, msg bus.InboundMessage) (s
 	return "", false
 }
 
+// resolveRoute determines the agent and session key for an inbound message.
+func (al *AgentLoop) resolveRoute(msg bus.InboundMessage) (*AgentInstance, string, routing.ResolvedRoute) {
+	route := al.registry.ResolveRoute(routing.RouteInput{
+		Channel:    msg.Channel,
+		AccountID:  msg.Metadata["account_id"],
+		Peer:       extractPeer(msg),
+		ParentPeer: extractParentPeer(msg),
+		GuildID:    msg.Metadata["guild_id"],
+		TeamID:     msg.Metadata["team_id"],
+	})
+
+	agentID := route.AgentID
+	// Use routed session key, but honor pre-set agent-scoped keys (for ProcessDirect/cron).
+	// The agent encoded in such a key also wins over the routed agent, so that
+	// direct callers (API, MCP) can address a specific agent.
+	sessionKey := route.SessionKey
+	if msg.SessionKey != "" && strings.HasPrefix(msg.SessionKey, "agent:") {
+		sessionKey = msg.SessionKey
+		if parsed := routing.ParseAgentSessionKey(msg.SessionKey); parsed != nil {
+			if _, exists := al.registry.GetAgent(parsed.AgentID); exists {
+				agentID = parsed.AgentID
+			}
+		}
+	}
+
+	agent, ok := al.registry.GetAgent(agentID)
+	if !ok {
+		agent = al.registry.GetDefaultAgent()
+	}
+	return agent, sessionKey, route
+}
+
 // extractPeer extracts the routing peer from inbound message metadata.
 func extractPeer(msg bus.InboundMessage) *routing.RoutePeer {
 	peerKind := msg.Metadata["peer_kind"]
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"slices"
+	"sync"
 	"testing"
 	"time"
 
@@ -13,6 +14,7 @@ import (
 	"github.com/tinyland-inc/tinyclaw/pkg/config"
 	"github.com/tinyland-inc/tinyclaw/pkg/providers"
 	"github.com/tinyland-inc/tinyclaw/pkg/tools"
+	"github.com/tinyland-inc/tinyclaw/pkg/tracing"
 )
 
 func TestRecordLastChannel(t *testing.T) {
@@ -591,3 +593,184 @@ func TestAgentLoop_ContextExhaustionRetry(t *testing.T) {
 		t.Errorf("Expected history to be compressed (len < 8), got %d", len(finalHistory))
 	}
 }
+
+// TestProcessDirect_AgentScopedSessionSelectsAgent verifies that an agent-scoped
+// session key addresses that agent even when routing would pick another.
+func TestProcessDirect_AgentScopedSessionSelectsAgent(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         filepath.Join(tmpDir, "main"),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+			List: []config.AgentConfig{
+				{ID: "main", Default: true},
+				{ID: "research", Workspace: filepath.Join(tmpDir, "research")},
+			},
+		},
+	}
+
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &simpleMockProvider{response: "ok"})
+
+	sessionKey := "agent:research:mcp"
+	if _, err := al.ProcessDirectWithChannel(context.Background(), "hello", sessionKey, "mcp", "mcp"); err != nil {
+		t.Fatalf("ProcessDirectWithChannel failed: %v", err)
+	}
+
+	research, ok := al.GetAgent("research")
+	if !ok {
+		t.Fatal("research agent not registered")
+	}
+	if got := len(research.Sessions.GetHistory(sessionKey)); got != 2 {
+		t.Errorf("expected 2 messages in research session, got %d", got)
+	}
+
+	mainAgent, _ := al.GetAgent("main")
+	if got := len(mainAgent.Sessions.GetHistory(sessionKey)); got != 0 {
+		t.Errorf("expected main agent session to be empty, got %d messages", got)
+	}
+}
+
+// toolThenAnswerProvider requests mock_custom once, then answers.
+type toolThenAnswerProvider struct {
+	calls int
+}
+
+func (m *toolThenAnswerProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	m.calls++
+	if m.calls == 1 {
+		return &providers.LLMResponse{
+			Content: "let me check",
+			ToolCalls: []providers.ToolCall{
+				{ID: "call_1", Name: "mock_custom", Arguments: map[string]any{}},
+			},
+		}, nil
+	}
+	return &providers.LLMResponse{Content: "all done"}, nil
+}
+
+func (m *toolThenAnswerProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+// TestProcessDirect_ReportsProgress verifies tool calls and intermediate
+// assistant text are reported through the context's ProgressFunc.
+func TestProcessDirect_ReportsProgress(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &toolThenAnswerProvider{})
+	al.RegisterTool(&mockCustomTool{})
+
+	var events []ProgressEvent
+	ctx := WithProgress(context.Background(), func(ev ProgressEvent) {
+		events = append(events, ev)
+	})
+
+	response, err := al.ProcessDirectWithChannel(ctx, "go", "progress-session", "api", "job")
+	if err != nil {
+		t.Fatalf("ProcessDirectWithChannel failed: %v", err)
+	}
+	if response != "all done" {
+		t.Errorf("response = %q", response)
+	}
+
+	kinds := make([]string, 0, len(events))
+	for _, ev := range events {
+		kinds = append(kinds, ev.Kind)
+	}
+	want := []string{ProgressAssistant, ProgressToolCall, ProgressToolResult}
+	if !slices.Equal(kinds, want) {
+		t.Fatalf("event kinds = %v, want %v", kinds, want)
+	}
+	if events[1].Tool != "mock_custom" || events[2].Content != "Custom tool executed" {
+		t.Errorf("unexpected tool events: %+v", events[1:])
+	}
+}
+
+// spanRecorder collects exported spans.
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []tracing.SpanData
+}
+
+func (r *spanRecorder) ExportSpans(_ context.Context, spans []tracing.SpanData) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, spans...)
+	return nil
+}
+
+func (r *spanRecorder) Shutdown(context.Context) error { return nil }
+
+func TestProcessMessage_Traced(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+
+	rec := &spanRecorder{}
+	tracer := tracing.NewTracer(rec)
+	tracing.SetDefault(tracer)
+	defer tracing.SetDefault(nil)
+
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &toolThenAnswerProvider{})
+	al.RegisterTool(&mockCustomTool{})
+
+	if _, err := al.ProcessDirectWithChannel(context.Background(), "go", "trace-session", "api", "t"); err != nil {
+		t.Fatalf("ProcessDirectWithChannel failed: %v", err)
+	}
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	byName := map[string][]tracing.SpanData{}
+	for _, s := range rec.spans {
+		byName[s.Name] = append(byName[s.Name], s)
+	}
+	for name, want := range map[string]int{
+		"agent.message": 1,
+		"agent.route":   1,
+		"agent.run":     1,
+		"llm.chat":      2,
+		"tool.execute":  1,
+	} {
+		if got := len(byName[name]); got != want {
+			t.Errorf("%s spans = %d, want %d", name, got, want)
+		}
+	}
+
+	root := byName["agent.message"][0]
+	for _, s := range rec.spans {
+		if s.TraceID != root.TraceID {
+			t.Errorf("span %s is in trace %s, want %s", s.Name, s.TraceID, root.TraceID)
+		}
+	}
+	if byName["tool.execute"][0].Attributes["tool"] != "mock_custom" {
+		t.Errorf("tool span attributes = %v", byName["tool.execute"][0].Attributes)
+	}
+}
@@ -12,33 +12,71 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+	"github.com/tinyland-inc/tinyclaw/pkg/logger"
+	"github.com/tinyland-inc/tinyclaw/pkg/memory"
+	"github.com/tinyland-inc/tinyclaw/pkg/tools"
 )
 
+// defaultPromptTopK is the number of memories recalled into the system
+// prompt when not configured.
+const defaultPromptTopK = 5
+
 // MemoryStore manages persistent memory for the agent.
 // - Long-term memory: memory/MEMORY.md
 // - Daily notes: memory/YYYYMM/YYYYMMDD.md
+// - Saved memories: memory/entries.json
+//
+// All three are indexed for retrieval; only the memories relevant to the
+// current message reach the prompt.
 type MemoryStore struct {
 	workspace  string
 	memoryDir  string
 	memoryFile string
+
+	index *memory.Store // nil if the memory directory could not be opened
+	topK  int
+	fuzzy bool
 }
 
 // NewMemoryStore creates a new MemoryStore with the given workspace path.
 // It ensures the memory directory exists.
 func NewMemoryStore(workspace string) *MemoryStore {
 	memoryDir := filepath.Join(workspace, "memory")
-	memoryFile := filepath.Join(memoryDir, "MEMORY.md")
-
-	// Ensure memory directory exists
-	os.MkdirAll(memoryDir, 0o755)
+	memoryFile := filepath.Join(memoryDir, memory.LongTermFile)
+
+	index, err := memory.Open(memoryDir)
+	if err != nil {
+		logger.WarnCF("agent", "Failed to open memory store, memories will not be recalled", map[string]any{
+			"path":  memoryDir,
+			"error": err.Error(),
+		})
+	}
 
 	return &MemoryStore{
 		workspace:  workspace,
 		memoryDir:  memoryDir,
 		memoryFile: memoryFile,
+		index:      index,
+		topK:       defaultPromptTopK,
 	}
 }
 
+// Configure applies the memory retrieval settings.
+func (ms *MemoryStore) Configure(cfg config.MemoryConfig) {
+	ms.topK = defaultPromptTopK
+	if cfg.PromptTopK > 0 {
+		ms.topK = cfg.PromptTopK
+	}
+	ms.fuzzy = cfg.Fuzzy
+}
+
+// Index returns the searchable memory store, or nil if it is unavailable.
+func (ms *MemoryStore) Index() *memory.Store {
+	return ms.index
+}
+
 // getTodayFile returns the path to today's daily note file (memory/YYYYMM/YYYYMMDD.md).
 func (ms *MemoryStore) getTodayFile() string {
 	today := time.Now().Format("20060102") // YYYYMMDD
@@ -122,30 +160,23 @@ func (ms *MemoryStore) GetRecentDailyNotes(days int) string {
 	return sb.String()
 }
 
-// GetMemoryContext returns formatted memory context for the agent prompt.
-// Includes long-term memory and recent daily notes.
-func (ms *MemoryStore) GetMemoryContext() string {
-	longTerm := ms.ReadLongTerm()
-	recentNotes := ms.GetRecentDailyNotes(3)
-
-	if longTerm == "" && recentNotes == "" {
+// GetMemoryContext returns the memories most relevant to query, formatted
+// for the agent prompt, or "" if none match.
+func (ms *MemoryStore) GetMemoryContext(query string) string {
+	if ms.index == nil || strings.TrimSpace(query) == "" {
 		return ""
 	}
-
-	var sb strings.Builder
-
-	if longTerm != "" {
-		sb.WriteString("## Long-term Memory\n\n")
-		sb.WriteString(longTerm)
+	results := ms.index.Search(query, memory.SearchOptions{Limit: ms.topK, Fuzzy: ms.fuzzy})
+	if len(results) == 0 {
+		return ""
 	}
 
-	if recentNotes != "" {
-		if longTerm != "" {
-			sb.WriteString("\n\n---\n\n")
-		}
-		sb.WriteString("## Recent Daily Notes\n\n")
-		sb.WriteString(recentNotes)
+	var sb strings.Builder
+	sb.WriteString("## Relevant Memories\n\n")
+	sb.WriteString("Recalled for the current message. Use memory_search to look up anything else.\n")
+	for _, r := range results {
+		sb.WriteString("\n- ")
+		sb.WriteString(strings.ReplaceAll(tools.FormatMemory(r.Entry), "\n", "\n  "))
 	}
-
 	return sb.String()
 }
@@ -0,0 +1,50 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+)
+
+func TestBuildMessages_RecallsRelevantMemories(t *testing.T) {
+	tmpDir := setupWorkspace(t, map[string]string{
+		"memory/MEMORY.md": "# Memory\n\n- User likes Go.\n- User's cat is called Miso.",
+	})
+	cb := NewContextBuilder(tmpDir)
+	cb.memory.Configure(config.MemoryConfig{PromptTopK: 2})
+	if _, err := cb.memory.Index().Save("The staging database is on port 5433", []string{"infra"}, "cli:direct"); err != nil {
+		t.Fatal(err)
+	}
+
+	if sp := cb.BuildSystemPromptWithCache(); strings.Contains(sp, "Miso") {
+		t.Error("static prompt should no longer carry MEMORY.md")
+	}
+
+	system := func(message string) string {
+		return cb.BuildMessages(nil, "", message, nil, "cli", "direct")[0].Content
+	}
+	got := system("which port does the staging database use?")
+	if !strings.Contains(got, "port 5433") || strings.Contains(got, "Miso") {
+		t.Errorf("recall for database question:\n%s", got)
+	}
+	got = system("what's my cat's name?")
+	if !strings.Contains(got, "Miso") || strings.Contains(got, "5433") || strings.Contains(got, "likes Go") {
+		t.Errorf("recall for cat question:\n%s", got)
+	}
+	if got := system("hello there"); strings.Contains(got, "# Memory") {
+		t.Errorf("unrelated message recalled memories:\n%s", got)
+	}
+
+	// Edits to MEMORY.md are picked up without invalidating the prompt cache.
+	path := filepath.Join(tmpDir, "memory", "MEMORY.md")
+	os.WriteFile(path, []byte("- User likes Rust."), 0o644)
+	future := time.Now().Add(2 * time.Second)
+	os.Chtimes(path, future, future)
+	if got := system("does the user like rust?"); !strings.Contains(got, "User likes Rust") {
+		t.Errorf("edited MEMORY.md not recalled:\n%s", got)
+	}
+}
@@ -0,0 +1,172 @@
+package agent
+
+import (
+	"context"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/metrics"
+	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+	"github.com/tinyland-inc/tinyclaw/pkg/tracing"
+)
+
+// startLLMSpan starts the span covering one provider call.
+func startLLMSpan(ctx context.Context, agentID, provider, model string, iteration int) (context.Context, *tracing.Span) {
+	return tracing.Start(ctx, "llm.chat", map[string]any{
+		"agent_id":  agentID,
+		"provider":  provider,
+		"model":     model,
+		"iteration": iteration,
+	})
+}
+
+// observeLLMCall records the outcome, latency and token usage of one
+// provider call and ends its span.
+func observeLLMCall(
+	span *tracing.Span,
+	agentID, provider, model string,
+	start time.Time,
+	resp *providers.LLMResponse,
+	err error,
+) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	metrics.LLMRequests.Inc(provider, model, agentID, outcome)
+	metrics.LLMDuration.Observe(time.Since(start).Seconds(), provider, model, agentID)
+
+	if resp != nil && resp.Usage != nil {
+		metrics.LLMTokens.Add(float64(resp.Usage.PromptTokens), provider, model, agentID, "prompt")
+		metrics.LLMTokens.Add(float64(resp.Usage.CompletionTokens), provider, model, agentID, "completion")
+		span.SetAttrs(map[string]any{
+			"prompt_tokens":     resp.Usage.PromptTokens,
+			"completion_tokens": resp.Usage.CompletionTokens,
+		})
+	}
+	span.RecordError(err)
+	span.End()
+}
+
+// recordRateLimit keeps the rate-limit headers of a call made outside the
+// fallback chain, which records its own, so status and metrics show them.
+func (al *AgentLoop) recordRateLimit(provider, model string, resp *providers.LLMResponse, err error) {
+	if al.cooldown == nil {
+		return
+	}
+	if resp != nil {
+		al.cooldown.RecordRateLimit(provider, resp.RateLimit)
+	}
+	if fe := providers.ClassifyError(err, provider, model); fe != nil {
+		al.cooldown.RecordRateLimit(provider, fe.RateLimit)
+	}
+}
+
+// providerStatus reports cooldowns and rate-limit quotas for the status
+// API, keyed by provider.
+func (al *AgentLoop) providerStatus() map[string]any {
+	status := make(map[string]any)
+	if al.cooldown != nil {
+		for _, s := range al.cooldown.Snapshot() {
+			entry := map[string]any{
+				"error_count":      s.ErrorCount,
+				"cooldown_seconds": s.Remaining.Seconds(),
+			}
+			if rl := s.RateLimit; rl != nil {
+				entry["rate_limit"] = map[string]any{
+					"limit_requests":         rl.LimitRequests,
+					"limit_tokens":           rl.LimitTokens,
+					"remaining_requests":     rl.RemainingRequests,
+					"remaining_tokens":       rl.RemainingTokens,
+					"reset_requests_seconds": rl.ResetRequests.Seconds(),
+					"reset_tokens_seconds":   rl.ResetTokens.Seconds(),
+				}
+			}
+			status[s.Provider] = entry
+		}
+	}
+	return status
+}
+
+// primaryProvider names the provider of an agent's primary model.
+func primaryProvider(agent *AgentInstance) string {
+	if len(agent.Candidates) > 0 && agent.Candidates[0].Provider != "" {
+		return agent.Candidates[0].Provider
+	}
+	return "default"
+}
+
+// RegisterMetrics registers scrape-time gauges for the message bus,
+// provider cooldowns and provider rate-limit quotas on r.
+func (al *AgentLoop) RegisterMetrics(r *metrics.Registry) {
+	r.NewGaugeFunc("tinyclaw_bus_queue_depth",
+		"Messages waiting in the bus queues.",
+		func(emit func(float64, ...string)) {
+			emit(float64(al.bus.InboundDepth()), "inbound")
+			emit(float64(al.bus.OutboundDepth()), "outbound")
+		}, "queue")
+
+	if agent := al.registry.GetDefaultAgent(); agent != nil {
+		if b := providers.BalancerOf(agent.Provider); b != nil {
+			r.NewGaugeFunc("tinyclaw_lb_endpoint_outstanding",
+				"Requests in flight per load-balanced endpoint.",
+				func(emit func(float64, ...string)) {
+					for _, s := range b.Status() {
+						emit(float64(s.Outstanding), b.ModelName(), s.Name)
+					}
+				}, "model", "endpoint")
+			r.NewGaugeFunc("tinyclaw_lb_endpoint_healthy",
+				"1 when a load-balanced endpoint is receiving traffic, 0 while drained.",
+				func(emit func(float64, ...string)) {
+					for _, s := range b.Status() {
+						v := 0.0
+						if s.Healthy {
+							v = 1
+						}
+						emit(v, b.ModelName(), s.Name)
+					}
+				}, "model", "endpoint")
+		}
+	}
+
+	if al.cooldown == nil {
+		return
+	}
+	r.NewGaugeFunc("tinyclaw_provider_cooldown_seconds",
+		"Seconds until a provider leaves cooldown (0 when available).",
+		func(emit func(float64, ...string)) {
+			for _, s := range al.cooldown.Snapshot() {
+				emit(s.Remaining.Seconds(), s.Provider)
+			}
+		}, "provider")
+	r.NewGaugeFunc("tinyclaw_provider_consecutive_errors",
+		"Consecutive failures recorded for a provider since its last success.",
+		func(emit func(float64, ...string)) {
+			for _, s := range al.cooldown.Snapshot() {
+				emit(float64(s.ErrorCount), s.Provider)
+			}
+		}, "provider")
+	r.NewGaugeFunc("tinyclaw_provider_ratelimit_remaining",
+		"Requests or tokens left in the provider's current rate-limit window, from its response headers.",
+		func(emit func(float64, ...string)) {
+			for _, s := range al.cooldown.Snapshot() {
+				if rl := s.RateLimit; rl != nil {
+					if rl.RemainingRequests >= 0 {
+						emit(float64(rl.RemainingRequests), s.Provider, "requests")
+					}
+					if rl.RemainingTokens >= 0 {
+						emit(float64(rl.RemainingTokens), s.Provider, "tokens")
+					}
+				}
+			}
+		}, "provider", "kind")
+	r.NewGaugeFunc("tinyclaw_provider_ratelimit_reset_seconds",
+		"Seconds until the provider's rate-limit window resets, from its response headers.",
+		func(emit func(float64, ...string)) {
+			for _, s := range al.cooldown.Snapshot() {
+				if rl := s.RateLimit; rl != nil {
+					emit(rl.ResetRequests.Seconds(), s.Provider, "requests")
+					emit(rl.ResetTokens.Seconds(), s.Provider, "tokens")
+				}
+			}
+		}, "provider", "kind")
+}
@@ -0,0 +1,46 @@
+package agent
+
+import (
+	"context"
+	"time"
+)
+
+// Progress event kinds emitted while an agent loop runs.
+const (
+	ProgressAssistant  = "assistant"   // intermediate assistant text alongside tool calls
+	ProgressToolCall   = "tool_call"   // a tool is about to be executed
+	ProgressToolResult = "tool_result" // a tool finished executing
+)
+
+// ProgressEvent describes one step of an in-flight agent turn.
+type ProgressEvent struct {
+	Kind      string    `json:"kind"`
+	Iteration int       `json:"iteration"`
+	Tool      string    `json:"tool,omitempty"`
+	Content   string    `json:"content,omitempty"`
+	IsError   bool      `json:"is_error,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// ProgressFunc receives progress events. It is called synchronously from the
+// agent loop and must not block.
+type ProgressFunc func(ProgressEvent)
+
+type progressKey struct{}
+
+// WithProgress returns a context that reports agent loop progress to fn.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// ReportProgress delivers ev to the ProgressFunc attached to ctx, if any.
+func ReportProgress(ctx context.Context, ev ProgressEvent) {
+	fn, ok := ctx.Value(progressKey{}).(ProgressFunc)
+	if !ok || fn == nil {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	fn(ev)
+}
@@ -0,0 +1,75 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/constants"
+	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+	"github.com/tinyland-inc/tinyclaw/pkg/routing"
+	"github.com/tinyland-inc/tinyclaw/pkg/session"
+	"github.com/tinyland-inc/tinyclaw/pkg/tools"
+)
+
+// requester identifies who a conversation turn is for.
+type requester struct {
+	channel string
+	peer    *routing.RoutePeer
+}
+
+type requesterKey struct{}
+
+func withRequester(ctx context.Context, channel string, peer *routing.RoutePeer) context.Context {
+	return context.WithValue(ctx, requesterKey{}, requester{channel: channel, peer: peer})
+}
+
+// SearchSessions runs a full-text search over the messages of every agent's
+// sessions.
+func (al *AgentLoop) SearchSessions(
+	ctx context.Context,
+	query string,
+	opts session.SearchOptions,
+) ([]session.SearchHit, error) {
+	return al.search.Search(ctx, query, opts)
+}
+
+// registerSessionSearch indexes every agent's sessions and gives each agent
+// a session_search tool over its own conversations.
+func (al *AgentLoop) registerSessionSearch() {
+	for _, agentID := range al.registry.ListAgentIDs() {
+		agent, ok := al.registry.GetAgent(agentID)
+		if !ok {
+			continue
+		}
+		al.search.Attach(agent.ID, agent.Sessions)
+		agent.Tools.Register(tools.NewSessionSearchTool(func(ctx context.Context, query string, limit int) ([]session.SearchHit, error) {
+			return al.SearchSessions(ctx, query, session.SearchOptions{
+				Limit: limit,
+				Allow: al.searchScope(ctx, agentID),
+			})
+		}))
+	}
+}
+
+// searchScope returns which sessions of agentID the turn in ctx may search.
+// Operators on internal channels see all of them. A user on a chat channel
+// sees the current session and their own direct-message sessions, never
+// other users' DMs or other groups.
+func (al *AgentLoop) searchScope(ctx context.Context, agentID string) func(agentID, key string) bool {
+	r, _ := ctx.Value(requesterKey{}).(requester)
+	current := providers.SessionKeyFromContext(ctx)
+	links := al.cfg.Session.IdentityLinks
+
+	return func(owner, key string) bool {
+		switch {
+		case owner != agentID:
+			return false
+		case r.channel != "" && constants.IsInternalChannel(r.channel):
+			return true
+		case key == current:
+			return true
+		case r.peer != nil && r.peer.Kind == "direct":
+			return routing.IsDirectSessionOf(key, r.channel, r.peer.ID, links)
+		}
+		return false
+	}
+}
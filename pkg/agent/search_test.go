@@ -0,0 +1,59 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+	"github.com/tinyland-inc/tinyclaw/pkg/routing"
+)
+
+func TestSessionSearchTool_IdentityScoping(t *testing.T) {
+	al, _ := newBudgetLoop(t)
+	agent := al.registry.GetDefaultAgent()
+	const (
+		aliceDM = "agent:main:telegram:direct:alice"
+		bobDM   = "agent:main:telegram:direct:bob"
+		group   = "agent:main:telegram:group:team"
+	)
+	agent.Sessions.AddMessage(aliceDM, "user", "alice secret: the launch codename is falcon")
+	agent.Sessions.AddMessage(bobDM, "user", "bob secret: the falcon budget is tight")
+	agent.Sessions.AddMessage(group, "assistant", "team notes: falcon ships in May")
+
+	tool, ok := agent.Tools.Get("session_search")
+	if !ok {
+		t.Fatal("session_search tool not registered")
+	}
+	search := func(channel string, peer *routing.RoutePeer, current string) string {
+		ctx := withRequester(context.Background(), channel, peer)
+		ctx = providers.WithSessionKey(ctx, current)
+		result := tool.Execute(ctx, map[string]any{"query": "falcon", "limit": 20.0})
+		if result.IsError {
+			t.Fatalf("session_search: %s", result.ForLLM)
+		}
+		return result.ForLLM
+	}
+
+	got := search("telegram", &routing.RoutePeer{Kind: "direct", ID: "alice"}, "agent:main:main")
+	if !strings.Contains(got, aliceDM) || strings.Contains(got, bobDM) || strings.Contains(got, group) {
+		t.Errorf("alice's DM search returned:\n%s", got)
+	}
+
+	got = search("telegram", &routing.RoutePeer{Kind: "group", ID: "team"}, group)
+	if !strings.Contains(got, group) || strings.Contains(got, aliceDM) || strings.Contains(got, bobDM) {
+		t.Errorf("group search returned:\n%s", got)
+	}
+
+	got = search("cli", nil, "agent:main:cli")
+	for _, key := range []string{aliceDM, bobDM, group} {
+		if !strings.Contains(got, key) {
+			t.Errorf("operator search misses %s:\n%s", key, got)
+		}
+	}
+
+	got = search("", nil, "heartbeat")
+	if !strings.Contains(got, "No past messages") {
+		t.Errorf("turn without a requester should only search its own session:\n%s", got)
+	}
+}
@@ -0,0 +1,119 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/routing"
+	"github.com/tinyland-inc/tinyclaw/pkg/session"
+)
+
+// ErrSummaryInProgress is returned when a session is already being summarized.
+var ErrSummaryInProgress = errors.New("summarization already in progress")
+
+// SessionInfo describes a session owned by one agent.
+type SessionInfo struct {
+	AgentID string `json:"agent_id"`
+	session.SessionInfo
+}
+
+// ListSessions returns the sessions of every registered agent, most
+// recently updated first.
+func (al *AgentLoop) ListSessions() []SessionInfo {
+	var infos []SessionInfo
+	for _, id := range al.registry.ListAgentIDs() {
+		agent, ok := al.registry.GetAgent(id)
+		if !ok {
+			continue
+		}
+		for _, info := range agent.Sessions.List() {
+			infos = append(infos, SessionInfo{AgentID: agent.ID, SessionInfo: info})
+		}
+	}
+	slices.SortFunc(infos, func(a, b SessionInfo) int {
+		return b.Updated.Compare(a.Updated)
+	})
+	return infos
+}
+
+// FindSession looks up a session by key. With an empty agentID the agent
+// encoded in an agent-scoped key is tried first, then every other agent.
+// It returns the ID of the agent that owns the session.
+func (al *AgentLoop) FindSession(agentID, key string) (session.Session, string, bool) {
+	agent, ok := al.sessionOwner(agentID, key)
+	if !ok {
+		return session.Session{}, "", false
+	}
+	sess, ok := agent.Sessions.Get(key)
+	return sess, agent.ID, ok
+}
+
+// FindSessionPage is FindSession holding only up to limit messages starting
+// at offset. It also returns the session's total message count.
+func (al *AgentLoop) FindSessionPage(agentID, key string, offset, limit int) (session.Session, int, string, bool) {
+	agent, ok := al.sessionOwner(agentID, key)
+	if !ok {
+		return session.Session{}, 0, "", false
+	}
+	sess, total, ok := agent.Sessions.GetPage(key, offset, limit)
+	return sess, total, agent.ID, ok
+}
+
+// DeleteSession removes a session from memory and disk. It reports whether
+// the session existed.
+func (al *AgentLoop) DeleteSession(agentID, key string) (bool, error) {
+	agent, ok := al.sessionOwner(agentID, key)
+	if !ok {
+		return false, nil
+	}
+	return agent.Sessions.Delete(key)
+}
+
+// SummarizeSession summarizes a session immediately, regardless of the
+// usual thresholds, and returns the resulting summary.
+func (al *AgentLoop) SummarizeSession(agentID, key string) (string, error) {
+	agent, ok := al.sessionOwner(agentID, key)
+	if !ok {
+		return "", fmt.Errorf("session %q not found", key)
+	}
+
+	summarizeKey := agent.ID + ":" + key
+	if _, loading := al.summarizing.LoadOrStore(summarizeKey, true); loading {
+		return "", ErrSummaryInProgress
+	}
+	defer al.summarizing.Delete(summarizeKey)
+
+	al.summarizeSession(agent, key)
+	return agent.Sessions.GetSummary(key), nil
+}
+
+// sessionOwner resolves the agent holding the session key.
+func (al *AgentLoop) sessionOwner(agentID, key string) (*AgentInstance, bool) {
+	has := func(agent *AgentInstance) bool {
+		return agent.Sessions.Has(key)
+	}
+
+	if agentID != "" {
+		agent, ok := al.registry.GetAgent(agentID)
+		if !ok || !has(agent) {
+			return nil, false
+		}
+		return agent, true
+	}
+
+	if parsed := routing.ParseAgentSessionKey(key); parsed != nil {
+		if agent, ok := al.registry.GetAgent(parsed.AgentID); ok && has(agent) {
+			return agent, true
+		}
+	}
+
+	ids := al.registry.ListAgentIDs()
+	slices.Sort(ids)
+	for _, id := range ids {
+		if agent, ok := al.registry.GetAgent(id); ok && has(agent) {
+			return agent, true
+		}
+	}
+	return nil, false
+}
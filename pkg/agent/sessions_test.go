@@ -0,0 +1,65 @@
+package agent
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/bus"
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+)
+
+func TestSessions_AcrossAgents(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         filepath.Join(tmpDir, "main"),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+			List: []config.AgentConfig{
+				{ID: "main", Default: true},
+				{ID: "research", Workspace: filepath.Join(tmpDir, "research")},
+			},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &simpleMockProvider{response: "ok"})
+
+	ctx := context.Background()
+	if _, err := al.ProcessDirectWithChannel(ctx, "hi", "agent:main:api:a", "api", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := al.ProcessDirectWithChannel(ctx, "hi", "agent:research:api:b", "api", "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	infos := al.ListSessions()
+	if len(infos) != 2 {
+		t.Fatalf("expected 2 sessions, got %+v", infos)
+	}
+	if infos[0].AgentID != "research" || infos[0].Channel != "api" || infos[0].MessageCount != 2 {
+		t.Errorf("unexpected newest session: %+v", infos[0])
+	}
+
+	sess, owner, ok := al.FindSession("", "agent:research:api:b")
+	if !ok || owner != "research" || len(sess.Messages) != 2 {
+		t.Errorf("FindSession = %+v, %q, %v", sess, owner, ok)
+	}
+	if _, _, ok := al.FindSession("main", "agent:research:api:b"); ok {
+		t.Error("FindSession should not find a session under the wrong agent")
+	}
+
+	existed, err := al.DeleteSession("", "agent:main:api:a")
+	if err != nil || !existed {
+		t.Fatalf("DeleteSession: existed=%v err=%v", existed, err)
+	}
+	if got := len(al.ListSessions()); got != 1 {
+		t.Errorf("expected 1 session after delete, got %d", got)
+	}
+
+	if _, err := al.SummarizeSession("", "missing"); err == nil {
+		t.Error("expected error summarizing an unknown session")
+	}
+}
@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+)
+
+// TaskHooks restrict and meter agent runs started by a program rather than
+// a chat, such as campaign steps. Every field is optional.
+type TaskHooks struct {
+	// Tools, when not nil, are the only tools offered to the model and
+	// allowed to run; an empty list offers none.
+	Tools []string
+	// BeforeIteration is called before every LLM call. An error ends the
+	// run with that error.
+	BeforeIteration func() error
+	// BeforeToolCall is called before every tool call the model makes. An
+	// error is returned to the model as the tool's result instead of
+	// running the tool.
+	BeforeToolCall func(name string) error
+	// OnUsage receives the token usage and cost of every LLM call. costUSD
+	// is 0 for models without a known price.
+	OnUsage func(model string, usage providers.UsageInfo, costUSD float64)
+}
+
+type taskHooksKey struct{}
+
+// WithTaskHooks returns a context whose agent runs apply h.
+func WithTaskHooks(ctx context.Context, h *TaskHooks) context.Context {
+	return context.WithValue(ctx, taskHooksKey{}, h)
+}
+
+func taskHooksFrom(ctx context.Context) *TaskHooks {
+	h, _ := ctx.Value(taskHooksKey{}).(*TaskHooks)
+	return h
+}
+
+// filterTools keeps the tool definitions the hooks allow.
+func (h *TaskHooks) filterTools(defs []providers.ToolDefinition) []providers.ToolDefinition {
+	if h == nil || h.Tools == nil {
+		return defs
+	}
+	kept := make([]providers.ToolDefinition, 0, len(h.Tools))
+	for _, d := range defs {
+		if slices.Contains(h.Tools, d.Function.Name) {
+			kept = append(kept, d)
+		}
+	}
+	return kept
+}
+
+// allowTool returns why the tool must not run, or nil.
+func (h *TaskHooks) allowTool(name string) error {
+	if h == nil {
+		return nil
+	}
+	if h.Tools != nil && !slices.Contains(h.Tools, name) {
+		return fmt.Errorf("tool %q is not available for this task", name)
+	}
+	if h.BeforeToolCall != nil {
+		return h.BeforeToolCall(name)
+	}
+	return nil
+}
+
+func (h *TaskHooks) beforeIteration() error {
+	if h == nil || h.BeforeIteration == nil {
+		return nil
+	}
+	return h.BeforeIteration()
+}
+
+func (h *TaskHooks) usage(model string, resp *providers.LLMResponse, costUSD float64) {
+	if h == nil || h.OnUsage == nil || resp == nil || resp.Usage == nil {
+		return
+	}
+	h.OnUsage(model, *resp.Usage, costUSD)
+}
+
+// RunTask runs prompt as a one-off task on agent agentID, recording the
+// exchange in session sessionKey without loading its earlier history. Use
+// WithTaskHooks on ctx to restrict and meter the run.
+func (al *AgentLoop) RunTask(ctx context.Context, agentID, sessionKey, prompt string) (string, error) {
+	agent, ok := al.registry.GetAgent(agentID)
+	if !ok {
+		return "", fmt.Errorf("agent %q not found", agentID)
+	}
+	return al.runAgentLoop(ctx, agent, processOptions{
+		SessionKey:      sessionKey,
+		SenderID:        "campaign",
+		Channel:         "campaign",
+		ChatID:          sessionKey,
+		UserMessage:     prompt,
+		DefaultResponse: "I've completed processing but have no response to give.",
+		NoHistory:       true,
+	})
+}
@@ -0,0 +1,114 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+)
+
+// taskProvider asks for write_file and read_file, then answers with the
+// tool results it got back.
+type taskProvider struct {
+	offered [][]string
+}
+
+func (p *taskProvider) Chat(
+	_ context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	_ string,
+	_ map[string]any,
+) (*providers.LLMResponse, error) {
+	var names []string
+	for _, d := range tools {
+		names = append(names, d.Function.Name)
+	}
+	p.offered = append(p.offered, names)
+
+	usage := &providers.UsageInfo{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120}
+	if len(p.offered) == 1 {
+		return &providers.LLMResponse{
+			ToolCalls: []providers.ToolCall{
+				{ID: "w", Name: "write_file", Arguments: map[string]any{"path": "x.txt", "content": "x"}},
+				{ID: "r", Name: "read_file", Arguments: map[string]any{"path": "missing.txt"}},
+			},
+			Usage: usage,
+		}, nil
+	}
+	var results []string
+	for _, m := range messages {
+		if m.Role == "tool" {
+			results = append(results, m.ToolCallID+": "+m.Content)
+		}
+	}
+	return &providers.LLMResponse{Content: strings.Join(results, "\n"), Usage: usage}, nil
+}
+
+func (p *taskProvider) GetDefaultModel() string { return "gpt-4o" }
+
+func TestRunTask_AppliesHooks(t *testing.T) {
+	p := &taskProvider{}
+	al, agent := newCompactionLoop(t, p)
+
+	var (
+		iterations int
+		allowed    []string
+		tokens     int
+	)
+	hooks := &TaskHooks{
+		Tools: []string{"read_file", "write_file"},
+		BeforeIteration: func() error {
+			iterations++
+			return nil
+		},
+		BeforeToolCall: func(name string) error {
+			if name == "write_file" {
+				return errors.New("guardrail: read_only")
+			}
+			allowed = append(allowed, name)
+			return nil
+		},
+		OnUsage: func(_ string, u providers.UsageInfo, _ float64) { tokens += u.TotalTokens },
+	}
+	out, err := al.RunTask(WithTaskHooks(context.Background(), hooks), agent.ID, "campaign:test:step", "go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := p.offered[0]; !slices.Equal(slices.Sorted(slices.Values(got)), []string{"read_file", "write_file"}) {
+		t.Errorf("offered tools = %v, want only the task's tools", got)
+	}
+	if !strings.Contains(out, "w: guardrail: read_only") {
+		t.Errorf("denied tool result missing from %q", out)
+	}
+	if !slices.Equal(allowed, []string{"read_file"}) {
+		t.Errorf("allowed tools = %v", allowed)
+	}
+	if iterations != 2 || tokens != 240 {
+		t.Errorf("iterations = %d, tokens = %d, want 2 and 240", iterations, tokens)
+	}
+}
+
+func TestRunTask_StopsOnHookError(t *testing.T) {
+	p := &taskProvider{}
+	al, agent := newCompactionLoop(t, p)
+	hooks := &TaskHooks{BeforeIteration: func() error { return errors.New("guardrail: budget_exhausted") }}
+
+	_, err := al.RunTask(WithTaskHooks(context.Background(), hooks), agent.ID, "campaign:test:step", "go")
+	if err == nil || !strings.Contains(err.Error(), "budget_exhausted") {
+		t.Errorf("RunTask error = %v", err)
+	}
+	if len(p.offered) != 0 {
+		t.Errorf("provider was called %d times after the hook failed", len(p.offered))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := al.RunTask(ctx, agent.ID, "campaign:test:step", "go"); !errors.Is(err, context.Canceled) {
+		t.Errorf("RunTask on a canceled context = %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package aperture
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/tracing"
+)
+
+// pendingTTL bounds how long a request ID waits for its usage event.
+// Aperture normally reports within seconds; entries older than this are
+// dropped so requests without a webhook do not accumulate.
+const pendingTTL = time.Hour
+
+// Attribution identifies who an LLM request was made for.
+type Attribution struct {
+	AgentID    string `json:"agent_id,omitempty"`
+	SessionKey string `json:"session_key,omitempty"`
+	CampaignID string `json:"campaign_id,omitempty"`
+}
+
+type attributionKey struct{}
+
+// WithAttribution returns a context carrying a. Empty fields keep the value
+// from any attribution already on ctx, so a campaign runner and the agent
+// loop can each fill in their part.
+func WithAttribution(ctx context.Context, a Attribution) context.Context {
+	prev := AttributionFromContext(ctx)
+	if a.AgentID == "" {
+		a.AgentID = prev.AgentID
+	}
+	if a.SessionKey == "" {
+		a.SessionKey = prev.SessionKey
+	}
+	if a.CampaignID == "" {
+		a.CampaignID = prev.CampaignID
+	}
+	return context.WithValue(ctx, attributionKey{}, a)
+}
+
+// AttributionFromContext returns the attribution set on ctx, if any.
+func AttributionFromContext(ctx context.Context) Attribution {
+	a, _ := ctx.Value(attributionKey{}).(Attribution)
+	return a
+}
+
+type pendingRequest struct {
+	attr    Attribution
+	created time.Time
+}
+
+// newRequestID returns a unique request ID. Traced requests are prefixed
+// with the trace ID so usage events can also be joined with the trace.
+// SDK retries reuse the request context, so the suffix is always random.
+func newRequestID(ctx context.Context) string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	suffix := hex.EncodeToString(b[:])
+	if sc := tracing.SpanContextFromContext(ctx); sc.IsValid() {
+		return sc.TraceID.String() + "-" + suffix
+	}
+	return "tc-" + suffix
+}
+
+// track remembers the attribution of an outgoing request until its usage
+// event arrives.
+func (c *Client) track(requestID string, a Attribution) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending == nil {
+		c.pending = make(map[string]pendingRequest)
+	}
+	if now.Sub(c.lastPrune) > time.Minute {
+		for id, p := range c.pending {
+			if now.Sub(p.created) > pendingTTL {
+				delete(c.pending, id)
+			}
+		}
+		c.lastPrune = now
+	}
+	c.pending[requestID] = pendingRequest{attr: a, created: now}
+}
+
+// TakeAttribution returns and forgets the attribution recorded for an
+// outgoing request ID.
+func (c *Client) TakeAttribution(requestID string) (Attribution, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.pending[requestID]
+	if ok {
+		delete(c.pending, requestID)
+	}
+	return p.attr, ok
+}
@@ -9,14 +9,18 @@ package aperture
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"maps"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
 	"github.com/tinyland-inc/tinyclaw/pkg/logger"
+	"github.com/tinyland-inc/tinyclaw/pkg/tracing"
 )
 
 // Config holds Aperture proxy configuration.
@@ -46,6 +50,11 @@ type Client struct {
 	httpClient   *http.Client
 	eventHandler func(UsageEvent)
 	mu           sync.RWMutex
+
+	// pending maps outgoing request IDs to the agent, session and campaign
+	// that made them, until the matching usage event arrives.
+	pending   map[string]pendingRequest
+	lastPrune time.Time
 }
 
 // NewClient creates a new Aperture client.
@@ -70,12 +79,21 @@ func NewClient(cfg Config) (*Client, error) {
 // Aperture. This can be used as the Transport for any http.Client to
 // transparently proxy LLM API calls.
 func (c *Client) ProxyTransport() http.RoundTripper {
+	return c.WrapTransport(http.DefaultTransport)
+}
+
+// WrapTransport returns inner routed through Aperture, or inner unchanged
+// when Aperture is disabled. Every request gets a generated X-Request-Id,
+// and the attribution on its context is kept until the usage event for that
+// ID arrives (see TakeAttribution).
+func (c *Client) WrapTransport(inner http.RoundTripper) http.RoundTripper {
 	if !c.config.Enabled || c.proxyURL == nil {
-		return http.DefaultTransport
+		return inner
 	}
 	return &apertureTransport{
+		client:   c,
 		proxyURL: c.proxyURL,
-		inner:    http.DefaultTransport,
+		inner:    inner,
 	}
 }
 
@@ -137,6 +155,18 @@ func (c *Client) WebhookHandler() http.Handler {
 	})
 }
 
+// DefaultWebhookPath is where the gateway mounts WebhookHandler when the
+// configured webhook URL has no path.
+const DefaultWebhookPath = "/webhook/aperture"
+
+// WebhookPath returns the path component of the configured webhook URL.
+func (c *Client) WebhookPath() string {
+	if u, err := url.Parse(c.config.WebhookURL); err == nil && u.Path != "" && u.Path != "/" {
+		return u.Path
+	}
+	return DefaultWebhookPath
+}
+
 // IsEnabled returns whether Aperture integration is active.
 func (c *Client) IsEnabled() bool {
 	return c.config.Enabled
@@ -144,6 +174,7 @@ func (c *Client) IsEnabled() bool {
 
 // apertureTransport is an http.RoundTripper that proxies requests through Aperture.
 type apertureTransport struct {
+	client   *Client
 	proxyURL *url.URL
 	inner    http.RoundTripper
 }
@@ -155,6 +186,19 @@ func (t *apertureTransport) RoundTrip(req *http.Request) (*http.Response, error)
 	// Set the original URL as a header for Aperture to route
 	proxyReq.Header.Set("X-Aperture-Target", req.URL.String())
 
+	// Tag the request so its usage event can be attributed to the agent,
+	// session and campaign that made it.
+	requestID := proxyReq.Header.Get("X-Request-Id")
+	if requestID == "" {
+		requestID = newRequestID(req.Context())
+		proxyReq.Header.Set("X-Request-Id", requestID)
+	}
+	t.client.track(requestID, AttributionFromContext(req.Context()))
+
+	if sc := tracing.SpanContextFromContext(req.Context()); sc.IsValid() {
+		proxyReq.Header.Set("Traceparent", sc.Traceparent())
+	}
+
 	// Rewrite the URL to point to Aperture
 	proxyReq.URL.Scheme = t.proxyURL.Scheme
 	proxyReq.URL.Host = t.proxyURL.Host
@@ -165,52 +209,119 @@ func (t *apertureTransport) RoundTrip(req *http.Request) (*http.Response, error)
 
 // MeterStore provides per-agent, per-session metrics aggregation from
 // Aperture usage events. This mirrors the remote-juggler gateway/metering.go
-// pattern.
+// pattern. A store created with NewPersistentMeterStore is saved to disk
+// after every event.
 type MeterStore struct {
-	mu     sync.RWMutex
-	meters map[string]*AgentMeter
+	mu        sync.RWMutex
+	meters    map[string]*AgentMeter
+	campaigns map[string]*CampaignMeter
+	path      string
 }
 
 // AgentMeter tracks per-agent usage metrics.
 type AgentMeter struct {
-	AgentID      string
-	TotalCalls   int64
-	TotalTokens  int64
-	TotalLatency float64
-	Errors       int64
-	Sessions     map[string]*SessionMeter
+	AgentID      string                   `json:"agent_id"`
+	TotalCalls   int64                    `json:"total_calls"`
+	TotalTokens  int64                    `json:"total_tokens"`
+	TotalLatency float64                  `json:"total_latency_ms"`
+	Errors       int64                    `json:"errors"`
+	Sessions     map[string]*SessionMeter `json:"sessions"`
 }
 
 // SessionMeter tracks per-session usage metrics.
 type SessionMeter struct {
-	SessionKey   string
-	Calls        int64
-	InputTokens  int64
-	OutputTokens int64
-	ToolCalls    int64
-	Duration     float64
-	LastActivity time.Time
+	SessionKey   string    `json:"session_key"`
+	Calls        int64     `json:"calls"`
+	InputTokens  int64     `json:"input_tokens"`
+	OutputTokens int64     `json:"output_tokens"`
+	ToolCalls    int64     `json:"tool_calls"`
+	Duration     float64   `json:"duration_ms"`
+	LastActivity time.Time `json:"last_activity"`
+}
+
+// CampaignMeter tracks usage of LLM calls made by one campaign.
+type CampaignMeter struct {
+	CampaignID   string    `json:"campaign_id"`
+	Calls        int64     `json:"calls"`
+	InputTokens  int64     `json:"input_tokens"`
+	OutputTokens int64     `json:"output_tokens"`
+	Errors       int64     `json:"errors"`
+	Duration     float64   `json:"duration_ms"`
+	LastActivity time.Time `json:"last_activity"`
 }
 
+// MeterSnapshot is a point-in-time copy of a MeterStore. It is also the
+// on-disk format of a persistent store.
+type MeterSnapshot struct {
+	Agents    map[string]*AgentMeter    `json:"agents"`
+	Campaigns map[string]*CampaignMeter `json:"campaigns,omitempty"`
+}
+
+// UnattributedAgent is the agent ID recorded for usage events whose request
+// ID was not issued by this process.
+const UnattributedAgent = "unknown"
+
 // NewMeterStore creates a new metering store.
 func NewMeterStore() *MeterStore {
 	return &MeterStore{
-		meters: make(map[string]*AgentMeter),
+		meters:    make(map[string]*AgentMeter),
+		campaigns: make(map[string]*CampaignMeter),
 	}
 }
 
+// NewPersistentMeterStore creates a metering store saved at path, loading
+// any meters already there.
+func NewPersistentMeterStore(path string) (*MeterStore, error) {
+	s := NewMeterStore()
+	s.path = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read meter store: %w", err)
+	}
+	var snap MeterSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("failed to parse meter store %s: %w", path, err)
+	}
+	for id, m := range snap.Agents {
+		if m.Sessions == nil {
+			m.Sessions = make(map[string]*SessionMeter)
+		}
+		s.meters[id] = m
+	}
+	maps.Copy(s.campaigns, snap.Campaigns)
+	return s, nil
+}
+
 // Record adds a usage event to the meter store.
 func (s *MeterStore) Record(agentID, sessionKey string, event UsageEvent) {
+	s.RecordAttributed(Attribution{AgentID: agentID, SessionKey: sessionKey}, event)
+}
+
+// RecordAttributed adds a usage event under its agent and session, and
+// under its campaign when it has one. Events without an agent are recorded
+// under UnattributedAgent.
+func (s *MeterStore) RecordAttributed(a Attribution, event UsageEvent) {
+	if a.AgentID == "" {
+		a.AgentID = UnattributedAgent
+	}
+	if a.SessionKey == "" {
+		a.SessionKey = "default"
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	meter, ok := s.meters[agentID]
+	meter, ok := s.meters[a.AgentID]
 	if !ok {
 		meter = &AgentMeter{
-			AgentID:  agentID,
+			AgentID:  a.AgentID,
 			Sessions: make(map[string]*SessionMeter),
 		}
-		s.meters[agentID] = meter
+		s.meters[a.AgentID] = meter
 	}
 
 	meter.TotalCalls++
@@ -220,10 +331,10 @@ func (s *MeterStore) Record(agentID, sessionKey string, event UsageEvent) {
 		meter.Errors++
 	}
 
-	sess, ok := meter.Sessions[sessionKey]
+	sess, ok := meter.Sessions[a.SessionKey]
 	if !ok {
-		sess = &SessionMeter{SessionKey: sessionKey}
-		meter.Sessions[sessionKey] = sess
+		sess = &SessionMeter{SessionKey: a.SessionKey}
+		meter.Sessions[a.SessionKey] = sess
 	}
 
 	sess.Calls++
@@ -231,23 +342,93 @@ func (s *MeterStore) Record(agentID, sessionKey string, event UsageEvent) {
 	sess.OutputTokens += int64(event.OutputTokens)
 	sess.Duration += event.Duration
 	sess.LastActivity = event.Timestamp
+
+	if a.CampaignID != "" {
+		camp, ok := s.campaigns[a.CampaignID]
+		if !ok {
+			camp = &CampaignMeter{CampaignID: a.CampaignID}
+			s.campaigns[a.CampaignID] = camp
+		}
+		camp.Calls++
+		camp.InputTokens += int64(event.InputTokens)
+		camp.OutputTokens += int64(event.OutputTokens)
+		camp.Duration += event.Duration
+		camp.LastActivity = event.Timestamp
+		if event.Status >= 400 {
+			camp.Errors++
+		}
+	}
+
+	if err := s.save(); err != nil {
+		logger.WarnCF("aperture", "Failed to save meter store", map[string]any{"error": err.Error()})
+	}
 }
 
-// GetAgentMeter returns metrics for a specific agent.
+// GetAgentMeter returns a copy of the metrics for a specific agent.
 func (s *MeterStore) GetAgentMeter(agentID string) (*AgentMeter, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	m, ok := s.meters[agentID]
-	return m, ok
+	if !ok {
+		return nil, false
+	}
+	return m.clone(), true
 }
 
 // GetAllMeters returns a snapshot of all agent meters.
 func (s *MeterStore) GetAllMeters() map[string]*AgentMeter {
+	return s.Snapshot().Agents
+}
+
+// Snapshot returns a deep copy of all agent and campaign meters.
+func (s *MeterStore) Snapshot() MeterSnapshot {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	result := make(map[string]*AgentMeter, len(s.meters))
-	maps.Copy(result, s.meters)
-	return result
+	snap := MeterSnapshot{
+		Agents:    make(map[string]*AgentMeter, len(s.meters)),
+		Campaigns: make(map[string]*CampaignMeter, len(s.campaigns)),
+	}
+	for id, m := range s.meters {
+		snap.Agents[id] = m.clone()
+	}
+	for id, c := range s.campaigns {
+		cp := *c
+		snap.Campaigns[id] = &cp
+	}
+	return snap
+}
+
+func (m *AgentMeter) clone() *AgentMeter {
+	cp := *m
+	cp.Sessions = make(map[string]*SessionMeter, len(m.Sessions))
+	for k, v := range m.Sessions {
+		sess := *v
+		cp.Sessions[k] = &sess
+	}
+	return &cp
+}
+
+// save writes the store atomically. Must be called with s.mu held.
+func (s *MeterStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(MeterSnapshot{Agents: s.meters, Campaigns: s.campaigns}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal meter store: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create meter store directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write meter store: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to rename meter store: %w", err)
+	}
+	return nil
 }
 
 // HandleEvent processes an Aperture usage event into the meter store.
@@ -257,3 +438,17 @@ func (s *MeterStore) HandleEvent(ctx context.Context, agentID, sessionKey string
 		s.Record(agentID, sessionKey, event)
 	}
 }
+
+// HandleAttributedEvent returns an event handler that records each event
+// under the attribution c kept for its request ID.
+func (s *MeterStore) HandleAttributedEvent(c *Client) func(UsageEvent) {
+	return func(event UsageEvent) {
+		a, ok := c.TakeAttribution(event.RequestID)
+		if !ok {
+			logger.DebugCF("aperture", "Usage event for unknown request ID", map[string]any{
+				"request_id": event.RequestID,
+			})
+		}
+		s.RecordAttributed(a, event)
+	}
+}
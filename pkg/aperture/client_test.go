@@ -1,12 +1,16 @@
 package aperture
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/tracing"
 )
 
 func TestNewClient_Disabled(t *testing.T) {
@@ -67,6 +71,44 @@ func TestProxyTransport_Enabled(t *testing.T) {
 	}
 }
 
+func TestProxyTransport_PropagatesTraceID(t *testing.T) {
+	var requestID, target string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID = r.Header.Get("X-Request-Id")
+		target = r.Header.Get("X-Aperture-Target")
+	}))
+	defer srv.Close()
+
+	c, _ := NewClient(Config{Enabled: true, ProxyURL: srv.URL})
+	tr := tracing.NewTracer(tracing.NewOTLPExporter(tracing.OTLPConfig{Endpoint: srv.URL}))
+	defer tr.Shutdown(context.Background())
+	ctx, span := tr.Start(context.Background(), "llm.chat", nil)
+	defer span.End()
+	ctx = WithAttribution(ctx, Attribution{CampaignID: "weekly-audit"})
+	ctx = WithAttribution(ctx, Attribution{AgentID: "research", SessionKey: "agent:research:main"})
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.example.com/v1/chat", nil)
+	resp, err := c.ProxyTransport().RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip: %v", err)
+	}
+	resp.Body.Close()
+
+	if !strings.HasPrefix(requestID, span.SpanContext().TraceID.String()+"-") {
+		t.Errorf("X-Request-Id = %q, want trace ID %s prefix", requestID, span.SpanContext().TraceID)
+	}
+	want := Attribution{AgentID: "research", SessionKey: "agent:research:main", CampaignID: "weekly-audit"}
+	if got, ok := c.TakeAttribution(requestID); !ok || got != want {
+		t.Errorf("TakeAttribution = %+v, %v; want %+v", got, ok, want)
+	}
+	if _, ok := c.TakeAttribution(requestID); ok {
+		t.Error("attribution should be taken only once")
+	}
+	if target != "https://api.example.com/v1/chat" {
+		t.Errorf("X-Aperture-Target = %q", target)
+	}
+}
+
 func TestWebhookHandler_MethodNotAllowed(t *testing.T) {
 	c, _ := NewClient(Config{Enabled: true})
 	handler := c.WebhookHandler()
@@ -214,3 +256,54 @@ func TestMeterStore_MultiAgent(t *testing.T) {
 		t.Errorf("expected 2 agents, got %d", len(meters))
 	}
 }
+
+func TestProxyTransport_UniqueRequestIDs(t *testing.T) {
+	var ids []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, r.Header.Get("X-Request-Id"))
+	}))
+	defer srv.Close()
+
+	c, _ := NewClient(Config{Enabled: true, ProxyURL: srv.URL})
+	tr := c.ProxyTransport()
+	for range 2 {
+		req, _ := http.NewRequest(http.MethodPost, "https://api.example.com/v1/chat", nil)
+		resp, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip: %v", err)
+		}
+		resp.Body.Close()
+	}
+	if len(ids) != 2 || ids[0] == "" || ids[0] == ids[1] {
+		t.Errorf("request IDs = %q, want two distinct IDs", ids)
+	}
+}
+
+func TestMeterStore_AttributedEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metering.json")
+	store, err := NewPersistentMeterStore(path)
+	if err != nil {
+		t.Fatalf("NewPersistentMeterStore: %v", err)
+	}
+
+	c, _ := NewClient(Config{Enabled: true})
+	c.track("req-1", Attribution{AgentID: "main", SessionKey: "s1", CampaignID: "audit"})
+	handle := store.HandleAttributedEvent(c)
+	handle(UsageEvent{RequestID: "req-1", InputTokens: 100, OutputTokens: 20, TotalTokens: 120})
+	handle(UsageEvent{RequestID: "req-unknown", TotalTokens: 5})
+
+	reloaded, err := NewPersistentMeterStore(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	snap := reloaded.Snapshot()
+	if m := snap.Agents["main"]; m == nil || m.TotalTokens != 120 || m.Sessions["s1"].InputTokens != 100 {
+		t.Errorf("main meter = %+v", m)
+	}
+	if m := snap.Agents[UnattributedAgent]; m == nil || m.TotalCalls != 1 {
+		t.Errorf("unattributed meter = %+v", m)
+	}
+	if camp := snap.Campaigns["audit"]; camp == nil || camp.Calls != 1 || camp.OutputTokens != 20 {
+		t.Errorf("campaign meter = %+v", camp)
+	}
+}
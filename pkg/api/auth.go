@@ -0,0 +1,348 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+	"github.com/tinyland-inc/tinyclaw/pkg/logger"
+	"github.com/tinyland-inc/tinyclaw/pkg/routing"
+)
+
+// apiKeyHashPrefix marks the hash scheme used for configured API keys.
+const apiKeyHashPrefix = "sha256:"
+
+// ErrUnauthenticated is returned when a request carries no valid identity.
+var ErrUnauthenticated = errors.New("missing or invalid credentials")
+
+// Principal is the authenticated caller of an API request.
+type Principal struct {
+	Name      string   // API key name or tailnet login
+	Kind      string   // "api_key" or "tailnet"
+	Role      string   // configured role name
+	Agents    []string // allowed agent IDs; empty allows all
+	Endpoints []string // allowed path prefixes; empty allows all
+	RateLimit int      // requests per minute; 0 is unlimited
+}
+
+// AllowsAgent reports whether the principal may address agentID.
+func (p *Principal) AllowsAgent(agentID string) bool {
+	if p == nil || len(p.Agents) == 0 {
+		return true
+	}
+	return slices.Contains(p.Agents, routing.NormalizeAgentID(agentID))
+}
+
+// Unrestricted reports whether the principal may address every agent and
+// endpoint. A nil principal, as when the API runs without authentication,
+// is unrestricted.
+func (p *Principal) Unrestricted() bool {
+	return p == nil || len(p.Agents) == 0 && len(p.Endpoints) == 0
+}
+
+// AllowsPath reports whether the principal may call the given URL path.
+func (p *Principal) AllowsPath(path string) bool {
+	if p == nil || len(p.Endpoints) == 0 {
+		return true
+	}
+	for _, prefix := range p.Endpoints {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// IdentityResolver maps a remote address to a tailnet login name.
+// The tailscale package's tsnet Server implements it.
+type IdentityResolver interface {
+	WhoIs(ctx context.Context, remoteAddr string) (string, error)
+}
+
+type apiKey struct {
+	name string
+	hash []byte
+	role string
+}
+
+// Authenticator validates API requests against configured API keys and,
+// optionally, Tailscale identities.
+type Authenticator struct {
+	keys         []apiKey
+	tailnetUsers map[string]string
+	roles        map[string]config.GatewayRole
+	whois        IdentityResolver
+	limiter      *rateLimiter
+}
+
+// NewAuthenticator builds an Authenticator from gateway auth config.
+// Malformed hashes and references to undefined roles are rejected so a
+// typo cannot silently grant or deny access.
+func NewAuthenticator(cfg config.GatewayAuthConfig) (*Authenticator, error) {
+	a := &Authenticator{
+		tailnetUsers: cfg.TailnetUsers,
+		roles:        cfg.Roles,
+		limiter:      newRateLimiter(),
+	}
+
+	for _, k := range cfg.APIKeys {
+		if !strings.HasPrefix(k.Hash, apiKeyHashPrefix) {
+			return nil, fmt.Errorf("api key %q: hash must start with %q", k.Name, apiKeyHashPrefix)
+		}
+		sum, err := hex.DecodeString(strings.TrimPrefix(k.Hash, apiKeyHashPrefix))
+		if err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("api key %q: invalid sha256 hash", k.Name)
+		}
+		if _, ok := cfg.Roles[k.Role]; !ok {
+			return nil, fmt.Errorf("api key %q: unknown role %q", k.Name, k.Role)
+		}
+		a.keys = append(a.keys, apiKey{name: k.Name, hash: sum, role: k.Role})
+	}
+	for login, role := range cfg.TailnetUsers {
+		if _, ok := cfg.Roles[role]; !ok {
+			return nil, fmt.Errorf("tailnet user %q: unknown role %q", login, role)
+		}
+	}
+
+	return a, nil
+}
+
+// SetIdentityResolver enables Tailscale identity auth for requests that do
+// not present an API key.
+func (a *Authenticator) SetIdentityResolver(r IdentityResolver) {
+	a.whois = r
+}
+
+// Authenticate identifies the caller of r.
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if key := requestAPIKey(r); key != "" {
+		sum := sha256.Sum256([]byte(key))
+		for _, k := range a.keys {
+			if subtle.ConstantTimeCompare(sum[:], k.hash) == 1 {
+				return a.principal(k.name, "api_key", k.role), nil
+			}
+		}
+		return nil, ErrUnauthenticated
+	}
+
+	if a.whois != nil && len(a.tailnetUsers) > 0 {
+		login, err := a.whois.WhoIs(r.Context(), r.RemoteAddr)
+		if err != nil {
+			logger.WarnCF("api", "Tailscale WhoIs failed", map[string]any{
+				"remote": r.RemoteAddr,
+				"error":  err.Error(),
+			})
+			return nil, ErrUnauthenticated
+		}
+		if role, ok := a.tailnetUsers[login]; ok {
+			return a.principal(login, "tailnet", role), nil
+		}
+	}
+
+	return nil, ErrUnauthenticated
+}
+
+// Allow applies the principal's rate limit. When the request is rejected
+// it returns how long the caller should wait.
+func (a *Authenticator) Allow(p *Principal) (bool, time.Duration) {
+	if p == nil || p.RateLimit <= 0 {
+		return true, 0
+	}
+	return a.limiter.allow(p.Kind+":"+p.Name, p.RateLimit, time.Now())
+}
+
+func (a *Authenticator) principal(name, kind, role string) *Principal {
+	r := a.roles[role]
+	return &Principal{
+		Name:      name,
+		Kind:      kind,
+		Role:      role,
+		Agents:    r.Agents,
+		Endpoints: r.Endpoints,
+		RateLimit: r.RateLimit,
+	}
+}
+
+func requestAPIKey(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if token, ok := strings.CutPrefix(auth, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
+
+// HashAPIKey returns the config representation of a raw API key.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return apiKeyHashPrefix + hex.EncodeToString(sum[:])
+}
+
+// GenerateAPIKey returns a new random API key.
+func GenerateAPIKey() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate api key: %w", err)
+	}
+	return "tc_" + hex.EncodeToString(buf), nil
+}
+
+// rateLimiter is a per-principal token bucket refilled continuously at
+// limit/minute with a burst of limit.
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: make(map[string]*tokenBucket)}
+}
+
+func (l *rateLimiter) allow(key string, perMinute int, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	capacity := float64(perMinute)
+	rate := capacity / 60 // tokens per second
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: capacity, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	return false, wait
+}
+
+type principalKey struct{}
+
+// PrincipalFromContext returns the authenticated caller, or nil when the
+// API runs without authentication.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// statusRecorder captures the response status for the audit log.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// guard authenticates, authorizes and rate-limits a request, then writes
+// one audit log line for it.
+func (h *Handlers) guard(next http.HandlerFunc) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		var principal *Principal
+		if h.auth != nil {
+			principal = h.serveAuth(rec, r)
+		}
+		if h.auth == nil || principal != nil {
+			next(rec, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+		}
+
+		fields := map[string]any{
+			"method":      r.Method,
+			"path":        r.URL.Path,
+			"status":      rec.status,
+			"remote":      r.RemoteAddr,
+			"duration_ms": time.Since(start).Milliseconds(),
+		}
+		if principal != nil {
+			fields["principal"] = principal.Name
+			fields["principal_kind"] = principal.Kind
+			fields["role"] = principal.Role
+		}
+		logger.InfoCF("audit", "API request", fields)
+	}
+}
+
+// serveAuth returns the request's principal, or writes an error response
+// and returns nil when the request must not proceed.
+func (h *Handlers) serveAuth(w http.ResponseWriter, r *http.Request) *Principal {
+	principal, err := h.auth.Authenticate(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="tinyclaw"`)
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+		return nil
+	}
+	if !principal.AllowsPath(r.URL.Path) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "endpoint not permitted for this credential"})
+		return nil
+	}
+	if ok, wait := h.auth.Allow(principal); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate limit exceeded"})
+		return nil
+	}
+	return principal
+}
+
+// Protect wraps a handler served outside the API routes, such as the MCP
+// server, so that every request must authenticate as a principal allowed
+// to use agentID. Requests are rate-limited and audit-logged like API calls.
+func (a *Authenticator) Protect(agentID string, next http.Handler) http.Handler {
+	h := &Handlers{auth: a}
+	return http.HandlerFunc(h.guard(func(w http.ResponseWriter, r *http.Request) {
+		if !PrincipalFromContext(r.Context()).AllowsAgent(agentID) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "agent not permitted for this credential"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// allowSession reports whether the request's principal may run sessionKey
+// on channel and chatID. The agent is the one the dispatcher will route the
+// message to; without an AgentRouter, keys that are not agent-scoped are
+// attributed to the default agent.
+func (h *Handlers) allowSession(r *http.Request, sessionKey, channel, chatID string) bool {
+	if router, ok := h.dispatcher.(AgentRouter); ok {
+		return PrincipalFromContext(r.Context()).AllowsAgent(router.RouteAgentID(sessionKey, channel, chatID))
+	}
+	if parsed := routing.ParseAgentSessionKey(sessionKey); parsed != nil {
+		return PrincipalFromContext(r.Context()).AllowsAgent(parsed.AgentID)
+	}
+	agentID := routing.DefaultAgentID
+	if ids := h.agentIDs(); len(ids) > 0 {
+		agentID = ids[0]
+	}
+	return PrincipalFromContext(r.Context()).AllowsAgent(agentID)
+}
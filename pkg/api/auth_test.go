@@ -0,0 +1,279 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+	"github.com/tinyland-inc/tinyclaw/pkg/routing"
+)
+
+const (
+	adminKey    = "tc_admin"
+	researchKey = "tc_research"
+	limitedKey  = "tc_limited"
+)
+
+type stubWhoIs map[string]string
+
+func (s stubWhoIs) WhoIs(_ context.Context, remoteAddr string) (string, error) {
+	if login, ok := s[remoteAddr]; ok {
+		return login, nil
+	}
+	return "", errors.New("unknown peer")
+}
+
+func testAuthConfig() config.GatewayAuthConfig {
+	return config.GatewayAuthConfig{
+		Enabled: true,
+		APIKeys: []config.GatewayAPIKey{
+			{Name: "admin", Hash: HashAPIKey(adminKey), Role: "admin"},
+			{Name: "research-bot", Hash: HashAPIKey(researchKey), Role: "research"},
+			{Name: "limited", Hash: HashAPIKey(limitedKey), Role: "limited"},
+		},
+		TailnetUsers: map[string]string{"alice@example.com": "admin"},
+		Roles: map[string]config.GatewayRole{
+			"admin": {},
+			"research": {
+				Agents:    []string{"research"},
+				Endpoints: []string{"/api/dispatch", "/v1/"},
+			},
+			"limited": {RateLimit: 2},
+		},
+	}
+}
+
+func newAuthMux(t *testing.T, d Dispatcher) *http.ServeMux {
+	t.Helper()
+	a, err := NewAuthenticator(testAuthConfig())
+	if err != nil {
+		t.Fatalf("NewAuthenticator: %v", err)
+	}
+	a.SetIdentityResolver(stubWhoIs{"100.64.0.1:4242": "alice@example.com"})
+
+	mux := http.NewServeMux()
+	h := NewHandlers(d)
+	h.SetAuthenticator(a)
+	h.Register(mux)
+	return mux
+}
+
+func authRequest(mux *http.ServeMux, method, path, body, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuth_RejectsMissingAndInvalidKeys(t *testing.T) {
+	mux := newAuthMux(t, &mockDispatcher{result: "ok"})
+
+	for _, key := range []string{"", "tc_wrong"} {
+		rec := authRequest(mux, http.MethodGet, "/api/status", "", key)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("key %q: expected 401, got %d", key, rec.Code)
+		}
+		if rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("key %q: missing WWW-Authenticate header", key)
+		}
+	}
+
+	if rec := authRequest(mux, http.MethodGet, "/api/status", "", adminKey); rec.Code != http.StatusOK {
+		t.Errorf("admin key: expected 200, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tools", nil)
+	req.Header.Set("X-API-Key", adminKey)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("X-API-Key header: expected 200, got %d", rec.Code)
+	}
+}
+
+func TestAuth_EndpointAndAgentScopes(t *testing.T) {
+	d := &recordingDispatcher{agents: []string{"main", "research"}}
+	d.result = "ok"
+	mux := newAuthMux(t, d)
+
+	if rec := authRequest(mux, http.MethodGet, "/api/tools", "", researchKey); rec.Code != http.StatusForbidden {
+		t.Errorf("endpoint outside scope: expected 403, got %d", rec.Code)
+	}
+
+	rec := authRequest(mux, http.MethodPost, "/api/dispatch", `{"content":"hi"}`, researchKey)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("default agent outside scope: expected 403, got %d", rec.Code)
+	}
+
+	rec = authRequest(mux, http.MethodPost, "/api/dispatch",
+		`{"content":"hi","session_key":"agent:research:ci"}`, researchKey)
+	if rec.Code != http.StatusOK {
+		t.Errorf("permitted agent: expected 200, got %d", rec.Code)
+	}
+
+	rec = authRequest(mux, http.MethodPost, "/v1/chat/completions",
+		`{"model":"tinyclaw/main","messages":[{"role":"user","content":"hi"}]}`, researchKey)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("model outside scope: expected 403, got %d", rec.Code)
+	}
+}
+
+// routingDispatcher routes sessions like the agent loop: agent-scoped keys
+// go to their agent, everything else by channel binding.
+type routingDispatcher struct {
+	recordingDispatcher
+	bindings map[string]string
+}
+
+func (d *routingDispatcher) RouteAgentID(sessionKey, channel, _ string) string {
+	if parsed := routing.ParseAgentSessionKey(sessionKey); parsed != nil {
+		return parsed.AgentID
+	}
+	if agentID, ok := d.bindings[channel]; ok {
+		return agentID
+	}
+	return routing.DefaultAgentID
+}
+
+func TestAuth_SessionScopeFollowsRouting(t *testing.T) {
+	d := &routingDispatcher{bindings: map[string]string{"slack": "research", "ops": "main"}}
+	d.result = "ok"
+	mux := newAuthMux(t, d)
+
+	rec := authRequest(mux, http.MethodPost, "/api/dispatch", `{"content":"hi","channel":"slack"}`, researchKey)
+	if rec.Code != http.StatusOK {
+		t.Errorf("channel bound to a permitted agent: expected 200, got %d", rec.Code)
+	}
+	rec = authRequest(mux, http.MethodPost, "/api/dispatch",
+		`{"content":"hi","channel":"ops","session_key":"ops:42"}`, researchKey)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("channel bound to another agent: expected 403, got %d", rec.Code)
+	}
+}
+
+func TestAuth_ModelsFilteredByScope(t *testing.T) {
+	mux := newAuthMux(t, &recordingDispatcher{agents: []string{"main", "research"}})
+
+	rec := authRequest(mux, http.MethodGet, "/v1/models", "", researchKey)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if !bytes.Contains(rec.Body.Bytes(), []byte("tinyclaw/research")) ||
+		bytes.Contains(rec.Body.Bytes(), []byte("tinyclaw/main")) {
+		t.Errorf("models not filtered: %s", rec.Body.String())
+	}
+}
+
+func TestAuth_RateLimit(t *testing.T) {
+	mux := newAuthMux(t, &recordingDispatcher{agents: []string{"research"}})
+
+	for i := range 2 {
+		if rec := authRequest(mux, http.MethodGet, "/v1/models", "", limitedKey); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i, rec.Code)
+		}
+	}
+	rec := authRequest(mux, http.MethodGet, "/v1/models", "", limitedKey)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("missing Retry-After header")
+	}
+
+	// Other principals have their own bucket.
+	if rec := authRequest(mux, http.MethodGet, "/v1/models", "", adminKey); rec.Code != http.StatusOK {
+		t.Errorf("admin: expected 200, got %d", rec.Code)
+	}
+}
+
+func TestAuth_TailnetIdentity(t *testing.T) {
+	mux := newAuthMux(t, &mockDispatcher{})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/status", nil)
+	req.RemoteAddr = "100.64.0.1:4242"
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("known tailnet user: expected 200, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/status", nil)
+	req.RemoteAddr = "100.64.0.9:4242"
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("unknown tailnet peer: expected 401, got %d", rec.Code)
+	}
+}
+
+func TestAuthenticator_Protect(t *testing.T) {
+	a, err := NewAuthenticator(testAuthConfig())
+	if err != nil {
+		t.Fatalf("NewAuthenticator: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", a.Protect("main", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	for key, want := range map[string]int{
+		"":          http.StatusUnauthorized,
+		adminKey:    http.StatusNoContent,
+		researchKey: http.StatusForbidden, // neither the endpoint nor the agent
+	} {
+		if rec := authRequest(mux, http.MethodPost, "/mcp", "{}", key); rec.Code != want {
+			t.Errorf("key %q: expected %d, got %d", key, want, rec.Code)
+		}
+	}
+}
+
+func TestNewAuthenticator_ValidatesConfig(t *testing.T) {
+	cases := map[string]config.GatewayAuthConfig{
+		"bad prefix": {
+			APIKeys: []config.GatewayAPIKey{{Name: "k", Hash: "md5:abc", Role: "admin"}},
+			Roles:   map[string]config.GatewayRole{"admin": {}},
+		},
+		"bad hex": {
+			APIKeys: []config.GatewayAPIKey{{Name: "k", Hash: "sha256:zz", Role: "admin"}},
+			Roles:   map[string]config.GatewayRole{"admin": {}},
+		},
+		"unknown key role": {
+			APIKeys: []config.GatewayAPIKey{{Name: "k", Hash: HashAPIKey("x"), Role: "nope"}},
+		},
+		"unknown tailnet role": {
+			TailnetUsers: map[string]string{"bob": "nope"},
+		},
+	}
+	for name, cfg := range cases {
+		if _, err := NewAuthenticator(cfg); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestRateLimiter_Refills(t *testing.T) {
+	l := newRateLimiter()
+	now := time.Now()
+
+	if ok, _ := l.allow("k", 60, now); !ok {
+		t.Fatal("first request should pass")
+	}
+	for range 59 {
+		l.allow("k", 60, now)
+	}
+	ok, wait := l.allow("k", 60, now)
+	if ok || wait <= 0 || wait > time.Second {
+		t.Fatalf("expected rejection with ~1s wait, got ok=%v wait=%v", ok, wait)
+	}
+	if ok, _ := l.allow("k", 60, now.Add(time.Second)); !ok {
+		t.Error("bucket should refill after one second")
+	}
+}
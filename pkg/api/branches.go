@@ -0,0 +1,143 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/session"
+)
+
+// SessionBrancher is implemented by session browsers whose conversations
+// can be rewound and forked. The branch endpoints are mounted only when the
+// dispatcher implements it.
+type SessionBrancher interface {
+	SessionBranches(agentID, key string) ([]session.Branch, string, error)
+	RewindSession(agentID, key, messageID string) (int, error)
+	RewindSessionTurns(agentID, key string, turns int) (int, error)
+	CheckoutSession(agentID, key, messageID string) error
+	ForkSession(agentID, key, newKey, messageID string) error
+}
+
+type branchRequest struct {
+	MessageID string `json:"message_id"`
+	Turns     int    `json:"turns"`
+	Key       string `json:"key"` // fork target
+}
+
+func (h *Handlers) registerBranches(r RouteRegistrar, sb SessionBrancher) {
+	h.branches = sb
+	r.HandleFunc("GET /api/sessions/{key}/branches", h.guard(h.handleListBranches))
+	r.HandleFunc("POST /api/sessions/{key}/rewind", h.guard(h.handleRewind))
+	r.HandleFunc("POST /api/sessions/{key}/checkout", h.guard(h.handleCheckout))
+	r.HandleFunc("POST /api/sessions/{key}/fork", h.guard(h.handleFork))
+}
+
+func (h *Handlers) handleListBranches(w http.ResponseWriter, r *http.Request) {
+	_, agentID, ok := h.findSession(w, r)
+	if !ok {
+		return
+	}
+	branches, _, err := h.branches.SessionBranches(agentID, r.PathValue("key"))
+	if err != nil {
+		writeBranchError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"agent_id": agentID,
+		"key":      r.PathValue("key"),
+		"branches": branches,
+	})
+}
+
+// handleRewind rewinds to message_id, or by turns user turns (default 1).
+func (h *Handlers) handleRewind(w http.ResponseWriter, r *http.Request) {
+	req, agentID, ok := h.branchRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var (
+		moved int
+		err   error
+	)
+	if req.MessageID != "" {
+		moved, err = h.branches.RewindSession(agentID, r.PathValue("key"), req.MessageID)
+	} else {
+		moved, err = h.branches.RewindSessionTurns(agentID, r.PathValue("key"), max(req.Turns, 1))
+	}
+	if err != nil {
+		writeBranchError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"agent_id": agentID,
+		"key":      r.PathValue("key"),
+		"moved":    moved,
+	})
+}
+
+func (h *Handlers) handleCheckout(w http.ResponseWriter, r *http.Request) {
+	req, agentID, ok := h.branchRequest(w, r)
+	if !ok {
+		return
+	}
+	if req.MessageID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "message_id is required"})
+		return
+	}
+	if err := h.branches.CheckoutSession(agentID, r.PathValue("key"), req.MessageID); err != nil {
+		writeBranchError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"agent_id":   agentID,
+		"key":        r.PathValue("key"),
+		"message_id": req.MessageID,
+	})
+}
+
+func (h *Handlers) handleFork(w http.ResponseWriter, r *http.Request) {
+	req, agentID, ok := h.branchRequest(w, r)
+	if !ok {
+		return
+	}
+	if req.Key == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "key is required"})
+		return
+	}
+	if err := h.branches.ForkSession(agentID, r.PathValue("key"), req.Key, req.MessageID); err != nil {
+		writeBranchError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"agent_id": agentID,
+		"key":      req.Key,
+		"source":   r.PathValue("key"),
+	})
+}
+
+// branchRequest decodes the optional request body and resolves the session
+// the caller may access, writing an error response when it cannot.
+func (h *Handlers) branchRequest(w http.ResponseWriter, r *http.Request) (branchRequest, string, bool) {
+	var req branchRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+			return req, "", false
+		}
+	}
+	_, agentID, ok := h.findSession(w, r)
+	return req, agentID, ok
+}
+
+func writeBranchError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, session.ErrNotFound), errors.Is(err, session.ErrMessageNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, session.ErrExists):
+		status = http.StatusConflict
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/agent"
+	"github.com/tinyland-inc/tinyclaw/pkg/session"
+)
+
+// branchDispatcher serves the sessions of a single "main" agent from a
+// real session manager.
+type branchDispatcher struct {
+	mockDispatcher
+	sm *session.SessionManager
+}
+
+func newBranchDispatcher() *branchDispatcher {
+	sm := session.NewSessionManager("")
+	for _, c := range []string{"q1", "a1", "q2", "a2"} {
+		role := "user"
+		if c[0] == 'a' {
+			role = "assistant"
+		}
+		sm.AddMessage("api:1", role, c)
+	}
+	return &branchDispatcher{sm: sm}
+}
+
+func (d *branchDispatcher) ListSessions() []agent.SessionInfo { return nil }
+
+func (d *branchDispatcher) FindSession(_, key string) (session.Session, string, bool) {
+	s, ok := d.sm.Get(key)
+	return s, "main", ok
+}
+
+func (d *branchDispatcher) DeleteSession(_, key string) (bool, error) { return d.sm.Delete(key) }
+
+func (d *branchDispatcher) SummarizeSession(_, _ string) (string, error) { return "", nil }
+
+func (d *branchDispatcher) SessionBranches(_, key string) ([]session.Branch, string, error) {
+	b, err := d.sm.Branches(key)
+	return b, "main", err
+}
+
+func (d *branchDispatcher) RewindSession(_, key, messageID string) (int, error) {
+	return d.sm.Rewind(key, messageID)
+}
+
+func (d *branchDispatcher) RewindSessionTurns(_, key string, turns int) (int, error) {
+	return d.sm.RewindTurns(key, turns)
+}
+
+func (d *branchDispatcher) CheckoutSession(_, key, messageID string) error {
+	return d.sm.Checkout(key, messageID)
+}
+
+func (d *branchDispatcher) ForkSession(_, key, newKey, messageID string) error {
+	return d.sm.Fork(key, newKey, messageID)
+}
+
+func TestBranches_RewindCheckoutFork(t *testing.T) {
+	d := newBranchDispatcher()
+	mux := newTestMux(d)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := do(http.MethodGet, "/api/sessions/api:1", "")
+	var got struct {
+		MessageIDs []string `json:"message_ids"`
+	}
+	_ = json.NewDecoder(rec.Body).Decode(&got)
+	if len(got.MessageIDs) != 4 || got.MessageIDs[0] != "1" {
+		t.Fatalf("message_ids = %v", got.MessageIDs)
+	}
+
+	if rec := do(http.MethodPost, "/api/sessions/api:1/rewind", `{"message_id":"2"}`); rec.Code != http.StatusOK {
+		t.Fatalf("rewind: %d %s", rec.Code, rec.Body)
+	}
+	if n := len(d.sm.GetHistory("api:1")); n != 2 {
+		t.Errorf("history after rewind = %d", n)
+	}
+
+	rec = do(http.MethodGet, "/api/sessions/api:1/branches", "")
+	var branches struct {
+		Branches []session.Branch `json:"branches"`
+	}
+	_ = json.NewDecoder(rec.Body).Decode(&branches)
+	if len(branches.Branches) != 2 || branches.Branches[1].LeafID != "4" {
+		t.Fatalf("branches = %+v", branches.Branches)
+	}
+
+	if rec := do(http.MethodPost, "/api/sessions/api:1/checkout", `{"message_id":"4"}`); rec.Code != http.StatusOK {
+		t.Fatalf("checkout: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodPost, "/api/sessions/api:1/checkout", `{"message_id":"42"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("checkout of unknown message: %d", rec.Code)
+	}
+
+	if rec := do(http.MethodPost, "/api/sessions/api:1/fork", `{"key":"api:2","message_id":"3"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("fork: %d %s", rec.Code, rec.Body)
+	}
+	if n := len(d.sm.GetHistory("api:2")); n != 3 {
+		t.Errorf("forked history = %d", n)
+	}
+	if rec := do(http.MethodPost, "/api/sessions/api:1/fork", `{"key":"api:2"}`); rec.Code != http.StatusConflict {
+		t.Errorf("fork into existing key: %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/sessions/missing/rewind", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("rewind of missing session: %d", rec.Code)
+	}
+}
+
+func TestBranches_NotMountedWithoutBrancher(t *testing.T) {
+	mux := newTestMux(newSessionDispatcher())
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/sessions/telegram:1/branches", nil))
+	if rec.Code == http.StatusOK {
+		t.Error("branch endpoints mounted for a browser without branching")
+	}
+}
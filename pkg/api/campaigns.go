@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/campaign"
+)
+
+// SetCampaignRunner enables the /api/campaigns endpoints.
+// Must be called before Register.
+func (h *Handlers) SetCampaignRunner(r *campaign.Runner) {
+	h.campaigns = r
+}
+
+func (h *Handlers) registerCampaigns(r RouteRegistrar) {
+	r.HandleFunc("POST /api/campaigns", h.guard(h.handleStartCampaign))
+	r.HandleFunc("GET /api/campaigns", h.guard(h.handleListCampaigns))
+	r.HandleFunc("GET /api/campaigns/{id}", h.guard(h.handleGetCampaign))
+	r.HandleFunc("GET /api/campaigns/{id}/logs", h.guard(h.handleCampaignLogs))
+	r.HandleFunc("DELETE /api/campaigns/{id}", h.guard(h.handleStopCampaign))
+}
+
+// allowCampaign reports whether the caller may address every target agent
+// of the campaign.
+func allowCampaign(r *http.Request, def *campaign.Definition) bool {
+	principal := PrincipalFromContext(r.Context())
+	if def == nil {
+		return principal.AllowsAgent("")
+	}
+	for _, t := range def.Targets {
+		if !principal.AllowsAgent(t.AgentID) {
+			return false
+		}
+	}
+	return true
+}
+
+// handleStartCampaign validates the JSON campaign definition in the body
+// and starts it.
+func (h *Handlers) handleStartCampaign(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	def, err := campaign.ParseDefinition(body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if !allowCampaign(r, def) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "agent not permitted for this credential"})
+		return
+	}
+	// Sinks deliver with the gateway's own credentials, so only callers
+	// that may address everything can send reports elsewhere.
+	if def.FeedbackTarget.Redirects() && !PrincipalFromContext(r.Context()).Unrestricted() {
+		writeJSON(w, http.StatusForbidden, map[string]string{
+			"error": "feedback_target channel, chat_id, repo and secret need an unrestricted credential",
+		})
+		return
+	}
+	if err := h.campaigns.Check(def); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// The campaign outlives the request.
+	exec, err := h.campaigns.Start(context.WithoutCancel(r.Context()), def)
+	if err != nil {
+		status := http.StatusBadRequest
+		if strings.Contains(err.Error(), "already running") {
+			status = http.StatusConflict
+		}
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+	w.Header().Set("Location", "/api/campaigns/"+exec.ID)
+	writeJSON(w, http.StatusAccepted, exec)
+}
+
+// handleListCampaigns lists executions, newest first, filtered by the
+// campaign, tag, status and limit query parameters.
+func (h *Handlers) handleListCampaigns(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, err := strconv.Atoi(q.Get("limit"))
+	if q.Get("limit") != "" && (err != nil || limit < 0) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "limit must be a non-negative integer"})
+		return
+	}
+	execs := h.campaigns.Query(campaign.Query{
+		CampaignID: q.Get("campaign"),
+		Tag:        q.Get("tag"),
+		Status:     campaign.Status(q.Get("status")),
+	})
+
+	summaries := make([]campaign.Summary, 0, len(execs))
+	for _, exec := range execs {
+		if !allowCampaign(r, exec.Definition) {
+			continue
+		}
+		summaries = append(summaries, exec.Summary())
+		if limit > 0 && len(summaries) == limit {
+			break
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"executions": summaries,
+		"count":      len(summaries),
+	})
+}
+
+func (h *Handlers) handleGetCampaign(w http.ResponseWriter, r *http.Request) {
+	exec, ok := h.findExecution(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, exec)
+}
+
+// handleCampaignLogs returns the step results of an execution.
+func (h *Handlers) handleCampaignLogs(w http.ResponseWriter, r *http.Request) {
+	exec, ok := h.findExecution(w, r)
+	if !ok {
+		return
+	}
+	results := exec.Results
+	if results == nil {
+		results = []campaign.StepResult{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"id":     exec.ID,
+		"status": exec.Status,
+		"steps":  results,
+	})
+}
+
+// handleStopCampaign activates the kill switch of a running execution.
+func (h *Handlers) handleStopCampaign(w http.ResponseWriter, r *http.Request) {
+	exec, ok := h.findExecution(w, r)
+	if !ok {
+		return
+	}
+	if err := h.campaigns.Stop(exec.ID); err != nil {
+		writeJSON(w, http.StatusConflict, map[string]any{"error": err.Error(), "execution": exec.Summary()})
+		return
+	}
+	exec, _ = h.campaigns.GetStatus(exec.ID)
+	writeJSON(w, http.StatusAccepted, exec.Summary())
+}
+
+// findExecution resolves the {id} path value, an execution or campaign ID,
+// to an execution the caller may access, writing a 404 when there is none.
+func (h *Handlers) findExecution(w http.ResponseWriter, r *http.Request) (*campaign.Execution, bool) {
+	exec, err := h.campaigns.GetStatus(r.PathValue("id"))
+	if err != nil || !allowCampaign(r, exec.Definition) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "campaign not found"})
+		return nil, false
+	}
+	return exec, true
+}
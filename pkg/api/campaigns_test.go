@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/campaign"
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+)
+
+// blockingAdapter finishes steps named "wait" only when their context ends.
+type blockingAdapter struct{}
+
+func (blockingAdapter) Execute(ctx context.Context, _, prompt string, _ []string) (string, error) {
+	if prompt == "wait" {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	return "did " + prompt, nil
+}
+
+func (blockingAdapter) Name() string { return "tinyclaw" }
+
+func newCampaignMux(t *testing.T) *http.ServeMux {
+	t.Helper()
+	a, err := NewAuthenticator(config.GatewayAuthConfig{
+		Enabled: true,
+		APIKeys: []config.GatewayAPIKey{
+			{Name: "admin", Hash: HashAPIKey(adminKey), Role: "admin"},
+			{Name: "research-bot", Hash: HashAPIKey(researchKey), Role: "research"},
+		},
+		Roles: map[string]config.GatewayRole{
+			"admin":    {},
+			"research": {Agents: []string{"research"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner := campaign.NewRunner()
+	runner.RegisterAdapter("tinyclaw", blockingAdapter{})
+
+	mux := http.NewServeMux()
+	h := NewHandlers(&mockDispatcher{})
+	h.SetAuthenticator(a)
+	h.SetCampaignRunner(runner)
+	h.Register(mux)
+	return mux
+}
+
+func campaignJSON(id, agent string, prompts ...string) string {
+	steps := make([]string, len(prompts))
+	for i, p := range prompts {
+		steps[i] = `{"name":"` + p + `","prompt":"` + p + `","tools":[],"timeout_minutes":1}`
+	}
+	return `{"id":"` + id + `","name":"` + id + `","description":"",
+		"targets":[{"agent_id":"` + agent + `","backend":"tinyclaw"}],
+		"steps":[` + strings.Join(steps, ",") + `],
+		"guardrails":{"max_duration_minutes":5,"read_only":false,"ai_api_budget_cents":100,
+			"kill_switch":true,"max_tool_calls":10,"max_iterations":10},
+		"feedback":"none","tags":["ci"]}`
+}
+
+func TestCampaigns_RunListAndLogs(t *testing.T) {
+	mux := newCampaignMux(t)
+
+	rec := authRequest(mux, http.MethodPost, "/api/campaigns", campaignJSON("audit", "main", "scan", "report"), adminKey)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("start: expected 202, got %d: %s", rec.Code, rec.Body)
+	}
+	var started campaign.Execution
+	if err := json.NewDecoder(rec.Body).Decode(&started); err != nil {
+		t.Fatal(err)
+	}
+
+	var logs struct {
+		Status campaign.Status       `json:"status"`
+		Steps  []campaign.StepResult `json:"steps"`
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for logs.Status != campaign.StatusCompleted && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		rec = authRequest(mux, http.MethodGet, "/api/campaigns/"+started.ID+"/logs", "", adminKey)
+		if err := json.NewDecoder(rec.Body).Decode(&logs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if logs.Status != campaign.StatusCompleted || len(logs.Steps) != 2 || logs.Steps[1].Output != "did report" {
+		t.Fatalf("logs = %+v", logs)
+	}
+
+	rec = authRequest(mux, http.MethodGet, "/api/campaigns?tag=ci&limit=5", "", adminKey)
+	var list struct {
+		Executions []campaign.Summary `json:"executions"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Executions) != 1 || list.Executions[0].StepsDone != 2 || list.Executions[0].CampaignID != "audit" {
+		t.Errorf("list = %+v", list.Executions)
+	}
+
+	// The research bot may not see campaigns targeting other agents.
+	if rec := authRequest(mux, http.MethodGet, "/api/campaigns/audit", "", researchKey); rec.Code != http.StatusNotFound {
+		t.Errorf("out-of-scope status: expected 404, got %d", rec.Code)
+	}
+	if rec := authRequest(mux, http.MethodPost, "/api/campaigns", campaignJSON("x", "main", "a"), researchKey); rec.Code != http.StatusForbidden {
+		t.Errorf("out-of-scope start: expected 403, got %d", rec.Code)
+	}
+}
+
+func TestCampaigns_Stop(t *testing.T) {
+	mux := newCampaignMux(t)
+
+	rec := authRequest(mux, http.MethodPost, "/api/campaigns", campaignJSON("long", "research", "wait"), researchKey)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("start: expected 202, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := authRequest(mux, http.MethodPost, "/api/campaigns", campaignJSON("long", "research", "wait"), researchKey); rec.Code != http.StatusConflict {
+		t.Errorf("second start: expected 409, got %d", rec.Code)
+	}
+
+	rec = authRequest(mux, http.MethodDelete, "/api/campaigns/long", "", researchKey)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("stop: expected 202, got %d: %s", rec.Code, rec.Body)
+	}
+	var stopped campaign.Summary
+	if err := json.NewDecoder(rec.Body).Decode(&stopped); err != nil {
+		t.Fatal(err)
+	}
+	if stopped.Status != campaign.StatusCanceled {
+		t.Errorf("stopped = %+v", stopped)
+	}
+	if rec := authRequest(mux, http.MethodDelete, "/api/campaigns/long", "", researchKey); rec.Code != http.StatusConflict {
+		t.Errorf("second stop: expected 409, got %d", rec.Code)
+	}
+}
+
+func TestCampaigns_Validation(t *testing.T) {
+	mux := newCampaignMux(t)
+
+	tests := map[string]string{
+		"bad json":        `{"id":`,
+		"unknown field":   `{"id":"x","stpes":[]}`,
+		"unknown backend": strings.Replace(campaignJSON("x", "main", "a"), `"backend":"tinyclaw"`, `"backend":"hexstrike"`, 1),
+		"no steps":        campaignJSON("x", "main"),
+	}
+	for name, body := range tests {
+		if rec := authRequest(mux, http.MethodPost, "/api/campaigns", body, adminKey); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, rec.Code)
+		}
+	}
+	if rec := authRequest(mux, http.MethodGet, "/api/campaigns/nope", "", adminKey); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown campaign: expected 404, got %d", rec.Code)
+	}
+	if rec := authRequest(mux, http.MethodGet, "/api/campaigns?limit=-1", "", adminKey); rec.Code != http.StatusBadRequest {
+		t.Errorf("negative limit: expected 400, got %d", rec.Code)
+	}
+}
+
+func TestCampaigns_FeedbackTargetNeedsUnrestrictedKey(t *testing.T) {
+	mux := newCampaignMux(t)
+
+	redirect := strings.Replace(campaignJSON("leak", "research", "a"), `"feedback":"none"`,
+		`"feedback":"none","feedback_target":{"repo":"someone/else"}`, 1)
+	if rec := authRequest(mux, http.MethodPost, "/api/campaigns", redirect, researchKey); rec.Code != http.StatusForbidden {
+		t.Errorf("restricted override: expected 403, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// Branches stay within the configured repository.
+	branch := strings.Replace(campaignJSON("branch", "research", "a"), `"feedback":"none"`,
+		`"feedback":"none","feedback_target":{"head":"campaign/branch"}`, 1)
+	if rec := authRequest(mux, http.MethodPost, "/api/campaigns", branch, researchKey); rec.Code != http.StatusAccepted {
+		t.Errorf("restricted branch: expected 202, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := authRequest(mux, http.MethodPost, "/api/campaigns", redirect, adminKey); rec.Code != http.StatusAccepted {
+		t.Errorf("unrestricted override: expected 202, got %d: %s", rec.Code, rec.Body)
+	}
+}
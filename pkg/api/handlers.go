@@ -7,6 +7,10 @@ import (
 	"log"
 	"net/http"
 	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/aperture"
+	"github.com/tinyland-inc/tinyclaw/pkg/campaign"
+	"github.com/tinyland-inc/tinyclaw/pkg/metrics"
 )
 
 // Dispatcher abstracts the agent loop for testability.
@@ -24,6 +28,13 @@ type RouteRegistrar interface {
 // Handlers holds references needed by the API endpoints.
 type Handlers struct {
 	dispatcher Dispatcher
+	jobs       *JobManager
+	auth       *Authenticator
+	sessions   SessionBrowser
+	branches   SessionBrancher
+	searcher   SessionSearcher
+	meters     *aperture.MeterStore
+	campaigns  *campaign.Runner
 }
 
 // NewHandlers creates a new Handlers instance.
@@ -31,11 +42,47 @@ func NewHandlers(d Dispatcher) *Handlers {
 	return &Handlers{dispatcher: d}
 }
 
+// SetJobManager enables the asynchronous /api/jobs endpoints.
+// Must be called before Register.
+func (h *Handlers) SetJobManager(m *JobManager) {
+	h.jobs = m
+}
+
+// SetAuthenticator requires every API request to authenticate.
+// Must be called before Register.
+func (h *Handlers) SetAuthenticator(a *Authenticator) {
+	h.auth = a
+}
+
 // Register adds all API routes to the given registrar.
 func (h *Handlers) Register(r RouteRegistrar) {
-	r.HandleFunc("POST /api/dispatch", h.handleDispatch)
-	r.HandleFunc("GET /api/tools", h.handleTools)
-	r.HandleFunc("GET /api/status", h.handleStatus)
+	r.HandleFunc("POST /api/dispatch", h.guard(h.handleDispatch))
+	r.HandleFunc("GET /api/tools", h.guard(h.handleTools))
+	r.HandleFunc("GET /api/status", h.guard(h.handleStatus))
+	r.HandleFunc("POST /v1/chat/completions", h.guard(h.handleChatCompletions))
+	r.HandleFunc("GET /v1/models", h.guard(h.handleModels))
+	r.HandleFunc("GET /metrics", h.guard(metrics.Default.Handler()))
+	if h.jobs != nil {
+		r.HandleFunc("POST /api/jobs", h.guard(h.handleCreateJob))
+		r.HandleFunc("GET /api/jobs", h.guard(h.handleListJobs))
+		r.HandleFunc("GET /api/jobs/{id}", h.guard(h.handleGetJob))
+		r.HandleFunc("DELETE /api/jobs/{id}", h.guard(h.handleCancelJob))
+	}
+	if sb, ok := h.dispatcher.(SessionBrowser); ok {
+		h.registerSessions(r, sb)
+		if br, ok := h.dispatcher.(SessionBrancher); ok {
+			h.registerBranches(r, br)
+		}
+		if ss, ok := h.dispatcher.(SessionSearcher); ok {
+			h.registerSearch(r, ss)
+		}
+	}
+	if h.meters != nil {
+		h.registerMetering(r)
+	}
+	if h.campaigns != nil {
+		h.registerCampaigns(r)
+	}
 }
 
 type dispatchRequest struct {
@@ -73,6 +120,10 @@ func (h *Handlers) handleDispatch(w http.ResponseWriter, r *http.Request) {
 	if req.SessionKey == "" {
 		req.SessionKey = "api:" + req.ChatID
 	}
+	if !h.allowSession(r, req.SessionKey, req.Channel, req.ChatID) {
+		writeJSON(w, http.StatusForbidden, dispatchResponse{Error: "agent not permitted for this credential"})
+		return
+	}
 
 	// Best-effort: extend the write deadline — dispatch can take minutes.
 	// Errors are ignored because not all ResponseWriter implementations support this.
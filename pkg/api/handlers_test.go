@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -205,3 +206,16 @@ func TestStatus(t *testing.T) {
 		t.Errorf("expected tool count 5, got %v", count)
 	}
 }
+
+func TestMetrics_Endpoint(t *testing.T) {
+	mux := newTestMux(&mockDispatcher{})
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "# TYPE tinyclaw_llm_requests_total counter") {
+		t.Errorf("missing LLM request metric:\n%s", rec.Body.String())
+	}
+}
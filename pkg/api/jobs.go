@@ -0,0 +1,535 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/agent"
+	"github.com/tinyland-inc/tinyclaw/pkg/logger"
+)
+
+// JobStatus is the lifecycle state of an asynchronous job.
+type JobStatus string
+
+const (
+	JobQueued      JobStatus = "queued"
+	JobRunning     JobStatus = "running"
+	JobSucceeded   JobStatus = "succeeded"
+	JobFailed      JobStatus = "failed"
+	JobCanceled    JobStatus = "canceled"
+	JobInterrupted JobStatus = "interrupted" // the gateway restarted mid-run
+)
+
+// Done reports whether the job has reached a terminal state.
+func (s JobStatus) Done() bool {
+	return s != JobQueued && s != JobRunning
+}
+
+const (
+	// jobTimeout bounds a single job's dispatch.
+	jobTimeout = 30 * time.Minute
+	// jobRetention is how long finished jobs are kept on disk.
+	jobRetention = 7 * 24 * time.Hour
+	// maxJobEvents caps the progress events kept per job.
+	maxJobEvents = 100
+	// webhookAttempts is how many times a completion callback is tried.
+	webhookAttempts = 3
+)
+
+// ErrJobNotFound is returned for unknown job IDs.
+var ErrJobNotFound = errors.New("job not found")
+
+// ErrJobFinished is returned when canceling a job that already finished.
+var ErrJobFinished = errors.New("job already finished")
+
+// Job is an asynchronous dispatch tracked by the JobManager.
+type Job struct {
+	ID            string                `json:"id"`
+	Status        JobStatus             `json:"status"`
+	Content       string                `json:"content"`
+	SessionKey    string                `json:"session_key"`
+	Channel       string                `json:"channel"`
+	ChatID        string                `json:"chat_id"`
+	CallbackURL   string                `json:"callback_url,omitempty"`
+	Owner         string                `json:"owner,omitempty"`
+	Result        string                `json:"result,omitempty"`
+	Error         string                `json:"error,omitempty"`
+	PartialOutput string                `json:"partial_output,omitempty"`
+	Progress      []agent.ProgressEvent `json:"progress,omitempty"`
+	WebhookStatus string                `json:"webhook_status,omitempty"`
+	WebhookError  string                `json:"webhook_error,omitempty"`
+	CreatedAt     time.Time             `json:"created_at"`
+	StartedAt     *time.Time            `json:"started_at,omitempty"`
+	FinishedAt    *time.Time            `json:"finished_at,omitempty"`
+}
+
+// JobRequest is the body accepted by POST /api/jobs.
+type JobRequest struct {
+	Content     string `json:"content"`
+	SessionKey  string `json:"session_key"`
+	Channel     string `json:"channel"`
+	ChatID      string `json:"chat_id"`
+	CallbackURL string `json:"callback_url"`
+}
+
+// JobManager runs dispatches in the background and persists their state
+// as one JSON file per job so it survives a gateway restart.
+type JobManager struct {
+	dispatcher Dispatcher
+	dir        string
+	client     *http.Client
+
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	cancels map[string]context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewJobManager creates a job manager storing state under dir. Jobs that
+// were still running when the previous process exited are marked
+// interrupted. Finished jobs past the retention period are removed now and
+// whenever another job finishes.
+func NewJobManager(d Dispatcher, dir string) (*JobManager, error) {
+	m := &JobManager{
+		dispatcher: d,
+		dir:        dir,
+		client:     callbackClient(),
+		jobs:       make(map[string]*Job),
+		cancels:    make(map[string]context.CancelFunc),
+	}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("create jobs directory: %w", err)
+		}
+		m.load()
+	}
+	return m, nil
+}
+
+// Submit validates req, records a new job for owner, the submitting
+// principal's name, and starts it in the background.
+func (m *JobManager) Submit(req JobRequest, owner string) (Job, error) {
+	if strings.TrimSpace(req.Content) == "" {
+		return Job{}, errors.New("content is required")
+	}
+	if req.CallbackURL != "" {
+		u, err := url.Parse(req.CallbackURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return Job{}, errors.New("callback_url must be an absolute http(s) URL")
+		}
+	}
+
+	id := uuid.NewString()
+	if req.Channel == "" {
+		req.Channel = "api"
+	}
+	if req.ChatID == "" {
+		req.ChatID = "job-" + id
+	}
+	if req.SessionKey == "" {
+		req.SessionKey = "api:" + req.ChatID
+	}
+
+	job := &Job{
+		ID:          id,
+		Status:      JobQueued,
+		Content:     req.Content,
+		SessionKey:  req.SessionKey,
+		Channel:     req.Channel,
+		ChatID:      req.ChatID,
+		CallbackURL: req.CallbackURL,
+		Owner:       owner,
+		CreatedAt:   time.Now(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), jobTimeout)
+
+	m.mu.Lock()
+	m.jobs[id] = job
+	m.cancels[id] = cancel
+	m.save(job)
+	snapshot := job.clone()
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go m.run(ctx, id)
+
+	return snapshot, nil
+}
+
+// Get returns a snapshot of the job with the given ID.
+func (m *JobManager) Get(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return job.clone(), true
+}
+
+// List returns snapshots of all jobs, newest first. An empty status
+// matches every job.
+func (m *JobManager) List(status JobStatus) []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := make([]Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		if status != "" && job.Status != status {
+			continue
+		}
+		jobs = append(jobs, job.clone())
+	}
+	slices.SortFunc(jobs, func(a, b Job) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return jobs
+}
+
+// Cancel stops a queued or running job.
+func (m *JobManager) Cancel(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+	if job.Status.Done() {
+		return job.clone(), ErrJobFinished
+	}
+	job.Status = JobCanceled
+	job.Error = "canceled by client"
+	now := time.Now()
+	job.FinishedAt = &now
+	m.save(job)
+	if cancel, ok := m.cancels[id]; ok {
+		cancel()
+	}
+	return job.clone(), nil
+}
+
+// Wait blocks until all background jobs and their callbacks have finished.
+func (m *JobManager) Wait() {
+	m.wg.Wait()
+}
+
+func (m *JobManager) run(ctx context.Context, id string) {
+	defer m.wg.Done()
+
+	m.mu.Lock()
+	job := m.jobs[id]
+	if job.Status.Done() {
+		// Canceled before it started.
+		m.finish(id)
+		m.mu.Unlock()
+		m.notify(id)
+		return
+	}
+	now := time.Now()
+	job.Status = JobRunning
+	job.StartedAt = &now
+	m.save(job)
+	content, sessionKey, channel, chatID := job.Content, job.SessionKey, job.Channel, job.ChatID
+	m.mu.Unlock()
+
+	ctx = agent.WithProgress(ctx, func(ev agent.ProgressEvent) {
+		m.recordProgress(id, ev)
+	})
+
+	result, err := m.dispatcher.ProcessDirectWithChannel(ctx, content, sessionKey, channel, chatID)
+
+	m.mu.Lock()
+	if !job.Status.Done() {
+		finished := time.Now()
+		job.FinishedAt = &finished
+		if err != nil {
+			job.Status = JobFailed
+			job.Error = err.Error()
+		} else {
+			job.Status = JobSucceeded
+			job.Result = result
+		}
+	}
+	status := job.Status
+	m.finish(id)
+	m.prune(time.Now())
+	m.mu.Unlock()
+
+	logger.InfoCF("api", "Job finished", map[string]any{
+		"job_id": id,
+		"status": string(status),
+	})
+	m.notify(id)
+}
+
+// finish releases the job's context and persists its final state.
+// Must be called with the lock held.
+func (m *JobManager) finish(id string) {
+	if cancel, ok := m.cancels[id]; ok {
+		cancel()
+		delete(m.cancels, id)
+	}
+	m.save(m.jobs[id])
+}
+
+// prune removes finished jobs past the retention period.
+// Must be called with the lock held.
+func (m *JobManager) prune(now time.Time) {
+	for id, job := range m.jobs {
+		if !job.Status.Done() || job.FinishedAt == nil || now.Sub(*job.FinishedAt) <= jobRetention {
+			continue
+		}
+		delete(m.jobs, id)
+		if m.dir != "" {
+			os.Remove(filepath.Join(m.dir, id+".json"))
+		}
+	}
+}
+
+func (m *JobManager) recordProgress(id string, ev agent.ProgressEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok || job.Status.Done() {
+		return
+	}
+	job.Progress = append(job.Progress, ev)
+	if len(job.Progress) > maxJobEvents {
+		job.Progress = job.Progress[len(job.Progress)-maxJobEvents:]
+	}
+	if ev.Kind == agent.ProgressAssistant {
+		job.PartialOutput = ev.Content
+	}
+	m.save(job)
+}
+
+// notify posts the finished job to its callback URL, retrying with a
+// short backoff, and records the delivery outcome on the job.
+func (m *JobManager) notify(id string) {
+	m.mu.Lock()
+	job := m.jobs[id]
+	snapshot := job.clone()
+	m.mu.Unlock()
+	if snapshot.CallbackURL == "" {
+		return
+	}
+
+	body, err := json.Marshal(snapshot)
+	if err != nil {
+		return
+	}
+
+	var lastErr error
+	for attempt := range webhookAttempts {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+		if lastErr = m.postCallback(snapshot.CallbackURL, body); lastErr == nil {
+			break
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if lastErr != nil {
+		job.WebhookStatus = "failed"
+		job.WebhookError = lastErr.Error()
+		logger.WarnCF("api", "Job callback failed", map[string]any{
+			"job_id": id,
+			"error":  lastErr.Error(),
+		})
+	} else {
+		job.WebhookStatus = "delivered"
+		job.WebhookError = ""
+	}
+	m.save(job)
+}
+
+// callbackClient returns the client that posts job callbacks. It refuses
+// to connect to loopback, private, link-local and other non-public
+// addresses, checked after DNS resolution and on every redirect, so a
+// callback_url cannot reach services on the gateway's own network.
+func callbackClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return fmt.Errorf("callback address %s is not a public address", host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: 10 * time.Second, Transport: transport}
+}
+
+// publicIP reports whether ip is routable on the public internet.
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast()
+}
+
+func (m *JobManager) postCallback(callbackURL string, body []byte) error {
+	resp, err := m.client.Post(callbackURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("callback returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// save writes the job to disk using temp file + rename.
+// Must be called with the lock held.
+func (m *JobManager) save(job *Job) {
+	if m.dir == "" {
+		return
+	}
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return
+	}
+	path := filepath.Join(m.dir, job.ID+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		logger.WarnCF("api", "Failed to persist job", map[string]any{"job_id": job.ID, "error": err.Error()})
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		logger.WarnCF("api", "Failed to persist job", map[string]any{"job_id": job.ID, "error": err.Error()})
+	}
+}
+
+func (m *JobManager) load() {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(m.dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var job Job
+		if err := json.Unmarshal(data, &job); err != nil || job.ID == "" {
+			continue
+		}
+
+		if !job.Status.Done() {
+			job.Status = JobInterrupted
+			job.Error = "gateway restarted before the job finished"
+			job.FinishedAt = &now
+			m.save(&job)
+		}
+		m.jobs[job.ID] = &job
+	}
+	m.prune(now)
+}
+
+func (j *Job) clone() Job {
+	c := *j
+	c.Progress = slices.Clone(j.Progress)
+	return c
+}
+
+// allowJob reports whether the request's principal submitted job and may
+// still use its session. Without authentication every job is visible.
+func (h *Handlers) allowJob(r *http.Request, job Job) bool {
+	if principal := PrincipalFromContext(r.Context()); principal != nil && principal.Name != job.Owner {
+		return false
+	}
+	return h.allowSession(r, job.SessionKey, job.Channel, job.ChatID)
+}
+
+func (h *Handlers) handleCreateJob(w http.ResponseWriter, r *http.Request) {
+	var req JobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	channel := req.Channel
+	if channel == "" {
+		channel = "api"
+	}
+	if !h.allowSession(r, req.SessionKey, channel, req.ChatID) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "agent not permitted for this credential"})
+		return
+	}
+	var owner string
+	if principal := PrincipalFromContext(r.Context()); principal != nil {
+		owner = principal.Name
+	}
+	job, err := h.jobs.Submit(req, owner)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	w.Header().Set("Location", "/api/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func (h *Handlers) handleListJobs(w http.ResponseWriter, r *http.Request) {
+	jobs := slices.DeleteFunc(h.jobs.List(JobStatus(r.URL.Query().Get("status"))), func(j Job) bool {
+		return !h.allowJob(r, j)
+	})
+	writeJSON(w, http.StatusOK, map[string]any{
+		"jobs":  jobs,
+		"count": len(jobs),
+	})
+}
+
+func (h *Handlers) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.jobs.Get(r.PathValue("id"))
+	if !ok || !h.allowJob(r, job) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": ErrJobNotFound.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+func (h *Handlers) handleCancelJob(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if job, ok := h.jobs.Get(id); !ok || !h.allowJob(r, job) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": ErrJobNotFound.Error()})
+		return
+	}
+	job, err := h.jobs.Cancel(id)
+	switch {
+	case errors.Is(err, ErrJobNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, ErrJobFinished):
+		writeJSON(w, http.StatusConflict, map[string]any{"error": err.Error(), "job": job})
+	default:
+		writeJSON(w, http.StatusAccepted, job)
+	}
+}
@@ -0,0 +1,323 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/agent"
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+)
+
+// progressDispatcher reports a tool call and, when block is set, waits for
+// cancellation before returning.
+type progressDispatcher struct {
+	mockDispatcher
+	block   bool
+	started chan struct{}
+}
+
+func (d *progressDispatcher) ProcessDirectWithChannel(
+	ctx context.Context, _, _, _, _ string,
+) (string, error) {
+	agent.ReportProgress(ctx, agent.ProgressEvent{Kind: agent.ProgressAssistant, Content: "thinking"})
+	agent.ReportProgress(ctx, agent.ProgressEvent{Kind: agent.ProgressToolCall, Tool: "web_search"})
+	if d.started != nil {
+		close(d.started)
+	}
+	if d.block {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	return d.result, d.err
+}
+
+func newJobMux(t *testing.T, d Dispatcher, dir string) (*http.ServeMux, *JobManager) {
+	t.Helper()
+	mux := http.NewServeMux()
+	jobs, err := NewJobManager(d, dir)
+	if err != nil {
+		t.Fatalf("NewJobManager: %v", err)
+	}
+	h := NewHandlers(d)
+	h.SetJobManager(jobs)
+	h.Register(mux)
+	return mux, jobs
+}
+
+func createJob(t *testing.T, mux *http.ServeMux, body string) Job {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/jobs", bytes.NewBufferString(body))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var job Job
+	if err := json.NewDecoder(rec.Body).Decode(&job); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return job
+}
+
+func getJob(t *testing.T, mux *http.ServeMux, id string) (int, Job) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/jobs/"+id, nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	var job Job
+	_ = json.NewDecoder(rec.Body).Decode(&job)
+	return rec.Code, job
+}
+
+func TestJobs_CompleteWithProgress(t *testing.T) {
+	d := &progressDispatcher{}
+	d.result = "done"
+	mux, jobs := newJobMux(t, d, t.TempDir())
+
+	job := createJob(t, mux, `{"content":"research this"}`)
+	if job.ID == "" || job.SessionKey != "api:job-"+job.ID {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+	jobs.Wait()
+
+	code, got := getJob(t, mux, job.ID)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if got.Status != JobSucceeded || got.Result != "done" {
+		t.Errorf("status=%s result=%q", got.Status, got.Result)
+	}
+	if got.PartialOutput != "thinking" {
+		t.Errorf("partial output = %q", got.PartialOutput)
+	}
+	if len(got.Progress) != 2 || got.Progress[1].Tool != "web_search" {
+		t.Errorf("progress = %+v", got.Progress)
+	}
+	if got.StartedAt == nil || got.FinishedAt == nil {
+		t.Error("expected timestamps to be set")
+	}
+}
+
+func TestJobs_Cancel(t *testing.T) {
+	d := &progressDispatcher{block: true, started: make(chan struct{})}
+	mux, jobs := newJobMux(t, d, t.TempDir())
+
+	job := createJob(t, mux, `{"content":"long task"}`)
+	<-d.started
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/jobs/"+job.ID, nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", rec.Code)
+	}
+	jobs.Wait()
+
+	_, got := getJob(t, mux, job.ID)
+	if got.Status != JobCanceled {
+		t.Errorf("status = %s, want canceled", got.Status)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/jobs/"+job.ID, nil))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("canceling a finished job: expected 409, got %d", rec.Code)
+	}
+}
+
+func TestJobs_NotFoundAndValidation(t *testing.T) {
+	mux, _ := newJobMux(t, &mockDispatcher{}, "")
+
+	if code, _ := getJob(t, mux, "missing"); code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", code)
+	}
+
+	for _, body := range []string{`{}`, `{"content":"x","callback_url":"ftp://example.com"}`, `{bad`} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/jobs", bytes.NewBufferString(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("body %s: expected 400, got %d", body, rec.Code)
+		}
+	}
+}
+
+func TestJobs_Webhook(t *testing.T) {
+	received := make(chan Job, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var job Job
+		_ = json.Unmarshal(data, &job)
+		received <- job
+	}))
+	defer hook.Close()
+
+	mux, jobs := newJobMux(t, &mockDispatcher{result: "ok"}, t.TempDir())
+	jobs.client = hook.Client() // the test server is on loopback
+	job := createJob(t, mux, `{"content":"hi","callback_url":"`+hook.URL+`"}`)
+	jobs.Wait()
+
+	select {
+	case got := <-received:
+		if got.ID != job.ID || got.Status != JobSucceeded {
+			t.Errorf("callback payload = %+v", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("callback not received")
+	}
+
+	_, got := getJob(t, mux, job.ID)
+	if got.WebhookStatus != "delivered" {
+		t.Errorf("webhook status = %q", got.WebhookStatus)
+	}
+}
+
+func TestJobs_WebhookRefusesInternalAddresses(t *testing.T) {
+	var hits int
+	hook := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { hits++ }))
+	defer hook.Close()
+
+	mux, jobs := newJobMux(t, &mockDispatcher{result: "ok"}, t.TempDir())
+	job := createJob(t, mux, `{"content":"hi","callback_url":"`+hook.URL+`"}`)
+	jobs.Wait()
+
+	_, got := getJob(t, mux, job.ID)
+	if hits != 0 || got.WebhookStatus != "failed" || !strings.Contains(got.WebhookError, "not a public address") {
+		t.Errorf("hits=%d webhook status=%q error=%q", hits, got.WebhookStatus, got.WebhookError)
+	}
+}
+
+func TestPublicIP(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34":   true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+	} {
+		if got := publicIP(net.ParseIP(addr)); got != want {
+			t.Errorf("publicIP(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestJobs_ScopedToOwner(t *testing.T) {
+	const otherKey = "tc_other"
+	cfg := testAuthConfig()
+	cfg.APIKeys = append(cfg.APIKeys, config.GatewayAPIKey{Name: "other", Hash: HashAPIKey(otherKey), Role: "admin"})
+	a, err := NewAuthenticator(cfg)
+	if err != nil {
+		t.Fatalf("NewAuthenticator: %v", err)
+	}
+	d := &mockDispatcher{result: "ok"}
+	jobs, err := NewJobManager(d, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewJobManager: %v", err)
+	}
+	h := NewHandlers(d)
+	h.SetAuthenticator(a)
+	h.SetJobManager(jobs)
+	mux := http.NewServeMux()
+	h.Register(mux)
+
+	rec := authRequest(mux, http.MethodPost, "/api/jobs", `{"content":"hi"}`, adminKey)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", rec.Code)
+	}
+	var job Job
+	_ = json.NewDecoder(rec.Body).Decode(&job)
+	jobs.Wait()
+	if job.Owner != "admin" {
+		t.Errorf("owner = %q", job.Owner)
+	}
+
+	if rec := authRequest(mux, http.MethodGet, "/api/jobs/"+job.ID, "", otherKey); rec.Code != http.StatusNotFound {
+		t.Errorf("another principal's job: expected 404, got %d", rec.Code)
+	}
+	if rec := authRequest(mux, http.MethodDelete, "/api/jobs/"+job.ID, "", otherKey); rec.Code != http.StatusNotFound {
+		t.Errorf("canceling another principal's job: expected 404, got %d", rec.Code)
+	}
+	rec = authRequest(mux, http.MethodGet, "/api/jobs", "", otherKey)
+	if !strings.Contains(rec.Body.String(), `"count":0`) {
+		t.Errorf("another principal's list: %s", rec.Body.String())
+	}
+	if rec := authRequest(mux, http.MethodGet, "/api/jobs/"+job.ID, "", adminKey); rec.Code != http.StatusOK {
+		t.Errorf("own job: expected 200, got %d", rec.Code)
+	}
+}
+
+func TestJobs_PrunedAfterRetention(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-jobRetention - time.Hour)
+	expired := Job{ID: "expired", Status: JobSucceeded, Content: "x", CreatedAt: old, FinishedAt: &old}
+	data, _ := json.Marshal(expired)
+	if err := os.WriteFile(filepath.Join(dir, "expired.json"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mux, jobs := newJobMux(t, &mockDispatcher{result: "ok"}, dir)
+	if code, _ := getJob(t, mux, "expired"); code != http.StatusNotFound {
+		t.Errorf("expired job at startup: expected 404, got %d", code)
+	}
+
+	// A job that expires while the gateway runs goes when another finishes.
+	jobs.mu.Lock()
+	jobs.jobs["expired"] = &expired
+	jobs.mu.Unlock()
+	createJob(t, mux, `{"content":"hi"}`)
+	jobs.Wait()
+	if code, _ := getJob(t, mux, "expired"); code != http.StatusNotFound {
+		t.Errorf("expired job after a run: expected 404, got %d", code)
+	}
+}
+
+func TestJobs_SurviveRestart(t *testing.T) {
+	dir := t.TempDir()
+	_, jobs := newJobMux(t, &mockDispatcher{result: "persisted"}, dir)
+	done, err := jobs.Submit(JobRequest{Content: "hi"}, "")
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	jobs.Wait()
+
+	// Simulate a job that was running when the gateway stopped.
+	stale := Job{ID: "stale", Status: JobRunning, Content: "x", CreatedAt: time.Now()}
+	data, _ := json.Marshal(stale)
+	if err := os.WriteFile(filepath.Join(dir, "stale.json"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	mux, _ := newJobMux(t, &mockDispatcher{}, dir)
+
+	_, got := getJob(t, mux, done.ID)
+	if got.Status != JobSucceeded || got.Result != "persisted" {
+		t.Errorf("reloaded job = %+v", got)
+	}
+	_, got = getJob(t, mux, "stale")
+	if got.Status != JobInterrupted {
+		t.Errorf("stale job status = %s, want interrupted", got.Status)
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/jobs?status=succeeded", nil))
+	var list struct {
+		Count int `json:"count"`
+	}
+	_ = json.NewDecoder(rec.Body).Decode(&list)
+	if list.Count != 1 {
+		t.Errorf("expected 1 succeeded job, got %d", list.Count)
+	}
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/aperture"
+)
+
+// SetMeterStore enables the /api/metering endpoints backed by Aperture
+// usage events. Must be called before Register.
+func (h *Handlers) SetMeterStore(s *aperture.MeterStore) {
+	h.meters = s
+}
+
+func (h *Handlers) registerMetering(r RouteRegistrar) {
+	r.HandleFunc("GET /api/metering", h.guard(h.handleListMeters))
+	r.HandleFunc("GET /api/metering/{agent}", h.guard(h.handleGetMeter))
+}
+
+// handleListMeters returns the meters of every agent the caller may address.
+// Campaign meters span agents, so only unrestricted credentials see them.
+func (h *Handlers) handleListMeters(w http.ResponseWriter, r *http.Request) {
+	principal := PrincipalFromContext(r.Context())
+	snap := h.meters.Snapshot()
+	for id := range snap.Agents {
+		if !principal.AllowsAgent(id) {
+			delete(snap.Agents, id)
+		}
+	}
+	if principal != nil && len(principal.Agents) > 0 {
+		snap.Campaigns = nil
+	}
+	writeJSON(w, http.StatusOK, snap)
+}
+
+func (h *Handlers) handleGetMeter(w http.ResponseWriter, r *http.Request) {
+	agentID := r.PathValue("agent")
+	if !PrincipalFromContext(r.Context()).AllowsAgent(agentID) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "agent not permitted for this credential"})
+		return
+	}
+	meter, ok := h.meters.GetAgentMeter(agentID)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no usage recorded for agent"})
+		return
+	}
+	writeJSON(w, http.StatusOK, meter)
+}
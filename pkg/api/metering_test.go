@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/aperture"
+)
+
+func newMeteringHandlers() *Handlers {
+	store := aperture.NewMeterStore()
+	store.RecordAttributed(aperture.Attribution{AgentID: "main", SessionKey: "s1", CampaignID: "audit"},
+		aperture.UsageEvent{InputTokens: 10, OutputTokens: 5, TotalTokens: 15})
+	store.RecordAttributed(aperture.Attribution{AgentID: "research", SessionKey: "s2"},
+		aperture.UsageEvent{TotalTokens: 7})
+
+	h := NewHandlers(&mockDispatcher{})
+	h.SetMeterStore(store)
+	return h
+}
+
+func TestMetering_List(t *testing.T) {
+	h := newMeteringHandlers()
+	mux := http.NewServeMux()
+	h.Register(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/metering", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var snap aperture.MeterSnapshot
+	if err := json.NewDecoder(rec.Body).Decode(&snap); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(snap.Agents) != 2 || snap.Agents["main"].Sessions["s1"].InputTokens != 10 {
+		t.Errorf("agents = %+v", snap.Agents)
+	}
+	if snap.Campaigns["audit"] == nil || snap.Campaigns["audit"].Calls != 1 {
+		t.Errorf("campaigns = %+v", snap.Campaigns)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/metering/nobody", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown agent: expected 404, got %d", rec.Code)
+	}
+}
+
+func TestMetering_ScopedToPrincipalAgents(t *testing.T) {
+	h := newMeteringHandlers()
+	ctx := context.WithValue(context.Background(), principalKey{}, &Principal{Name: "bot", Agents: []string{"research"}})
+
+	rec := httptest.NewRecorder()
+	h.handleListMeters(rec, httptest.NewRequest(http.MethodGet, "/api/metering", nil).WithContext(ctx))
+	var snap aperture.MeterSnapshot
+	if err := json.NewDecoder(rec.Body).Decode(&snap); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(snap.Agents) != 1 || snap.Agents["research"] == nil || len(snap.Campaigns) != 0 {
+		t.Errorf("scoped snapshot = %+v", snap)
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/metering/main", nil).WithContext(ctx)
+	req.SetPathValue("agent", "main")
+	h.handleGetMeter(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("agent outside scope: expected 403, got %d", rec.Code)
+	}
+}
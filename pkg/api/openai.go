@@ -0,0 +1,424 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/agent"
+	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+	"github.com/tinyland-inc/tinyclaw/pkg/routing"
+)
+
+// AgentLister is implemented by dispatchers that host several agents.
+// The OpenAI-compatible endpoints use it to map model names to agent IDs.
+type AgentLister interface {
+	ListAgentIDs() []string
+}
+
+// AgentRouter is implemented by dispatchers that route messages to agents
+// by channel bindings. Authorization uses it to check the agent a dispatch
+// will actually reach.
+type AgentRouter interface {
+	RouteAgentID(sessionKey, channel, chatID string) string
+}
+
+// SessionDeleter is implemented by dispatchers that can drop a session.
+// Stateless completions use it to discard their throwaway session.
+type SessionDeleter interface {
+	DeleteSession(agentID, key string) (bool, error)
+}
+
+// SessionHeader lets OpenAI-style clients pin a conversation to a TinyClaw
+// session. When absent, the request's "user" field is used instead.
+const SessionHeader = "X-TinyClaw-Session"
+
+// modelPrefix is the optional namespace for agent model names ("tinyclaw/main").
+const modelPrefix = "tinyclaw/"
+
+// sseKeepAlive is how often a comment line is written while a streamed
+// response waits for the agent.
+const sseKeepAlive = 15 * time.Second
+
+type chatMessage struct {
+	Role    string `json:"role,omitempty"`
+	Content any    `json:"content,omitempty"`
+}
+
+type chatCompletionRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+	Stream   bool          `json:"stream"`
+	User     string        `json:"user,omitempty"`
+}
+
+type chatCompletionChoice struct {
+	Index        int          `json:"index"`
+	Message      *chatMessage `json:"message,omitempty"`
+	Delta        *chatMessage `json:"delta,omitempty"`
+	FinishReason *string      `json:"finish_reason"`
+}
+
+type chatCompletionUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type chatCompletionResponse struct {
+	ID      string                 `json:"id"`
+	Object  string                 `json:"object"`
+	Created int64                  `json:"created"`
+	Model   string                 `json:"model"`
+	Choices []chatCompletionChoice `json:"choices"`
+	Usage   *chatCompletionUsage   `json:"usage,omitempty"`
+}
+
+type openAIError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Code    string `json:"code,omitempty"`
+}
+
+type modelObject struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+func (h *Handlers) handleModels(w http.ResponseWriter, r *http.Request) {
+	principal := PrincipalFromContext(r.Context())
+	ids := h.agentIDs()
+	models := make([]modelObject, 0, len(ids))
+	for _, id := range ids {
+		if !principal.AllowsAgent(id) {
+			continue
+		}
+		models = append(models, modelObject{
+			ID:      modelPrefix + id,
+			Object:  "model",
+			Created: 0,
+			OwnedBy: "tinyclaw",
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"object": "list",
+		"data":   models,
+	})
+}
+
+func (h *Handlers) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req chatCompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid request body", "invalid_request_error", "")
+		return
+	}
+
+	agentID, ok := h.resolveModel(req.Model)
+	if !ok {
+		writeOpenAIError(w, http.StatusNotFound,
+			fmt.Sprintf("The model %q does not exist", req.Model), "invalid_request_error", "model_not_found")
+		return
+	}
+	if !PrincipalFromContext(r.Context()).AllowsAgent(agentID) {
+		writeOpenAIError(w, http.StatusForbidden,
+			fmt.Sprintf("The model %q is not permitted for this credential", req.Model), "permission_error", "")
+		return
+	}
+
+	session := strings.TrimSpace(r.Header.Get(SessionHeader))
+	if session == "" {
+		session = strings.TrimSpace(req.User)
+	}
+
+	content := buildPrompt(req.Messages, session == "")
+	if content == "" {
+		writeOpenAIError(w, http.StatusBadRequest, "messages must contain a user message", "invalid_request_error", "")
+		return
+	}
+
+	ephemeral := session == ""
+	if ephemeral {
+		// Stateless request: the prompt already carries the client's history.
+		session = "ephemeral-" + uuid.NewString()
+	}
+	// Scope the session to the caller so that one credential cannot resume
+	// another's conversation by guessing its session name.
+	scope := session
+	if principal := PrincipalFromContext(r.Context()); principal != nil {
+		scope = principal.Name + ":" + session
+	}
+	c := &completion{
+		agentID:    agentID,
+		sessionKey: fmt.Sprintf("agent:%s:openai:%s", agentID, scope),
+		session:    session,
+		content:    content,
+		ephemeral:  ephemeral,
+	}
+	model := modelPrefix + agentID
+
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Now().Add(10 * time.Minute))
+
+	completionID := "chatcmpl-" + uuid.NewString()
+	created := time.Now().Unix()
+
+	if req.Stream {
+		h.streamCompletion(r.Context(), w, rc, completionID, created, model, c)
+		return
+	}
+
+	result, err := h.runCompletion(r.Context(), c)
+	if err != nil {
+		writeOpenAIError(w, http.StatusInternalServerError, err.Error(), "server_error", "")
+		return
+	}
+
+	stop := "stop"
+	w.Header().Set(SessionHeader, session)
+	writeJSON(w, http.StatusOK, chatCompletionResponse{
+		ID:      completionID,
+		Object:  "chat.completion",
+		Created: created,
+		Model:   model,
+		Choices: []chatCompletionChoice{{
+			Index:        0,
+			Message:      &chatMessage{Role: "assistant", Content: result},
+			FinishReason: &stop,
+		}},
+		Usage: c.usage(),
+	})
+}
+
+// completion is one chat completion dispatch and the token usage of its
+// LLM calls.
+type completion struct {
+	agentID    string
+	sessionKey string
+	session    string
+	content    string
+	ephemeral  bool
+
+	mu     sync.Mutex
+	tokens chatCompletionUsage
+	calls  int
+}
+
+// usage returns the summed usage, or nil when no LLM call reported any.
+func (c *completion) usage() *chatCompletionUsage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.calls == 0 {
+		return nil
+	}
+	u := c.tokens
+	return &u
+}
+
+// runCompletion dispatches c, counting its usage. An ephemeral session is
+// deleted afterwards so stateless requests leave no history behind.
+func (h *Handlers) runCompletion(ctx context.Context, c *completion) (string, error) {
+	if deleter, ok := h.dispatcher.(SessionDeleter); ok && c.ephemeral {
+		defer func() {
+			if _, err := deleter.DeleteSession(c.agentID, c.sessionKey); err != nil {
+				log.Printf("api: failed to delete ephemeral session %s: %v", c.sessionKey, err)
+			}
+		}()
+	}
+	ctx = agent.WithTaskHooks(ctx, &agent.TaskHooks{
+		OnUsage: func(_ string, u providers.UsageInfo, _ float64) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.calls++
+			c.tokens.PromptTokens += u.PromptTokens
+			c.tokens.CompletionTokens += u.CompletionTokens
+			c.tokens.TotalTokens += u.TotalTokens
+		},
+	})
+	return h.dispatcher.ProcessDirectWithChannel(ctx, c.content, c.sessionKey, "api", c.session)
+}
+
+// streamCompletion runs the dispatch and emits it as OpenAI-style SSE chunks.
+// The agent loop is not token-streaming, so the reply arrives as one content
+// chunk; keep-alive comments are written while waiting.
+func (h *Handlers) streamCompletion(
+	ctx context.Context,
+	w http.ResponseWriter,
+	rc *http.ResponseController,
+	id string,
+	created int64,
+	model string,
+	c *completion,
+) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set(SessionHeader, c.session)
+	w.WriteHeader(http.StatusOK)
+
+	chunk := func(delta *chatMessage, finish *string) chatCompletionResponse {
+		return chatCompletionResponse{
+			ID:      id,
+			Object:  "chat.completion.chunk",
+			Created: created,
+			Model:   model,
+			Choices: []chatCompletionChoice{{Index: 0, Delta: delta, FinishReason: finish}},
+		}
+	}
+
+	writeSSE(w, rc, chunk(&chatMessage{Role: "assistant", Content: ""}, nil))
+
+	type outcome struct {
+		result string
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := h.runCompletion(ctx, c)
+		done <- outcome{result: result, err: err}
+	}()
+
+	ticker := time.NewTicker(sseKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			_ = rc.Flush()
+		case out := <-done:
+			if out.err != nil {
+				errBody, _ := json.Marshal(map[string]openAIError{
+					"error": {Message: out.err.Error(), Type: "server_error"},
+				})
+				fmt.Fprintf(w, "data: %s\n\n", errBody)
+			} else {
+				writeSSE(w, rc, chunk(&chatMessage{Content: out.result}, nil))
+				stop := "stop"
+				writeSSE(w, rc, chunk(&chatMessage{}, &stop))
+			}
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			_ = rc.Flush()
+			return
+		}
+	}
+}
+
+// resolveModel maps an OpenAI model name onto a registered agent ID.
+// Accepts "tinyclaw/<agent>" or a bare agent ID; an empty model selects the
+// first (default) agent.
+func (h *Handlers) resolveModel(model string) (string, bool) {
+	ids := h.agentIDs()
+	model = strings.TrimSpace(model)
+	if model == "" {
+		if len(ids) == 0 {
+			return routing.DefaultAgentID, true
+		}
+		return ids[0], true
+	}
+	id := routing.NormalizeAgentID(strings.TrimPrefix(model, modelPrefix))
+	if slices.Contains(ids, id) {
+		return id, true
+	}
+	return "", false
+}
+
+// agentIDs returns the known agent IDs with the default agent first.
+func (h *Handlers) agentIDs() []string {
+	lister, ok := h.dispatcher.(AgentLister)
+	if !ok {
+		return []string{routing.DefaultAgentID}
+	}
+	ids := slices.Clone(lister.ListAgentIDs())
+	slices.Sort(ids)
+	if i := slices.Index(ids, routing.DefaultAgentID); i > 0 {
+		ids = append([]string{routing.DefaultAgentID}, slices.Delete(ids, i, i+1)...)
+	}
+	return ids
+}
+
+// buildPrompt extracts the text to send to the agent. With a persistent
+// session only the latest user message is needed because the agent keeps
+// its own history; without one, earlier turns are included as a transcript.
+func buildPrompt(messages []chatMessage, includeHistory bool) string {
+	last := -1
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			last = i
+			break
+		}
+	}
+	if last < 0 {
+		return ""
+	}
+
+	latest := messageText(messages[last].Content)
+	if !includeHistory || last == 0 {
+		return latest
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Conversation so far:\n")
+	for _, m := range messages[:last] {
+		text := messageText(m.Content)
+		if text == "" {
+			continue
+		}
+		fmt.Fprintf(&sb, "%s: %s\n", m.Role, text)
+	}
+	sb.WriteString("\nCurrent message:\n")
+	sb.WriteString(latest)
+	return sb.String()
+}
+
+// messageText flattens OpenAI message content, which is either a string or
+// a list of typed parts; only text parts are kept.
+func messageText(content any) string {
+	switch c := content.(type) {
+	case string:
+		return c
+	case []any:
+		var parts []string
+		for _, p := range c {
+			part, ok := p.(map[string]any)
+			if !ok {
+				continue
+			}
+			if part["type"] == "text" {
+				if text, ok := part["text"].(string); ok {
+					parts = append(parts, text)
+				}
+			}
+		}
+		return strings.Join(parts, "\n")
+	default:
+		return ""
+	}
+}
+
+func writeSSE(w http.ResponseWriter, rc *http.ResponseController, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("api: failed to encode stream chunk: %v", err)
+		return
+	}
+	fmt.Fprintf(w, "data: %s\n\n", data)
+	_ = rc.Flush()
+}
+
+func writeOpenAIError(w http.ResponseWriter, status int, message, errType, code string) {
+	writeJSON(w, status, map[string]openAIError{
+		"error": {Message: message, Type: errType, Code: code},
+	})
+}
@@ -0,0 +1,294 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// recordingDispatcher captures dispatch arguments and lists agents.
+type recordingDispatcher struct {
+	mockDispatcher
+
+	agents     []string
+	content    string
+	sessionKey string
+	channel    string
+}
+
+func (d *recordingDispatcher) ProcessDirectWithChannel(
+	_ context.Context, content, sessionKey, channel, _ string,
+) (string, error) {
+	d.content = content
+	d.sessionKey = sessionKey
+	d.channel = channel
+	return d.result, d.err
+}
+
+func (d *recordingDispatcher) ListAgentIDs() []string { return d.agents }
+
+// deletingDispatcher records the sessions it is asked to delete.
+type deletingDispatcher struct {
+	recordingDispatcher
+	deleted []string
+}
+
+func (d *deletingDispatcher) DeleteSession(_, key string) (bool, error) {
+	d.deleted = append(d.deleted, key)
+	return true, nil
+}
+
+func TestModels_ListsAgents(t *testing.T) {
+	mux := newTestMux(&recordingDispatcher{agents: []string{"research", "main"}})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var resp struct {
+		Object string        `json:"object"`
+		Data   []modelObject `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Object != "list" || len(resp.Data) != 2 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if resp.Data[0].ID != "tinyclaw/main" || resp.Data[1].ID != "tinyclaw/research" {
+		t.Errorf("expected default agent first, got %+v", resp.Data)
+	}
+}
+
+func TestChatCompletions_SessionFromHeader(t *testing.T) {
+	d := &recordingDispatcher{agents: []string{"main", "research"}}
+	d.result = "hi there"
+	mux := newTestMux(d)
+
+	body := `{"model":"tinyclaw/research","messages":[
+		{"role":"system","content":"be brief"},
+		{"role":"user","content":"first"},
+		{"role":"assistant","content":"ok"},
+		{"role":"user","content":"hello"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(body))
+	req.Header.Set(SessionHeader, "ide-1")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if d.sessionKey != "agent:research:openai:ide-1" {
+		t.Errorf("session key = %q", d.sessionKey)
+	}
+	if d.content != "hello" {
+		t.Errorf("with a session only the latest user message is sent, got %q", d.content)
+	}
+	if d.channel != "api" {
+		t.Errorf("channel = %q", d.channel)
+	}
+
+	var resp chatCompletionResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Object != "chat.completion" || len(resp.Choices) != 1 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if resp.Choices[0].Message.Content != "hi there" {
+		t.Errorf("content = %v", resp.Choices[0].Message.Content)
+	}
+	if resp.Model != "tinyclaw/research" {
+		t.Errorf("model = %q", resp.Model)
+	}
+}
+
+func TestChatCompletions_UserFieldAndBareModel(t *testing.T) {
+	d := &recordingDispatcher{agents: []string{"main"}}
+	mux := newTestMux(d)
+
+	body := `{"model":"main","user":"alice","messages":[{"role":"user","content":[{"type":"text","text":"part one"}]}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(body))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if d.sessionKey != "agent:main:openai:alice" {
+		t.Errorf("session key = %q", d.sessionKey)
+	}
+	if d.content != "part one" {
+		t.Errorf("content = %q", d.content)
+	}
+}
+
+func TestChatCompletions_StatelessIncludesHistory(t *testing.T) {
+	d := &recordingDispatcher{agents: []string{"main"}}
+	mux := newTestMux(d)
+
+	body := `{"model":"tinyclaw/main","messages":[
+		{"role":"user","content":"my name is Bo"},
+		{"role":"assistant","content":"hi Bo"},
+		{"role":"user","content":"what is my name?"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(body))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if !strings.Contains(d.content, "my name is Bo") || !strings.HasSuffix(d.content, "what is my name?") {
+		t.Errorf("expected transcript in content, got %q", d.content)
+	}
+	if !strings.HasPrefix(d.sessionKey, "agent:main:openai:ephemeral-") {
+		t.Errorf("session key = %q", d.sessionKey)
+	}
+}
+
+func TestChatCompletions_StatelessSessionDeleted(t *testing.T) {
+	d := &deletingDispatcher{recordingDispatcher: recordingDispatcher{agents: []string{"main"}}}
+	mux := newTestMux(d)
+
+	body := `{"model":"tinyclaw/main","messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(body))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if len(d.deleted) != 1 || d.deleted[0] != d.sessionKey {
+		t.Errorf("deleted %v, want [%s]", d.deleted, d.sessionKey)
+	}
+	if strings.Contains(rec.Body.String(), `"usage"`) {
+		t.Errorf("usage reported without LLM calls: %s", rec.Body.String())
+	}
+
+	// A named session is kept.
+	req = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(body))
+	req.Header.Set(SessionHeader, "ide-1")
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+	if len(d.deleted) != 1 {
+		t.Errorf("named session deleted: %v", d.deleted)
+	}
+}
+
+func TestChatCompletions_SessionScopedToPrincipal(t *testing.T) {
+	d := &recordingDispatcher{agents: []string{"main", "research"}}
+	mux := newAuthMux(t, d)
+
+	body := `{"model":"tinyclaw/research","user":"alice","messages":[{"role":"user","content":"hi"}]}`
+	if rec := authRequest(mux, http.MethodPost, "/v1/chat/completions", body, researchKey); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if d.sessionKey != "agent:research:openai:research-bot:alice" {
+		t.Errorf("session key = %q", d.sessionKey)
+	}
+}
+
+func TestChatCompletions_UnknownModel(t *testing.T) {
+	mux := newTestMux(&recordingDispatcher{agents: []string{"main"}})
+
+	body := `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(body))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+	var resp map[string]openAIError
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp["error"].Code != "model_not_found" {
+		t.Errorf("error code = %q", resp["error"].Code)
+	}
+}
+
+func TestChatCompletions_NoUserMessage(t *testing.T) {
+	mux := newTestMux(&recordingDispatcher{agents: []string{"main"}})
+
+	body := `{"model":"main","messages":[{"role":"system","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(body))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestChatCompletions_Stream(t *testing.T) {
+	d := &recordingDispatcher{agents: []string{"main"}}
+	d.result = "streamed reply"
+	mux := newTestMux(d)
+
+	body := `{"model":"main","stream":true,"user":"u","messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(body))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type = %q", ct)
+	}
+
+	var chunks []chatCompletionResponse
+	sawDone := false
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		payload := strings.TrimPrefix(line, "data: ")
+		if payload == "[DONE]" {
+			sawDone = true
+			continue
+		}
+		var c chatCompletionResponse
+		if err := json.Unmarshal([]byte(payload), &c); err != nil {
+			t.Fatalf("decode chunk: %v", err)
+		}
+		chunks = append(chunks, c)
+	}
+
+	if !sawDone {
+		t.Error("missing [DONE] terminator")
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", len(chunks))
+	}
+	if chunks[0].Choices[0].Delta.Role != "assistant" {
+		t.Errorf("first chunk should carry the role, got %+v", chunks[0].Choices[0].Delta)
+	}
+	if chunks[1].Choices[0].Delta.Content != "streamed reply" {
+		t.Errorf("content chunk = %+v", chunks[1].Choices[0].Delta)
+	}
+	if fr := chunks[2].Choices[0].FinishReason; fr == nil || *fr != "stop" {
+		t.Errorf("expected finish_reason stop on last chunk")
+	}
+}
+
+func TestChatCompletions_WithoutAgentLister(t *testing.T) {
+	mux := newTestMux(&mockDispatcher{result: "ok"})
+
+	body := `{"model":"tinyclaw/main","messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(body))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+}
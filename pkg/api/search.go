@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/session"
+)
+
+const maxSearchResults = 100
+
+// SessionSearcher is implemented by session browsers with a full-text index
+// of their conversations. GET /api/sessions/search is mounted only when the
+// dispatcher implements it.
+type SessionSearcher interface {
+	SearchSessions(ctx context.Context, query string, opts session.SearchOptions) ([]session.SearchHit, error)
+}
+
+func (h *Handlers) registerSearch(r RouteRegistrar, ss SessionSearcher) {
+	h.searcher = ss
+	r.HandleFunc("GET /api/sessions/search", h.guard(h.handleSearchSessions))
+}
+
+// handleSearchSessions searches the messages of the sessions the principal
+// may access, optionally narrowed to one agent.
+func (h *Handlers) handleSearchSessions(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "q is required"})
+		return
+	}
+	limit, err := queryInt(r, "limit", 10)
+	if err != nil || limit <= 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "limit must be a positive integer"})
+		return
+	}
+	agentID := r.URL.Query().Get("agent")
+
+	principal := PrincipalFromContext(r.Context())
+	hits, err := h.searcher.SearchSessions(r.Context(), query, session.SearchOptions{
+		Limit: min(limit, maxSearchResults),
+		Allow: func(owner, _ string) bool {
+			return (agentID == "" || owner == agentID) && principal.AllowsAgent(owner)
+		},
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"query":   query,
+		"results": hits,
+		"count":   len(hits),
+	})
+}
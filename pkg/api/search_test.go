@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/session"
+)
+
+// searchDispatcher indexes the sessions of a "main" and a "research" agent.
+type searchDispatcher struct {
+	branchDispatcher
+	index *session.SearchIndex
+}
+
+func newSearchDispatcher() *searchDispatcher {
+	d := &searchDispatcher{branchDispatcher: *newBranchDispatcher(), index: session.NewSearchIndex()}
+	research := session.NewSessionManager("")
+	d.index.Attach("main", d.sm)
+	d.index.Attach("research", research)
+	d.sm.AddMessage("api:1", "user", "which database did we pick for billing?")
+	research.AddMessage("api:2", "assistant", "the billing database is Postgres")
+	return d
+}
+
+func (d *searchDispatcher) SearchSessions(
+	ctx context.Context,
+	query string,
+	opts session.SearchOptions,
+) ([]session.SearchHit, error) {
+	return d.index.Search(ctx, query, opts)
+}
+
+func TestSearchSessions(t *testing.T) {
+	mux := newTestMux(newSearchDispatcher())
+
+	search := func(url string) (int, []session.SearchHit) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		var body struct {
+			Results []session.SearchHit `json:"results"`
+		}
+		_ = json.NewDecoder(rec.Body).Decode(&body)
+		return rec.Code, body.Results
+	}
+
+	code, hits := search("/api/sessions/search?q=billing+database")
+	if code != http.StatusOK || len(hits) != 2 {
+		t.Fatalf("search = %d, %+v", code, hits)
+	}
+	if hits[0].SessionKey == "" || hits[0].Snippet == "" || hits[0].Time.IsZero() {
+		t.Errorf("hit missing fields: %+v", hits[0])
+	}
+
+	if _, hits := search("/api/sessions/search?q=billing&agent=research"); len(hits) != 1 || hits[0].AgentID != "research" {
+		t.Errorf("agent filter = %+v", hits)
+	}
+	if code, _ := search("/api/sessions/search"); code != http.StatusBadRequest {
+		t.Errorf("missing q: expected 400, got %d", code)
+	}
+	if code, _ := search("/api/sessions/search?q=x&limit=0"); code != http.StatusBadRequest {
+		t.Errorf("bad limit: expected 400, got %d", code)
+	}
+}
+
+func TestSearchSessions_ScopedToPrincipalAgents(t *testing.T) {
+	h := NewHandlers(newSearchDispatcher())
+	h.searcher = h.dispatcher.(SessionSearcher)
+	ctx := context.WithValue(context.Background(), principalKey{}, &Principal{Name: "bot", Agents: []string{"research"}})
+
+	rec := httptest.NewRecorder()
+	h.handleSearchSessions(rec, httptest.NewRequest(http.MethodGet, "/api/sessions/search?q=billing", nil).WithContext(ctx))
+	var body struct {
+		Results []session.SearchHit `json:"results"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(body.Results) != 1 || body.Results[0].AgentID != "research" {
+		t.Errorf("scoped results = %+v", body.Results)
+	}
+}
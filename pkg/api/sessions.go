@@ -0,0 +1,275 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/agent"
+	"github.com/tinyland-inc/tinyclaw/pkg/session"
+)
+
+const (
+	defaultMessagePage = 50
+	maxMessagePage     = 500
+)
+
+// SessionBrowser is implemented by dispatchers that expose their stored
+// conversations. The /api/sessions endpoints are mounted only when the
+// dispatcher implements it.
+type SessionBrowser interface {
+	ListSessions() []agent.SessionInfo
+	FindSession(agentID, key string) (session.Session, string, bool)
+	DeleteSession(agentID, key string) (bool, error)
+	SummarizeSession(agentID, key string) (string, error)
+}
+
+// SessionPager is implemented by session browsers that can read one page of
+// a conversation without loading all of it.
+type SessionPager interface {
+	FindSessionPage(agentID, key string, offset, limit int) (session.Session, int, string, bool)
+}
+
+type sessionResponse struct {
+	AgentID  string    `json:"agent_id"`
+	Key      string    `json:"key"`
+	Channel  string    `json:"channel,omitempty"`
+	Summary  string    `json:"summary,omitempty"`
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+	Total    int       `json:"total"`
+	Offset   int       `json:"offset"`
+	Limit    int       `json:"limit"`
+	Messages any       `json:"messages"`
+	// MessageIDs identifies Messages, for rewinding and forking.
+	MessageIDs []string `json:"message_ids,omitempty"`
+}
+
+func (h *Handlers) registerSessions(r RouteRegistrar, sb SessionBrowser) {
+	h.sessions = sb
+	r.HandleFunc("GET /api/sessions", h.guard(h.handleListSessions))
+	r.HandleFunc("GET /api/sessions/{key}", h.guard(h.handleGetSession))
+	r.HandleFunc("DELETE /api/sessions/{key}", h.guard(h.handleDeleteSession))
+	r.HandleFunc("POST /api/sessions/{key}/summary", h.guard(h.handleSummarizeSession))
+	r.HandleFunc("GET /api/sessions/{key}/export", h.guard(h.handleExportSession))
+}
+
+func (h *Handlers) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	agentID := q.Get("agent")
+	channel := q.Get("channel")
+
+	var since time.Time
+	if v := q.Get("updated_since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "updated_since must be RFC 3339"})
+			return
+		}
+		since = t
+	}
+
+	principal := PrincipalFromContext(r.Context())
+	sessions := make([]agent.SessionInfo, 0)
+	for _, s := range h.sessions.ListSessions() {
+		if agentID != "" && s.AgentID != agentID {
+			continue
+		}
+		if channel != "" && s.Channel != channel {
+			continue
+		}
+		if !since.IsZero() && s.Updated.Before(since) {
+			continue
+		}
+		if !principal.AllowsAgent(s.AgentID) {
+			continue
+		}
+		sessions = append(sessions, s)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"sessions": sessions,
+		"count":    len(sessions),
+	})
+}
+
+func (h *Handlers) handleGetSession(w http.ResponseWriter, r *http.Request) {
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "offset must be a non-negative integer"})
+		return
+	}
+	limit, err := queryInt(r, "limit", defaultMessagePage)
+	if err != nil || limit <= 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "limit must be a positive integer"})
+		return
+	}
+	limit = min(limit, maxMessagePage)
+
+	var (
+		sess    session.Session
+		agentID string
+		total   int
+	)
+	if pager, ok := h.sessions.(SessionPager); ok {
+		var found bool
+		sess, total, agentID, found = pager.FindSessionPage(r.URL.Query().Get("agent"), r.PathValue("key"), offset, limit)
+		if !found || !PrincipalFromContext(r.Context()).AllowsAgent(agentID) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "session not found"})
+			return
+		}
+		offset = min(offset, total)
+	} else {
+		var found bool
+		sess, agentID, found = h.findSession(w, r)
+		if !found {
+			return
+		}
+		total = len(sess.Messages)
+		offset = min(offset, total)
+		sess.Messages = sess.Messages[offset:min(offset+limit, total)]
+		if len(sess.MessageIDs) == total {
+			sess.MessageIDs = sess.MessageIDs[offset:min(offset+limit, total)]
+		} else {
+			sess.MessageIDs = nil
+		}
+	}
+
+	writeJSON(w, http.StatusOK, sessionResponse{
+		AgentID:    agentID,
+		Key:        sess.Key,
+		Channel:    sess.Channel,
+		Summary:    sess.Summary,
+		Created:    sess.Created,
+		Updated:    sess.Updated,
+		Total:      total,
+		Offset:     offset,
+		Limit:      limit,
+		Messages:   sess.Messages,
+		MessageIDs: sess.MessageIDs,
+	})
+}
+
+func (h *Handlers) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	_, agentID, ok := h.findSession(w, r)
+	if !ok {
+		return
+	}
+	if _, err := h.sessions.DeleteSession(agentID, r.PathValue("key")); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handlers) handleSummarizeSession(w http.ResponseWriter, r *http.Request) {
+	_, agentID, ok := h.findSession(w, r)
+	if !ok {
+		return
+	}
+
+	// Summarization calls the LLM and may take a while.
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Now().Add(3 * time.Minute))
+
+	summary, err := h.sessions.SummarizeSession(agentID, r.PathValue("key"))
+	if errors.Is(err, agent.ErrSummaryInProgress) {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"agent_id": agentID,
+		"key":      r.PathValue("key"),
+		"summary":  summary,
+	})
+}
+
+func (h *Handlers) handleExportSession(w http.ResponseWriter, r *http.Request) {
+	sess, agentID, ok := h.findSession(w, r)
+	if !ok {
+		return
+	}
+
+	name := strings.NewReplacer(":", "_", "/", "_").Replace(sess.Key)
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".json"))
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(struct {
+			AgentID string `json:"agent_id"`
+			session.Session
+		}{AgentID: agentID, Session: sess})
+	case "markdown", "md":
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".md"))
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		fmt.Fprint(w, sessionMarkdown(agentID, sess))
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "format must be json or markdown"})
+	}
+}
+
+// findSession resolves the {key} path value (and optional ?agent=) to a
+// session the caller may access, writing a 404 when there is none.
+func (h *Handlers) findSession(w http.ResponseWriter, r *http.Request) (session.Session, string, bool) {
+	sess, agentID, ok := h.sessions.FindSession(r.URL.Query().Get("agent"), r.PathValue("key"))
+	if !ok || !PrincipalFromContext(r.Context()).AllowsAgent(agentID) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "session not found"})
+		return session.Session{}, "", false
+	}
+	return sess, agentID, true
+}
+
+// sessionMarkdown renders a session as a readable transcript.
+func sessionMarkdown(agentID string, sess session.Session) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Session %s\n\n", sess.Key)
+	fmt.Fprintf(&sb, "- Agent: %s\n", agentID)
+	if sess.Channel != "" {
+		fmt.Fprintf(&sb, "- Channel: %s\n", sess.Channel)
+	}
+	fmt.Fprintf(&sb, "- Created: %s\n", sess.Created.Format(time.RFC3339))
+	fmt.Fprintf(&sb, "- Updated: %s\n", sess.Updated.Format(time.RFC3339))
+	if sess.Summary != "" {
+		fmt.Fprintf(&sb, "\n## Summary\n\n%s\n", sess.Summary)
+	}
+	sb.WriteString("\n## Messages\n")
+	for _, m := range sess.Messages {
+		switch {
+		case m.Role == "tool":
+			fmt.Fprintf(&sb, "\n### tool result (%s)\n\n```\n%s\n```\n", m.ToolCallID, m.Content)
+		case len(m.ToolCalls) > 0:
+			fmt.Fprintf(&sb, "\n### %s\n\n", m.Role)
+			if m.Content != "" {
+				fmt.Fprintf(&sb, "%s\n\n", m.Content)
+			}
+			for _, tc := range m.ToolCalls {
+				args := ""
+				if tc.Function != nil {
+					args = tc.Function.Arguments
+				}
+				fmt.Fprintf(&sb, "- calls `%s` %s\n", tc.Name, args)
+			}
+		default:
+			fmt.Fprintf(&sb, "\n### %s\n\n%s\n", m.Role, m.Content)
+		}
+	}
+	return sb.String()
+}
+
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	return strconv.Atoi(v)
+}
@@ -0,0 +1,193 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/agent"
+	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+	"github.com/tinyland-inc/tinyclaw/pkg/session"
+)
+
+// sessionDispatcher serves sessions from an in-memory map keyed by agent.
+type sessionDispatcher struct {
+	mockDispatcher
+	sessions   map[string]map[string]session.Session
+	summarized string
+}
+
+func newSessionDispatcher() *sessionDispatcher {
+	now := time.Now()
+	msgs := make([]providers.Message, 0, 5)
+	for i := range 5 {
+		msgs = append(msgs, providers.Message{Role: "user", Content: strings.Repeat("m", i+1)})
+	}
+	return &sessionDispatcher{sessions: map[string]map[string]session.Session{
+		"main": {
+			"telegram:1": {Key: "telegram:1", Channel: "telegram", Messages: msgs, Updated: now},
+		},
+		"research": {
+			"agent:research:api": {Key: "agent:research:api", Channel: "api", Updated: now.Add(-48 * time.Hour)},
+		},
+	}}
+}
+
+func (d *sessionDispatcher) ListSessions() []agent.SessionInfo {
+	var infos []agent.SessionInfo
+	for agentID, sessions := range d.sessions {
+		for _, s := range sessions {
+			infos = append(infos, agent.SessionInfo{AgentID: agentID, SessionInfo: session.SessionInfo{
+				Key: s.Key, Channel: s.Channel, MessageCount: len(s.Messages), Updated: s.Updated,
+			}})
+		}
+	}
+	return infos
+}
+
+func (d *sessionDispatcher) FindSession(agentID, key string) (session.Session, string, bool) {
+	for id, sessions := range d.sessions {
+		if agentID != "" && id != agentID {
+			continue
+		}
+		if s, ok := sessions[key]; ok {
+			return s, id, true
+		}
+	}
+	return session.Session{}, "", false
+}
+
+func (d *sessionDispatcher) DeleteSession(agentID, key string) (bool, error) {
+	_, ok := d.sessions[agentID][key]
+	delete(d.sessions[agentID], key)
+	return ok, nil
+}
+
+func (d *sessionDispatcher) SummarizeSession(agentID, key string) (string, error) {
+	d.summarized = agentID + "/" + key
+	return "summary of " + key, nil
+}
+
+func TestSessions_ListFilters(t *testing.T) {
+	mux := newTestMux(newSessionDispatcher())
+
+	cases := map[string]int{
+		"/api/sessions":                  2,
+		"/api/sessions?agent=research":   1,
+		"/api/sessions?channel=telegram": 1,
+		"/api/sessions?updated_since=" + time.Now().Add(-time.Hour).UTC().Format(time.RFC3339): 1,
+	}
+	for path, want := range cases {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", path, rec.Code)
+		}
+		var resp struct {
+			Count int `json:"count"`
+		}
+		_ = json.NewDecoder(rec.Body).Decode(&resp)
+		if resp.Count != want {
+			t.Errorf("%s: count = %d, want %d", path, resp.Count, want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/sessions?updated_since=yesterday", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("bad updated_since: expected 400, got %d", rec.Code)
+	}
+}
+
+func TestSessions_GetPaginated(t *testing.T) {
+	mux := newTestMux(newSessionDispatcher())
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/sessions/telegram:1?offset=1&limit=2", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var resp struct {
+		AgentID  string              `json:"agent_id"`
+		Total    int                 `json:"total"`
+		Messages []providers.Message `json:"messages"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.AgentID != "main" || resp.Total != 5 || len(resp.Messages) != 2 || resp.Messages[0].Content != "mm" {
+		t.Errorf("unexpected page: %+v", resp)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/sessions/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing session: expected 404, got %d", rec.Code)
+	}
+}
+
+func TestSessions_DeleteAndSummarize(t *testing.T) {
+	d := newSessionDispatcher()
+	mux := newTestMux(d)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/sessions/agent:research:api/summary", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("summary: expected 200, got %d", rec.Code)
+	}
+	if d.summarized != "research/agent:research:api" {
+		t.Errorf("summarized = %q", d.summarized)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/sessions/telegram:1", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d", rec.Code)
+	}
+	if _, ok := d.sessions["main"]["telegram:1"]; ok {
+		t.Error("session not deleted")
+	}
+}
+
+func TestSessions_Export(t *testing.T) {
+	mux := newTestMux(newSessionDispatcher())
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/sessions/telegram:1/export?format=markdown", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Header().Get("Content-Disposition"), "telegram_1.md") {
+		t.Errorf("Content-Disposition = %q", rec.Header().Get("Content-Disposition"))
+	}
+	if !strings.Contains(rec.Body.String(), "# Session telegram:1") {
+		t.Errorf("unexpected markdown: %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/sessions/telegram:1/export", nil))
+	var exported struct {
+		AgentID  string              `json:"agent_id"`
+		Messages []providers.Message `json:"messages"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&exported); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if exported.AgentID != "main" || len(exported.Messages) != 5 {
+		t.Errorf("unexpected export: %+v", exported)
+	}
+}
+
+func TestSessions_NotMountedWithoutBrowser(t *testing.T) {
+	mux := newTestMux(&mockDispatcher{})
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/sessions", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", rec.Code)
+	}
+}
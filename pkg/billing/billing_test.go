@@ -0,0 +1,149 @@
+package billing
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+)
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestPrice_Cost(t *testing.T) {
+	p := Price{Input: 3, Output: 15, Cached: 0.3}
+	// 1M prompt tokens of which 400k cached, 100k completion.
+	got := p.Cost(1_000_000, 100_000, 400_000)
+	want := 0.6*3 + 0.4*0.3 + 0.1*15
+	if !almostEqual(got, want) {
+		t.Errorf("Cost = %v, want %v", got, want)
+	}
+
+	// Without a cached rate, cached tokens cost the input rate.
+	if got := (Price{Input: 2, Output: 8}).Cost(500_000, 0, 500_000); !almostEqual(got, 1) {
+		t.Errorf("Cost without cached rate = %v, want 1", got)
+	}
+}
+
+func TestPriceTable_Lookup(t *testing.T) {
+	table := NewPriceTable([]config.ModelConfig{
+		{ModelName: "house", Model: "openai/my-finetune", Pricing: &config.ModelPricing{Input: 1, Output: 2}},
+	})
+
+	cases := map[string]Price{
+		"gpt-4o":                   DefaultPrices["gpt-4o"],
+		"openai/gpt-4o-mini":       DefaultPrices["gpt-4o-mini"],
+		"claude-sonnet-4-20250514": DefaultPrices["claude-sonnet-4"],
+		"house":                    {Input: 1, Output: 2},
+		"my-finetune":              {Input: 1, Output: 2},
+		"openai/my-finetune":       {Input: 1, Output: 2},
+	}
+	for model, want := range cases {
+		got, ok := table.Lookup(model)
+		if !ok || got != want {
+			t.Errorf("Lookup(%q) = %+v, %v; want %+v", model, got, ok, want)
+		}
+	}
+	if _, ok := table.Lookup("mystery-model"); ok {
+		t.Error("unknown model should not be priced")
+	}
+}
+
+func TestLedger_RecordAndReload(t *testing.T) {
+	dir := t.TempDir()
+	day := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	l := NewLedger(dir)
+	entries := []Entry{
+		{Time: day, AgentID: "main", SessionKey: "s1", SenderID: "alice", Model: "gpt-4o", InputTokens: 100, OutputTokens: 10, CostUSD: 0.5},
+		{Time: day, AgentID: "main", SessionKey: "s2", SenderID: "bob", Model: "gpt-4o", InputTokens: 50, OutputTokens: 5, CostUSD: 0.25},
+		{Time: day.AddDate(0, 0, -1), AgentID: "research", SenderID: "alice", Model: "o3", CostUSD: 1},
+	}
+	for _, e := range entries {
+		if err := l.Record(e); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	reloaded := NewLedger(dir)
+	today := reloaded.Breakdown(PeriodDay, day)
+	if today.Total.Calls != 2 || !almostEqual(today.Total.CostUSD, 0.75) {
+		t.Errorf("day total = %+v", today.Total)
+	}
+	if got := reloaded.Spent(PeriodDay, ScopeSender, "alice", day); !almostEqual(got.CostUSD, 0.5) {
+		t.Errorf("alice today = %+v", got)
+	}
+	if got := reloaded.Spent(PeriodMonth, ScopeSender, "alice", day); !almostEqual(got.CostUSD, 1.5) {
+		t.Errorf("alice this month = %+v", got)
+	}
+	if got := reloaded.Spent(PeriodMonth, ScopeAgent, "research", day); got.Calls != 1 {
+		t.Errorf("research this month = %+v", got)
+	}
+	if got := reloaded.Spent(PeriodDay, ScopeSession, "s2", day); got.InputTokens != 50 {
+		t.Errorf("session s2 today = %+v", got)
+	}
+}
+
+func TestBudget_Check(t *testing.T) {
+	ledger := NewLedger(t.TempDir())
+	now := time.Now()
+	record := func(sender string, cost float64) {
+		if err := ledger.Record(Entry{Time: now, AgentID: "main", SenderID: sender, CostUSD: cost}); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	b, err := NewBudget([]config.BudgetRule{
+		{Scope: "sender", Period: "day", SoftUSD: 1, HardUSD: 2},
+		{Scope: "agent", Match: "main", Period: "month", HardUSD: 5, Action: "downgrade", DowngradeModel: "cheap"},
+	}, []config.ModelConfig{{ModelName: "cheap", Model: "openai/gpt-4o-mini"}}, ledger)
+	if err != nil {
+		t.Fatalf("NewBudget: %v", err)
+	}
+	alice := Subject{AgentID: "main", SenderID: "alice"}
+
+	if d := b.Check(alice); d.Err != nil || d.Model != "" || len(d.Warnings) != 0 {
+		t.Fatalf("fresh budget: %+v", d)
+	}
+
+	record("alice", 1.5)
+	d := b.Check(alice)
+	if len(d.Warnings) != 1 || d.Err != nil {
+		t.Fatalf("soft limit: %+v", d)
+	}
+	if d := b.Check(alice); len(d.Warnings) != 0 {
+		t.Errorf("soft warning should fire once per period: %+v", d)
+	}
+
+	record("alice", 1)
+	if d := b.Check(alice); !errors.Is(d.Err, ErrBudgetExceeded) {
+		t.Errorf("hard limit: %+v", d)
+	}
+	if d := b.Check(Subject{AgentID: "main", SenderID: "bob"}); d.Err != nil {
+		t.Errorf("bob has a separate sender budget: %+v", d)
+	}
+
+	record("", 3) // e.g. cron runs, which have no per-sender budget
+	if d := b.Check(Subject{AgentID: "main", SenderID: "bob"}); d.Model != "cheap" || d.Err != nil {
+		t.Errorf("agent budget should downgrade: %+v", d)
+	}
+}
+
+func TestNewBudget_Invalid(t *testing.T) {
+	bad := []config.BudgetRule{
+		{Scope: "team", Period: "day", HardUSD: 1},
+		{Scope: "agent", Period: "week", HardUSD: 1},
+		{Scope: "agent", Period: "day", HardUSD: 1, Action: "downgrade"},
+		{Scope: "agent", Period: "day", HardUSD: 1, Action: "downgrade", DowngradeModel: "missing"},
+		{Scope: "agent", Period: "day"},
+	}
+	models := []config.ModelConfig{{ModelName: "cheap", Model: "openai/gpt-4o-mini"}}
+	for _, r := range bad {
+		if _, err := NewBudget([]config.BudgetRule{r}, models, NewLedger(t.TempDir())); err == nil {
+			t.Errorf("NewBudget(%+v) should fail", r)
+		}
+	}
+}
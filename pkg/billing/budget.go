@@ -0,0 +1,177 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+)
+
+// ErrBudgetExceeded is returned when a hard budget refuses an LLM call.
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+const (
+	ActionRefuse    = "refuse"
+	ActionDowngrade = "downgrade"
+)
+
+// Subject identifies who an LLM call is made for.
+type Subject struct {
+	AgentID    string
+	SessionKey string
+	SenderID   string
+}
+
+func (s Subject) key(scope Scope) string {
+	switch scope {
+	case ScopeAgent:
+		return s.AgentID
+	case ScopeSession:
+		return s.SessionKey
+	case ScopeSender:
+		return s.SenderID
+	}
+	return ""
+}
+
+// Decision is the outcome of a budget check.
+type Decision struct {
+	// Warnings lists soft limits crossed for the first time this period.
+	Warnings []string
+	// Err wraps ErrBudgetExceeded when a hard limit refuses the call.
+	Err error
+	// Model is the model to use instead when a hard limit downgrades.
+	Model string
+}
+
+// BudgetStatus is the current spend against one rule.
+type BudgetStatus struct {
+	Rule     config.BudgetRule
+	Key      string
+	SpentUSD float64
+
+	index int
+}
+
+// Budget checks spend recorded in a ledger against configured rules.
+type Budget struct {
+	rules  []config.BudgetRule
+	ledger *Ledger
+	now    func() time.Time
+
+	mu     sync.Mutex
+	warned map[string]bool
+}
+
+// NewBudget validates rules and returns a budget enforcing them. A rule's
+// downgrade_model must be a model_name in models.
+func NewBudget(rules []config.BudgetRule, models []config.ModelConfig, ledger *Ledger) (*Budget, error) {
+	for i, r := range rules {
+		switch Scope(r.Scope) {
+		case ScopeGlobal, ScopeAgent, ScopeSession, ScopeSender:
+		default:
+			return nil, fmt.Errorf("budget rule %d: unknown scope %q", i, r.Scope)
+		}
+		switch Period(r.Period) {
+		case PeriodDay, PeriodMonth:
+		default:
+			return nil, fmt.Errorf("budget rule %d: period must be day or month, got %q", i, r.Period)
+		}
+		switch r.Action {
+		case "", ActionRefuse:
+		case ActionDowngrade:
+			if r.DowngradeModel == "" {
+				return nil, fmt.Errorf("budget rule %d: downgrade requires downgrade_model", i)
+			}
+			if !slices.ContainsFunc(models, func(mc config.ModelConfig) bool { return mc.ModelName == r.DowngradeModel }) {
+				return nil, fmt.Errorf("budget rule %d: downgrade_model %q is not a model_name in model_list", i, r.DowngradeModel)
+			}
+		default:
+			return nil, fmt.Errorf("budget rule %d: unknown action %q", i, r.Action)
+		}
+		if r.SoftUSD <= 0 && r.HardUSD <= 0 {
+			return nil, fmt.Errorf("budget rule %d: set soft_usd or hard_usd", i)
+		}
+	}
+	return &Budget{rules: rules, ledger: ledger, now: time.Now, warned: make(map[string]bool)}, nil
+}
+
+// Check evaluates every rule that applies to s.
+func (b *Budget) Check(s Subject) Decision {
+	var d Decision
+	now := b.now()
+
+	for _, st := range b.Status(s) {
+		r := st.Rule
+		label := ruleLabel(r, st.Key)
+
+		if r.HardUSD > 0 && st.SpentUSD >= r.HardUSD {
+			if r.Action == ActionDowngrade {
+				if d.Model == "" {
+					d.Model = r.DowngradeModel
+				}
+				continue
+			}
+			d.Err = fmt.Errorf("%w: %s spent $%.2f of $%.2f", ErrBudgetExceeded, label, st.SpentUSD, r.HardUSD)
+			return d
+		}
+
+		if r.SoftUSD > 0 && st.SpentUSD >= r.SoftUSD {
+			warnKey := fmt.Sprintf("%d|%s|%s", st.index, st.Key, periodKey(Period(r.Period), now))
+			b.mu.Lock()
+			first := !b.warned[warnKey]
+			b.warned[warnKey] = true
+			b.mu.Unlock()
+			if first {
+				d.Warnings = append(d.Warnings, fmt.Sprintf("%s has spent $%.2f, past its $%.2f warning threshold",
+					label, st.SpentUSD, r.SoftUSD))
+			}
+		}
+	}
+	return d
+}
+
+// Status returns the current spend for each rule that applies to s, in rule
+// order.
+func (b *Budget) Status(s Subject) []BudgetStatus {
+	now := b.now()
+	var out []BudgetStatus
+	for i, r := range b.rules {
+		scope := Scope(r.Scope)
+		key := s.key(scope)
+		if scope != ScopeGlobal {
+			if key == "" || (r.Match != "" && r.Match != "*" && r.Match != key) {
+				continue
+			}
+		}
+		spent := b.ledger.Spent(Period(r.Period), scope, key, now)
+		out = append(out, BudgetStatus{Rule: r, Key: key, SpentUSD: spent.CostUSD, index: i})
+	}
+	return out
+}
+
+func ruleLabel(r config.BudgetRule, key string) string {
+	period := "daily"
+	if Period(r.Period) == PeriodMonth {
+		period = "monthly"
+	}
+	if Scope(r.Scope) == ScopeGlobal {
+		return period + " global budget"
+	}
+	return fmt.Sprintf("%s %s budget for %s", period, r.Scope, key)
+}
+
+func periodKey(p Period, t time.Time) string {
+	if p == PeriodMonth {
+		return t.Format("2006-01")
+	}
+	return t.Format("2006-01-02")
+}
+
+// Label describes the rule and the key it was applied to.
+func (s BudgetStatus) Label() string {
+	return ruleLabel(s.Rule, s.Key)
+}
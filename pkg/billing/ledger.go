@@ -0,0 +1,233 @@
+package billing
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Period selects a ledger window.
+type Period string
+
+const (
+	PeriodDay   Period = "day"
+	PeriodMonth Period = "month"
+)
+
+// Scope selects the dimension spend is aggregated by.
+type Scope string
+
+const (
+	ScopeGlobal  Scope = "global"
+	ScopeAgent   Scope = "agent"
+	ScopeSession Scope = "session"
+	ScopeSender  Scope = "sender"
+)
+
+// Totals aggregates usage and cost.
+type Totals struct {
+	Calls        int64   `json:"calls"`
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	CachedTokens int64   `json:"cached_tokens,omitempty"`
+	CostUSD      float64 `json:"cost_usd"`
+}
+
+func (t *Totals) add(e Entry) {
+	t.Calls++
+	t.InputTokens += int64(e.InputTokens)
+	t.OutputTokens += int64(e.OutputTokens)
+	t.CachedTokens += int64(e.CachedTokens)
+	t.CostUSD += e.CostUSD
+}
+
+// Breakdown is the spend of one period, in total and per dimension.
+type Breakdown struct {
+	Total    Totals             `json:"total"`
+	Agents   map[string]*Totals `json:"agents,omitempty"`
+	Sessions map[string]*Totals `json:"sessions,omitempty"`
+	Senders  map[string]*Totals `json:"senders,omitempty"`
+	Models   map[string]*Totals `json:"models,omitempty"`
+}
+
+func (b *Breakdown) add(e Entry) {
+	b.Total.add(e)
+	addTo(&b.Agents, e.AgentID, e)
+	addTo(&b.Sessions, e.SessionKey, e)
+	addTo(&b.Senders, e.SenderID, e)
+	addTo(&b.Models, e.Model, e)
+}
+
+func addTo(m *map[string]*Totals, key string, e Entry) {
+	if key == "" {
+		return
+	}
+	if *m == nil {
+		*m = make(map[string]*Totals)
+	}
+	t, ok := (*m)[key]
+	if !ok {
+		t = &Totals{}
+		(*m)[key] = t
+	}
+	t.add(e)
+}
+
+// Get returns the totals for scope and key.
+func (b *Breakdown) Get(scope Scope, key string) Totals {
+	var m map[string]*Totals
+	switch scope {
+	case ScopeGlobal:
+		return b.Total
+	case ScopeAgent:
+		m = b.Agents
+	case ScopeSession:
+		m = b.Sessions
+	case ScopeSender:
+		m = b.Senders
+	}
+	if t, ok := m[key]; ok {
+		return *t
+	}
+	return Totals{}
+}
+
+func (b *Breakdown) clone() Breakdown {
+	c := Breakdown{Total: b.Total}
+	for _, pair := range []struct {
+		dst *map[string]*Totals
+		src map[string]*Totals
+	}{{&c.Agents, b.Agents}, {&c.Sessions, b.Sessions}, {&c.Senders, b.Senders}, {&c.Models, b.Models}} {
+		if pair.src == nil {
+			continue
+		}
+		*pair.dst = make(map[string]*Totals, len(pair.src))
+		for k, v := range pair.src {
+			t := *v
+			(*pair.dst)[k] = &t
+		}
+	}
+	return c
+}
+
+// Entry is the usage of one LLM call.
+type Entry struct {
+	Time         time.Time
+	AgentID      string
+	SessionKey   string
+	SenderID     string
+	Model        string
+	InputTokens  int
+	OutputTokens int
+	CachedTokens int
+	CostUSD      float64
+}
+
+// monthLedger is the on-disk form of one month: the month's breakdown plus
+// one breakdown per day.
+type monthLedger struct {
+	Month Breakdown             `json:"month"`
+	Days  map[string]*Breakdown `json:"days"`
+}
+
+// Ledger persists spend as one JSON file per month (YYYY-MM.json) in dir.
+type Ledger struct {
+	dir string
+
+	mu     sync.Mutex
+	months map[string]*monthLedger
+}
+
+// NewLedger creates a ledger stored in dir. Files are loaded on first use.
+func NewLedger(dir string) *Ledger {
+	return &Ledger{dir: dir, months: make(map[string]*monthLedger)}
+}
+
+// Record adds e to its day and month and saves the month file.
+func (l *Ledger) Record(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	monthKey, dayKey := e.Time.Format("2006-01"), e.Time.Format("2006-01-02")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	m := l.month(monthKey)
+	m.Month.add(e)
+	day, ok := m.Days[dayKey]
+	if !ok {
+		day = &Breakdown{}
+		m.Days[dayKey] = day
+	}
+	day.add(e)
+
+	return l.save(monthKey, m)
+}
+
+// Breakdown returns a copy of the spend for the period containing t.
+func (l *Ledger) Breakdown(period Period, t time.Time) Breakdown {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	m := l.month(t.Format("2006-01"))
+	if period == PeriodMonth {
+		return m.Month.clone()
+	}
+	if day, ok := m.Days[t.Format("2006-01-02")]; ok {
+		return day.clone()
+	}
+	return Breakdown{}
+}
+
+// Spent returns the totals for scope and key in the period containing t.
+func (l *Ledger) Spent(period Period, scope Scope, key string, t time.Time) Totals {
+	b := l.Breakdown(period, t)
+	return b.Get(scope, key)
+}
+
+// month returns the ledger for monthKey, loading it from disk when needed.
+// Must be called with l.mu held.
+func (l *Ledger) month(monthKey string) *monthLedger {
+	if m, ok := l.months[monthKey]; ok {
+		return m
+	}
+	m := &monthLedger{}
+	data, err := os.ReadFile(l.path(monthKey))
+	if err == nil {
+		_ = json.Unmarshal(data, m)
+	}
+	if m.Days == nil {
+		m.Days = make(map[string]*Breakdown)
+	}
+	l.months[monthKey] = m
+	return m
+}
+
+func (l *Ledger) path(monthKey string) string {
+	return filepath.Join(l.dir, monthKey+".json")
+}
+
+// save writes a month atomically. Must be called with l.mu held.
+func (l *Ledger) save(monthKey string, m *monthLedger) error {
+	if err := os.MkdirAll(l.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create ledger directory: %w", err)
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal ledger: %w", err)
+	}
+	path := l.path(monthKey)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write ledger: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to rename ledger: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// Package billing turns LLM token usage into cost, keeps daily and monthly
+// spend ledgers, and enforces spending budgets.
+package billing
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+)
+
+// Price is the cost of a model in USD per million tokens.
+type Price struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+	Cached float64 `json:"cached,omitempty"`
+}
+
+// Cost returns the USD cost of one call. cachedTokens is the part of
+// promptTokens served from the prompt cache; it is billed at the cached rate
+// when the price has one and at the input rate otherwise.
+func (p Price) Cost(promptTokens, completionTokens, cachedTokens int) float64 {
+	cachedTokens = min(cachedTokens, promptTokens)
+	cachedRate := p.Cached
+	if cachedRate == 0 {
+		cachedRate = p.Input
+	}
+	return (float64(promptTokens-cachedTokens)*p.Input +
+		float64(cachedTokens)*cachedRate +
+		float64(completionTokens)*p.Output) / 1e6
+}
+
+// DefaultPrices are list prices for common models at the time of writing.
+// Keys match a model ID exactly or as a prefix ("claude-sonnet-4" covers
+// "claude-sonnet-4-20250514"). Override them per model in model_list.
+var DefaultPrices = map[string]Price{
+	"gpt-4o":            {Input: 2.5, Output: 10, Cached: 1.25},
+	"gpt-4o-mini":       {Input: 0.15, Output: 0.6, Cached: 0.075},
+	"gpt-4.1":           {Input: 2, Output: 8, Cached: 0.5},
+	"gpt-4.1-mini":      {Input: 0.4, Output: 1.6, Cached: 0.1},
+	"gpt-4.1-nano":      {Input: 0.1, Output: 0.4, Cached: 0.025},
+	"gpt-5":             {Input: 1.25, Output: 10, Cached: 0.125},
+	"gpt-5-mini":        {Input: 0.25, Output: 2, Cached: 0.025},
+	"gpt-5-nano":        {Input: 0.05, Output: 0.4, Cached: 0.005},
+	"o3":                {Input: 2, Output: 8, Cached: 0.5},
+	"o3-mini":           {Input: 1.1, Output: 4.4, Cached: 0.55},
+	"o4-mini":           {Input: 1.1, Output: 4.4, Cached: 0.275},
+	"claude-opus-4":     {Input: 15, Output: 75, Cached: 1.5},
+	"claude-sonnet-4":   {Input: 3, Output: 15, Cached: 0.3},
+	"claude-haiku-4":    {Input: 1, Output: 5, Cached: 0.1},
+	"claude-3-5-haiku":  {Input: 0.8, Output: 4, Cached: 0.08},
+	"gemini-2.5-pro":    {Input: 1.25, Output: 10, Cached: 0.31},
+	"gemini-2.5-flash":  {Input: 0.3, Output: 2.5, Cached: 0.075},
+	"deepseek-chat":     {Input: 0.27, Output: 1.1, Cached: 0.07},
+	"deepseek-reasoner": {Input: 0.55, Output: 2.19, Cached: 0.14},
+}
+
+// PriceTable resolves model names to prices.
+type PriceTable struct {
+	prices map[string]Price
+	keys   []string // longest first, for prefix matching
+}
+
+// NewPriceTable builds a table from DefaultPrices and the pricing overrides
+// in modelList. An override is registered under the model's alias and its
+// model ID, with and without the protocol prefix.
+func NewPriceTable(modelList []config.ModelConfig) *PriceTable {
+	prices := make(map[string]Price, len(DefaultPrices))
+	for k, v := range DefaultPrices {
+		prices[k] = v
+	}
+	for _, mc := range modelList {
+		if mc.Pricing == nil {
+			continue
+		}
+		p := Price{Input: mc.Pricing.Input, Output: mc.Pricing.Output, Cached: mc.Pricing.Cached}
+		for _, name := range []string{mc.ModelName, mc.Model, stripProvider(mc.Model)} {
+			if name != "" {
+				prices[name] = p
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(prices))
+	for k := range prices {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+	return &PriceTable{prices: prices, keys: keys}
+}
+
+// Lookup returns the price of model, trying the exact name, the name without
+// a "provider/" prefix, and finally the longest known prefix.
+func (t *PriceTable) Lookup(model string) (Price, bool) {
+	if p, ok := t.prices[model]; ok {
+		return p, true
+	}
+	bare := stripProvider(model)
+	if p, ok := t.prices[bare]; ok {
+		return p, true
+	}
+	for _, k := range t.keys {
+		if strings.HasPrefix(bare, k) {
+			return t.prices[k], true
+		}
+	}
+	return Price{}, false
+}
+
+func stripProvider(model string) string {
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		return model[i+1:]
+	}
+	return model
+}
@@ -3,6 +3,8 @@ package bus
 import (
 	"context"
 	"sync"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/metrics"
 )
 
 type MessageBus struct {
@@ -27,6 +29,7 @@ func (mb *MessageBus) PublishInbound(msg InboundMessage) {
 	if mb.closed {
 		return
 	}
+	metrics.ChannelMessages.Inc(msg.Channel, "inbound")
 	mb.inbound <- msg
 }
 
@@ -45,6 +48,7 @@ func (mb *MessageBus) PublishOutbound(msg OutboundMessage) {
 	if mb.closed {
 		return
 	}
+	metrics.ChannelMessages.Inc(msg.Channel, "outbound")
 	mb.outbound <- msg
 }
 
@@ -57,6 +61,16 @@ func (mb *MessageBus) SubscribeOutbound(ctx context.Context) (OutboundMessage, b
 	}
 }
 
+// InboundDepth returns the number of inbound messages waiting to be consumed.
+func (mb *MessageBus) InboundDepth() int {
+	return len(mb.inbound)
+}
+
+// OutboundDepth returns the number of outbound messages waiting to be delivered.
+func (mb *MessageBus) OutboundDepth() int {
+	return len(mb.outbound)
+}
+
 func (mb *MessageBus) RegisterHandler(channel string, handler MessageHandler) {
 	mb.mu.Lock()
 	defer mb.mu.Unlock()
@@ -9,33 +9,62 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/tinyland-inc/tinyclaw/pkg/agent"
 	"github.com/tinyland-inc/tinyclaw/pkg/campaign"
+	"github.com/tinyland-inc/tinyclaw/pkg/providers"
 )
 
-// TinyClawAdapter dispatches campaign steps to the local TinyClaw agent loop.
+// TinyClawAdapter dispatches campaign steps to agents of the local TinyClaw
+// agent loop. Under a campaign runner it offers the agent only the step's
+// tools, checks the guardrails before every LLM and tool call, and reports
+// token usage and cost back into the execution.
 type TinyClawAdapter struct {
-	// ProcessFn is the function to call for processing a message.
-	// This is wired to agent.AgentLoop.ProcessDirect at gateway startup.
-	ProcessFn func(ctx context.Context, prompt, sessionKey string) (string, error)
+	loop *agent.AgentLoop
 }
 
-// NewTinyClawAdapter creates a new adapter for the local TinyClaw agent.
-func NewTinyClawAdapter() *TinyClawAdapter {
-	return &TinyClawAdapter{}
+// NewTinyClawAdapter creates a new adapter for the local TinyClaw agent loop.
+func NewTinyClawAdapter(loop *agent.AgentLoop) *TinyClawAdapter {
+	return &TinyClawAdapter{loop: loop}
 }
 
 func (a *TinyClawAdapter) Execute(ctx context.Context, agentID, prompt string, tools []string) (string, error) {
-	if a.ProcessFn == nil {
-		return "", errors.New("tinyclaw adapter not initialized: ProcessFn is nil")
+	if a.loop == nil {
+		return "", errors.New("tinyclaw adapter not initialized: agent loop is nil")
 	}
 	sessionKey := "campaign:" + agentID
-	return a.ProcessFn(ctx, prompt, sessionKey)
+	hooks := &agent.TaskHooks{Tools: append([]string{}, tools...)}
+	if run := campaign.StepRunFromContext(ctx); run != nil {
+		sessionKey = "campaign:" + run.ExecutionID() + ":" + run.StepName()
+		hooks.Tools = run.Tools()
+		hooks.BeforeIteration = run.BeginIteration
+		hooks.BeforeToolCall = run.AllowTool
+		hooks.OnUsage = func(_ string, usage providers.UsageInfo, costUSD float64) {
+			run.AddUsage(usage.TotalTokens, costUSD)
+		}
+	}
+	return a.loop.RunTask(agent.WithTaskHooks(ctx, hooks), agentID, sessionKey, prompt)
 }
 
 func (a *TinyClawAdapter) Name() string { return "tinyclaw" }
 
-// Ensure TinyClawAdapter implements BackendAdapter
-var _ campaign.BackendAdapter = (*TinyClawAdapter)(nil)
+// HasTool reports whether agent agentID of the loop has the named tool.
+func (a *TinyClawAdapter) HasTool(agentID, name string) bool {
+	if a.loop == nil {
+		return false
+	}
+	instance, ok := a.loop.GetAgent(agentID)
+	if !ok {
+		return false
+	}
+	_, ok = instance.Tools.Get(name)
+	return ok
+}
+
+// Ensure TinyClawAdapter implements BackendAdapter and ToolChecker
+var (
+	_ campaign.BackendAdapter = (*TinyClawAdapter)(nil)
+	_ campaign.ToolChecker    = (*TinyClawAdapter)(nil)
+)
 
 // StubAdapter is a test/development adapter that returns canned responses.
 type StubAdapter struct {
@@ -0,0 +1,184 @@
+package campaign
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/logger"
+)
+
+// feedbackTimeout bounds the delivery of one report.
+const feedbackTimeout = 2 * time.Minute
+
+// maxReportOutput caps each step's output in a report, in runes.
+const maxReportOutput = 4000
+
+// FeedbackTarget says where a campaign's report goes. Which fields apply
+// depends on the feedback policy; empty fields keep the sink's configured
+// defaults.
+type FeedbackTarget struct {
+	// Channel and ChatID receive the report for FeedbackChannel.
+	Channel string `json:"channel,omitempty"`
+	ChatID  string `json:"chat_id,omitempty"`
+	// Repo is the "owner/name" GitHub repository for FeedbackGitHubIssue
+	// and FeedbackGitHubPR.
+	Repo string `json:"repo,omitempty"`
+	// Head is the branch a FeedbackGitHubPR pull request proposes, e.g.
+	// one a step pushed; Base is the branch it merges into.
+	Head string `json:"head,omitempty"`
+	Base string `json:"base,omitempty"`
+	// Secret is the name the report is stored under for FeedbackSetec.
+	Secret string `json:"secret,omitempty"`
+}
+
+// Redirects reports whether the target sends the report somewhere other
+// than the sink's configured chat, repository or secret. Head and Base only
+// pick branches within the configured repository.
+func (t FeedbackTarget) Redirects() bool {
+	return t.Channel != "" || t.ChatID != "" || t.Repo != "" || t.Secret != ""
+}
+
+// Report is the outcome of a finished execution, formatted for delivery.
+type Report struct {
+	// Execution is a snapshot of the finished execution.
+	Execution *Execution
+	Policy    FeedbackPolicy
+	Target    FeedbackTarget
+	// Title names the campaign, so that sinks can find the issue or pull
+	// request of earlier runs and update it.
+	Title string
+	// Body is the Markdown report.
+	Body string
+}
+
+// FeedbackSink delivers campaign reports for one or more feedback policies.
+type FeedbackSink interface {
+	// Deliver sends the report and returns where it went, such as an issue
+	// URL, for the execution's record.
+	Deliver(ctx context.Context, report *Report) (string, error)
+}
+
+// FeedbackResult records the delivery of an execution's report.
+type FeedbackResult struct {
+	Policy FeedbackPolicy `json:"policy"`
+	Time   time.Time      `json:"time"`
+	// Ref says where the report went, such as an issue URL.
+	Ref string `json:"ref,omitempty"`
+	// Error is why the report could not be delivered.
+	Error string `json:"error,omitempty"`
+}
+
+// RegisterSink registers the sink that delivers reports of campaigns with
+// the given feedback policy.
+func (r *Runner) RegisterSink(policy FeedbackPolicy, sink FeedbackSink) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sinks[policy] = sink
+}
+
+// newReport formats the report of a finished execution. Must be called
+// with r.mu held.
+func newReport(exec *Execution) *Report {
+	def := exec.Definition
+	report := &Report{
+		Execution: exec.snapshot(),
+		Policy:    def.Feedback,
+		Target:    def.FeedbackTarget,
+		Title:     "[campaign] " + def.Name,
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s: %s\n\n", def.Name, exec.Status)
+	if def.Description != "" {
+		fmt.Fprintf(&b, "%s\n\n", def.Description)
+	}
+	fmt.Fprintf(&b, "Execution `%s` started %s", exec.ID, exec.StartTime.UTC().Format(time.RFC3339))
+	if !exec.EndTime.IsZero() {
+		fmt.Fprintf(&b, " and ran %s", exec.EndTime.Sub(exec.StartTime).Round(time.Second))
+	}
+	fmt.Fprintf(&b, ": %d iteration(s), %d tool call(s), %d¢.\n", exec.Iterations, exec.ToolCalls, exec.SpentCents)
+	if exec.Error != "" {
+		fmt.Fprintf(&b, "\n**Halted:** %s\n", exec.Error)
+	}
+	if exec.KillSwitchUsed {
+		b.WriteString("\n**Stopped with the kill switch.**\n")
+	}
+
+	if len(exec.Results) > 0 {
+		b.WriteString("\n| Step | Status | Attempts | Duration |\n|---|---|---|---|\n")
+		for _, res := range exec.Results {
+			fmt.Fprintf(&b, "| %s | %s | %d | %s |\n",
+				res.StepName, res.Status, res.Attempts, res.Duration.Round(time.Second))
+		}
+	}
+	for _, res := range exec.Results {
+		if res.Output == "" && res.Error == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "\n### %s\n\n", res.StepName)
+		if res.Error != "" {
+			fmt.Fprintf(&b, "_%s_\n\n", res.Error)
+		}
+		if res.Output != "" {
+			b.WriteString(truncateRunes(strings.TrimSpace(res.Output), maxReportOutput))
+			b.WriteString("\n")
+		}
+	}
+	report.Body = b.String()
+	return report
+}
+
+// deliverFeedback hands the report to the sink of its feedback policy and
+// records the outcome on the execution.
+func (r *Runner) deliverFeedback(ctx context.Context, exec *Execution, report *Report) {
+	if report.Policy == "" || report.Policy == FeedbackNone {
+		return
+	}
+	r.mu.RLock()
+	sink := r.sinks[report.Policy]
+	r.mu.RUnlock()
+
+	result := &FeedbackResult{Policy: report.Policy}
+	if sink == nil {
+		result.Error = fmt.Sprintf("no sink registered for feedback policy %q", report.Policy)
+	} else {
+		// Deliver even when the execution was stopped or timed out.
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), feedbackTimeout)
+		ref, err := sink.Deliver(ctx, report)
+		cancel()
+		result.Ref = ref
+		if err != nil {
+			result.Error = err.Error()
+		}
+	}
+	result.Time = time.Now()
+
+	if result.Error != "" {
+		logger.WarnCF("campaign", "Feedback delivery failed", map[string]any{
+			"execution_id": exec.ID,
+			"policy":       string(report.Policy),
+			"error":        result.Error,
+		})
+	} else {
+		logger.InfoCF("campaign", "Feedback delivered", map[string]any{
+			"execution_id": exec.ID,
+			"policy":       string(report.Policy),
+			"ref":          result.Ref,
+		})
+	}
+
+	r.mu.Lock()
+	exec.Feedback = result
+	r.persist(exec, nil)
+	r.mu.Unlock()
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "\n\n…(truncated)"
+}
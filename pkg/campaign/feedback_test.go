@@ -0,0 +1,138 @@
+package campaign
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingSink records the reports it is given.
+type recordingSink struct {
+	mu      sync.Mutex
+	reports []*Report
+	err     error
+}
+
+func (s *recordingSink) Deliver(_ context.Context, report *Report) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reports = append(s.reports, report)
+	if s.err != nil {
+		return "", s.err
+	}
+	return "https://example.com/issues/1", nil
+}
+
+// waitFeedback waits for the report of an execution to be delivered.
+func waitFeedback(t *testing.T, r *Runner, id string) *Execution {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		exec, err := r.GetStatus(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exec.Feedback != nil {
+			return exec
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("execution %s delivered no feedback", id)
+	return nil
+}
+
+func TestRunner_DeliversFeedback(t *testing.T) {
+	sink := &recordingSink{}
+	runner := NewRunner()
+	runner.RegisterAdapter("tinyclaw", funcAdapter(func(prompt string) (string, error) {
+		if prompt == "lint" {
+			return "", errors.New("linter crashed")
+		}
+		return "found 2 outdated modules", nil
+	}))
+	runner.RegisterSink(FeedbackGitHubIssue, sink)
+
+	def := storeDefinition("audit", "scan", "lint")
+	def.Description = "Weekly dependency audit"
+	def.Feedback = FeedbackGitHubIssue
+	def.FeedbackTarget = FeedbackTarget{Repo: "tinyland-inc/tinyclaw"}
+	if err := runner.Check(def); err != nil {
+		t.Fatalf("Check() = %v", err)
+	}
+	exec, err := runner.Start(context.Background(), def)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exec = waitFeedback(t, runner, exec.ID)
+	if exec.Feedback.Error != "" || exec.Feedback.Ref != "https://example.com/issues/1" || exec.Feedback.Policy != FeedbackGitHubIssue {
+		t.Errorf("Feedback = %+v", exec.Feedback)
+	}
+	if len(sink.reports) != 1 {
+		t.Fatalf("sink got %d reports, want 1", len(sink.reports))
+	}
+	report := sink.reports[0]
+	if report.Title != "[campaign] audit" || report.Target.Repo != "tinyland-inc/tinyclaw" {
+		t.Errorf("report = %+v", report)
+	}
+	if report.Execution.Status != exec.Status || len(report.Execution.Results) != 2 {
+		t.Errorf("report execution = %+v", report.Execution)
+	}
+	for _, want := range []string{"Weekly dependency audit", "| scan | succeeded |", "found 2 outdated modules", "linter crashed"} {
+		if !strings.Contains(report.Body, want) {
+			t.Errorf("report body lacks %q:\n%s", want, report.Body)
+		}
+	}
+	if s := exec.Summary(); s.Feedback == nil {
+		t.Error("Summary() has no feedback")
+	}
+}
+
+func TestRunner_RecordsFeedbackErrors(t *testing.T) {
+	runner := NewRunner()
+	runner.RegisterAdapter("tinyclaw", funcAdapter(func(string) (string, error) { return "ok", nil }))
+	runner.RegisterSink(FeedbackSetec, &recordingSink{err: errors.New("setec unreachable")})
+
+	def := storeDefinition("secret", "scan")
+	def.Feedback = FeedbackSetec
+	exec, err := runner.Start(context.Background(), def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exec = waitFeedback(t, runner, exec.ID)
+	if exec.Status != StatusCompleted || exec.Feedback.Error != "setec unreachable" {
+		t.Errorf("status %s, feedback %+v", exec.Status, exec.Feedback)
+	}
+
+	// Without a sink, Check rejects the campaign and a run records why
+	// nothing was delivered.
+	def = storeDefinition("chat", "scan")
+	def.Feedback = FeedbackChannel
+	if err := runner.Check(def); err == nil || !strings.Contains(err.Error(), "no sink") {
+		t.Errorf("Check() without a sink = %v", err)
+	}
+	exec, err = runner.Start(context.Background(), def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exec = waitFeedback(t, runner, exec.ID)
+	if !strings.Contains(exec.Feedback.Error, "no sink registered") {
+		t.Errorf("Feedback = %+v", exec.Feedback)
+	}
+}
+
+func TestNewReport_TruncatesOutput(t *testing.T) {
+	exec := &Execution{
+		ID:         "audit-1",
+		Definition: storeDefinition("audit", "scan"),
+		Status:     StatusCompleted,
+		Results:    []StepResult{{StepName: "scan", Status: StepSucceeded, Output: strings.Repeat("é", maxReportOutput+10)}},
+	}
+	report := newReport(exec)
+	if strings.Count(report.Body, "é") != maxReportOutput || !strings.Contains(report.Body, "(truncated)") {
+		t.Errorf("output not truncated to %d runes", maxReportOutput)
+	}
+}
@@ -1,6 +1,10 @@
 package campaign
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"slices"
+)
 
 // BackendAdapter is the interface that campaign backends must implement.
 // This allows campaigns to target different agent systems.
@@ -11,6 +15,14 @@ type BackendAdapter interface {
 	Name() string
 }
 
+// ToolChecker is implemented by adapters that know the tools of their
+// agents, letting Runner.Check reject steps that use tools an agent lacks.
+type ToolChecker interface {
+	// HasTool reports whether the agent has the named tool. An unknown
+	// agent has no tools.
+	HasTool(agentID, name string) bool
+}
+
 // GuardrailCheck evaluates whether an execution violates any guardrails.
 // Returns nil if all guardrails pass, or an error describing the violation.
 func GuardrailCheck(exec *Execution) error {
@@ -19,7 +31,7 @@ func GuardrailCheck(exec *Execution) error {
 	}
 	reason := checkGuardrails(exec, &exec.Definition.Guardrails)
 	if reason != "" {
-		return &GuardrailError{Reason: reason, CampaignID: exec.ID}
+		return &GuardrailError{Reason: reason, CampaignID: exec.CampaignID}
 	}
 	return nil
 }
@@ -34,32 +46,52 @@ func (e *GuardrailError) Error() string {
 	return "campaign " + e.CampaignID + ": guardrail violation: " + e.Reason
 }
 
+// readOnlyTools are the registered tools that cannot change anything
+// outside the agent. Read-only campaigns may use only these.
+var readOnlyTools = map[string]bool{
+	"read_file":      true,
+	"list_dir":       true,
+	"web_search":     true,
+	"web_fetch":      true,
+	"memory_search":  true,
+	"session_search": true,
+	"find_skills":    true,
+}
+
+// IsReadOnlyTool reports whether a tool may run in a read-only campaign.
+// Tools not known to be read-only, including MCP tools, are not.
+func IsReadOnlyTool(name string) bool {
+	return readOnlyTools[name]
+}
+
 // CanExecuteTool checks whether a tool call is permitted under the current guardrails.
 func CanExecuteTool(exec *Execution, toolName string) bool {
+	return toolDenial(exec, toolName) == ""
+}
+
+// toolDenial returns why a tool call is not permitted, or "".
+func toolDenial(exec *Execution, toolName string) string {
 	if exec == nil || exec.Definition == nil {
-		return true
+		return ""
 	}
 	g := &exec.Definition.Guardrails
 
 	// Kill switch
 	if exec.KillSwitchUsed {
-		return false
+		return "kill_switch_activated"
 	}
 
-	// Read-only mode: deny write operations
-	if g.ReadOnly {
-		switch toolName {
-		case "write_file", "exec_command", "delete_file":
-			return false
-		}
+	// Read-only mode: deny everything that may write
+	if g.ReadOnly && !IsReadOnlyTool(toolName) {
+		return "read_only"
 	}
 
 	// Tool call limit
 	if exec.ToolCalls >= g.MaxToolCalls {
-		return false
+		return "tool_call_limit"
 	}
 
-	return true
+	return ""
 }
 
 // RecordToolCall increments the tool call counter and budget spend.
@@ -70,3 +102,96 @@ func RecordToolCall(exec *Execution, costCents int) {
 	exec.ToolCalls++
 	exec.SpentCents += costCents
 }
+
+// StepRun is the guardrail and metering handle of a running step. The
+// runner passes it to adapters through the context, so adapters that can
+// observe the agent at work enforce guardrails on every LLM and tool call
+// and report real usage back into the execution.
+type StepRun struct {
+	r    *Runner
+	exec *Execution
+	step Step
+
+	// Usage of this step, under r.mu.
+	toolCalls  int
+	iterations int
+	tokens     int
+}
+
+type stepRunKey struct{}
+
+func withStepRun(ctx context.Context, sr *StepRun) context.Context {
+	return context.WithValue(ctx, stepRunKey{}, sr)
+}
+
+// StepRunFromContext returns the step an adapter is executing, or nil when
+// ctx does not come from a campaign runner.
+func StepRunFromContext(ctx context.Context) *StepRun {
+	sr, _ := ctx.Value(stepRunKey{}).(*StepRun)
+	return sr
+}
+
+// ExecutionID returns the ID of the execution the step belongs to.
+func (s *StepRun) ExecutionID() string { return s.exec.ID }
+
+// StepName returns the name of the step.
+func (s *StepRun) StepName() string { return s.step.Name }
+
+// Tools returns the step's tools the guardrails permit. It is never nil, so
+// a step without tools gets none.
+func (s *StepRun) Tools() []string {
+	tools := make([]string, 0, len(s.step.Tools))
+	for _, name := range s.step.Tools {
+		if !s.exec.Definition.Guardrails.ReadOnly || IsReadOnlyTool(name) {
+			tools = append(tools, name)
+		}
+	}
+	return tools
+}
+
+// BeginIteration counts an LLM call of the step. It fails with a
+// *GuardrailError once the execution exceeds its guardrails.
+func (s *StepRun) BeginIteration() error {
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+
+	if reason := checkGuardrails(s.exec, &s.exec.Definition.Guardrails); reason != "" {
+		return &GuardrailError{Reason: reason, CampaignID: s.exec.CampaignID}
+	}
+	s.exec.Iterations++
+	s.iterations++
+	return nil
+}
+
+// AllowTool checks a tool call against the step's tools and the
+// guardrails, counting it when allowed.
+func (s *StepRun) AllowTool(name string) error {
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+
+	if !slices.Contains(s.step.Tools, name) {
+		return fmt.Errorf("tool %q is not listed for step %q", name, s.step.Name)
+	}
+	reason := toolDenial(s.exec, name)
+	if reason == "" {
+		reason = checkGuardrails(s.exec, &s.exec.Definition.Guardrails)
+	}
+	if reason != "" {
+		return &GuardrailError{Reason: reason, CampaignID: s.exec.CampaignID}
+	}
+	RecordToolCall(s.exec, 0)
+	s.toolCalls++
+	return nil
+}
+
+// AddUsage records the tokens and cost of an LLM call of the step.
+func (s *StepRun) AddUsage(tokens int, costUSD float64) {
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+
+	s.tokens += tokens
+	cents := costUSD*100 + s.exec.centsRemainder
+	whole := int(cents)
+	s.exec.SpentCents += whole
+	s.exec.centsRemainder = cents - float64(whole)
+}
@@ -0,0 +1,73 @@
+package campaign
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// dhallFeedback maps the constructors of the Dhall FeedbackPolicy union,
+// which dhall-to-json renders as plain strings, to feedback policies.
+var dhallFeedback = map[string]FeedbackPolicy{
+	"CreateGitHubIssue": FeedbackGitHubIssue,
+	"CreateGitHubPR":    FeedbackGitHubPR,
+	"PostToChannel":     FeedbackChannel,
+	"StoreInSetec":      FeedbackSetec,
+	"NoFeedback":        FeedbackNone,
+}
+
+// UnmarshalJSON accepts both the JSON names of feedback policies and the
+// constructor names of the Dhall FeedbackPolicy union.
+func (p *FeedbackPolicy) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("feedback must be a string: %w", err)
+	}
+	if mapped, ok := dhallFeedback[s]; ok {
+		s = string(mapped)
+	}
+	*p = FeedbackPolicy(s)
+	return nil
+}
+
+// ParseDefinition decodes a campaign definition from JSON, rejecting
+// unknown fields so that typos do not silently drop settings.
+func ParseDefinition(data []byte) (*Definition, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var def Definition
+	if err := dec.Decode(&def); err != nil {
+		return nil, fmt.Errorf("parse campaign definition: %w", err)
+	}
+	return &def, nil
+}
+
+// LoadDefinition reads a campaign definition from a JSON file, or from a
+// Dhall file (*.dhall) through dhall-to-json.
+func LoadDefinition(ctx context.Context, path string) (*Definition, error) {
+	if !strings.EqualFold(filepath.Ext(path), ".dhall") {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return ParseDefinition(data)
+	}
+
+	bin, err := exec.LookPath("dhall-to-json")
+	if err != nil {
+		return nil, errors.New("dhall-to-json not found: install dhall-json or convert the definition to JSON")
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, bin, "--file", path)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("dhall-to-json %s: %w: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+	return ParseDefinition(stdout.Bytes())
+}
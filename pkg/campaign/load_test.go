@@ -0,0 +1,75 @@
+package campaign
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// toolAdapter is a tinyclaw adapter whose agents have a fixed set of tools.
+type toolAdapter struct {
+	recordingAdapter
+	tools map[string]bool
+}
+
+func (a *toolAdapter) HasTool(_, name string) bool { return a.tools[name] }
+
+func TestLoadDefinition_JSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.json")
+	data := `{
+		"id": "audit", "name": "Audit", "description": "",
+		"targets": [{"agent_id": "main", "backend": "tinyclaw", "config_override": null}],
+		"steps": [{"name": "scan", "prompt": "scan", "tools": ["read_file"], "timeout_minutes": 5,
+			"depends_on": [], "retry": {"max_attempts": 1, "backoff_seconds": 0}, "condition": null}],
+		"guardrails": {"max_duration_minutes": 30, "read_only": true, "ai_api_budget_cents": 500,
+			"kill_switch": true, "max_tool_calls": 50, "max_iterations": 25},
+		"feedback": "CreateGitHubIssue",
+		"tags": ["nightly"]
+	}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	def, err := LoadDefinition(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def.Feedback != FeedbackGitHubIssue || def.Steps[0].Tools[0] != "read_file" || !def.Guardrails.ReadOnly {
+		t.Errorf("definition = %+v", def)
+	}
+	if err := def.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+
+	if _, err := ParseDefinition([]byte(`{"id": "x", "stpes": []}`)); err == nil {
+		t.Error("unknown field was accepted")
+	}
+	def.Feedback = "carrier_pigeon"
+	if err := def.Validate(); err == nil || !strings.Contains(err.Error(), "feedback") {
+		t.Errorf("Validate() with unknown feedback = %v", err)
+	}
+}
+
+func TestRunner_Check(t *testing.T) {
+	runner := NewRunner()
+	runner.RegisterAdapter("tinyclaw", &toolAdapter{tools: map[string]bool{"read_file": true}})
+
+	def := storeDefinition("checked", "scan")
+	def.Steps[0].Tools = []string{"read_file"}
+	if err := runner.Check(def); err != nil {
+		t.Errorf("Check() = %v", err)
+	}
+
+	def.Steps[0].Tools = []string{"read_file", "exec"}
+	if err := runner.Check(def); err == nil || !strings.Contains(err.Error(), `has no tool "exec"`) {
+		t.Errorf("Check() with a missing tool = %v", err)
+	}
+
+	def.Steps[0].Tools = nil
+	def.Targets = append(def.Targets, Target{AgentID: "scanner", Backend: "hexstrike"})
+	if err := runner.Check(def); err == nil || !strings.Contains(err.Error(), "not registered") {
+		t.Errorf("Check() with an unknown backend = %v", err)
+	}
+}
@@ -9,9 +9,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"sync"
 	"time"
 
+	"github.com/tinyland-inc/tinyclaw/pkg/aperture"
 	"github.com/tinyland-inc/tinyclaw/pkg/logger"
 )
 
@@ -67,11 +69,20 @@ type Target struct {
 }
 
 // Step defines a single process step within a campaign.
+//
+// Steps run once their DependsOn steps have finished, independent steps in
+// parallel. A campaign that declares no dependencies or conditions at all
+// runs its steps in order. The prompt may reference the results of the
+// steps it depends on as {{steps.<name>.output}}, {{steps.<name>.error}}
+// and {{steps.<name>.status}}.
 type Step struct {
-	Name           string   `json:"name"`
-	Prompt         string   `json:"prompt"`
-	Tools          []string `json:"tools"`
-	TimeoutMinutes int      `json:"timeout_minutes"`
+	Name           string      `json:"name"`
+	Prompt         string      `json:"prompt"`
+	Tools          []string    `json:"tools"`
+	TimeoutMinutes int         `json:"timeout_minutes"`
+	DependsOn      []string    `json:"depends_on,omitempty"`
+	Retry          RetryPolicy `json:"retry,omitzero"`
+	Condition      *Condition  `json:"condition,omitempty"`
 }
 
 // Definition describes a complete campaign.
@@ -83,33 +94,86 @@ type Definition struct {
 	Steps       []Step         `json:"steps"`
 	Guardrails  Guardrails     `json:"guardrails"`
 	Feedback    FeedbackPolicy `json:"feedback"`
-	Tags        []string       `json:"tags"`
+	// FeedbackTarget overrides where the report of a finished run goes.
+	FeedbackTarget FeedbackTarget `json:"feedback_target,omitzero"`
+	Tags           []string       `json:"tags"`
 }
 
-// Execution tracks the runtime state of a campaign.
+// Execution tracks the runtime state of one run of a campaign.
 type Execution struct {
-	ID             string
-	Definition     *Definition
-	Status         Status
-	StartTime      time.Time
-	EndTime        time.Time
-	CurrentStep    int
-	SpentCents     int
-	ToolCalls      int
-	Iterations     int
-	Results        []StepResult
-	Error          string
-	KillSwitchUsed bool
+	// ID identifies this run; CampaignID is the ID of its definition.
+	ID             string       `json:"id"`
+	CampaignID     string       `json:"campaign_id"`
+	Definition     *Definition  `json:"definition"`
+	Status         Status       `json:"status"`
+	StartTime      time.Time    `json:"start_time"`
+	EndTime        time.Time    `json:"end_time,omitzero"`
+	CurrentStep    int          `json:"current_step"`
+	SpentCents     int          `json:"spent_cents,omitempty"`
+	ToolCalls      int          `json:"tool_calls,omitempty"`
+	Iterations     int          `json:"iterations,omitempty"`
+	Results        []StepResult `json:"results,omitempty"`
+	Error          string       `json:"error,omitempty"`
+	KillSwitchUsed bool         `json:"kill_switch_used,omitempty"`
+	// Feedback records the delivery of the report once the run finished.
+	Feedback *FeedbackResult `json:"feedback,omitempty"`
+
+	// centsRemainder carries the fraction of a cent spent but not yet
+	// counted in SpentCents.
+	centsRemainder float64
+}
+
+// Summary is the overview of an execution shown in listings.
+type Summary struct {
+	ID         string    `json:"id"`
+	CampaignID string    `json:"campaign_id"`
+	Name       string    `json:"name"`
+	Status     Status    `json:"status"`
+	StartTime  time.Time `json:"start_time"`
+	EndTime    time.Time `json:"end_time,omitzero"`
+	StepsDone  int       `json:"steps_done"`
+	Steps      int       `json:"steps"`
+	SpentCents int       `json:"spent_cents"`
+	ToolCalls  int       `json:"tool_calls"`
+	Iterations int       `json:"iterations"`
+	Error      string    `json:"error,omitempty"`
+
+	Feedback *FeedbackResult `json:"feedback,omitempty"`
+}
+
+// Summary returns the overview of the execution.
+func (exec *Execution) Summary() Summary {
+	s := Summary{
+		ID:         exec.ID,
+		CampaignID: exec.CampaignID,
+		Status:     exec.Status,
+		StartTime:  exec.StartTime,
+		EndTime:    exec.EndTime,
+		StepsDone:  len(exec.Results),
+		SpentCents: exec.SpentCents,
+		ToolCalls:  exec.ToolCalls,
+		Iterations: exec.Iterations,
+		Error:      exec.Error,
+		Feedback:   exec.Feedback,
+	}
+	if exec.Definition != nil {
+		s.Name = exec.Definition.Name
+		s.Steps = len(exec.Definition.Steps)
+	}
+	return s
 }
 
 // StepResult captures the outcome of a single campaign step.
 type StepResult struct {
-	StepName  string
-	Output    string
-	Duration  time.Duration
-	ToolCalls int
-	Tokens    int
-	Error     string
+	StepName  string        `json:"step_name"`
+	Status    StepStatus    `json:"status"`
+	Output    string        `json:"output"`
+	Duration  time.Duration `json:"duration"`
+	Attempts  int           `json:"attempts,omitempty"`
+	ToolCalls int           `json:"tool_calls,omitempty"`
+	Tokens    int           `json:"tokens,omitempty"`
+	// Error is the last attempt's error, or why the step was skipped.
+	Error string `json:"error,omitempty"`
 }
 
 // Runner executes campaigns against agent backends.
@@ -118,15 +182,43 @@ type Runner struct {
 	executions map[string]*Execution
 	adapters   map[string]BackendAdapter
 	cancel     map[string]context.CancelFunc
+	sinks      map[FeedbackPolicy]FeedbackSink
+
+	// store persists executions when set; retention bounds what it keeps.
+	store     *Store
+	retention Retention
+	stopPoll  time.Duration
 }
 
-// NewRunner creates a new campaign runner.
+// NewRunner creates a new campaign runner that keeps executions in memory.
 func NewRunner() *Runner {
 	return &Runner{
 		executions: make(map[string]*Execution),
 		adapters:   make(map[string]BackendAdapter),
 		cancel:     make(map[string]context.CancelFunc),
+		sinks:      make(map[FeedbackPolicy]FeedbackSink),
+	}
+}
+
+// NewRunnerWithStore creates a campaign runner that persists executions to
+// store after every step and loads the ones already stored, applying
+// retention. Call Resume once the adapters are registered to continue the
+// executions a restart interrupted.
+func NewRunnerWithStore(store *Store, retention Retention) (*Runner, error) {
+	r := NewRunner()
+	r.store = store
+	r.retention = retention
+	r.stopPoll = stopPollInterval
+
+	execs, err := store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("load campaign executions: %w", err)
+	}
+	for _, exec := range execs {
+		r.executions[exec.ID] = exec
 	}
+	r.Prune(time.Now())
+	return r, nil
 }
 
 // RegisterAdapter registers a backend adapter for dispatching campaigns.
@@ -136,194 +228,622 @@ func (r *Runner) RegisterAdapter(backend string, adapter BackendAdapter) {
 	r.adapters[backend] = adapter
 }
 
-// Start begins executing a campaign asynchronously.
+// Check validates def against this runner before it is started: on top of
+// Definition.Validate, its feedback policy needs a sink, every target
+// backend must be registered and, for
+// adapters that implement ToolChecker, every step tool must exist on every
+// target agent.
+func (r *Runner) Check(def *Definition) error {
+	if def == nil {
+		return errors.New("campaign definition is nil")
+	}
+	if err := def.Validate(); err != nil {
+		return err
+	}
+	if len(def.Targets) == 0 {
+		return errors.New("campaign must have at least one target")
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if def.Feedback != "" && def.Feedback != FeedbackNone && r.sinks[def.Feedback] == nil {
+		return fmt.Errorf("no sink is registered for feedback policy %q", def.Feedback)
+	}
+	for _, target := range def.Targets {
+		adapter, ok := r.adapters[target.Backend]
+		if !ok {
+			return fmt.Errorf("target %q: backend %q is not registered", target.AgentID, target.Backend)
+		}
+		checker, ok := adapter.(ToolChecker)
+		if !ok {
+			continue
+		}
+		for _, step := range def.Steps {
+			for _, tool := range step.Tools {
+				if !checker.HasTool(target.AgentID, tool) {
+					return fmt.Errorf("step %q: agent %q has no tool %q", step.Name, target.AgentID, tool)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Start begins executing a campaign asynchronously and returns the new
+// execution as it started; use GetStatus to follow it. A campaign runs at
+// most once at a time; each run is a new execution with its own ID.
 func (r *Runner) Start(ctx context.Context, def *Definition) (*Execution, error) {
 	if def == nil {
 		return nil, errors.New("campaign definition is nil")
 	}
-	if def.ID == "" {
-		return nil, errors.New("campaign ID is required")
-	}
-	if len(def.Steps) == 0 {
-		return nil, errors.New("campaign must have at least one step")
+	if err := def.Validate(); err != nil {
+		return nil, err
 	}
 
 	r.mu.Lock()
-	if _, exists := r.executions[def.ID]; exists {
-		r.mu.Unlock()
-		return nil, fmt.Errorf("campaign %q is already running", def.ID)
+	for _, e := range r.executions {
+		if e.CampaignID == def.ID && e.Status == StatusRunning {
+			r.mu.Unlock()
+			return nil, fmt.Errorf("campaign %q is already running", def.ID)
+		}
 	}
 
+	now := time.Now()
 	exec := &Execution{
-		ID:          def.ID,
+		ID:          r.newExecutionID(def.ID, now),
+		CampaignID:  def.ID,
 		Definition:  def,
 		Status:      StatusRunning,
-		StartTime:   time.Now(),
+		StartTime:   now,
 		CurrentStep: 0,
 		Results:     make([]StepResult, 0, len(def.Steps)),
 	}
-	r.executions[def.ID] = exec
+	if r.store != nil {
+		if err := r.store.Create(exec); err != nil {
+			r.mu.Unlock()
+			return nil, err
+		}
+		if _, err := r.store.claim(exec.ID); err != nil {
+			logger.WarnCF("campaign", "Failed to lock execution", map[string]any{
+				"execution_id": exec.ID,
+				"error":        err.Error(),
+			})
+		}
+	}
+	r.executions[exec.ID] = exec
 
 	execCtx, cancelFn := context.WithTimeout(ctx,
 		time.Duration(def.Guardrails.MaxDurationMinutes)*time.Minute)
-	r.cancel[def.ID] = cancelFn
+	r.cancel[exec.ID] = cancelFn
+	started := exec.snapshot()
 	r.mu.Unlock()
 
 	go r.run(execCtx, exec)
 
-	return exec, nil
+	return started, nil
+}
+
+// newExecutionID returns a unique ID for a run of campaignID started at t.
+// Must be called with r.mu held.
+func (r *Runner) newExecutionID(campaignID string, t time.Time) string {
+	base := campaignID + "-" + t.UTC().Format("20060102T150405")
+	id := base
+	for n := 2; r.executions[id] != nil; n++ {
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+	return id
+}
+
+// Resume continues the stored executions that were running when the runner
+// last stopped. Steps with a stored result are not run again, and
+// executions another live process holds the lock of are left to it. It
+// returns the IDs of the resumed executions.
+func (r *Runner) Resume(ctx context.Context) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var resumed []string
+	for _, exec := range r.executions {
+		if exec.Status != StatusRunning || r.cancel[exec.ID] != nil {
+			continue
+		}
+		if r.store != nil {
+			ok, err := r.store.claim(exec.ID)
+			if err != nil {
+				logger.WarnCF("campaign", "Failed to lock execution, not resuming it", map[string]any{
+					"execution_id": exec.ID,
+					"error":        err.Error(),
+				})
+				continue
+			}
+			if !ok {
+				logger.InfoCF("campaign", "Execution is running in another process", map[string]any{
+					"campaign_id":  exec.CampaignID,
+					"execution_id": exec.ID,
+				})
+				continue
+			}
+		}
+		deadline := exec.StartTime.Add(time.Duration(exec.Definition.Guardrails.MaxDurationMinutes) * time.Minute)
+		execCtx, cancelFn := context.WithDeadline(ctx, deadline)
+		r.cancel[exec.ID] = cancelFn
+		go r.run(execCtx, exec)
+
+		resumed = append(resumed, exec.ID)
+		logger.InfoCF("campaign", "Resuming campaign", map[string]any{
+			"campaign_id":  exec.CampaignID,
+			"execution_id": exec.ID,
+			"steps_done":   len(exec.Results),
+		})
+	}
+	slices.Sort(resumed)
+	return resumed
 }
 
-// Stop activates the kill switch for a running campaign.
-func (r *Runner) Stop(campaignID string) error {
+// Stop activates the kill switch for a running campaign. id is an execution
+// ID or a campaign ID.
+func (r *Runner) Stop(id string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	exec, ok := r.executions[campaignID]
-	if !ok {
-		return fmt.Errorf("campaign %q not found", campaignID)
+	exec := r.lookup(id)
+	if exec == nil {
+		return fmt.Errorf("campaign %q not found", id)
 	}
 	if exec.Status != StatusRunning {
-		return fmt.Errorf("campaign %q is not running (status: %s)", campaignID, exec.Status)
+		return fmt.Errorf("campaign %q is not running (status: %s)", id, exec.Status)
 	}
 
 	exec.KillSwitchUsed = true
 	exec.Status = StatusCanceled
 	exec.EndTime = time.Now()
+	r.persist(exec, nil)
 
-	if cancel, ok := r.cancel[campaignID]; ok {
+	if cancel, ok := r.cancel[exec.ID]; ok {
 		cancel()
-		delete(r.cancel, campaignID)
+		delete(r.cancel, exec.ID)
 	}
 
 	logger.InfoCF("campaign", "Kill switch activated", map[string]any{
-		"campaign_id": campaignID,
+		"campaign_id":  exec.CampaignID,
+		"execution_id": exec.ID,
 	})
 
 	return nil
 }
 
-// GetStatus returns the current execution state of a campaign.
-func (r *Runner) GetStatus(campaignID string) (*Execution, error) {
+// GetStatus returns a snapshot of the execution state of a campaign. id is
+// an execution ID or a campaign ID, which selects the campaign's running or
+// latest execution.
+func (r *Runner) GetStatus(id string) (*Execution, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	exec, ok := r.executions[campaignID]
-	if !ok {
-		return nil, fmt.Errorf("campaign %q not found", campaignID)
+	exec := r.lookup(id)
+	if exec == nil {
+		return nil, fmt.Errorf("campaign %q not found", id)
 	}
-	return exec, nil
+	return exec.snapshot(), nil
+}
+
+// snapshot copies the execution so it can be read while the campaign keeps
+// running. Must be called with the runner's lock held.
+func (exec *Execution) snapshot() *Execution {
+	c := *exec
+	c.Results = slices.Clone(exec.Results)
+	return &c
 }
 
-// ListExecutions returns all campaign executions.
+// lookup resolves an execution or campaign ID. Must be called with r.mu
+// held.
+func (r *Runner) lookup(id string) *Execution {
+	return lookupExecution(r.executions, id)
+}
+
+// lookupExecution resolves an execution ID, or a campaign ID to the
+// campaign's running or latest execution.
+func lookupExecution(executions map[string]*Execution, id string) *Execution {
+	if exec, ok := executions[id]; ok {
+		return exec
+	}
+	var latest *Execution
+	for _, exec := range executions {
+		if exec.CampaignID != id {
+			continue
+		}
+		if exec.Status == StatusRunning {
+			return exec
+		}
+		if latest == nil || exec.StartTime.After(latest.StartTime) {
+			latest = exec
+		}
+	}
+	return latest
+}
+
+// ListExecutions returns snapshots of all campaign executions.
 func (r *Runner) ListExecutions() []*Execution {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	result := make([]*Execution, 0, len(r.executions))
 	for _, exec := range r.executions {
-		result = append(result, exec)
+		result = append(result, exec.snapshot())
 	}
 	return result
 }
 
-// run executes a campaign step by step.
-//
-//nolint:funlen // campaign execution: sequential step processing with state transitions
-func (r *Runner) run(ctx context.Context, exec *Execution) {
-	defer func() {
-		r.mu.Lock()
-		delete(r.cancel, exec.ID)
-		r.mu.Unlock()
-	}()
+// Query selects executions. Zero fields match everything.
+type Query struct {
+	CampaignID string
+	Tag        string
+	Status     Status
+	// Limit caps the number of executions returned; 0 returns all.
+	Limit int
+}
 
-	def := exec.Definition
+// Query returns snapshots of the executions matching q, most recent first.
+func (r *Runner) Query(q Query) []*Execution {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
-	for i, step := range def.Steps {
-		// Check context (kill switch / timeout)
-		if ctx.Err() != nil {
-			r.mu.Lock()
-			if exec.Status == StatusRunning {
-				exec.Status = StatusCanceled
-				exec.Error = ctx.Err().Error()
+	var result []*Execution
+	for _, exec := range r.executions {
+		if q.matches(exec) {
+			result = append(result, exec.snapshot())
+		}
+	}
+	return q.order(result)
+}
+
+func (q Query) matches(exec *Execution) bool {
+	if q.CampaignID != "" && exec.CampaignID != q.CampaignID {
+		return false
+	}
+	if q.Status != "" && exec.Status != q.Status {
+		return false
+	}
+	if q.Tag != "" && (exec.Definition == nil || !slices.Contains(exec.Definition.Tags, q.Tag)) {
+		return false
+	}
+	return true
+}
+
+// order sorts matched executions most recent first and applies the limit.
+func (q Query) order(execs []*Execution) []*Execution {
+	slices.SortFunc(execs, func(a, b *Execution) int { return b.StartTime.Compare(a.StartTime) })
+	if q.Limit > 0 && len(execs) > q.Limit {
+		execs = execs[:q.Limit]
+	}
+	return execs
+}
+
+// Prune applies the retention policy to finished executions, removing them
+// from memory and from the store. It returns how many were removed.
+func (r *Runner) Prune(now time.Time) int {
+	if r.store == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	byCampaign := make(map[string][]*Execution)
+	for _, exec := range r.executions {
+		if exec.Status != StatusRunning {
+			byCampaign[exec.CampaignID] = append(byCampaign[exec.CampaignID], exec)
+		}
+	}
+
+	removed := 0
+	for _, execs := range byCampaign {
+		slices.SortFunc(execs, func(a, b *Execution) int { return b.StartTime.Compare(a.StartTime) })
+		for i, exec := range execs {
+			tooMany := r.retention.MaxPerCampaign > 0 && i >= r.retention.MaxPerCampaign
+			tooOld := r.retention.MaxAge > 0 && now.Sub(exec.EndTime) > r.retention.MaxAge
+			if !tooMany && !tooOld {
+				continue
 			}
-			exec.EndTime = time.Now()
-			r.mu.Unlock()
-			return
+			if err := r.store.Delete(exec.ID); err != nil {
+				logger.WarnCF("campaign", "Failed to delete expired execution", map[string]any{
+					"execution_id": exec.ID,
+					"error":        err.Error(),
+				})
+				continue
+			}
+			delete(r.executions, exec.ID)
+			removed++
 		}
+	}
+	return removed
+}
 
-		// Check guardrails
-		if reason := checkGuardrails(exec, &def.Guardrails); reason != "" {
-			r.mu.Lock()
-			exec.Status = StatusFailed
-			exec.Error = "guardrail: " + reason
-			exec.EndTime = time.Now()
-			r.mu.Unlock()
+// stopPollInterval is how often a running execution looks for a stop
+// request from another process and refreshes its lock.
+const stopPollInterval = 2 * time.Second
+
+// watchStopRequests stops the execution when another process, such as
+// `tinyclaw campaign stop`, files a stop request in the store, and keeps
+// the execution's lock fresh, until ctx ends. It then releases the lock.
+func (r *Runner) watchStopRequests(ctx context.Context, id string) {
+	ticker := time.NewTicker(r.stopPoll)
+	defer ticker.Stop()
+	defer r.releaseLock(id)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.store.heartbeat(id); err != nil {
+				logger.WarnCF("campaign", "Failed to refresh execution lock", map[string]any{
+					"execution_id": id,
+					"error":        err.Error(),
+				})
+			}
+			if !r.store.takeStopRequest(id) {
+				continue
+			}
+			if err := r.Stop(id); err != nil {
+				logger.WarnCF("campaign", "Ignoring stop request", map[string]any{
+					"execution_id": id,
+					"error":        err.Error(),
+				})
+			}
 			return
 		}
+	}
+}
 
-		r.mu.Lock()
-		exec.CurrentStep = i
-		r.mu.Unlock()
+// releaseLock gives up this process's lock of a finished execution.
+func (r *Runner) releaseLock(id string) {
+	if err := r.store.release(id); err != nil {
+		logger.WarnCF("campaign", "Failed to release execution lock", map[string]any{
+			"execution_id": id,
+			"error":        err.Error(),
+		})
+	}
+}
 
-		logger.InfoCF("campaign", "Executing step", map[string]any{
-			"campaign_id": exec.ID,
-			"step":        step.Name,
-			"step_index":  i,
+// persist appends the execution's state, and step when not nil, to the
+// store. Must be called with r.mu held.
+func (r *Runner) persist(exec *Execution, step *StepResult) {
+	if r.store == nil {
+		return
+	}
+	if err := r.store.Append(exec, step); err != nil {
+		logger.ErrorCF("campaign", "Failed to persist execution", map[string]any{
+			"execution_id": exec.ID,
+			"error":        err.Error(),
 		})
+	}
+}
+
+// run schedules the campaign's steps, launching each once its dependencies
+// have finished, until every step is done or the campaign is halted.
+//
+//nolint:funlen,gocognit // campaign scheduling: dependency tracking with state transitions
+func (r *Runner) run(ctx context.Context, exec *Execution) {
+	defer func() {
+		r.mu.Lock()
+		delete(r.cancel, exec.ID)
+		r.mu.Unlock()
+	}()
 
-		stepStart := time.Now()
+	nodes := exec.Definition.plan()
+	stepCtx, cancelSteps := context.WithCancel(ctx)
+	defer cancelSteps()
+	if r.store != nil {
+		go r.watchStopRequests(stepCtx, exec.ID)
+	}
 
-		// Dispatch to backend adapter
-		var output string
-		var stepErr error
+	// Steps with a result, e.g. from before a restart, are done.
+	results := make(map[string]StepResult, len(nodes))
+	r.mu.RLock()
+	for _, res := range exec.Results {
+		results[res.StepName] = res
+	}
+	r.mu.RUnlock()
+	started := make(map[string]bool, len(nodes))
+	for name := range results {
+		started[name] = true
+	}
 
-		for _, target := range def.Targets {
+	done := make(chan StepResult)
+	running := 0
+	var status Status
+	var halt string
+	for {
+		if halt == "" {
 			r.mu.RLock()
-			adapter, ok := r.adapters[target.Backend]
+			reason := checkGuardrails(exec, &exec.Definition.Guardrails)
 			r.mu.RUnlock()
+			switch {
+			case ctx.Err() != nil:
+				status, halt = StatusCanceled, ctx.Err().Error()
+			case reason != "":
+				status, halt = StatusFailed, "guardrail: "+reason
+			}
+			if halt != "" {
+				cancelSteps()
+			}
+		}
 
-			if !ok {
-				stepErr = fmt.Errorf("no adapter for backend %q", target.Backend)
+		progressed := false
+		for _, n := range nodes {
+			if halt != "" {
+				break
+			}
+			if started[n.step.Name] || !allDone(n.deps, results) {
+				continue
+			}
+			started[n.step.Name] = true
+			progressed = true
+
+			if reason := n.skipReason(results); reason != "" {
+				res := StepResult{StepName: n.step.Name, Status: StepSkipped, Error: reason}
+				results[n.step.Name] = res
+				r.record(exec, res)
+				logger.InfoCF("campaign", "Skipping step", map[string]any{
+					"campaign_id":  exec.CampaignID,
+					"execution_id": exec.ID,
+					"step":         n.step.Name,
+					"reason":       reason,
+				})
 				continue
 			}
 
-			stepCtx, stepCancel := context.WithTimeout(ctx,
-				time.Duration(step.TimeoutMinutes)*time.Minute)
-			output, stepErr = adapter.Execute(stepCtx, target.AgentID, step.Prompt, step.Tools)
-			stepCancel()
+			r.mu.Lock()
+			exec.CurrentStep = n.index
+			r.persist(exec, nil)
+			r.mu.Unlock()
 
-			if stepErr == nil {
-				break // Success with first available adapter
+			logger.InfoCF("campaign", "Executing step", map[string]any{
+				"campaign_id":  exec.CampaignID,
+				"execution_id": exec.ID,
+				"step":         n.step.Name,
+				"step_index":   n.index,
+			})
+
+			prompt := renderPrompt(n.step.Prompt, results)
+			running++
+			go func() {
+				done <- r.executeStep(stepCtx, exec, n.step, prompt)
+			}()
+		}
+
+		if running == 0 {
+			if progressed {
+				continue // skipped steps may have unblocked others
 			}
+			break
 		}
+		res := <-done
+		running--
+		results[res.StepName] = res
+		r.record(exec, res)
+	}
 
-		result := StepResult{
-			StepName:  step.Name,
-			Output:    output,
-			Duration:  time.Since(stepStart),
-			ToolCalls: len(step.Tools), // Approximate
+	r.mu.Lock()
+	// Unless already stopped with the kill switch.
+	if exec.Status == StatusRunning {
+		if halt != "" {
+			exec.Status = status
+			exec.Error = halt
+		} else {
+			exec.Status = StatusCompleted
 		}
-		if stepErr != nil {
-			result.Error = stepErr.Error()
+		exec.EndTime = time.Now()
+		r.persist(exec, nil)
+	}
+	report := newReport(exec)
+	r.mu.Unlock()
+
+	r.deliverFeedback(ctx, exec, report)
+	if report.Execution.Status == StatusCompleted {
+		logger.InfoCF("campaign", "Campaign completed", map[string]any{
+			"campaign_id":  exec.CampaignID,
+			"execution_id": exec.ID,
+			"duration":     time.Since(exec.StartTime).String(),
+			"steps":        len(results),
+		})
+	}
+	r.Prune(time.Now())
+}
+
+// allDone reports whether every named step has a result.
+func allDone(names []string, results map[string]StepResult) bool {
+	for _, name := range names {
+		if _, ok := results[name]; !ok {
+			return false
 		}
+	}
+	return true
+}
 
-		r.mu.Lock()
-		exec.Results = append(exec.Results, result)
-		exec.Iterations++
-		r.mu.Unlock()
+// record adds a finished step to the execution and persists it.
+func (r *Runner) record(exec *Execution, res StepResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	exec.Results = append(exec.Results, res)
+	r.persist(exec, &res)
+}
+
+// executeStep runs one step with its retry policy.
+func (r *Runner) executeStep(ctx context.Context, exec *Execution, step Step, prompt string) StepResult {
+	start := time.Now()
+	res := StepResult{StepName: step.Name}
+	sr := &StepRun{r: r, exec: exec, step: step}
+	ctx = withStepRun(ctx, sr)
+
+	var err error
+	attempts := max(step.Retry.MaxAttempts, 1)
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if wait := step.Retry.backoff(attempt); wait > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(wait):
+			}
+		}
+		if ctx.Err() != nil && attempt > 1 {
+			break
+		}
+		res.Attempts = attempt
+		res.Output, err = r.dispatch(ctx, exec.Definition, step, prompt)
+		if err == nil {
+			break
+		}
+		if attempt < attempts {
+			logger.WarnCF("campaign", "Step failed, retrying", map[string]any{
+				"execution_id": exec.ID,
+				"step":         step.Name,
+				"attempt":      attempt,
+				"error":        err.Error(),
+			})
+		}
 	}
 
+	res.Duration = time.Since(start)
 	r.mu.Lock()
-	exec.Status = StatusCompleted
-	exec.EndTime = time.Now()
+	res.ToolCalls, res.Tokens = sr.toolCalls, sr.tokens
+	if sr.iterations == 0 {
+		// The adapter does not meter LLM calls; count one per attempt.
+		exec.Iterations += res.Attempts
+	}
 	r.mu.Unlock()
+	res.Status = StepSucceeded
+	if err != nil {
+		res.Status = StepFailed
+		res.Error = err.Error()
+	}
+	return res
+}
 
-	logger.InfoCF("campaign", "Campaign completed", map[string]any{
-		"campaign_id": exec.ID,
-		"duration":    time.Since(exec.StartTime).String(),
-		"steps":       len(exec.Results),
-	})
+// dispatch sends a step's prompt to the campaign's targets, returning the
+// output of the first one that succeeds.
+func (r *Runner) dispatch(ctx context.Context, def *Definition, step Step, prompt string) (string, error) {
+	var output string
+	var stepErr error
+
+	for _, target := range def.Targets {
+		r.mu.RLock()
+		adapter, ok := r.adapters[target.Backend]
+		r.mu.RUnlock()
+
+		if !ok {
+			stepErr = fmt.Errorf("no adapter for backend %q", target.Backend)
+			continue
+		}
+
+		stepCtx, stepCancel := context.WithTimeout(
+			aperture.WithAttribution(ctx, aperture.Attribution{CampaignID: def.ID}),
+			time.Duration(step.TimeoutMinutes)*time.Minute)
+		output, stepErr = adapter.Execute(stepCtx, target.AgentID, prompt, step.Tools)
+		stepCancel()
+
+		if stepErr == nil {
+			break // Success with first available adapter
+		}
+	}
+	return output, stepErr
 }
 
 // checkGuardrails returns a halt reason if guardrails are exceeded, or empty string.
@@ -3,12 +3,14 @@ package campaign
 import (
 	"context"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 )
 
 // testAdapter is a simple backend adapter for testing.
 type testAdapter struct {
+	mu        sync.Mutex
 	name      string
 	responses map[string]string
 	delay     time.Duration
@@ -17,8 +19,11 @@ type testAdapter struct {
 }
 
 func (a *testAdapter) Execute(_ context.Context, agentID, prompt string, _ []string) (string, error) {
+	a.mu.Lock()
 	a.callCount++
-	if a.failAfter > 0 && a.callCount > a.failAfter {
+	calls := a.callCount
+	a.mu.Unlock()
+	if a.failAfter > 0 && calls > a.failAfter {
 		return "", fmt.Errorf("adapter failure after %d calls", a.failAfter)
 	}
 	if a.delay > 0 {
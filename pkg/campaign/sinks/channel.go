@@ -0,0 +1,49 @@
+// Package sinks delivers campaign reports to chat channels, GitHub and
+// Tailscale Setec. Setec delivery is not functional yet: it needs the
+// tailscale.com/setec client, which is not linked in.
+//
+// Each sink implements campaign.FeedbackSink and is registered on a runner
+// for the feedback policies it serves.
+package sinks
+
+import (
+	"context"
+	"errors"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/bus"
+	"github.com/tinyland-inc/tinyclaw/pkg/campaign"
+)
+
+// ChannelSink posts reports to a chat through the message bus.
+type ChannelSink struct {
+	bus     *bus.MessageBus
+	channel string
+	chatID  string
+}
+
+// NewChannelSink creates a sink posting to channel and chatID unless a
+// campaign's feedback target names another chat.
+func NewChannelSink(msgBus *bus.MessageBus, channel, chatID string) *ChannelSink {
+	return &ChannelSink{bus: msgBus, channel: channel, chatID: chatID}
+}
+
+func (s *ChannelSink) Deliver(_ context.Context, report *campaign.Report) (string, error) {
+	channel, chatID := s.channel, s.chatID
+	if report.Target.Channel != "" {
+		channel, chatID = report.Target.Channel, report.Target.ChatID
+	} else if report.Target.ChatID != "" {
+		chatID = report.Target.ChatID
+	}
+	if channel == "" || chatID == "" {
+		return "", errors.New("channel feedback needs a channel and chat ID")
+	}
+
+	s.bus.PublishOutbound(bus.OutboundMessage{
+		Channel: channel,
+		ChatID:  chatID,
+		Content: report.Body,
+	})
+	return channel + ":" + chatID, nil
+}
+
+var _ campaign.FeedbackSink = (*ChannelSink)(nil)
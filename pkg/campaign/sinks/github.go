@@ -0,0 +1,185 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/campaign"
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+)
+
+const defaultGitHubBaseURL = "https://api.github.com"
+
+// GitHubSink files reports as GitHub issues or pull requests through the
+// REST API. Later runs of a campaign update the open issue or pull request
+// of earlier runs, found by title (issues) or head branch (pull requests),
+// instead of opening another one.
+type GitHubSink struct {
+	baseURL string
+	token   string
+	repo    string
+	labels  []string
+	client  *http.Client
+}
+
+// NewGitHubSink creates a sink for the campaign.FeedbackGitHubIssue and
+// campaign.FeedbackGitHubPR policies.
+func NewGitHubSink(cfg config.CampaignGitHubConfig) *GitHubSink {
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = defaultGitHubBaseURL
+	}
+	return &GitHubSink{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   cfg.Token,
+		repo:    cfg.Repo,
+		labels:  cfg.Labels,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// githubItem is the part of an issue or pull request the sink uses.
+type githubItem struct {
+	Number      int       `json:"number"`
+	Title       string    `json:"title"`
+	HTMLURL     string    `json:"html_url"`
+	PullRequest *struct{} `json:"pull_request,omitempty"`
+}
+
+func (s *GitHubSink) Deliver(ctx context.Context, report *campaign.Report) (string, error) {
+	repo := report.Target.Repo
+	if repo == "" {
+		repo = s.repo
+	}
+	owner, name, ok := strings.Cut(repo, "/")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return "", fmt.Errorf("github feedback needs a repository as owner/name, got %q", repo)
+	}
+	if s.token == "" {
+		return "", errors.New("github feedback needs a token")
+	}
+	repoPath := "/repos/" + url.PathEscape(owner) + "/" + url.PathEscape(name)
+
+	if report.Policy == campaign.FeedbackGitHubPR {
+		return s.deliverPR(ctx, repoPath, owner, report)
+	}
+	return s.deliverIssue(ctx, repoPath, report)
+}
+
+// deliverIssue comments on the open issue of the campaign, or opens one.
+func (s *GitHubSink) deliverIssue(ctx context.Context, repoPath string, report *campaign.Report) (string, error) {
+	query := url.Values{"state": {"open"}, "per_page": {"100"}}
+	if len(s.labels) > 0 {
+		query.Set("labels", strings.Join(s.labels, ","))
+	}
+	var open []githubItem
+	if err := s.do(ctx, http.MethodGet, repoPath+"/issues?"+query.Encode(), nil, &open); err != nil {
+		return "", err
+	}
+	for _, issue := range open {
+		if issue.PullRequest != nil || issue.Title != report.Title {
+			continue
+		}
+		var comment githubItem
+		path := fmt.Sprintf("%s/issues/%d/comments", repoPath, issue.Number)
+		if err := s.do(ctx, http.MethodPost, path, map[string]any{"body": report.Body}, &comment); err != nil {
+			return "", err
+		}
+		return comment.HTMLURL, nil
+	}
+
+	body := map[string]any{"title": report.Title, "body": report.Body}
+	if len(s.labels) > 0 {
+		body["labels"] = s.labels
+	}
+	var issue githubItem
+	if err := s.do(ctx, http.MethodPost, repoPath+"/issues", body, &issue); err != nil {
+		return "", err
+	}
+	return issue.HTMLURL, nil
+}
+
+// deliverPR updates the description of the open pull request from the
+// target's head branch, or opens one.
+func (s *GitHubSink) deliverPR(ctx context.Context, repoPath, owner string, report *campaign.Report) (string, error) {
+	head := report.Target.Head
+	if head == "" {
+		return "", errors.New("github_pr feedback needs feedback_target.head, the branch to propose")
+	}
+	base := report.Target.Base
+	if base == "" {
+		base = "main"
+	}
+
+	query := url.Values{"state": {"open"}, "head": {owner + ":" + head}}
+	var open []githubItem
+	if err := s.do(ctx, http.MethodGet, repoPath+"/pulls?"+query.Encode(), nil, &open); err != nil {
+		return "", err
+	}
+	var pr githubItem
+	if len(open) > 0 {
+		path := fmt.Sprintf("%s/pulls/%d", repoPath, open[0].Number)
+		if err := s.do(ctx, http.MethodPatch, path, map[string]any{"body": report.Body}, &pr); err != nil {
+			return "", err
+		}
+		return pr.HTMLURL, nil
+	}
+
+	body := map[string]any{"title": report.Title, "head": head, "base": base, "body": report.Body}
+	if err := s.do(ctx, http.MethodPost, repoPath+"/pulls", body, &pr); err != nil {
+		return "", err
+	}
+	return pr.HTMLURL, nil
+}
+
+// do sends a GitHub API request and decodes the JSON response into out.
+func (s *GitHubSink) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("github %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if json.Unmarshal(data, &apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = strings.TrimSpace(string(data))
+		}
+		return fmt.Errorf("github %s %s: %s: %s", method, path, resp.Status, apiErr.Message)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("github %s %s: decode response: %w", method, path, err)
+	}
+	return nil
+}
+
+var _ campaign.FeedbackSink = (*GitHubSink)(nil)
@@ -0,0 +1,31 @@
+package sinks
+
+import (
+	"github.com/tinyland-inc/tinyclaw/pkg/bus"
+	"github.com/tinyland-inc/tinyclaw/pkg/campaign"
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+	"github.com/tinyland-inc/tinyclaw/pkg/tailscale"
+)
+
+// Register registers the sinks configured by cfg on runner. Without a
+// message bus whose outbound messages reach the channels, as outside the
+// gateway, channel feedback is not available. Setec feedback needs
+// setec.enabled and a build with the Setec client, which this one lacks, so
+// the runner's Check rejects campaigns that use it.
+func Register(runner *campaign.Runner, msgBus *bus.MessageBus, cfg config.CampaignFeedbackConfig) {
+	if msgBus != nil {
+		runner.RegisterSink(campaign.FeedbackChannel, NewChannelSink(msgBus, cfg.Channel, cfg.ChatID))
+	}
+
+	github := NewGitHubSink(cfg.GitHub)
+	runner.RegisterSink(campaign.FeedbackGitHubIssue, github)
+	runner.RegisterSink(campaign.FeedbackGitHubPR, github)
+
+	if cfg.Setec.Enabled && tailscale.SetecAvailable() {
+		runner.RegisterSink(campaign.FeedbackSetec, NewSetecSink(tailscale.NewSetecClient(tailscale.SetecConfig{
+			Enabled: cfg.Setec.Enabled,
+			BaseURL: cfg.Setec.BaseURL,
+			Prefix:  cfg.Setec.Prefix,
+		})))
+	}
+}
@@ -0,0 +1,38 @@
+package sinks
+
+import (
+	"context"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/campaign"
+)
+
+// SecretStore stores named secrets. *tailscale.SetecClient implements it.
+type SecretStore interface {
+	Put(ctx context.Context, name, value string) error
+}
+
+// SetecSink stores reports as secrets, for campaigns whose findings must
+// not leave the tailnet.
+type SetecSink struct {
+	store SecretStore
+}
+
+// NewSetecSink creates a sink storing reports in store.
+func NewSetecSink(store SecretStore) *SetecSink {
+	return &SetecSink{store: store}
+}
+
+// Deliver stores the report under the target's secret name, by default
+// campaigns/<campaign ID>/latest, and returns that name.
+func (s *SetecSink) Deliver(ctx context.Context, report *campaign.Report) (string, error) {
+	name := report.Target.Secret
+	if name == "" {
+		name = "campaigns/" + report.Execution.CampaignID + "/latest"
+	}
+	if err := s.store.Put(ctx, name, report.Body); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+var _ campaign.FeedbackSink = (*SetecSink)(nil)
@@ -0,0 +1,223 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/bus"
+	"github.com/tinyland-inc/tinyclaw/pkg/campaign"
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+)
+
+// fakeGitHub serves the issue and pull request endpoints the sink uses.
+type fakeGitHub struct {
+	mu       sync.Mutex
+	issues   []map[string]any
+	pulls    []map[string]any
+	requests []string
+	bodies   []map[string]any
+}
+
+func (f *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Bad credentials"})
+		return
+	}
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	var in map[string]any
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&in)
+	}
+	f.bodies = append(f.bodies, in)
+
+	const repo = "/repos/tinyland-inc/tinyclaw"
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == repo+"/issues":
+		json.NewEncoder(w).Encode(f.issues)
+	case r.Method == http.MethodPost && r.URL.Path == repo+"/issues":
+		issue := map[string]any{"number": 7, "title": in["title"], "html_url": "https://github.com/tinyland-inc/tinyclaw/issues/7"}
+		f.issues = append(f.issues, issue)
+		json.NewEncoder(w).Encode(issue)
+	case r.Method == http.MethodPost && r.URL.Path == repo+"/issues/7/comments":
+		json.NewEncoder(w).Encode(map[string]any{"html_url": "https://github.com/tinyland-inc/tinyclaw/issues/7#issuecomment-1"})
+	case r.Method == http.MethodGet && r.URL.Path == repo+"/pulls":
+		if r.URL.Query().Get("head") != "tinyland-inc:deps/bump" {
+			json.NewEncoder(w).Encode([]any{})
+			return
+		}
+		json.NewEncoder(w).Encode(f.pulls)
+	case r.Method == http.MethodPost && r.URL.Path == repo+"/pulls":
+		pr := map[string]any{"number": 9, "html_url": "https://github.com/tinyland-inc/tinyclaw/pull/9"}
+		f.pulls = append(f.pulls, pr)
+		json.NewEncoder(w).Encode(pr)
+	case r.Method == http.MethodPatch && r.URL.Path == repo+"/pulls/9":
+		json.NewEncoder(w).Encode(f.pulls[0])
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Not Found"})
+	}
+}
+
+func testReport(policy campaign.FeedbackPolicy, target campaign.FeedbackTarget) *campaign.Report {
+	return &campaign.Report{
+		Execution: &campaign.Execution{ID: "audit-1", CampaignID: "audit"},
+		Policy:    policy,
+		Target:    target,
+		Title:     "[campaign] audit",
+		Body:      "## audit: completed",
+	}
+}
+
+func TestGitHubSink_Issue(t *testing.T) {
+	fake := &fakeGitHub{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	sink := NewGitHubSink(config.CampaignGitHubConfig{
+		BaseURL: srv.URL, Token: "secret", Repo: "tinyland-inc/tinyclaw", Labels: []string{"campaign"},
+	})
+	report := testReport(campaign.FeedbackGitHubIssue, campaign.FeedbackTarget{})
+
+	ref, err := sink.Deliver(context.Background(), report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref != "https://github.com/tinyland-inc/tinyclaw/issues/7" {
+		t.Errorf("first run ref = %q", ref)
+	}
+	if labels, _ := fake.bodies[1]["labels"].([]any); len(labels) != 1 || labels[0] != "campaign" {
+		t.Errorf("created issue labels = %v", fake.bodies[1]["labels"])
+	}
+
+	// The next run comments on the open issue instead of opening another.
+	ref, err = sink.Deliver(context.Background(), report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(ref, "#issuecomment-1") {
+		t.Errorf("second run ref = %q", ref)
+	}
+	if got := fake.requests[len(fake.requests)-1]; got != "POST /repos/tinyland-inc/tinyclaw/issues/7/comments" {
+		t.Errorf("second run ended with %s", got)
+	}
+}
+
+func TestGitHubSink_PullRequest(t *testing.T) {
+	fake := &fakeGitHub{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	sink := NewGitHubSink(config.CampaignGitHubConfig{BaseURL: srv.URL, Token: "secret"})
+	report := testReport(campaign.FeedbackGitHubPR, campaign.FeedbackTarget{Repo: "tinyland-inc/tinyclaw", Head: "deps/bump"})
+
+	ref, err := sink.Deliver(context.Background(), report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref != "https://github.com/tinyland-inc/tinyclaw/pull/9" || fake.bodies[1]["base"] != "main" {
+		t.Errorf("ref = %q, created %v", ref, fake.bodies[1])
+	}
+
+	if _, err := sink.Deliver(context.Background(), report); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.requests[len(fake.requests)-1]; got != "PATCH /repos/tinyland-inc/tinyclaw/pulls/9" {
+		t.Errorf("second run ended with %s", got)
+	}
+
+	report.Target.Head = ""
+	if _, err := sink.Deliver(context.Background(), report); err == nil || !strings.Contains(err.Error(), "feedback_target.head") {
+		t.Errorf("Deliver() without a head = %v", err)
+	}
+}
+
+func TestGitHubSink_Errors(t *testing.T) {
+	srv := httptest.NewServer(&fakeGitHub{})
+	defer srv.Close()
+
+	report := testReport(campaign.FeedbackGitHubIssue, campaign.FeedbackTarget{})
+	if _, err := NewGitHubSink(config.CampaignGitHubConfig{Token: "secret"}).Deliver(context.Background(), report); err == nil || !strings.Contains(err.Error(), "owner/name") {
+		t.Errorf("Deliver() without a repo = %v", err)
+	}
+	sink := NewGitHubSink(config.CampaignGitHubConfig{BaseURL: srv.URL, Token: "wrong", Repo: "tinyland-inc/tinyclaw"})
+	if _, err := sink.Deliver(context.Background(), report); err == nil || !strings.Contains(err.Error(), "Bad credentials") {
+		t.Errorf("Deliver() with a bad token = %v", err)
+	}
+}
+
+func TestChannelSink(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+	sink := NewChannelSink(msgBus, "telegram", "42")
+
+	ref, err := sink.Deliver(context.Background(), testReport(campaign.FeedbackChannel, campaign.FeedbackTarget{ChatID: "99"}))
+	if err != nil || ref != "telegram:99" {
+		t.Fatalf("Deliver() = %q, %v", ref, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, ok := msgBus.SubscribeOutbound(ctx)
+	if !ok || msg.Channel != "telegram" || msg.ChatID != "99" || msg.Content != "## audit: completed" {
+		t.Errorf("outbound = %+v, %v", msg, ok)
+	}
+
+	if _, err := NewChannelSink(msgBus, "", "").Deliver(context.Background(), testReport(campaign.FeedbackChannel, campaign.FeedbackTarget{})); err == nil {
+		t.Error("Deliver() without a chat succeeded")
+	}
+}
+
+// memorySecrets is a SecretStore in memory.
+type memorySecrets map[string]string
+
+func (m memorySecrets) Put(_ context.Context, name, value string) error {
+	if name == "" {
+		return errors.New("empty name")
+	}
+	m[name] = value
+	return nil
+}
+
+func TestSetecSink(t *testing.T) {
+	secrets := memorySecrets{}
+	sink := NewSetecSink(secrets)
+
+	ref, err := sink.Deliver(context.Background(), testReport(campaign.FeedbackSetec, campaign.FeedbackTarget{}))
+	if err != nil || ref != "campaigns/audit/latest" || secrets[ref] != "## audit: completed" {
+		t.Errorf("Deliver() = %q, %v; secrets %v", ref, err, secrets)
+	}
+	ref, err = sink.Deliver(context.Background(), testReport(campaign.FeedbackSetec, campaign.FeedbackTarget{Secret: "audits/weekly"}))
+	if err != nil || ref != "audits/weekly" {
+		t.Errorf("Deliver() with a secret name = %q, %v", ref, err)
+	}
+}
+
+func TestRegister_SetecNeedsClient(t *testing.T) {
+	runner := campaign.NewRunner()
+	runner.RegisterAdapter("tinyclaw", nil)
+	cfg := config.CampaignFeedbackConfig{Setec: config.CampaignSetecFeedbackConfig{Enabled: true}}
+	Register(runner, bus.NewMessageBus(), cfg)
+
+	def := &campaign.Definition{
+		ID:         "audit",
+		Name:       "audit",
+		Targets:    []campaign.Target{{AgentID: "main", Backend: "tinyclaw"}},
+		Steps:      []campaign.Step{{Name: "scan", Prompt: "scan", TimeoutMinutes: 1}},
+		Guardrails: campaign.DefaultGuardrails(),
+		Feedback:   campaign.FeedbackSetec,
+	}
+	if err := runner.Check(def); err == nil {
+		t.Error("Check accepted setec feedback without a Setec client")
+	}
+	def.Feedback = campaign.FeedbackChannel
+	if err := runner.Check(def); err != nil {
+		t.Errorf("Check(channel) = %v", err)
+	}
+}
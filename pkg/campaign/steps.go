@@ -0,0 +1,267 @@
+package campaign
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// StepStatus is the outcome of a campaign step.
+type StepStatus string
+
+const (
+	StepSucceeded StepStatus = "succeeded"
+	StepFailed    StepStatus = "failed"
+	StepSkipped   StepStatus = "skipped"
+)
+
+// RetryPolicy controls how often a failing step is attempted.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts; 0 and 1 mean no retry.
+	MaxAttempts int `json:"max_attempts"`
+	// BackoffSeconds is the wait before the second attempt, doubled before
+	// each further one.
+	BackoffSeconds int `json:"backoff_seconds"`
+}
+
+// Condition values for Condition.When.
+const (
+	WhenSuccess = "success"
+	WhenFailure = "failure"
+	WhenAlways  = "always"
+)
+
+// Condition gates a step on the result of an earlier step, which becomes
+// an implicit dependency.
+type Condition struct {
+	Step string `json:"step"`
+	// When is WhenSuccess (the default), WhenFailure or WhenAlways, which
+	// matches any outcome including a skip.
+	When string `json:"when,omitempty"`
+	// OutputContains additionally requires the step's output to contain
+	// this text.
+	OutputContains string `json:"output_contains,omitempty"`
+}
+
+// templateRef matches {{steps.<name>.<field>}} in step prompts.
+var templateRef = regexp.MustCompile(`\{\{\s*steps\.([A-Za-z0-9_-]+)\.(output|error|status)\s*\}\}`)
+
+// stepNode is a step with its resolved dependencies.
+type stepNode struct {
+	index int
+	step  Step
+	deps  []string
+	// strict skips the step when a dependency did not succeed. Sequential
+	// campaigns run every step regardless, as they always did.
+	strict bool
+}
+
+// sequential reports whether the campaign predates step dependencies:
+// without any depends_on or condition, steps run one after another.
+func (d *Definition) sequential() bool {
+	for _, s := range d.Steps {
+		if len(s.DependsOn) > 0 || s.Condition != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// plan returns the steps with their dependencies.
+func (d *Definition) plan() []stepNode {
+	seq := d.sequential()
+	nodes := make([]stepNode, len(d.Steps))
+	for i, s := range d.Steps {
+		n := stepNode{index: i, step: s, strict: !seq}
+		switch {
+		case seq && i > 0:
+			n.deps = []string{d.Steps[i-1].Name}
+		case !seq:
+			n.deps = slices.Clone(s.DependsOn)
+			if s.Condition != nil && !slices.Contains(n.deps, s.Condition.Step) {
+				n.deps = append(n.deps, s.Condition.Step)
+			}
+		}
+		nodes[i] = n
+	}
+	return nodes
+}
+
+// Validate checks a campaign definition: unique step names, known and
+// acyclic dependencies, valid conditions and retry policies, and prompt
+// templates that only reference steps the step depends on.
+func (d *Definition) Validate() error {
+	if d.ID == "" {
+		return errors.New("campaign ID is required")
+	}
+	if strings.ContainsAny(d.ID, `/\`) || strings.HasPrefix(d.ID, ".") {
+		return fmt.Errorf("campaign ID %q must not contain path separators or start with a dot", d.ID)
+	}
+	if len(d.Steps) == 0 {
+		return errors.New("campaign must have at least one step")
+	}
+	switch d.Feedback {
+	case "", FeedbackGitHubIssue, FeedbackGitHubPR, FeedbackChannel, FeedbackSetec, FeedbackNone:
+	default:
+		return fmt.Errorf("unknown feedback policy %q", d.Feedback)
+	}
+
+	names := make(map[string]bool, len(d.Steps))
+	for i, s := range d.Steps {
+		if s.Name == "" {
+			return fmt.Errorf("step %d has no name", i+1)
+		}
+		if names[s.Name] {
+			return fmt.Errorf("duplicate step name %q", s.Name)
+		}
+		names[s.Name] = true
+		if s.Retry.MaxAttempts < 0 || s.Retry.BackoffSeconds < 0 {
+			return fmt.Errorf("step %q: retry values must not be negative", s.Name)
+		}
+		if d.Guardrails.ReadOnly {
+			for _, tool := range s.Tools {
+				if !IsReadOnlyTool(tool) {
+					return fmt.Errorf("step %q: tool %q may write, which read-only campaigns forbid", s.Name, tool)
+				}
+			}
+		}
+	}
+
+	nodes := d.plan()
+	for _, n := range nodes {
+		for _, dep := range n.deps {
+			if !names[dep] {
+				return fmt.Errorf("step %q depends on unknown step %q", n.step.Name, dep)
+			}
+			if dep == n.step.Name {
+				return fmt.Errorf("step %q depends on itself", n.step.Name)
+			}
+		}
+		if c := n.step.Condition; c != nil {
+			switch c.When {
+			case "", WhenSuccess, WhenFailure, WhenAlways:
+			default:
+				return fmt.Errorf("step %q: condition when must be %s, %s or %s",
+					n.step.Name, WhenSuccess, WhenFailure, WhenAlways)
+			}
+		}
+	}
+
+	ancestors, err := ancestorsOf(nodes)
+	if err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		for _, m := range templateRef.FindAllStringSubmatch(n.step.Prompt, -1) {
+			if !ancestors[n.step.Name][m[1]] {
+				return fmt.Errorf("step %q references %s, which it does not depend on", n.step.Name, m[0])
+			}
+		}
+	}
+	return nil
+}
+
+// ancestorsOf returns the transitive dependencies of every step, or an
+// error when the dependencies form a cycle.
+func ancestorsOf(nodes []stepNode) (map[string]map[string]bool, error) {
+	byName := make(map[string]stepNode, len(nodes))
+	for _, n := range nodes {
+		byName[n.step.Name] = n
+	}
+	ancestors := make(map[string]map[string]bool, len(nodes))
+	visiting := make(map[string]bool)
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		if ancestors[name] != nil {
+			return nil
+		}
+		if visiting[name] {
+			return fmt.Errorf("step dependencies form a cycle through %q", name)
+		}
+		visiting[name] = true
+		set := make(map[string]bool)
+		for _, dep := range byName[name].deps {
+			if err := visit(dep); err != nil {
+				return err
+			}
+			set[dep] = true
+			for a := range ancestors[dep] {
+				set[a] = true
+			}
+		}
+		visiting[name] = false
+		ancestors[name] = set
+		return nil
+	}
+	for _, n := range nodes {
+		if err := visit(n.step.Name); err != nil {
+			return nil, err
+		}
+	}
+	return ancestors, nil
+}
+
+// renderPrompt fills {{steps.<name>.output|error|status}} with the
+// results of earlier steps.
+func renderPrompt(prompt string, results map[string]StepResult) string {
+	return templateRef.ReplaceAllStringFunc(prompt, func(ref string) string {
+		m := templateRef.FindStringSubmatch(ref)
+		r := results[m[1]]
+		switch m[2] {
+		case "output":
+			return r.Output
+		case "error":
+			return r.Error
+		default:
+			return string(r.Status)
+		}
+	})
+}
+
+// skipReason returns why a step whose dependencies have all finished must
+// be skipped, or "" when it should run.
+func (n stepNode) skipReason(results map[string]StepResult) string {
+	c := n.step.Condition
+	if n.strict {
+		for _, dep := range n.deps {
+			if c != nil && dep == c.Step {
+				continue // the condition decides about this one
+			}
+			if r := results[dep]; r.Status != StepSucceeded {
+				return fmt.Sprintf("dependency %q %s", dep, r.Status)
+			}
+		}
+	}
+	if c == nil {
+		return ""
+	}
+
+	r := results[c.Step]
+	switch c.When {
+	case WhenFailure:
+		if r.Status != StepFailed {
+			return fmt.Sprintf("condition not met: %q did not fail", c.Step)
+		}
+	case WhenAlways:
+	default:
+		if r.Status != StepSucceeded {
+			return fmt.Sprintf("condition not met: %q did not succeed", c.Step)
+		}
+	}
+	if c.OutputContains != "" && !strings.Contains(r.Output, c.OutputContains) {
+		return fmt.Sprintf("condition not met: output of %q does not contain %q", c.Step, c.OutputContains)
+	}
+	return ""
+}
+
+// backoff returns the wait before attempt (counted from 1) of a step.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	if p.BackoffSeconds <= 0 || attempt < 2 {
+		return 0
+	}
+	return time.Duration(p.BackoffSeconds) * time.Second << (attempt - 2)
+}
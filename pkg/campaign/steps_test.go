@@ -0,0 +1,263 @@
+package campaign
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// funcAdapter runs steps with a function of the prompt.
+type funcAdapter func(prompt string) (string, error)
+
+func (f funcAdapter) Execute(_ context.Context, _, prompt string, _ []string) (string, error) {
+	return f(prompt)
+}
+
+func (f funcAdapter) Name() string { return "tinyclaw" }
+
+func dagDefinition(id string, steps ...Step) *Definition {
+	for i := range steps {
+		steps[i].TimeoutMinutes = 1
+	}
+	return &Definition{
+		ID:         id,
+		Name:       id,
+		Targets:    []Target{{AgentID: "agent-1", Backend: "tinyclaw"}},
+		Steps:      steps,
+		Guardrails: DefaultGuardrails(),
+		Feedback:   FeedbackNone,
+	}
+}
+
+func resultsByName(exec *Execution) map[string]StepResult {
+	m := make(map[string]StepResult)
+	for _, r := range exec.Results {
+		m[r.StepName] = r
+	}
+	return m
+}
+
+func TestDefinition_Validate(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []Step
+		want  string
+	}{
+		{"duplicate", []Step{{Name: "a"}, {Name: "a"}}, "duplicate step name"},
+		{"unknown dependency", []Step{{Name: "a", DependsOn: []string{"b"}}}, "unknown step"},
+		{"cycle", []Step{{Name: "a", DependsOn: []string{"b"}}, {Name: "b", DependsOn: []string{"a"}}}, "cycle"},
+		{"template without dependency", []Step{
+			{Name: "a"},
+			{Name: "b", Prompt: "use {{steps.a.output}}", DependsOn: []string{}},
+			{Name: "c", DependsOn: []string{"b"}},
+		}, "does not depend on"},
+		{"bad condition", []Step{{Name: "a"}, {Name: "b", Condition: &Condition{Step: "a", When: "sometimes"}}}, "condition when"},
+		{"negative retry", []Step{{Name: "a", Retry: RetryPolicy{MaxAttempts: -1}}}, "must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := dagDefinition("v", tt.steps...).Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+
+	// Sequential campaigns may reference any earlier step.
+	ok := dagDefinition("v", Step{Name: "a"}, Step{Name: "b"}, Step{Name: "c", Prompt: "{{steps.a.output}}"})
+	if err := ok.Validate(); err != nil {
+		t.Errorf("sequential definition: %v", err)
+	}
+}
+
+func TestRunner_DAGRunsIndependentStepsInParallel(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		prompts = make(map[string]string)
+		both    sync.WaitGroup
+	)
+	both.Add(2)
+	adapter := funcAdapter(func(prompt string) (string, error) {
+		name, _, _ := strings.Cut(prompt, ":")
+		mu.Lock()
+		prompts[name] = prompt
+		mu.Unlock()
+		switch name {
+		case "security", "quality":
+			// Each review waits for the other to start.
+			both.Done()
+			waited := make(chan struct{})
+			go func() { both.Wait(); close(waited) }()
+			select {
+			case <-waited:
+			case <-time.After(time.Second):
+				return "", errors.New("reviews did not run in parallel")
+			}
+		}
+		return name + " found issue #" + name, nil
+	})
+
+	runner := NewRunner()
+	runner.RegisterAdapter("tinyclaw", adapter)
+	def := dagDefinition("review",
+		Step{Name: "fetch", Prompt: "fetch: diffs"},
+		Step{Name: "security", Prompt: "security: review {{steps.fetch.output}}", DependsOn: []string{"fetch"}},
+		Step{Name: "quality", Prompt: "quality: review {{ steps.fetch.output }}", DependsOn: []string{"fetch"}},
+		Step{
+			Name:      "report",
+			Prompt:    "report: {{steps.security.output}} / {{steps.quality.output}} ({{steps.security.status}})",
+			DependsOn: []string{"security", "quality"},
+		},
+	)
+	if _, err := runner.Start(context.Background(), def); err != nil {
+		t.Fatal(err)
+	}
+	exec := waitFinished(t, runner, "review")
+
+	if exec.Status != StatusCompleted || len(exec.Results) != 4 {
+		t.Fatalf("execution = %+v", exec)
+	}
+	for name, r := range resultsByName(exec) {
+		if r.Status != StepSucceeded {
+			t.Errorf("step %s = %+v", name, r)
+		}
+	}
+	if got := prompts["security"]; got != "security: review fetch found issue #fetch" {
+		t.Errorf("security prompt = %q", got)
+	}
+	want := "report: security found issue #security / quality found issue #quality (succeeded)"
+	if got := prompts["report"]; got != want {
+		t.Errorf("report prompt = %q, want %q", got, want)
+	}
+}
+
+func TestRunner_RetriesAndConditions(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	adapter := funcAdapter(func(prompt string) (string, error) {
+		mu.Lock()
+		calls[prompt]++
+		n := calls[prompt]
+		mu.Unlock()
+		switch prompt {
+		case "flaky":
+			if n < 3 {
+				return "", errors.New("temporary failure")
+			}
+			return "no findings", nil
+		case "broken":
+			return "", errors.New("always fails")
+		}
+		return "ran " + prompt, nil
+	})
+
+	runner := NewRunner()
+	runner.RegisterAdapter("tinyclaw", adapter)
+	def := dagDefinition("gated",
+		Step{Name: "scan", Prompt: "flaky", Retry: RetryPolicy{MaxAttempts: 3}},
+		Step{Name: "fix", Prompt: "fix", Condition: &Condition{Step: "scan", OutputContains: "VULN"}},
+		Step{Name: "archive", Prompt: "archive", Condition: &Condition{Step: "scan"}},
+		Step{Name: "deploy", Prompt: "broken"},
+		Step{Name: "rollback", Prompt: "rollback", Condition: &Condition{Step: "deploy", When: WhenFailure}},
+		Step{Name: "announce", Prompt: "announce", DependsOn: []string{"deploy"}},
+		Step{Name: "after-fix", Prompt: "after", DependsOn: []string{"fix"}},
+		Step{Name: "summary", Prompt: "summary", Condition: &Condition{Step: "fix", When: WhenAlways}},
+	)
+	if _, err := runner.Start(context.Background(), def); err != nil {
+		t.Fatal(err)
+	}
+	exec := waitFinished(t, runner, "gated")
+	got := resultsByName(exec)
+
+	if r := got["scan"]; r.Status != StepSucceeded || r.Attempts != 3 {
+		t.Errorf("scan = %+v, want success on the third attempt", r)
+	}
+	want := map[string]StepStatus{
+		"fix":       StepSkipped,
+		"archive":   StepSucceeded,
+		"deploy":    StepFailed,
+		"rollback":  StepSucceeded,
+		"announce":  StepSkipped,
+		"after-fix": StepSkipped,
+		"summary":   StepSucceeded,
+	}
+	for name, status := range want {
+		if got[name].Status != status {
+			t.Errorf("%s = %+v, want %s", name, got[name], status)
+		}
+	}
+	if !strings.Contains(got["announce"].Error, `dependency "deploy" failed`) {
+		t.Errorf("announce skip reason = %q", got["announce"].Error)
+	}
+	if exec.Status != StatusCompleted {
+		t.Errorf("execution status = %s", exec.Status)
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 4, BackoffSeconds: 2}
+	for attempt, want := range map[int]time.Duration{1: 0, 2: 2 * time.Second, 3: 4 * time.Second, 4: 8 * time.Second} {
+		if got := p.backoff(attempt); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+}
+
+// meteringAdapter behaves like an adapter that observes its agent: two LLM
+// calls, a read and a write attempt, and a cent and a half per call.
+type meteringAdapter struct {
+	denied []error
+}
+
+func (a *meteringAdapter) Execute(ctx context.Context, _, _ string, _ []string) (string, error) {
+	run := StepRunFromContext(ctx)
+	for range 2 {
+		if err := run.BeginIteration(); err != nil {
+			return "", err
+		}
+		run.AddUsage(500, 0.015)
+	}
+	if err := run.AllowTool("read_file"); err != nil {
+		return "", err
+	}
+	a.denied = append(a.denied, run.AllowTool("write_file"), run.AllowTool("web_fetch"))
+	return "ok", nil
+}
+
+func (a *meteringAdapter) Name() string { return "tinyclaw" }
+
+func TestRunner_MetersStepRuns(t *testing.T) {
+	adapter := &meteringAdapter{}
+	runner := NewRunner()
+	runner.RegisterAdapter("tinyclaw", adapter)
+	def := dagDefinition("metered", Step{Name: "scan", Tools: []string{"read_file", "write_file"}})
+	def.Guardrails.ReadOnly = true
+
+	if err := def.Validate(); err == nil || !strings.Contains(err.Error(), `"write_file" may write`) {
+		t.Fatalf("Validate() = %v, want the write tool rejected", err)
+	}
+	def.Guardrails.ReadOnly = false
+	def.Guardrails.MaxToolCalls = 1
+	if _, err := runner.Start(context.Background(), def); err != nil {
+		t.Fatal(err)
+	}
+	exec := waitFinished(t, runner, "metered")
+
+	if exec.Iterations != 2 || exec.ToolCalls != 1 || exec.SpentCents != 3 {
+		t.Errorf("iterations = %d, tool calls = %d, spent = %d¢, want 2, 1 and 3",
+			exec.Iterations, exec.ToolCalls, exec.SpentCents)
+	}
+	if r := exec.Results[0]; r.ToolCalls != 1 || r.Tokens != 1000 {
+		t.Errorf("step result = %+v", r)
+	}
+	var ge *GuardrailError
+	if len(adapter.denied) != 2 || !errors.As(adapter.denied[0], &ge) || ge.Reason != "tool_call_limit" {
+		t.Errorf("write_file after the limit = %v", adapter.denied)
+	}
+	if err := adapter.denied[1]; err == nil || !strings.Contains(err.Error(), "not listed") {
+		t.Errorf("unlisted tool = %v", err)
+	}
+}
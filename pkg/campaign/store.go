@@ -0,0 +1,372 @@
+package campaign
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/logger"
+)
+
+// Retention bounds how many finished executions a persistent runner keeps.
+// Running executions are never removed.
+type Retention struct {
+	// MaxAge removes finished executions that ended longer ago; 0 keeps
+	// them regardless of age.
+	MaxAge time.Duration
+	// MaxPerCampaign keeps only the most recent finished executions of each
+	// campaign; 0 keeps all of them.
+	MaxPerCampaign int
+}
+
+// DefaultRetention keeps a month of history, at most 50 runs per campaign.
+func DefaultRetention() Retention {
+	return Retention{MaxAge: 30 * 24 * time.Hour, MaxPerCampaign: 50}
+}
+
+// Store persists executions as one append-only JSONL file per execution in
+// a directory. The first line of a file carries the execution header, every
+// line the execution state at the time it was written, and step lines the
+// step's result, so a file cut short by a crash still replays to the last
+// completed step.
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewStore creates a store in dir. The directory is created on first write.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// executionState is the mutable part of an execution.
+type executionState struct {
+	Status         Status          `json:"status"`
+	EndTime        time.Time       `json:"end_time,omitzero"`
+	CurrentStep    int             `json:"current_step"`
+	SpentCents     int             `json:"spent_cents,omitempty"`
+	ToolCalls      int             `json:"tool_calls,omitempty"`
+	Iterations     int             `json:"iterations,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	KillSwitchUsed bool            `json:"kill_switch_used,omitempty"`
+	Feedback       *FeedbackResult `json:"feedback,omitempty"`
+}
+
+// storeRecord is one line of an execution file.
+type storeRecord struct {
+	Time      time.Time      `json:"time"`
+	Execution *Execution     `json:"execution,omitempty"`
+	State     executionState `json:"state"`
+	Step      *StepResult    `json:"step,omitempty"`
+}
+
+func stateOf(exec *Execution) executionState {
+	return executionState{
+		Status:         exec.Status,
+		EndTime:        exec.EndTime,
+		CurrentStep:    exec.CurrentStep,
+		SpentCents:     exec.SpentCents,
+		ToolCalls:      exec.ToolCalls,
+		Iterations:     exec.Iterations,
+		Error:          exec.Error,
+		KillSwitchUsed: exec.KillSwitchUsed,
+		Feedback:       exec.Feedback,
+	}
+}
+
+func (st executionState) applyTo(exec *Execution) {
+	exec.Status = st.Status
+	exec.EndTime = st.EndTime
+	exec.CurrentStep = st.CurrentStep
+	exec.SpentCents = st.SpentCents
+	exec.ToolCalls = st.ToolCalls
+	exec.Iterations = st.Iterations
+	exec.Error = st.Error
+	exec.KillSwitchUsed = st.KillSwitchUsed
+	exec.Feedback = st.Feedback
+}
+
+func (s *Store) path(id string) (string, error) {
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return "", fmt.Errorf("invalid execution ID %q", id)
+	}
+	return filepath.Join(s.dir, id+".jsonl"), nil
+}
+
+// Create starts the file of a new execution with its header.
+func (s *Store) Create(exec *Execution) error {
+	header := *exec
+	header.Results = nil
+	return s.write(exec.ID, storeRecord{Time: time.Now(), Execution: &header, State: stateOf(exec)}, true)
+}
+
+// Append records the execution's current state and, when step is not nil,
+// the result of a finished step.
+func (s *Store) Append(exec *Execution, step *StepResult) error {
+	return s.write(exec.ID, storeRecord{Time: time.Now(), State: stateOf(exec), Step: step}, false)
+}
+
+func (s *Store) write(id string, rec storeRecord, create bool) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("encode execution record: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	flags := os.O_WRONLY | os.O_APPEND
+	if create {
+		if err := os.MkdirAll(s.dir, 0o755); err != nil {
+			return fmt.Errorf("create campaign store: %w", err)
+		}
+		flags |= os.O_CREATE | os.O_EXCL
+	}
+	f, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return fmt.Errorf("open execution file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write execution record: %w", err)
+	}
+	return f.Sync()
+}
+
+// Load replays every execution file in the store, oldest start first.
+// Unreadable files are skipped with a warning.
+func (s *Store) Load() ([]*Execution, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	var execs []*Execution
+	for _, path := range paths {
+		exec, err := readExecution(path)
+		if err != nil {
+			logger.WarnCF("campaign", "Skipping unreadable execution file", map[string]any{
+				"path":  path,
+				"error": err.Error(),
+			})
+			continue
+		}
+		execs = append(execs, exec)
+	}
+	slices.SortFunc(execs, func(a, b *Execution) int { return a.StartTime.Compare(b.StartTime) })
+	return execs, nil
+}
+
+// Get reads an execution from the store without loading it into a
+// runner. id is an execution ID or a campaign ID, which selects the
+// campaign's running or latest execution.
+func (s *Store) Get(id string) (*Execution, error) {
+	execs, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*Execution, len(execs))
+	for _, exec := range execs {
+		byID[exec.ID] = exec
+	}
+	exec := lookupExecution(byID, id)
+	if exec == nil {
+		return nil, fmt.Errorf("campaign %q not found", id)
+	}
+	return exec, nil
+}
+
+// Query reads the stored executions matching q, most recent first. Unlike
+// a runner's Query it neither loads them into a runner nor applies
+// retention, so it is safe for processes that only inspect the store.
+func (s *Store) Query(q Query) ([]*Execution, error) {
+	execs, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+	var result []*Execution
+	for _, exec := range execs {
+		if q.matches(exec) {
+			result = append(result, exec)
+		}
+	}
+	return q.order(result), nil
+}
+
+func readExecution(path string) (*Execution, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var exec *Execution
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		var rec storeRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			// A crash can leave a partial last line; keep what came before.
+			break
+		}
+		if exec == nil {
+			if rec.Execution == nil {
+				return nil, errors.New("missing execution header")
+			}
+			exec = rec.Execution
+			exec.Results = nil
+		}
+		rec.State.applyTo(exec)
+		if rec.Step != nil {
+			exec.Results = append(exec.Results, *rec.Step)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if exec == nil {
+		return nil, errors.New("empty execution file")
+	}
+	return exec, nil
+}
+
+// Delete removes an execution's file, any pending stop request and its
+// lock.
+func (s *Store) Delete(id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range []string{path, stopPath(path), lockPath(path)} {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// RequestStop asks the process running an execution to stop it. The runner
+// of a persistent execution polls for the request, so this works across
+// processes sharing the store.
+func (s *Store) RequestStop(id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("execution %q is not stored: %w", id, err)
+	}
+	return os.WriteFile(stopPath(path), []byte(time.Now().UTC().Format(time.RFC3339)+"\n"), 0o644)
+}
+
+// takeStopRequest reports whether a stop of the execution was requested,
+// consuming the request.
+func (s *Store) takeStopRequest(id string) bool {
+	path, err := s.path(id)
+	if err != nil {
+		return false
+	}
+	return os.Remove(stopPath(path)) == nil
+}
+
+// stopPath returns the stop request file of an execution file.
+func stopPath(path string) string {
+	return strings.TrimSuffix(path, ".jsonl") + ".stop"
+}
+
+// lockStaleAfter is how long a lock outlives its last heartbeat. Runners
+// refresh their locks every stop poll, well within it.
+const lockStaleAfter = 30 * time.Second
+
+// executionLock is the content of a lock file, which marks the process
+// running an execution.
+type executionLock struct {
+	PID       int       `json:"pid"`
+	Heartbeat time.Time `json:"heartbeat"`
+}
+
+// heldElsewhere reports whether the lock belongs to another process that
+// is still alive, judged by its heartbeat. A lock with this process's PID
+// is left over from an earlier process that had the same PID, as happens
+// in containers, since a runner tracks its own executions in memory.
+func (l executionLock) heldElsewhere(now time.Time) bool {
+	return l.PID != os.Getpid() && now.Sub(l.Heartbeat) < lockStaleAfter
+}
+
+// claim takes the lock of an execution for this process, unless another
+// live process holds it. It reports whether the lock is now ours.
+func (s *Store) claim(id string) (bool, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if data, err := os.ReadFile(lockPath(path)); err == nil {
+		var held executionLock
+		if json.Unmarshal(data, &held) == nil && held.heldElsewhere(time.Now()) {
+			return false, nil
+		}
+	}
+	return true, writeLock(lockPath(path))
+}
+
+// heartbeat refreshes this process's lock of an execution, unless the
+// execution was deleted meanwhile.
+func (s *Store) heartbeat(id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return writeLock(lockPath(path))
+}
+
+// release removes this process's lock of an execution.
+func (s *Store) release(id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(lockPath(path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func writeLock(path string) error {
+	data, err := json.Marshal(executionLock{PID: os.Getpid(), Heartbeat: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create campaign store: %w", err)
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// lockPath returns the lock file of an execution file.
+func lockPath(path string) string {
+	return strings.TrimSuffix(path, ".jsonl") + ".lock"
+}
@@ -0,0 +1,278 @@
+package campaign
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingAdapter remembers the prompts it executed.
+type recordingAdapter struct {
+	mu      sync.Mutex
+	prompts []string
+}
+
+func (a *recordingAdapter) Execute(_ context.Context, _, prompt string, _ []string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.prompts = append(a.prompts, prompt)
+	return "did " + prompt, nil
+}
+
+func (a *recordingAdapter) Name() string { return "tinyclaw" }
+
+func storeDefinition(id string, prompts ...string) *Definition {
+	def := &Definition{
+		ID:         id,
+		Name:       id,
+		Targets:    []Target{{AgentID: "agent-1", Backend: "tinyclaw"}},
+		Guardrails: DefaultGuardrails(),
+		Feedback:   FeedbackNone,
+		Tags:       []string{"nightly"},
+	}
+	for _, p := range prompts {
+		def.Steps = append(def.Steps, Step{Name: p, Prompt: p, TimeoutMinutes: 5})
+	}
+	return def
+}
+
+func waitFinished(t *testing.T, r *Runner, id string) *Execution {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		r.mu.RLock()
+		exec := r.lookup(id)
+		done := exec != nil && exec.Status != StatusRunning && r.cancel[exec.ID] == nil
+		r.mu.RUnlock()
+		if done {
+			return exec
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("execution %s did not finish", id)
+	return nil
+}
+
+func TestRunner_PersistsExecutions(t *testing.T) {
+	dir := t.TempDir()
+	runner, err := NewRunnerWithStore(NewStore(dir), Retention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner.RegisterAdapter("tinyclaw", &recordingAdapter{})
+
+	if _, err := runner.Start(context.Background(), storeDefinition("audit", "first", "second")); err != nil {
+		t.Fatal(err)
+	}
+	waitFinished(t, runner, "audit")
+
+	reloaded, err := NewRunnerWithStore(NewStore(dir), Retention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exec, err := reloaded.GetStatus("audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exec.Status != StatusCompleted || len(exec.Results) != 2 || exec.Results[1].Output != "did second" {
+		t.Errorf("reloaded execution = %+v", exec)
+	}
+	if got := reloaded.Query(Query{Tag: "nightly"}); len(got) != 1 || got[0].CampaignID != "audit" {
+		t.Errorf("query by tag = %+v", got)
+	}
+	if got := reloaded.Query(Query{CampaignID: "other"}); len(got) != 0 {
+		t.Errorf("query by another campaign = %+v", got)
+	}
+}
+
+func TestRunner_ResumesInterruptedExecution(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(dir)
+
+	// An execution that stopped while running its second step.
+	exec := &Execution{
+		ID:         "deploy-1",
+		CampaignID: "deploy",
+		Definition: storeDefinition("deploy", "first", "second", "third"),
+		Status:     StatusRunning,
+		StartTime:  time.Now(),
+	}
+	if err := store.Create(exec); err != nil {
+		t.Fatal(err)
+	}
+	exec.Iterations = 1
+	store.Append(exec, &StepResult{StepName: "first", Output: "did first"})
+	exec.CurrentStep = 1
+	store.Append(exec, nil)
+	f, _ := os.OpenFile(filepath.Join(dir, "deploy-1.jsonl"), os.O_WRONLY|os.O_APPEND, 0o644)
+	f.WriteString(`{"time":"2026-`)
+	f.Close()
+
+	runner, err := NewRunnerWithStore(store, DefaultRetention())
+	if err != nil {
+		t.Fatal(err)
+	}
+	adapter := &recordingAdapter{}
+	runner.RegisterAdapter("tinyclaw", adapter)
+
+	if got := runner.Resume(context.Background()); !slices.Equal(got, []string{"deploy-1"}) {
+		t.Fatalf("Resume = %v", got)
+	}
+	exec = waitFinished(t, runner, "deploy-1")
+	if exec.Status != StatusCompleted || len(exec.Results) != 3 {
+		t.Errorf("resumed execution = %+v", exec)
+	}
+	if !slices.Equal(adapter.prompts, []string{"second", "third"}) {
+		t.Errorf("resumed steps = %v, want second and third", adapter.prompts)
+	}
+}
+
+func TestRunner_Retention(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(dir)
+
+	old := &Execution{
+		ID:         "report-old",
+		CampaignID: "report",
+		Definition: storeDefinition("report", "x"),
+		Status:     StatusCompleted,
+		StartTime:  time.Now().Add(-48 * time.Hour),
+		EndTime:    time.Now().Add(-47 * time.Hour),
+	}
+	if err := store.Create(old); err != nil {
+		t.Fatal(err)
+	}
+
+	runner, err := NewRunnerWithStore(store, Retention{MaxAge: 24 * time.Hour, MaxPerCampaign: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runner.GetStatus("report-old"); err == nil {
+		t.Error("expired execution was loaded")
+	}
+	runner.RegisterAdapter("tinyclaw", &recordingAdapter{})
+
+	for range 2 {
+		if _, err := runner.Start(context.Background(), storeDefinition("report", "x")); err != nil {
+			t.Fatal(err)
+		}
+		waitFinished(t, runner, "report")
+	}
+	if got := runner.Query(Query{CampaignID: "report"}); len(got) != 1 {
+		t.Errorf("kept %d executions, want 1", len(got))
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if len(files) != 1 {
+		t.Errorf("store files = %v", files)
+	}
+}
+
+func TestRunner_StopRequestFromAnotherProcess(t *testing.T) {
+	dir := t.TempDir()
+	runner, err := NewRunnerWithStore(NewStore(dir), Retention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner.stopPoll = 10 * time.Millisecond
+	runner.RegisterAdapter("tinyclaw", &testAdapter{name: "tinyclaw", delay: time.Second})
+	exec, err := runner.Start(context.Background(), storeDefinition("long", "wait"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another process sees the running execution in the store.
+	if err := NewStore(dir).RequestStop(exec.ID); err != nil {
+		t.Fatal(err)
+	}
+	exec = waitFinished(t, runner, exec.ID)
+	if exec.Status != StatusCanceled || !exec.KillSwitchUsed {
+		t.Errorf("execution = %+v, want canceled by the kill switch", exec)
+	}
+	if err := NewStore(dir).RequestStop("missing"); err == nil {
+		t.Error("stop request for an unknown execution succeeded")
+	}
+}
+
+func TestRunner_ResumeSkipsExecutionLockedElsewhere(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(dir)
+	exec := &Execution{
+		ID:         "sync-1",
+		CampaignID: "sync",
+		Definition: storeDefinition("sync", "only"),
+		Status:     StatusRunning,
+		StartTime:  time.Now(),
+	}
+	if err := store.Create(exec); err != nil {
+		t.Fatal(err)
+	}
+	writeTestLock := func(heartbeat time.Time) {
+		t.Helper()
+		data, _ := json.Marshal(executionLock{PID: os.Getpid() + 1, Heartbeat: heartbeat})
+		if err := os.WriteFile(filepath.Join(dir, "sync-1.lock"), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Another process is still running the execution.
+	writeTestLock(time.Now())
+	runner, err := NewRunnerWithStore(store, Retention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner.RegisterAdapter("tinyclaw", &recordingAdapter{})
+	if got := runner.Resume(context.Background()); len(got) != 0 {
+		t.Fatalf("Resume = %v, want the locked execution left alone", got)
+	}
+
+	// The other process died without releasing the lock.
+	writeTestLock(time.Now().Add(-2 * lockStaleAfter))
+	if got := runner.Resume(context.Background()); !slices.Equal(got, []string{"sync-1"}) {
+		t.Fatalf("Resume = %v, want the stale lock taken over", got)
+	}
+	if exec := waitFinished(t, runner, "sync-1"); exec.Status != StatusCompleted {
+		t.Errorf("resumed execution = %+v", exec)
+	}
+}
+
+func TestStore_QueryLeavesStoreUntouched(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(dir)
+	for i, id := range []string{"report-1", "report-2"} {
+		start := time.Now().Add(time.Duration(i-100) * 24 * time.Hour)
+		exec := &Execution{
+			ID:         id,
+			CampaignID: "report",
+			Definition: storeDefinition("report", "x"),
+			Status:     StatusCompleted,
+			StartTime:  start,
+			EndTime:    start.Add(time.Minute),
+		}
+		if err := store.Create(exec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := store.Query(Query{Tag: "nightly"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].ID != "report-2" {
+		t.Errorf("Query = %+v, want both executions, latest first", got)
+	}
+	exec, err := store.Get("report")
+	if err != nil || exec.ID != "report-2" {
+		t.Errorf("Get(report) = %+v, %v", exec, err)
+	}
+	if _, err := store.Get("missing"); err == nil {
+		t.Error("Get of an unknown execution succeeded")
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.jsonl")); len(files) != 2 {
+		t.Errorf("store files = %v, want expired executions kept by a query", files)
+	}
+}
@@ -65,6 +65,10 @@ type Config struct {
 	Devices   DevicesConfig   `json:"devices"`
 	Tailscale TailscaleConfig `json:"tailscale,omitzero"`
 	Aperture  ApertureConfig  `json:"aperture,omitzero"`
+	Tracing   TracingConfig   `json:"tracing,omitzero"`
+	Budgets   BudgetsConfig   `json:"budgets,omitzero"`
+	Memory    MemoryConfig    `json:"memory,omitzero"`
+	Campaigns CampaignsConfig `json:"campaigns,omitzero"`
 }
 
 // MarshalJSON implements custom JSON marshaling for Config
@@ -86,7 +90,8 @@ func (c Config) MarshalJSON() ([]byte, error) {
 	}
 
 	// Only include session if not empty
-	if c.Session.DMScope != "" || len(c.Session.IdentityLinks) > 0 {
+	if c.Session.DMScope != "" || len(c.Session.IdentityLinks) > 0 || c.Session.Store != "" ||
+		c.Session.Timezone != "" || len(c.Session.UserTimezones) > 0 {
 		aux.Session = &c.Session
 	}
 
@@ -173,6 +178,32 @@ type AgentBinding struct {
 type SessionConfig struct {
 	DMScope       string              `json:"dm_scope,omitempty"`
 	IdentityLinks map[string][]string `json:"identity_links,omitempty"`
+	// Store is the session storage backend: "json" (default, one file per
+	// session) or "bolt" (an embedded database in sessions/sessions.db).
+	Store string `env:"TINYCLAW_SESSION_STORE" json:"store,omitempty"`
+	// Timezone is the IANA zone, e.g. "Europe/Berlin", that schedules are
+	// written in for users without one in UserTimezones. Empty means the
+	// server's zone.
+	Timezone string `env:"TINYCLAW_SESSION_TIMEZONE" json:"timezone,omitempty"`
+	// UserTimezones maps users to IANA zones. Keys are canonical names of
+	// IdentityLinks, "channel:peer" or bare peer IDs.
+	UserTimezones map[string]string `json:"user_timezones,omitempty"`
+}
+
+// Session storage backends.
+const (
+	SessionStoreJSON = "json"
+	SessionStoreBolt = "bolt"
+)
+
+// Validate checks the session settings.
+func (c *SessionConfig) Validate() error {
+	switch c.Store {
+	case "", SessionStoreJSON, SessionStoreBolt:
+		return nil
+	default:
+		return fmt.Errorf("session.store: unknown backend %q", c.Store)
+	}
 }
 
 type AgentDefaults struct {
@@ -353,6 +384,97 @@ type ApertureConfig struct {
 	CerbosURL  string `env:"TINYCLAW_APERTURE_CERBOS_URL"  json:"cerbos_url"`
 }
 
+// MemoryConfig tunes long-term memory retrieval. Instead of the whole of
+// MEMORY.md, the system prompt carries the PromptTopK memories most relevant
+// to the current message (default 5). Fuzzy also matches misspelled terms.
+type MemoryConfig struct {
+	PromptTopK    int                       `env:"TINYCLAW_MEMORY_PROMPT_TOP_K" json:"prompt_top_k,omitempty"`
+	Fuzzy         bool                      `env:"TINYCLAW_MEMORY_FUZZY"        json:"fuzzy,omitempty"`
+	Consolidation MemoryConsolidationConfig `json:"consolidation,omitzero"`
+}
+
+// MemoryConsolidationConfig schedules distilling each agent's daily notes
+// older than MinAgeDays (default 7) into MEMORY.md every IntervalHours
+// (default 24), using Model (default: the agent's own model). With DryRun
+// the job only logs the diff it would apply.
+type MemoryConsolidationConfig struct {
+	Enabled       bool   `env:"TINYCLAW_MEMORY_CONSOLIDATION_ENABLED"        json:"enabled"`
+	Model         string `env:"TINYCLAW_MEMORY_CONSOLIDATION_MODEL"          json:"model,omitempty"`
+	IntervalHours int    `env:"TINYCLAW_MEMORY_CONSOLIDATION_INTERVAL_HOURS" json:"interval_hours,omitempty"`
+	MinAgeDays    int    `env:"TINYCLAW_MEMORY_CONSOLIDATION_MIN_AGE_DAYS"   json:"min_age_days,omitempty"`
+	DryRun        bool   `env:"TINYCLAW_MEMORY_CONSOLIDATION_DRY_RUN"        json:"dry_run,omitempty"`
+}
+
+// CampaignsConfig configures campaign runs.
+type CampaignsConfig struct {
+	Feedback CampaignFeedbackConfig `json:"feedback,omitzero"`
+}
+
+// CampaignFeedbackConfig holds the default destinations of campaign
+// reports. A campaign's feedback_target overrides them.
+type CampaignFeedbackConfig struct {
+	// Channel and ChatID receive reports of "channel" campaigns.
+	Channel string                      `env:"TINYCLAW_CAMPAIGNS_FEEDBACK_CHANNEL" json:"channel,omitempty"`
+	ChatID  string                      `env:"TINYCLAW_CAMPAIGNS_FEEDBACK_CHAT_ID" json:"chat_id,omitempty"`
+	GitHub  CampaignGitHubConfig        `json:"github,omitzero"`
+	Setec   CampaignSetecFeedbackConfig `json:"setec,omitzero"`
+}
+
+// CampaignGitHubConfig configures the GitHub issues and pull requests that
+// receive campaign reports. BaseURL defaults to https://api.github.com;
+// point it at a GitHub Enterprise API or a test server.
+type CampaignGitHubConfig struct {
+	BaseURL string   `env:"TINYCLAW_CAMPAIGNS_GITHUB_BASE_URL" json:"base_url,omitempty"`
+	Token   string   `env:"TINYCLAW_CAMPAIGNS_GITHUB_TOKEN"    json:"token,omitempty"`
+	Repo    string   `env:"TINYCLAW_CAMPAIGNS_GITHUB_REPO"     json:"repo,omitempty"`
+	Labels  []string `json:"labels,omitempty"`
+}
+
+// CampaignSetecFeedbackConfig configures storing campaign reports as
+// Setec secrets named Prefix plus the report's secret name. Not functional
+// yet: builds without the tailscale.com/setec client reject campaigns with
+// setec feedback.
+type CampaignSetecFeedbackConfig struct {
+	Enabled bool   `env:"TINYCLAW_CAMPAIGNS_SETEC_ENABLED"  json:"enabled"`
+	BaseURL string `env:"TINYCLAW_CAMPAIGNS_SETEC_BASE_URL" json:"base_url,omitempty"`
+	Prefix  string `env:"TINYCLAW_CAMPAIGNS_SETEC_PREFIX"   json:"prefix,omitempty"`
+}
+
+// BudgetsConfig holds spending limits checked before each LLM call.
+type BudgetsConfig struct {
+	Enabled bool         `env:"TINYCLAW_BUDGETS_ENABLED" json:"enabled"`
+	Rules   []BudgetRule `json:"rules,omitempty"`
+}
+
+// BudgetRule limits spend for one scope over a day or a month.
+//
+// Scope is "global", "agent", "session" or "sender". Match names the agent,
+// session key or sender the rule applies to; empty or "*" applies the limit
+// to each one separately. Crossing SoftUSD warns once per period; crossing
+// HardUSD either refuses further LLM calls (Action "refuse", the default)
+// or switches to DowngradeModel, a model_name in model_list (Action
+// "downgrade").
+type BudgetRule struct {
+	Scope          string  `json:"scope"`
+	Match          string  `json:"match,omitempty"`
+	Period         string  `json:"period"`
+	SoftUSD        float64 `json:"soft_usd,omitempty"`
+	HardUSD        float64 `json:"hard_usd,omitempty"`
+	Action         string  `json:"action,omitempty"`
+	DowngradeModel string  `json:"downgrade_model,omitempty"`
+}
+
+// TracingConfig configures distributed tracing. Exporter is "otlp" (OTLP/HTTP
+// JSON to Endpoint) or "jsonl" (one span per line in FilePath).
+type TracingConfig struct {
+	Enabled     bool              `env:"TINYCLAW_TRACING_ENABLED"      json:"enabled"`
+	Exporter    string            `env:"TINYCLAW_TRACING_EXPORTER"     json:"exporter,omitempty"`
+	Endpoint    string            `env:"TINYCLAW_TRACING_ENDPOINT"     json:"endpoint,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	FilePath    string            `env:"TINYCLAW_TRACING_FILE"         json:"file_path,omitempty"`
+	ServiceName string            `env:"TINYCLAW_TRACING_SERVICE_NAME" json:"service_name,omitempty"`
+}
+
 type ProvidersConfig struct {
 	Anthropic     ProviderConfig       `json:"anthropic"`
 	OpenAI        OpenAIProviderConfig `json:"openai"`
@@ -445,7 +567,25 @@ type ModelConfig struct {
 
 	// Optional optimizations
 	RPM            int    `json:"rpm,omitempty"`              // Requests per minute limit
+	TPM            int    `json:"tpm,omitempty"`              // Tokens per minute limit
+	MaxConcurrent  int    `json:"max_concurrent,omitempty"`   // Concurrent requests limit
 	MaxTokensField string `json:"max_tokens_field,omitempty"` // Field name for max tokens (e.g., "max_completion_tokens")
+
+	// Load balancing across entries sharing a model_name. The strategy is
+	// taken from the first entry that sets one; Weight defaults to 1.
+	LoadBalancing string `json:"load_balancing,omitempty"`
+	Weight        int    `json:"weight,omitempty"`
+
+	// Pricing overrides the built-in price table for this model.
+	Pricing *ModelPricing `json:"pricing,omitempty"`
+}
+
+// ModelPricing is the price of a model in USD per million tokens. Cached
+// prompt tokens are billed at Cached when set and at Input otherwise.
+type ModelPricing struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+	Cached float64 `json:"cached,omitempty"`
 }
 
 // Validate checks if the ModelConfig has all required fields.
@@ -456,12 +596,62 @@ func (c *ModelConfig) Validate() error {
 	if c.Model == "" {
 		return errors.New("model is required")
 	}
+	switch c.LoadBalancing {
+	case "", LBRoundRobin, LBWeighted, LBLeastOutstanding, LBLatency, LBSticky:
+	default:
+		return fmt.Errorf("unknown load_balancing strategy %q", c.LoadBalancing)
+	}
+	if c.Weight < 0 {
+		return errors.New("weight must not be negative")
+	}
 	return nil
 }
 
+// Load balancing strategies for model_list entries sharing a model_name.
+const (
+	LBRoundRobin       = "round_robin"       // rotate through endpoints (default)
+	LBWeighted         = "weighted"          // smooth weighted round-robin by Weight
+	LBLeastOutstanding = "least_outstanding" // fewest requests in flight
+	LBLatency          = "latency"           // lowest EWMA latency
+	LBSticky           = "sticky"            // hash the session key to one endpoint
+)
+
 type GatewayConfig struct {
-	Host string `env:"TINYCLAW_GATEWAY_HOST" json:"host"`
-	Port int    `env:"TINYCLAW_GATEWAY_PORT" json:"port"`
+	Host string            `env:"TINYCLAW_GATEWAY_HOST" json:"host"`
+	Port int               `env:"TINYCLAW_GATEWAY_PORT" json:"port"`
+	Auth GatewayAuthConfig `json:"auth,omitzero"`
+}
+
+// GatewayAuthConfig controls authentication for the gateway HTTP API.
+// Health and readiness probes are never authenticated.
+type GatewayAuthConfig struct {
+	Enabled bool `env:"TINYCLAW_GATEWAY_AUTH_ENABLED" json:"enabled"`
+	// APIKeys are accepted as "Authorization: Bearer <key>" or "X-API-Key".
+	APIKeys []GatewayAPIKey `json:"api_keys,omitempty"`
+	// TailnetUsers maps Tailscale login names to role names. Requests from
+	// the tailnet are identified through tsnet WhoIs; builds without tsnet
+	// refuse to start the gateway when it is set.
+	TailnetUsers map[string]string `json:"tailnet_users,omitempty"`
+	// Roles defines what each role may access.
+	Roles map[string]GatewayRole `json:"roles,omitempty"`
+}
+
+// GatewayAPIKey is a hashed API key bound to a role.
+type GatewayAPIKey struct {
+	Name string `json:"name"`
+	Hash string `json:"hash"` // "sha256:<hex>" of the raw key
+	Role string `json:"role"`
+}
+
+// GatewayRole scopes a principal to agents and endpoints.
+type GatewayRole struct {
+	// Agents lists the agent IDs the role may address; empty allows all.
+	Agents []string `json:"agents,omitempty"`
+	// Endpoints lists allowed path prefixes (e.g. "/api/dispatch", "/v1/");
+	// empty allows all.
+	Endpoints []string `json:"endpoints,omitempty"`
+	// RateLimit is the number of requests allowed per minute; 0 disables it.
+	RateLimit int `json:"rate_limit,omitempty"`
 }
 
 type BraveConfig struct {
@@ -500,6 +690,10 @@ type WebToolsConfig struct {
 
 type CronToolsConfig struct {
 	ExecTimeoutMinutes int `env:"TINYCLAW_TOOLS_CRON_EXEC_TIMEOUT_MINUTES" json:"exec_timeout_minutes"` // 0 means no timeout
+	// FailureChannel and FailureChatID receive a message whenever a job run
+	// fails after all its attempts. Empty disables the notifications.
+	FailureChannel string `env:"TINYCLAW_TOOLS_CRON_FAILURE_CHANNEL" json:"failure_channel,omitempty"`
+	FailureChatID  string `env:"TINYCLAW_TOOLS_CRON_FAILURE_CHAT_ID" json:"failure_chat_id,omitempty"`
 }
 
 type ExecConfig struct {
@@ -602,6 +796,10 @@ func LoadDhallConfig(path string) (*Config, error) {
 		return nil, err
 	}
 
+	if err := cfg.Session.Validate(); err != nil {
+		return nil, err
+	}
+
 	return cfg, nil
 }
 
@@ -648,6 +846,10 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, err
 	}
 
+	if err := cfg.Session.Validate(); err != nil {
+		return nil, err
+	}
+
 	return cfg, nil
 }
 
@@ -732,7 +934,9 @@ func expandHome(path string) string {
 
 // GetModelConfig returns the ModelConfig for the given model name.
 // If multiple configs exist with the same model_name, it uses round-robin
-// selection for load balancing. Returns an error if the model is not found.
+// selection. Per-request balancing across such entries is done by the
+// provider built in providers.CreateProvider. Returns an error if the model
+// is not found.
 func (c *Config) GetModelConfig(modelName string) (*ModelConfig, error) {
 	matches := c.findMatches(modelName)
 	if len(matches) == 0 {
@@ -747,6 +951,12 @@ func (c *Config) GetModelConfig(modelName string) (*ModelConfig, error) {
 	return &matches[idx], nil
 }
 
+// ModelConfigs returns copies of all entries with the given model_name, in
+// model_list order.
+func (c *Config) ModelConfigs(modelName string) []ModelConfig {
+	return c.findMatches(modelName)
+}
+
 // findMatches finds all ModelConfig entries with the given model_name.
 func (c *Config) findMatches(modelName string) []ModelConfig {
 	var matches []ModelConfig
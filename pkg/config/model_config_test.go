@@ -277,6 +277,25 @@ func TestModelConfig_Validate(t *testing.T) {
 			config:  ModelConfig{},
 			wantErr: true,
 		},
+		{
+			name: "sticky load balancing",
+			config: ModelConfig{
+				ModelName:     "test",
+				Model:         "openai/gpt-4o",
+				LoadBalancing: LBSticky,
+				Weight:        3,
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown load balancing strategy",
+			config: ModelConfig{
+				ModelName:     "test",
+				Model:         "openai/gpt-4o",
+				LoadBalancing: "random",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
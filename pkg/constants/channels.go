@@ -8,6 +8,8 @@ var internalChannels = map[string]struct{}{
 	"system":   {},
 	"subagent": {},
 	"api":      {},
+	"mcp":      {},
+	"campaign": {},
 }
 
 // IsInternalChannel returns true if the channel is an internal channel.
@@ -0,0 +1,189 @@
+package cron
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/utils"
+)
+
+// DefaultHistoryPerJob is how many runs of each job the history keeps.
+const DefaultHistoryPerJob = 50
+
+// maxHistoryRecords bounds the whole history, which also keeps runs of
+// jobs that deleted themselves after running once.
+const maxHistoryRecords = 2000
+
+// maxOutputExcerpt caps the output kept for each run, in runes.
+const maxOutputExcerpt = 500
+
+// Run statuses.
+const (
+	RunOK      = "ok"
+	RunError   = "error"
+	RunSkipped = "skipped"
+)
+
+// RunRecord is one run of a job in the history.
+type RunRecord struct {
+	JobID       string `json:"jobId"`
+	JobName     string `json:"jobName"`
+	StartedAtMS int64  `json:"startedAtMs"`
+	EndedAtMS   int64  `json:"endedAtMs"`
+	DurationMS  int64  `json:"durationMs"`
+	Status      string `json:"status"`
+	// Attempts counts the handler calls of the run, retries included.
+	Attempts int `json:"attempts,omitempty"`
+	// Output is the start of the last attempt's output.
+	Output string `json:"output,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// Tokens are the LLM tokens the run used, as reported through AddUsage.
+	Tokens int64 `json:"tokens,omitempty"`
+}
+
+// runHistory is a JSONL file of recent runs, bounded per job.
+type runHistory struct {
+	path   string
+	perJob int
+	mu     sync.Mutex
+}
+
+func newRunHistory(path string, perJob int) *runHistory {
+	return &runHistory{path: path, perJob: perJob}
+}
+
+// append adds a run and drops the oldest runs of its job beyond the bound.
+func (h *runHistory) append(rec RunRecord) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if rec.Output != "" {
+		rec.Output = utils.Truncate(rec.Output, maxOutputExcerpt)
+	}
+	records, err := h.readUnsafe()
+	if err != nil {
+		return err
+	}
+	records = append(records, rec)
+
+	kept := 0
+	for _, r := range records {
+		if r.JobID == rec.JobID {
+			kept++
+		}
+	}
+	if drop := kept - h.perJob; drop > 0 {
+		trimmed := records[:0]
+		for _, r := range records {
+			if r.JobID == rec.JobID && drop > 0 {
+				drop--
+				continue
+			}
+			trimmed = append(trimmed, r)
+		}
+		records = trimmed
+	}
+	if len(records) > maxHistoryRecords {
+		records = records[len(records)-maxHistoryRecords:]
+	}
+	return h.writeUnsafe(records)
+}
+
+// remove drops the runs of a job.
+func (h *runHistory) remove(jobID string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	records, err := h.readUnsafe()
+	if err != nil {
+		return err
+	}
+	kept := records[:0]
+	for _, r := range records {
+		if r.JobID != jobID {
+			kept = append(kept, r)
+		}
+	}
+	if len(kept) == len(records) {
+		return nil
+	}
+	return h.writeUnsafe(kept)
+}
+
+// list returns the runs of a job, most recent first. limit <= 0 returns all.
+func (h *runHistory) list(jobID string, limit int) ([]RunRecord, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	records, err := h.readUnsafe()
+	if err != nil {
+		return nil, err
+	}
+	var result []RunRecord
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].JobID != jobID {
+			continue
+		}
+		result = append(result, records[i])
+		if limit > 0 && len(result) == limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+func (h *runHistory) readUnsafe() ([]RunRecord, error) {
+	data, err := os.ReadFile(h.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var records []RunRecord
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec RunRecord
+		// Skip lines a crash left half-written.
+		if json.Unmarshal(scanner.Bytes(), &rec) == nil {
+			records = append(records, rec)
+		}
+	}
+	return records, scanner.Err()
+}
+
+func (h *runHistory) writeUnsafe(records []RunRecord) error {
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o755); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, h.path)
+}
+
+type usageKey struct{}
+
+// AddUsage adds LLM tokens to the run of the job handler given ctx, for the
+// run's history record. It does nothing outside a job run.
+func AddUsage(ctx context.Context, tokens int) {
+	if n, ok := ctx.Value(usageKey{}).(*atomic.Int64); ok {
+		n.Add(int64(tokens))
+	}
+}
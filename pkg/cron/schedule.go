@@ -0,0 +1,330 @@
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adhocore/gronx"
+)
+
+// Location returns the zone the schedule's cron expression is evaluated in:
+// TZ, or the server's local zone when TZ is empty.
+func (s CronSchedule) Location() (*time.Location, error) {
+	if s.TZ == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(s.TZ)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", s.TZ)
+	}
+	return loc, nil
+}
+
+// nextCronTime returns the first fire time of a cron schedule after now,
+// evaluating the expression on the wall clock of the schedule's zone.
+func nextCronTime(s CronSchedule, now time.Time) (time.Time, error) {
+	loc, err := s.Location()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// Walk the zone's wall clock as if it had no DST, then place each match
+	// in the zone: a time skipped when clocks spring forward fires after the
+	// gap, and a time repeated when they fall back fires once.
+	local := now.In(loc)
+	wall := time.Date(local.Year(), local.Month(), local.Day(),
+		local.Hour(), local.Minute(), local.Second(), local.Nanosecond(), time.UTC)
+	for range 2000 {
+		next, err := gronx.NextTickAfter(s.Expr, wall, false)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if !next.After(wall) {
+			wall = wall.Add(time.Second)
+			continue
+		}
+		wall = next
+		at := time.Date(next.Year(), next.Month(), next.Day(),
+			next.Hour(), next.Minute(), next.Second(), 0, loc)
+		if at.Hour() != next.Hour() || at.Minute() != next.Minute() {
+			// next is in a gap; move at to the offset after it.
+			_, before := at.Zone()
+			_, after := at.Add(24 * time.Hour).Zone()
+			at = at.Add(time.Duration(after-before) * time.Second)
+		}
+		if at.After(now) {
+			return at, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("no fire time after %s", now.Format(time.RFC3339))
+}
+
+// NextRuns returns the next n fire times of a schedule after now, in the
+// schedule's zone. A one-time schedule in the past has none.
+func NextRuns(s CronSchedule, now time.Time, n int) ([]time.Time, error) {
+	loc, err := s.Location()
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []time.Time
+	switch s.Kind {
+	case "at":
+		if s.AtMS == nil {
+			return nil, errors.New("one-time schedule has no time")
+		}
+		if at := time.UnixMilli(*s.AtMS); at.After(now) && n > 0 {
+			runs = append(runs, at.In(loc))
+		}
+	case "every":
+		if s.EveryMS == nil || *s.EveryMS <= 0 {
+			return nil, errors.New("interval must be positive")
+		}
+		every := time.Duration(*s.EveryMS) * time.Millisecond
+		for i := 1; i <= n; i++ {
+			runs = append(runs, now.Add(time.Duration(i)*every).In(loc))
+		}
+	case "cron":
+		if !gronx.New().IsValid(s.Expr) {
+			return nil, fmt.Errorf("invalid cron expression %q", s.Expr)
+		}
+		next := now
+		for range n {
+			if next, err = nextCronTime(s, next); err != nil {
+				return nil, err
+			}
+			runs = append(runs, next)
+		}
+	default:
+		return nil, fmt.Errorf("unknown schedule kind %q", s.Kind)
+	}
+	return runs, nil
+}
+
+var (
+	durationPattern = regexp.MustCompile(`^(\d+|an?)\s*([a-z]+)$`)
+	clockPattern    = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?\s*(am|pm)?$`)
+)
+
+var durationUnits = map[string]time.Duration{
+	"s": time.Second, "sec": time.Second, "secs": time.Second, "second": time.Second, "seconds": time.Second,
+	"m": time.Minute, "min": time.Minute, "mins": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hrs": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour, "week": 7 * 24 * time.Hour, "weeks": 7 * 24 * time.Hour,
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday, "thurs": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+// ParseSchedule turns a schedule phrase into a schedule. It accepts cron
+// expressions and phrases such as "in 2 hours", "tomorrow 18:30",
+// "friday at 5pm", "every 15 minutes", "every day at 9am", "every weekday
+// at 9am" and "every monday and thursday at 17:30". Wall times are in loc,
+// which recurring schedules keep as their TZ.
+func ParseSchedule(phrase string, now time.Time, loc *time.Location) (CronSchedule, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	p := strings.ToLower(strings.Join(strings.Fields(phrase), " "))
+	p = strings.TrimSuffix(p, ".")
+	if p == "" {
+		return CronSchedule{}, errors.New("empty schedule")
+	}
+
+	if n := len(strings.Fields(p)); (n == 5 || n == 6) && gronx.New().IsValid(p) {
+		return CronSchedule{Kind: "cron", Expr: p, TZ: zoneName(loc)}, nil
+	}
+
+	switch {
+	case strings.HasPrefix(p, "in "):
+		d, ok := parseDuration(strings.TrimPrefix(p, "in "))
+		if !ok {
+			return CronSchedule{}, fmt.Errorf("cannot read the delay in %q", phrase)
+		}
+		at := now.Add(d).UnixMilli()
+		return CronSchedule{Kind: "at", AtMS: &at}, nil
+	case p == "hourly":
+		every := time.Hour.Milliseconds()
+		return CronSchedule{Kind: "every", EveryMS: &every}, nil
+	case strings.HasPrefix(p, "every "):
+		rest := strings.TrimPrefix(p, "every ")
+		if d, ok := parseDuration(rest); ok {
+			every := d.Milliseconds()
+			return CronSchedule{Kind: "every", EveryMS: &every}, nil
+		}
+		return parseRecurring(rest, phrase, loc)
+	case strings.HasPrefix(p, "daily"), strings.HasPrefix(p, "weekdays"), strings.HasPrefix(p, "weekends"):
+		return parseRecurring(p, phrase, loc)
+	}
+	return parseOneTime(p, phrase, now.In(loc))
+}
+
+// parseDuration reads "2 hours", "90m", "an hour" or "1h30m".
+func parseDuration(s string) (time.Duration, bool) {
+	if m := durationPattern.FindStringSubmatch(s); m != nil {
+		unit, ok := durationUnits[m[2]]
+		if !ok {
+			return 0, false
+		}
+		n := 1
+		if m[1] != "a" && m[1] != "an" {
+			n, _ = strconv.Atoi(m[1])
+		}
+		return time.Duration(n) * unit, n > 0
+	}
+	if unit, ok := durationUnits[s]; ok && len(s) > 1 {
+		return unit, true // "every hour"
+	}
+	d, err := time.ParseDuration(s)
+	return d, err == nil && d > 0
+}
+
+// parseClock reads "9am", "9:30 pm", "18:30", "noon" or "midnight".
+func parseClock(s string) (hour, minute int, ok bool) {
+	switch s {
+	case "noon":
+		return 12, 0, true
+	case "midnight":
+		return 0, 0, true
+	}
+	m := clockPattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0, 0, false
+	}
+	hour, _ = strconv.Atoi(m[1])
+	if m[2] != "" {
+		minute, _ = strconv.Atoi(m[2])
+	}
+	switch m[3] {
+	case "am", "pm":
+		if hour < 1 || hour > 12 {
+			return 0, 0, false
+		}
+		hour %= 12
+		if m[3] == "pm" {
+			hour += 12
+		}
+	default:
+		if hour > 23 {
+			return 0, 0, false
+		}
+	}
+	return hour, minute, minute < 60
+}
+
+// splitClock splits the time of day off the end of s: "friday at 5pm"
+// gives "friday" and 17:00.
+func splitClock(s string) (head string, hour, minute int, ok bool) {
+	if before, after, found := strings.Cut(s, " at "); found {
+		hour, minute, ok = parseClock(after)
+		return before, hour, minute, ok
+	}
+	if after, found := strings.CutPrefix(s, "at "); found {
+		hour, minute, ok = parseClock(after)
+		return "", hour, minute, ok
+	}
+	fields := strings.Fields(s)
+	for n := min(2, len(fields)); n > 0; n-- {
+		if hour, minute, ok = parseClock(strings.Join(fields[len(fields)-n:], " ")); ok {
+			return strings.Join(fields[:len(fields)-n], " "), hour, minute, true
+		}
+	}
+	return s, 0, 0, false
+}
+
+// parseRecurring reads the days and time of a recurring phrase such as
+// "weekday at 9am" into a cron schedule.
+func parseRecurring(s, phrase string, loc *time.Location) (CronSchedule, error) {
+	days, hour, minute, ok := splitClock(s)
+	if !ok {
+		return CronSchedule{}, fmt.Errorf("%q needs a time of day, e.g. %q", phrase, "every day at 9am")
+	}
+
+	var dow string
+	switch days {
+	case "", "day", "daily", "every day":
+		dow = "*"
+	case "weekday", "weekdays":
+		dow = "1-5"
+	case "weekend", "weekends":
+		dow = "0,6"
+	default:
+		var list []string
+		for _, name := range strings.FieldsFunc(days, func(r rune) bool { return r == ',' || r == ' ' || r == '&' }) {
+			if name == "and" {
+				continue
+			}
+			day, ok := weekdayNames[name]
+			if !ok {
+				day, ok = weekdayNames[strings.TrimSuffix(name, "s")] // "mondays"
+			}
+			if !ok {
+				return CronSchedule{}, fmt.Errorf("cannot read the days in %q", phrase)
+			}
+			list = append(list, strconv.Itoa(int(day)))
+		}
+		if len(list) == 0 {
+			return CronSchedule{}, fmt.Errorf("cannot read the days in %q", phrase)
+		}
+		dow = strings.Join(list, ",")
+	}
+
+	expr := fmt.Sprintf("%d %d * * %s", minute, hour, dow)
+	return CronSchedule{Kind: "cron", Expr: expr, TZ: zoneName(loc)}, nil
+}
+
+// parseOneTime reads a one-time phrase such as "tomorrow 18:30", "friday
+// at 5pm" or "at 7am" relative to now, which is in the user's zone.
+func parseOneTime(s, phrase string, now time.Time) (CronSchedule, error) {
+	s = strings.TrimPrefix(s, "on ")
+	day, hour, minute, ok := splitClock(s)
+	if !ok {
+		return CronSchedule{}, fmt.Errorf("cannot read %q as a schedule; try e.g. %q, %q or %q",
+			phrase, "in 2 hours", "tomorrow 18:30", "every weekday at 9am")
+	}
+
+	at := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	switch day {
+	case "", "today", "tonight":
+		if !at.After(now) {
+			if day != "" {
+				return CronSchedule{}, fmt.Errorf("%q has already passed", phrase)
+			}
+			at = at.AddDate(0, 0, 1)
+		}
+	case "tomorrow":
+		at = at.AddDate(0, 0, 1)
+	default:
+		weekday, ok := weekdayNames[strings.TrimPrefix(day, "next ")]
+		if !ok {
+			return CronSchedule{}, fmt.Errorf("cannot read the day in %q", phrase)
+		}
+		days := (int(weekday) - int(now.Weekday()) + 7) % 7
+		at = at.AddDate(0, 0, days)
+		if !at.After(now) {
+			at = at.AddDate(0, 0, 7)
+		}
+	}
+	atMS := at.UnixMilli()
+	return CronSchedule{Kind: "at", AtMS: &atMS}, nil
+}
+
+// zoneName is the TZ of loc; empty for the server's local zone.
+func zoneName(loc *time.Location) string {
+	if loc == time.Local {
+		return ""
+	}
+	return loc.String()
+}
@@ -0,0 +1,159 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+	return loc
+}
+
+func TestParseSchedule(t *testing.T) {
+	berlin := mustLocation(t, "Europe/Berlin")
+	// A Wednesday afternoon in Berlin.
+	now := time.Date(2026, 3, 4, 15, 0, 0, 0, berlin)
+
+	at := func(d time.Time) CronSchedule {
+		ms := d.UnixMilli()
+		return CronSchedule{Kind: "at", AtMS: &ms}
+	}
+	every := func(d time.Duration) CronSchedule {
+		ms := d.Milliseconds()
+		return CronSchedule{Kind: "every", EveryMS: &ms}
+	}
+	cronExpr := func(expr string) CronSchedule {
+		return CronSchedule{Kind: "cron", Expr: expr, TZ: "Europe/Berlin"}
+	}
+
+	tests := []struct {
+		phrase string
+		want   CronSchedule
+	}{
+		{"in 2 hours", at(now.Add(2 * time.Hour))},
+		{"in 90m", at(now.Add(90 * time.Minute))},
+		{"in an hour", at(now.Add(time.Hour))},
+		{"in 1h30m", at(now.Add(90 * time.Minute))},
+		{"tomorrow 18:30", at(time.Date(2026, 3, 5, 18, 30, 0, 0, berlin))},
+		{"Tomorrow at 9am", at(time.Date(2026, 3, 5, 9, 0, 0, 0, berlin))},
+		{"at 7am", at(time.Date(2026, 3, 5, 7, 0, 0, 0, berlin))},
+		{"today at 5:30 pm", at(time.Date(2026, 3, 4, 17, 30, 0, 0, berlin))},
+		{"friday at noon", at(time.Date(2026, 3, 6, 12, 0, 0, 0, berlin))},
+		{"on wednesday 9am", at(time.Date(2026, 3, 11, 9, 0, 0, 0, berlin))},
+		{"every 15 minutes", every(15 * time.Minute)},
+		{"every hour", every(time.Hour)},
+		{"hourly", every(time.Hour)},
+		{"every 2 days", every(48 * time.Hour)},
+		{"every day at 9am", cronExpr("0 9 * * *")},
+		{"daily at 21:15", cronExpr("15 21 * * *")},
+		{"every weekday at 9am", cronExpr("0 9 * * 1-5")},
+		{"weekends at 10:30am", cronExpr("30 10 * * 0,6")},
+		{"every monday and friday at 17:30", cronExpr("30 17 * * 1,5")},
+		{"every tue, thu at midnight", cronExpr("0 0 * * 2,4")},
+		{"every mondays 8am", cronExpr("0 8 * * 1")},
+		{"0 9 * * 1-5", cronExpr("0 9 * * 1-5")},
+	}
+	for _, tt := range tests {
+		got, err := ParseSchedule(tt.phrase, now, berlin)
+		if err != nil {
+			t.Errorf("ParseSchedule(%q): %v", tt.phrase, err)
+			continue
+		}
+		if got.Kind != tt.want.Kind || got.Expr != tt.want.Expr || got.TZ != tt.want.TZ ||
+			!equalMS(got.AtMS, tt.want.AtMS) || !equalMS(got.EveryMS, tt.want.EveryMS) {
+			t.Errorf("ParseSchedule(%q) = %s, want %s", tt.phrase, describe(got), describe(tt.want))
+		}
+	}
+
+	for _, phrase := range []string{"", "soon", "every weekday", "today at 9am", "every blursday at 9am", "at 25:00", "in 0 minutes"} {
+		if got, err := ParseSchedule(phrase, now, berlin); err == nil {
+			t.Errorf("ParseSchedule(%q) = %s, want an error", phrase, describe(got))
+		}
+	}
+}
+
+func equalMS(a, b *int64) bool {
+	return (a == nil) == (b == nil) && (a == nil || *a == *b)
+}
+
+func describe(s CronSchedule) string {
+	switch {
+	case s.AtMS != nil:
+		return "at " + time.UnixMilli(*s.AtMS).UTC().Format(time.RFC3339)
+	case s.EveryMS != nil:
+		return "every " + (time.Duration(*s.EveryMS) * time.Millisecond).String()
+	}
+	return s.Kind + " " + s.Expr + " " + s.TZ
+}
+
+func TestNextRuns_TimezoneAndDST(t *testing.T) {
+	newYork := mustLocation(t, "America/New_York")
+	schedule := CronSchedule{Kind: "cron", Expr: "0 9 * * *", TZ: "America/New_York"}
+
+	// Clocks spring forward on March 8, 2026; 9am stays 9am local.
+	now := time.Date(2026, 3, 7, 15, 0, 0, 0, time.UTC)
+	runs, err := NextRuns(schedule, now, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Time{
+		time.Date(2026, 3, 8, 13, 0, 0, 0, time.UTC),
+		time.Date(2026, 3, 9, 13, 0, 0, 0, time.UTC),
+	}
+	for i := range want {
+		if !runs[i].Equal(want[i]) || runs[i].Location().String() != newYork.String() {
+			t.Errorf("run %d = %s, want %s", i, runs[i], want[i])
+		}
+	}
+
+	// 2:30 does not exist that day; the job runs after the gap instead of
+	// skipping the day.
+	schedule.Expr = "30 2 * * *"
+	runs, err = NextRuns(schedule, now, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 3, 8, 7, 30, 0, 0, time.UTC); !runs[0].Equal(want) {
+		t.Errorf("run in the gap = %s, want %s", runs[0], want)
+	}
+
+	// When clocks fall back on November 1, 1:30 happens twice; the job
+	// fires once.
+	schedule.Expr = "30 1 * * *"
+	first := time.Date(2026, 11, 1, 1, 30, 0, 0, newYork)
+	runs, err = NextRuns(schedule, first, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 11, 2, 1, 30, 0, 0, newYork); !runs[0].Equal(want) {
+		t.Errorf("run after the repeated hour = %s, want %s", runs[0], want)
+	}
+
+	// So does every run of an expression during the repeated hour.
+	schedule.Expr = "*/20 * * * *"
+	runs, err = NextRuns(schedule, time.Date(2026, 11, 1, 0, 50, 0, 0, newYork), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(runs); i++ {
+		if gap := runs[i].Sub(runs[i-1]); gap != 20*time.Minute && gap != 80*time.Minute {
+			t.Errorf("runs %s and %s are %s apart", runs[i-1], runs[i], gap)
+		}
+	}
+
+	// The service evaluates the expression in the job's zone too.
+	cs := &CronService{}
+	next := cs.computeNextRun(&CronSchedule{Kind: "cron", Expr: "0 9 * * *", TZ: "Asia/Tokyo"}, now.UnixMilli())
+	if next == nil || time.UnixMilli(*next).UTC().Hour() != 0 {
+		t.Errorf("computeNextRun in Tokyo = %v, want midnight UTC", next)
+	}
+
+	if _, err := NextRuns(CronSchedule{Kind: "cron", Expr: "0 9 * * *", TZ: "Mars/Olympus"}, now, 1); err == nil {
+		t.Error("NextRuns with an unknown zone succeeded")
+	}
+}
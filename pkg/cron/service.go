@@ -1,6 +1,7 @@
 package cron
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
@@ -11,9 +12,12 @@ import (
 	"path/filepath"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/adhocore/gronx"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/metrics"
 )
 
 type CronSchedule struct {
@@ -34,10 +38,67 @@ type CronPayload struct {
 }
 
 type CronJobState struct {
-	NextRunAtMS *int64 `json:"nextRunAtMs,omitempty"`
-	LastRunAtMS *int64 `json:"lastRunAtMs,omitempty"`
-	LastStatus  string `json:"lastStatus,omitempty"`
-	LastError   string `json:"lastError,omitempty"`
+	NextRunAtMS    *int64 `json:"nextRunAtMs,omitempty"`
+	LastRunAtMS    *int64 `json:"lastRunAtMs,omitempty"`
+	LastStatus     string `json:"lastStatus,omitempty"`
+	LastError      string `json:"lastError,omitempty"`
+	LastDurationMS int64  `json:"lastDurationMs,omitempty"`
+	// ConsecutiveFailures counts the failed runs since the last success.
+	ConsecutiveFailures int `json:"consecutiveFailures,omitempty"`
+}
+
+// Overlap policies say what happens when a job is due while an earlier run
+// of it is still going.
+const (
+	// OverlapSkip drops the new run. It is the default.
+	OverlapSkip = "skip"
+	// OverlapQueue starts the new run when the earlier one ends. At most one
+	// run is queued.
+	OverlapQueue = "queue"
+	// OverlapAllow starts the new run alongside the earlier one.
+	OverlapAllow = "allow"
+)
+
+const (
+	defaultRetryBackoff = 30 * time.Second
+	defaultMaxBackoff   = 10 * time.Minute
+)
+
+// RetryPolicy retries the failed attempts of a run.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts per run; 0 and 1 disable
+	// retries.
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+	// BackoffMS is the delay before the first retry, 30s by default. Each
+	// later retry waits twice as long, up to MaxBackoffMS (10m by default).
+	BackoffMS    int64 `json:"backoffMs,omitempty"`
+	MaxBackoffMS int64 `json:"maxBackoffMs,omitempty"`
+}
+
+func (p RetryPolicy) attempts() int {
+	return max(p.MaxAttempts, 1)
+}
+
+// backoff returns the delay after the given number of failed attempts.
+func (p RetryPolicy) backoff(failed int) time.Duration {
+	delay := time.Duration(p.BackoffMS) * time.Millisecond
+	if delay <= 0 {
+		delay = defaultRetryBackoff
+	}
+	limit := time.Duration(p.MaxBackoffMS) * time.Millisecond
+	if limit <= 0 {
+		limit = defaultMaxBackoff
+	}
+	for i := 1; i < failed && delay < limit; i++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
+
+// ValidOverlap reports whether s is an overlap policy; empty means the
+// default.
+func ValidOverlap(s string) bool {
+	return s == "" || s == OverlapSkip || s == OverlapQueue || s == OverlapAllow
 }
 
 type CronJob struct {
@@ -50,6 +111,9 @@ type CronJob struct {
 	CreatedAtMS    int64        `json:"createdAtMs"`
 	UpdatedAtMS    int64        `json:"updatedAtMs"`
 	DeleteAfterRun bool         `json:"deleteAfterRun"`
+	Retry          RetryPolicy  `json:"retry,omitzero"`
+	// Overlap is one of the Overlap policies; empty means OverlapSkip.
+	Overlap string `json:"overlap,omitempty"`
 }
 
 type CronStore struct {
@@ -57,23 +121,45 @@ type CronStore struct {
 	Jobs    []CronJob `json:"jobs"`
 }
 
-type JobHandler func(job *CronJob) (string, error)
+// JobHandler runs a job and returns its output. ctx is canceled when the
+// service stops.
+type JobHandler func(ctx context.Context, job *CronJob) (string, error)
+
+// FailureHandler is told about runs that failed after all their attempts.
+// job reflects the failed run in its State.
+type FailureHandler func(job CronJob, run RunRecord)
 
 type CronService struct {
 	storePath string
 	store     *CronStore
+	history   *runHistory
 	onJob     JobHandler
+	onFailure FailureHandler
 	mu        sync.RWMutex
 	running   bool
 	stopChan  chan struct{}
 	gronx     *gronx.Gronx
+
+	// ctx is canceled by Stop to end running jobs and retry waits.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	// active counts the running runs of each job; queued marks jobs with
+	// a run waiting for them under OverlapQueue.
+	active map[string]int
+	queued map[string]bool
 }
 
+// NewCronService creates a service storing jobs at storePath and their run
+// history in history.jsonl next to it.
 func NewCronService(storePath string, onJob JobHandler) *CronService {
 	cs := &CronService{
 		storePath: storePath,
+		history:   newRunHistory(filepath.Join(filepath.Dir(storePath), "history.jsonl"), DefaultHistoryPerJob),
 		onJob:     onJob,
 		gronx:     gronx.New(),
+		active:    make(map[string]int),
+		queued:    make(map[string]bool),
 	}
 	// Initialize and load store on creation
 	cs.loadStore()
@@ -98,17 +184,19 @@ func (cs *CronService) Start() error {
 	}
 
 	cs.stopChan = make(chan struct{})
+	cs.ctx, cs.cancel = context.WithCancel(context.Background())
 	cs.running = true
 	go cs.runLoop(cs.stopChan)
 
 	return nil
 }
 
+// Stop stops scheduling jobs, cancels the running ones and waits for them
+// to return.
 func (cs *CronService) Stop() {
 	cs.mu.Lock()
-	defer cs.mu.Unlock()
-
 	if !cs.running {
+		cs.mu.Unlock()
 		return
 	}
 
@@ -117,6 +205,11 @@ func (cs *CronService) Stop() {
 		close(cs.stopChan)
 		cs.stopChan = nil
 	}
+	cs.cancel()
+	clear(cs.queued)
+	cs.mu.Unlock()
+
+	cs.wg.Wait()
 }
 
 func (cs *CronService) runLoop(stopChan chan struct{}) {
@@ -134,74 +227,148 @@ func (cs *CronService) runLoop(stopChan chan struct{}) {
 }
 
 func (cs *CronService) checkJobs() {
+	// Skipped runs are recorded after unlocking, since writing the history
+	// can rewrite the whole file.
+	for _, rec := range cs.startDueJobs() {
+		cs.appendHistory(rec)
+	}
+}
+
+// startDueJobs starts the jobs that are due and returns the records of the
+// runs it skipped because the previous run is still in progress.
+func (cs *CronService) startDueJobs() []RunRecord {
 	cs.mu.Lock()
+	defer cs.mu.Unlock()
 
 	if !cs.running {
-		cs.mu.Unlock()
-		return
+		return nil
 	}
 
 	now := time.Now().UnixMilli()
-	var dueJobIDs []string
-
-	// Collect jobs that are due (we need to copy them to execute outside lock)
+	due := false
+	var skipped []RunRecord
 	for i := range cs.store.Jobs {
 		job := &cs.store.Jobs[i]
-		if job.Enabled && job.State.NextRunAtMS != nil && *job.State.NextRunAtMS <= now {
-			dueJobIDs = append(dueJobIDs, job.ID)
+		if !job.Enabled || job.State.NextRunAtMS == nil || *job.State.NextRunAtMS > now {
+			continue
 		}
-	}
+		due = true
 
-	// Reset next run for due jobs before unlocking to avoid duplicate execution.
-	dueMap := make(map[string]bool, len(dueJobIDs))
-	for _, jobID := range dueJobIDs {
-		dueMap[jobID] = true
-	}
-	for i := range cs.store.Jobs {
-		if dueMap[cs.store.Jobs[i].ID] {
-			cs.store.Jobs[i].State.NextRunAtMS = nil
+		// Schedule the next run before starting this one, so that a slow run
+		// neither delays the schedule nor runs twice.
+		if job.Schedule.Kind == "at" {
+			job.State.NextRunAtMS = nil
+		} else {
+			job.State.NextRunAtMS = cs.computeNextRun(&job.Schedule, now)
 		}
-	}
 
-	if err := cs.saveStoreUnsafe(); err != nil {
-		log.Printf("[cron] failed to save store: %v", err)
+		if cs.active[job.ID] > 0 {
+			switch job.Overlap {
+			case OverlapAllow:
+			case OverlapQueue:
+				cs.queued[job.ID] = true
+				continue
+			default:
+				skipped = append(skipped, RunRecord{
+					JobID:       job.ID,
+					JobName:     job.Name,
+					StartedAtMS: now,
+					EndedAtMS:   now,
+					Status:      RunSkipped,
+					Error:       "previous run still in progress",
+				})
+				continue
+			}
+		}
+		cs.startRunUnsafe(*job)
 	}
 
-	cs.mu.Unlock()
-
-	// Execute jobs outside lock.
-	for _, jobID := range dueJobIDs {
-		cs.executeJobByID(jobID)
+	if due {
+		if err := cs.saveStoreUnsafe(); err != nil {
+			log.Printf("[cron] failed to save store: %v", err)
+		}
 	}
+	return skipped
+}
+
+// startRunUnsafe runs a copy of the job in the background. Must be called
+// with cs.mu held while the service is running.
+func (cs *CronService) startRunUnsafe(job CronJob) {
+	cs.active[job.ID]++
+	cs.wg.Add(1)
+	go cs.runJob(cs.ctx, job)
 }
 
-func (cs *CronService) executeJobByID(jobID string) {
-	startTime := time.Now().UnixMilli()
+// runJob calls the handler, retrying as the job's policy says, then
+// records the run.
+func (cs *CronService) runJob(ctx context.Context, job CronJob) {
+	defer cs.wg.Done()
 
 	cs.mu.RLock()
-	var callbackJob *CronJob
-	for i := range cs.store.Jobs {
-		job := &cs.store.Jobs[i]
-		if job.ID == jobID {
-			jobCopy := *job
-			callbackJob = &jobCopy
+	handler := cs.onJob
+	cs.mu.RUnlock()
+
+	start := time.Now()
+	var tokens atomic.Int64
+	runCtx := context.WithValue(ctx, usageKey{}, &tokens)
+
+	run := RunRecord{JobID: job.ID, JobName: job.Name, StartedAtMS: start.UnixMilli()}
+	var err error
+	for attempt := 1; ; attempt++ {
+		run.Attempts = attempt
+		if handler != nil {
+			run.Output, err = handler(runCtx, &job)
+		}
+		if err == nil || attempt >= job.Retry.attempts() || ctx.Err() != nil {
 			break
 		}
-	}
-	cs.mu.RUnlock()
 
-	if callbackJob == nil {
-		return
+		delay := job.Retry.backoff(attempt)
+		log.Printf("[cron] job %s failed (attempt %d/%d), retrying in %s: %v",
+			job.ID, attempt, job.Retry.attempts(), delay, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+		}
+		timer.Stop()
+		if ctx.Err() != nil {
+			break
+		}
 	}
 
-	var err error
-	if cs.onJob != nil {
-		_, err = cs.onJob(callbackJob)
+	end := time.Now()
+	run.EndedAtMS = end.UnixMilli()
+	run.DurationMS = end.Sub(start).Milliseconds()
+	run.Tokens = tokens.Load()
+	run.Status = RunOK
+	if err != nil {
+		run.Status = RunError
+		run.Error = err.Error()
 	}
+	metrics.CronRuns.Inc(job.Name, run.Status)
+	metrics.CronDuration.Observe(end.Sub(start).Seconds(), job.Name)
 
-	// Now acquire lock to update state
 	cs.mu.Lock()
-	defer cs.mu.Unlock()
+	if updated := cs.finishRunUnsafe(job.ID, run); updated != nil {
+		job = *updated
+	}
+	onFailure := cs.onFailure
+	cs.mu.Unlock()
+
+	cs.appendHistory(run)
+	if err != nil && onFailure != nil {
+		onFailure(job, run)
+	}
+}
+
+// finishRunUnsafe records a finished run on its job, starts the queued run,
+// if any, and returns a copy of the updated job, or nil when the job is
+// gone. Must be called with cs.mu held.
+func (cs *CronService) finishRunUnsafe(jobID string, run RunRecord) *CronJob {
+	if cs.active[jobID]--; cs.active[jobID] <= 0 {
+		delete(cs.active, jobID)
+	}
 
 	var job *CronJob
 	for i := range cs.store.Jobs {
@@ -212,36 +379,55 @@ func (cs *CronService) executeJobByID(jobID string) {
 	}
 	if job == nil {
 		log.Printf("[cron] job %s disappeared before state update", jobID)
-		return
+		delete(cs.queued, jobID)
+		return nil
 	}
 
-	job.State.LastRunAtMS = &startTime
-	job.UpdatedAtMS = time.Now().UnixMilli()
-
-	if err != nil {
-		job.State.LastStatus = "error"
-		job.State.LastError = err.Error()
+	job.State.LastRunAtMS = &run.StartedAtMS
+	job.State.LastStatus = run.Status
+	job.State.LastError = run.Error
+	job.State.LastDurationMS = run.DurationMS
+	if run.Status == RunError {
+		job.State.ConsecutiveFailures++
 	} else {
-		job.State.LastStatus = "ok"
-		job.State.LastError = ""
+		job.State.ConsecutiveFailures = 0
 	}
+	job.UpdatedAtMS = time.Now().UnixMilli()
 
-	// Compute next run time
 	if job.Schedule.Kind == "at" {
 		if job.DeleteAfterRun {
+			updated := *job
 			cs.removeJobUnsafe(job.ID)
-		} else {
-			job.Enabled = false
-			job.State.NextRunAtMS = nil
+			return &updated
+		}
+		job.Enabled = false
+		job.State.NextRunAtMS = nil
+	}
+
+	if cs.queued[jobID] && cs.active[jobID] == 0 {
+		delete(cs.queued, jobID)
+		if cs.running && job.Enabled {
+			cs.startRunUnsafe(*job)
 		}
-	} else {
-		nextRun := cs.computeNextRun(&job.Schedule, time.Now().UnixMilli())
-		job.State.NextRunAtMS = nextRun
 	}
 
 	if err := cs.saveStoreUnsafe(); err != nil {
 		log.Printf("[cron] failed to save store: %v", err)
 	}
+	updated := *job
+	return &updated
+}
+
+func (cs *CronService) appendHistory(run RunRecord) {
+	if err := cs.history.append(run); err != nil {
+		log.Printf("[cron] failed to record run of job %s: %v", run.JobID, err)
+	}
+}
+
+// History returns the recorded runs of a job, most recent first. limit <= 0
+// returns all of them.
+func (cs *CronService) History(jobID string, limit int) ([]RunRecord, error) {
+	return cs.history.list(jobID, limit)
 }
 
 func (cs *CronService) computeNextRun(schedule *CronSchedule, nowMS int64) *int64 {
@@ -265,9 +451,8 @@ func (cs *CronService) computeNextRun(schedule *CronSchedule, nowMS int64) *int6
 			return nil
 		}
 
-		// Use gronx to calculate next run time
-		now := time.UnixMilli(nowMS)
-		nextTime, err := gronx.NextTickAfter(schedule.Expr, now, false)
+		// Evaluate the expression in the schedule's zone
+		nextTime, err := nextCronTime(*schedule, time.UnixMilli(nowMS))
 		if err != nil {
 			log.Printf("[cron] failed to compute next run for expr '%s': %v", schedule.Expr, err)
 			return nil
@@ -314,6 +499,14 @@ func (cs *CronService) SetOnJob(handler JobHandler) {
 	cs.onJob = handler
 }
 
+// SetOnFailure sets the handler told about runs that failed after all their
+// attempts.
+func (cs *CronService) SetOnFailure(handler FailureHandler) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.onFailure = handler
+}
+
 func (cs *CronService) loadStore() error {
 	cs.store = &CronStore{
 		Version: 1,
@@ -424,6 +617,9 @@ func (cs *CronService) removeJobUnsafe(jobID string) bool {
 		if err := cs.saveStoreUnsafe(); err != nil {
 			log.Printf("[cron] failed to save store after remove: %v", err)
 		}
+		if err := cs.history.remove(jobID); err != nil {
+			log.Printf("[cron] failed to remove history of job %s: %v", jobID, err)
+		}
 	}
 
 	return removed
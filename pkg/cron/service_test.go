@@ -1,10 +1,16 @@
 package cron
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestSaveStore_FilePermissions(t *testing.T) {
@@ -36,3 +42,181 @@ func TestSaveStore_FilePermissions(t *testing.T) {
 func int64Ptr(v int64) *int64 {
 	return &v
 }
+
+// makeDue schedules a job's next run now.
+func makeDue(cs *CronService, jobID string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	now := time.Now().UnixMilli()
+	for i := range cs.store.Jobs {
+		if cs.store.Jobs[i].ID == jobID {
+			cs.store.Jobs[i].State.NextRunAtMS = &now
+		}
+	}
+}
+
+// waitRuns waits until the history of a job has n runs.
+func waitRuns(t *testing.T, cs *CronService, jobID string, n int) []RunRecord {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		runs, err := cs.History(jobID, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(runs) >= n {
+			return runs
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not record %d runs", jobID, n)
+	return nil
+}
+
+func addHourlyJob(t *testing.T, cs *CronService) *CronJob {
+	t.Helper()
+	job, err := cs.AddJob("report", CronSchedule{Kind: "every", EveryMS: int64Ptr(3600000)}, "hello", false, "cli", "direct")
+	if err != nil {
+		t.Fatalf("AddJob failed: %v", err)
+	}
+	return job
+}
+
+func TestCronService_RetriesAndRecordsRuns(t *testing.T) {
+	var calls atomic.Int32
+	cs := NewCronService(filepath.Join(t.TempDir(), "cron", "jobs.json"), func(ctx context.Context, _ *CronJob) (string, error) {
+		AddUsage(ctx, 100)
+		if calls.Add(1) < 3 {
+			return "", errors.New("provider unavailable")
+		}
+		return "all good", nil
+	})
+	var failures []RunRecord
+	var failMu sync.Mutex
+	cs.SetOnFailure(func(_ CronJob, run RunRecord) {
+		failMu.Lock()
+		failures = append(failures, run)
+		failMu.Unlock()
+	})
+
+	job := addHourlyJob(t, cs)
+	job.Retry = RetryPolicy{MaxAttempts: 3, BackoffMS: 1}
+	if err := cs.UpdateJob(job); err != nil {
+		t.Fatal(err)
+	}
+	if err := cs.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Stop()
+
+	makeDue(cs, job.ID)
+	cs.checkJobs()
+	runs := waitRuns(t, cs, job.ID, 1)
+	if r := runs[0]; r.Status != RunOK || r.Attempts != 3 || r.Output != "all good" || r.Tokens != 300 {
+		t.Errorf("run = %+v", r)
+	}
+
+	// A run failing every attempt is recorded and reported.
+	calls.Store(-10)
+	makeDue(cs, job.ID)
+	cs.checkJobs()
+	runs = waitRuns(t, cs, job.ID, 2)
+	if r := runs[0]; r.Status != RunError || r.Error != "provider unavailable" || r.Attempts != 3 {
+		t.Errorf("failed run = %+v", r)
+	}
+	cs.Stop()
+	jobs := cs.ListJobs(true)
+	if jobs[0].State.LastStatus != RunError || jobs[0].State.ConsecutiveFailures != 1 || jobs[0].State.NextRunAtMS == nil {
+		t.Errorf("state = %+v", jobs[0].State)
+	}
+	failMu.Lock()
+	defer failMu.Unlock()
+	if len(failures) != 1 || failures[0].Error != "provider unavailable" {
+		t.Errorf("failures = %+v", failures)
+	}
+}
+
+func TestCronService_Overlap(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	cs := NewCronService(filepath.Join(t.TempDir(), "jobs.json"), func(context.Context, *CronJob) (string, error) {
+		if calls.Add(1) == 1 {
+			<-release
+		}
+		return "done", nil
+	})
+	job := addHourlyJob(t, cs)
+	if err := cs.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Stop()
+
+	// Skip: the run due while the first one is going is dropped.
+	makeDue(cs, job.ID)
+	cs.checkJobs()
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	makeDue(cs, job.ID)
+	cs.checkJobs()
+	if runs := waitRuns(t, cs, job.ID, 1); runs[0].Status != RunSkipped {
+		t.Errorf("overlapping run = %+v", runs[0])
+	}
+
+	// Queue: it runs once the first one ends.
+	cs.mu.Lock()
+	cs.store.Jobs[0].Overlap = OverlapQueue
+	cs.mu.Unlock()
+	makeDue(cs, job.ID)
+	cs.checkJobs()
+	close(release)
+	runs := waitRuns(t, cs, job.ID, 3)
+	if runs[0].Status != RunOK || runs[1].Status != RunOK || calls.Load() != 2 {
+		t.Errorf("runs = %+v, calls %d", runs, calls.Load())
+	}
+}
+
+func TestRunHistory_Bounded(t *testing.T) {
+	h := newRunHistory(filepath.Join(t.TempDir(), "history.jsonl"), 3)
+	for i := range 5 {
+		if err := h.append(RunRecord{JobID: "a", StartedAtMS: int64(i), Status: RunOK}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := h.append(RunRecord{JobID: "b", Status: RunOK, Output: strings.Repeat("x", 2*maxOutputExcerpt)}); err != nil {
+		t.Fatal(err)
+	}
+
+	runs, err := h.list("a", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 3 || runs[0].StartedAtMS != 4 || runs[2].StartedAtMS != 2 {
+		t.Errorf("runs of a = %+v", runs)
+	}
+	if runs, _ := h.list("b", 1); len(runs) != 1 || len([]rune(runs[0].Output)) != maxOutputExcerpt {
+		t.Errorf("runs of b = %+v", runs)
+	}
+
+	if err := h.remove("a"); err != nil {
+		t.Fatal(err)
+	}
+	if runs, _ := h.list("a", 0); len(runs) != 0 {
+		t.Errorf("runs of a after remove = %+v", runs)
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, BackoffMS: 1000, MaxBackoffMS: 3000}
+	for failed, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 3 * time.Second, 4: 3 * time.Second} {
+		if got := p.backoff(failed); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", failed, got, want)
+		}
+	}
+	if got := (RetryPolicy{}).backoff(1); got != defaultRetryBackoff {
+		t.Errorf("default backoff = %s", got)
+	}
+	if (RetryPolicy{}).attempts() != 1 {
+		t.Error("zero policy should make one attempt")
+	}
+}
@@ -0,0 +1,364 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/search"
+)
+
+// ArchiveDir is the directory, inside the memory directory, that
+// consolidated daily notes are moved to. Archived notes are not indexed.
+const ArchiveDir = "archive"
+
+// defaultMaxChars caps the daily notes sent to the model in one
+// consolidation; older notes go first and the rest wait for the next run.
+const defaultMaxChars = 40000
+
+// Summarizer sends a prompt to a language model and returns its reply.
+type Summarizer func(ctx context.Context, prompt string) (string, error)
+
+// ConsolidateOptions controls a consolidation run.
+type ConsolidateOptions struct {
+	// Before selects the daily notes dated before this day.
+	Before time.Time
+	// MaxChars caps the size of the notes consolidated in one run
+	// (default 40000). At least one note is always taken.
+	MaxChars int
+	// DryRun reports the changes without applying them.
+	DryRun bool
+}
+
+// Contradiction is a fact from the daily notes that conflicts with
+// long-term memory. It is flagged for review instead of being merged.
+type Contradiction struct {
+	Note     string `json:"note"`
+	Existing string `json:"existing"`
+}
+
+// ConsolidationReport describes what a consolidation run changed, or would
+// change in a dry run.
+type ConsolidationReport struct {
+	// Notes are the daily notes consolidated, relative to the memory
+	// directory.
+	Notes          []string        `json:"notes"`
+	Facts          []string        `json:"facts,omitempty"`
+	Duplicates     []string        `json:"duplicates,omitempty"`
+	Contradictions []Contradiction `json:"contradictions,omitempty"`
+	// Diff is a unified diff of MEMORY.md followed by the archive moves.
+	Diff   string `json:"diff,omitempty"`
+	DryRun bool   `json:"dry_run,omitempty"`
+}
+
+type consolidation struct {
+	Facts          []string        `json:"facts"`
+	Duplicates     []string        `json:"duplicates"`
+	Contradictions []Contradiction `json:"contradictions"`
+}
+
+type dailyNote struct {
+	rel     string
+	day     time.Time
+	content string
+}
+
+// Consolidate distills the daily notes dated before opts.Before into a new
+// dated section of MEMORY.md and moves them to the archive. Facts already
+// in MEMORY.md are dropped and facts contradicting it are listed under
+// "Needs review" in the section. A run with no old notes returns an empty
+// report.
+func (s *Store) Consolidate(ctx context.Context, summarize Summarizer, opts ConsolidateOptions) (*ConsolidationReport, error) {
+	s.consolidating.Lock()
+	defer s.consolidating.Unlock()
+
+	report := &ConsolidationReport{DryRun: opts.DryRun}
+	notes, err := s.oldNotes(opts)
+	if err != nil || len(notes) == 0 {
+		return report, err
+	}
+	for _, n := range notes {
+		report.Notes = append(report.Notes, filepath.ToSlash(n.rel))
+	}
+
+	longTermPath := filepath.Join(s.dir, LongTermFile)
+	data, err := os.ReadFile(longTermPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	longTerm := string(data)
+
+	reply, err := summarize(ctx, consolidationPrompt(longTerm, notes))
+	if err != nil {
+		return nil, fmt.Errorf("summarizing notes: %w", err)
+	}
+	result, err := parseConsolidation(reply)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool)
+	for _, line := range strings.Split(longTerm, "\n") {
+		known[normalizeFact(line)] = true
+	}
+	report.Duplicates = result.Duplicates
+	for _, fact := range result.Facts {
+		fact = strings.TrimSpace(fact)
+		key := normalizeFact(fact)
+		switch {
+		case key == "":
+		case known[key]:
+			report.Duplicates = append(report.Duplicates, fact)
+		default:
+			known[key] = true
+			report.Facts = append(report.Facts, fact)
+		}
+	}
+	for _, c := range result.Contradictions {
+		if strings.TrimSpace(c.Note) != "" {
+			report.Contradictions = append(report.Contradictions, c)
+		}
+	}
+
+	updated := longTerm
+	if section := consolidatedSection(notes, report); section != "" {
+		if strings.TrimSpace(updated) == "" {
+			updated = "# Long-term Memory\n"
+		}
+		updated = strings.TrimRight(updated, "\n") + "\n\n" + section
+	}
+
+	var diff strings.Builder
+	diff.WriteString(lineDiff(LongTermFile, longTerm, updated))
+	for _, n := range notes {
+		fmt.Fprintf(&diff, "rename %s => %s\n", filepath.ToSlash(n.rel), filepath.ToSlash(filepath.Join(ArchiveDir, n.rel)))
+	}
+	report.Diff = diff.String()
+
+	if opts.DryRun {
+		return report, nil
+	}
+	if updated != longTerm {
+		if err := writeFileAtomic(longTermPath, []byte(updated)); err != nil {
+			return nil, err
+		}
+	}
+	for _, n := range notes {
+		if err := s.archive(n); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// oldNotes returns the daily notes dated before opts.Before, oldest first,
+// up to opts.MaxChars.
+func (s *Store) oldNotes(opts ConsolidateOptions) ([]dailyNote, error) {
+	maxChars := opts.MaxChars
+	if maxChars <= 0 {
+		maxChars = defaultMaxChars
+	}
+	y, m, d := opts.Before.Date()
+	cutoff := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+
+	var notes []dailyNote
+	for _, rel := range s.notesFiles() {
+		day, err := time.ParseInLocation("20060102", strings.TrimSuffix(filepath.Base(rel), ".md"), time.Local)
+		if err != nil || !day.Before(cutoff) {
+			continue
+		}
+		notes = append(notes, dailyNote{rel: rel, day: day})
+	}
+	sort.Slice(notes, func(i, j int) bool { return notes[i].day.Before(notes[j].day) })
+
+	total := 0
+	for i := range notes {
+		data, err := os.ReadFile(filepath.Join(s.dir, notes[i].rel))
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 && total+len(data) > maxChars {
+			return notes[:i], nil
+		}
+		notes[i].content = string(data)
+		total += len(data)
+	}
+	return notes, nil
+}
+
+func consolidationPrompt(longTerm string, notes []dailyNote) string {
+	var sb strings.Builder
+	sb.WriteString(`You maintain an assistant's long-term memory. Below are the current long-term memory (MEMORY.md) and older daily notes.
+
+Extract the durable facts from the daily notes that are worth keeping: preferences, decisions, people, projects, recurring context. Skip transient chatter and anything that only mattered on that day. Write each fact as one self-contained sentence.
+- A fact already in MEMORY.md, even phrased differently, goes under "duplicates", not "facts".
+- A fact contradicting MEMORY.md goes under "contradictions" together with the conflicting MEMORY.md text, not under "facts".
+
+Reply with JSON only:
+{"facts": ["..."], "duplicates": ["..."], "contradictions": [{"note": "...", "existing": "..."}]}
+
+# MEMORY.md
+
+`)
+	if strings.TrimSpace(longTerm) == "" {
+		sb.WriteString("(empty)\n")
+	} else {
+		sb.WriteString(strings.TrimSpace(longTerm))
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\n# Daily notes\n")
+	for _, n := range notes {
+		fmt.Fprintf(&sb, "\n## %s\n\n%s\n", n.day.Format("2006-01-02"), strings.TrimSpace(n.content))
+	}
+	return sb.String()
+}
+
+// parseConsolidation reads the model's JSON reply, tolerating prose or a
+// code fence around it.
+func parseConsolidation(reply string) (*consolidation, error) {
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return nil, errors.New("consolidation reply is not JSON")
+	}
+	var c consolidation
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &c); err != nil {
+		return nil, fmt.Errorf("parsing consolidation reply: %w", err)
+	}
+	return &c, nil
+}
+
+// normalizeFact reduces a fact or MEMORY.md line to its lower-cased terms,
+// so list markers, case and punctuation do not defeat deduplication.
+func normalizeFact(s string) string {
+	return strings.Join(search.Tokenize(s), " ")
+}
+
+// consolidatedSection renders the MEMORY.md section for a run, headed with
+// the dates of the notes it covers. It is empty when nothing new was found.
+func consolidatedSection(notes []dailyNote, report *ConsolidationReport) string {
+	if len(report.Facts) == 0 && len(report.Contradictions) == 0 {
+		return ""
+	}
+
+	first, last := notes[0].day.Format("2006-01-02"), notes[len(notes)-1].day.Format("2006-01-02")
+	var sb strings.Builder
+	if first == last {
+		fmt.Fprintf(&sb, "## Notes from %s\n", first)
+	} else {
+		fmt.Fprintf(&sb, "## Notes from %s to %s\n", first, last)
+	}
+	if len(report.Facts) > 0 {
+		sb.WriteString("\n")
+		for _, f := range report.Facts {
+			fmt.Fprintf(&sb, "- %s\n", f)
+		}
+	}
+	if len(report.Contradictions) > 0 {
+		sb.WriteString("\n### Needs review\n\n")
+		for _, c := range report.Contradictions {
+			fmt.Fprintf(&sb, "- Notes say: %s\n  MEMORY.md says: %s\n", c.Note, c.Existing)
+		}
+	}
+	return sb.String()
+}
+
+// archive moves a consolidated note under ArchiveDir, appending to an
+// archived note of the same day if there is one.
+func (s *Store) archive(n dailyNote) error {
+	src := filepath.Join(s.dir, n.rel)
+	dst := filepath.Join(s.dir, ArchiveDir, n.rel)
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	if _, err := os.Stat(dst); err == nil {
+		f, err := os.OpenFile(dst, os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		_, err = f.WriteString("\n" + n.content)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		return os.Remove(src)
+	}
+	return os.Rename(src, dst)
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// lineDiff renders the change from before to after as a single-hunk
+// unified diff with three lines of context, or "" if they are equal.
+func lineDiff(name, before, after string) string {
+	if before == after {
+		return ""
+	}
+	a, b := splitLines(before), splitLines(after)
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	const contextLines = 3
+	start := max(prefix-contextLines, 0)
+	endA := min(len(a)-suffix+contextLines, len(a))
+	endB := min(len(b)-suffix+contextLines, len(b))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- a/%s\n+++ b/%s\n", name, name)
+	fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(start, endA-start), hunkRange(start, endB-start))
+	for _, l := range a[start:prefix] {
+		sb.WriteString(" " + l + "\n")
+	}
+	for _, l := range a[prefix : len(a)-suffix] {
+		sb.WriteString("-" + l + "\n")
+	}
+	for _, l := range b[prefix : len(b)-suffix] {
+		sb.WriteString("+" + l + "\n")
+	}
+	for _, l := range a[len(a)-suffix : endA] {
+		sb.WriteString(" " + l + "\n")
+	}
+	return sb.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
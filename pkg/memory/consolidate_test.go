@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStore_Consolidate(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	longTerm := "# Long-term Memory\n\n- User likes Go.\n- User lives in Berlin.\n"
+	writeFile(t, filepath.Join(dir, LongTermFile), longTerm)
+	writeFile(t, filepath.Join(dir, "202610", "20261001.md"), "# 2026-10-01\n\nUser said they moved to Lisbon.")
+	writeFile(t, filepath.Join(dir, "202610", "20261002.md"), "# 2026-10-02\n\nProject falcon ships in May. Still likes Go.")
+	writeFile(t, filepath.Join(dir, "202610", "20261017.md"), "# 2026-10-17\n\nToo recent to consolidate.")
+
+	var prompt string
+	summarize := func(_ context.Context, p string) (string, error) {
+		prompt = p
+		return "```json\n" + `{
+			"facts": ["Project falcon ships in May.", "user likes go"],
+			"duplicates": [],
+			"contradictions": [{"note": "User moved to Lisbon.", "existing": "User lives in Berlin."}]
+		}` + "\n```", nil
+	}
+	opts := ConsolidateOptions{Before: time.Date(2026, 10, 10, 12, 0, 0, 0, time.Local), DryRun: true}
+
+	report, err := s.Consolidate(context.Background(), summarize, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(prompt, "User lives in Berlin") || !strings.Contains(prompt, "moved to Lisbon") || strings.Contains(prompt, "Too recent") {
+		t.Errorf("prompt:\n%s", prompt)
+	}
+	if len(report.Notes) != 2 || len(report.Facts) != 1 || len(report.Duplicates) != 1 || len(report.Contradictions) != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	for _, want := range []string{
+		"+## Notes from 2026-10-01 to 2026-10-02",
+		"+- Project falcon ships in May.",
+		"+### Needs review",
+		"rename 202610/20261001.md => archive/202610/20261001.md",
+	} {
+		if !strings.Contains(report.Diff, want) {
+			t.Errorf("diff misses %q:\n%s", want, report.Diff)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, LongTermFile)); string(data) != longTerm {
+		t.Errorf("dry run changed MEMORY.md:\n%s", data)
+	}
+
+	opts.DryRun = false
+	if _, err := s.Consolidate(context.Background(), summarize, opts); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, LongTermFile))
+	if !strings.HasPrefix(string(data), longTerm) || !strings.Contains(string(data), "- Project falcon ships in May.\n") {
+		t.Errorf("MEMORY.md after consolidation:\n%s", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "202610", "20261001.md")); !errors.Is(err, os.ErrNotExist) {
+		t.Error("consolidated note not moved")
+	}
+	if _, err := os.Stat(filepath.Join(dir, ArchiveDir, "202610", "20261002.md")); err != nil {
+		t.Errorf("consolidated note not archived: %v", err)
+	}
+	if got := s.Search("falcon", SearchOptions{}); len(got) != 1 || got[0].Source != LongTermFile {
+		t.Errorf("archived note still indexed or fact missing: %+v", got)
+	}
+
+	// Nothing left to consolidate: the model is not called.
+	report, err = s.Consolidate(context.Background(), func(context.Context, string) (string, error) {
+		t.Error("summarizer called without notes")
+		return "", nil
+	}, opts)
+	if err != nil || len(report.Notes) != 0 {
+		t.Errorf("second run = %+v, %v", report, err)
+	}
+}
+
+func TestStore_ConsolidateRejectsBadReply(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	note := filepath.Join(dir, "202609", "20260901.md")
+	writeFile(t, note, "something")
+
+	_, err = s.Consolidate(context.Background(), func(context.Context, string) (string, error) {
+		return "I could not do that.", nil
+	}, ConsolidateOptions{Before: time.Now()})
+	if err == nil {
+		t.Fatal("expected an error for a non-JSON reply")
+	}
+	if _, err := os.Stat(note); err != nil {
+		t.Errorf("note archived despite the failure: %v", err)
+	}
+}
+
+func TestLineDiff(t *testing.T) {
+	got := lineDiff("f", "a\nb\nc\nd\ne\n", "a\nb\nc\nd\ne\n\nf\n")
+	want := "--- a/f\n+++ b/f\n@@ -3,3 +3,5 @@\n c\n d\n e\n+\n+f\n"
+	if got != want {
+		t.Errorf("diff =\n%s\nwant\n%s", got, want)
+	}
+	if lineDiff("f", "x", "x") != "" {
+		t.Error("equal texts should have no diff")
+	}
+}
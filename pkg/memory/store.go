@@ -0,0 +1,447 @@
+// Package memory keeps an agent's long-term memories: entries saved through
+// the memory tools plus the paragraphs of MEMORY.md and the daily notes, all
+// searchable through a local BM25 index.
+package memory
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/logger"
+	"github.com/tinyland-inc/tinyclaw/pkg/search"
+)
+
+// EntriesFile is the file, inside the memory directory, holding saved
+// entries.
+const EntriesFile = "entries.json"
+
+// LongTermFile is the hand-edited long-term memory file.
+const LongTermFile = "MEMORY.md"
+
+var (
+	// ErrNotFound is returned for an unknown memory ID.
+	ErrNotFound = errors.New("memory not found")
+	// ErrReadOnly is returned when forgetting a memory read from a notes
+	// file, which has to be edited instead.
+	ErrReadOnly = errors.New("memory comes from a notes file")
+)
+
+// Entry is one memory.
+type Entry struct {
+	ID   string   `json:"id"`
+	Text string   `json:"text"`
+	Tags []string `json:"tags,omitempty"`
+	// Source is the session the memory was saved from, or the notes file
+	// it was read from.
+	Source  string    `json:"source,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// Result is a memory matching a search.
+type Result struct {
+	Entry
+	Score float64 `json:"score"`
+}
+
+// SearchOptions narrows a memory search.
+type SearchOptions struct {
+	// Limit caps the number of results; <= 0 returns all matches.
+	Limit int
+	// Tags keeps only memories carrying every one of them.
+	Tags []string
+	// Fuzzy also matches misspelled terms.
+	Fuzzy bool
+}
+
+// Store holds the memories of one memory directory. Notes files are
+// re-read when they change on disk.
+type Store struct {
+	dir string
+
+	consolidating sync.Mutex // serializes Consolidate runs
+
+	mu      sync.Mutex
+	entries map[string]Entry
+	notes   map[string]Entry
+	files   map[string]time.Time // notes file -> modification time indexed
+	index   *search.Index
+}
+
+var (
+	storesMu sync.Mutex
+	stores   = map[string]*Store{}
+)
+
+// Open returns the store of the memory directory dir, loading its saved
+// entries. Agents sharing a workspace share one store.
+func Open(dir string) (*Store, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	storesMu.Lock()
+	defer storesMu.Unlock()
+	if s, ok := stores[abs]; ok {
+		return s, nil
+	}
+
+	if err := os.MkdirAll(abs, 0o755); err != nil {
+		return nil, err
+	}
+	s := &Store{
+		dir:     abs,
+		entries: make(map[string]Entry),
+		notes:   make(map[string]Entry),
+		files:   make(map[string]time.Time),
+		index:   search.NewIndex(),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	stores[abs] = s
+	return s, nil
+}
+
+func (s *Store) load() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, EntriesFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("%s: %w", EntriesFile, err)
+	}
+	for _, e := range entries {
+		s.entries[e.ID] = e
+		s.index.Add(e.ID, indexText(e))
+	}
+	return nil
+}
+
+// save writes the entries file atomically. Must be called with s.mu held.
+func (s *Store) save() error {
+	entries := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Created.Before(entries[j].Created) })
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, "entries-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(s.dir, EntriesFile))
+}
+
+func indexText(e Entry) string {
+	return e.Text + " " + strings.Join(e.Tags, " ")
+}
+
+func normalizeTags(tags []string) []string {
+	var out []string
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != "" && !slices.Contains(out, t) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func sameText(a, b string) bool {
+	return strings.EqualFold(strings.Join(strings.Fields(a), " "), strings.Join(strings.Fields(b), " "))
+}
+
+func newID() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Save stores a memory. Saving the text of an existing memory again adds
+// the new tags to it instead of duplicating it.
+func (s *Store) Save(text string, tags []string, source string) (Entry, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return Entry{}, errors.New("memory text is empty")
+	}
+	tags = normalizeTags(tags)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, e := range s.entries {
+		if !sameText(e.Text, text) {
+			continue
+		}
+		e.Tags = normalizeTags(append(e.Tags, tags...))
+		s.entries[id] = e
+		s.index.Add(id, indexText(e))
+		return e, s.save()
+	}
+
+	e := Entry{ID: newID(), Text: text, Tags: tags, Source: source, Created: time.Now()}
+	s.entries[e.ID] = e
+	s.index.Add(e.ID, indexText(e))
+	if err := s.save(); err != nil {
+		delete(s.entries, e.ID)
+		s.index.Remove(e.ID)
+		return Entry{}, err
+	}
+	return e, nil
+}
+
+// Forget deletes a saved memory.
+func (s *Store) Forget(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[id]
+	if !ok {
+		if n, isNote := s.notes[id]; isNote {
+			return fmt.Errorf("%w: edit %s instead", ErrReadOnly, n.Source)
+		}
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	delete(s.entries, id)
+	s.index.Remove(id)
+	if err := s.save(); err != nil {
+		s.entries[id] = e
+		s.index.Add(id, indexText(e))
+		return err
+	}
+	return nil
+}
+
+// Get returns the memory id, saved or read from notes.
+func (s *Store) Get(id string) (Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[id]; ok {
+		return e, true
+	}
+	e, ok := s.notes[id]
+	return e, ok
+}
+
+// List returns the saved memories, newest first.
+func (s *Store) List() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Created.After(entries[j].Created) })
+	return entries
+}
+
+// Search returns the memories best matching query.
+func (s *Store) Search(query string, opts SearchOptions) []Result {
+	tags := normalizeTags(opts.Tags)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refreshNotes()
+
+	lookup := func(id string) (Entry, bool) {
+		if e, ok := s.entries[id]; ok {
+			return e, true
+		}
+		e, ok := s.notes[id]
+		return e, ok
+	}
+	keep := func(id string) bool {
+		e, ok := lookup(id)
+		if !ok {
+			return false
+		}
+		for _, t := range tags {
+			if !slices.Contains(e.Tags, t) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var hits []search.Hit
+	if opts.Fuzzy {
+		hits = s.index.SearchFuzzy(query, opts.Limit, keep)
+	} else {
+		hits = s.index.Search(query, opts.Limit, keep)
+	}
+	results := make([]Result, 0, len(hits))
+	for _, h := range hits {
+		e, _ := lookup(h.ID)
+		results = append(results, Result{Entry: e, Score: h.Score})
+	}
+	return results
+}
+
+// refreshNotes re-indexes the notes files created, changed or removed since
+// they were last read. Must be called with s.mu held.
+func (s *Store) refreshNotes() {
+	current := make(map[string]time.Time)
+	for _, rel := range s.notesFiles() {
+		if info, err := os.Stat(filepath.Join(s.dir, rel)); err == nil {
+			current[rel] = info.ModTime()
+		}
+	}
+
+	for rel := range s.files {
+		if _, ok := current[rel]; !ok {
+			s.dropNotes(rel)
+			delete(s.files, rel)
+		}
+	}
+	for rel, mtime := range current {
+		if indexed, ok := s.files[rel]; ok && indexed.Equal(mtime) {
+			continue
+		}
+		s.dropNotes(rel)
+		if err := s.indexNotes(rel, mtime); err != nil {
+			logger.WarnCF("memory", "Failed to index notes", map[string]any{
+				"file":  rel,
+				"error": err.Error(),
+			})
+			continue
+		}
+		s.files[rel] = mtime
+	}
+}
+
+// notesFiles returns MEMORY.md and the daily notes (YYYYMM/YYYYMMDD.md)
+// relative to the memory directory.
+func (s *Store) notesFiles() []string {
+	files := []string{LongTermFile}
+	months, _ := os.ReadDir(s.dir)
+	for _, m := range months {
+		if !m.IsDir() || !isDigits(m.Name(), 6) {
+			continue
+		}
+		days, _ := os.ReadDir(filepath.Join(s.dir, m.Name()))
+		for _, d := range days {
+			name := strings.TrimSuffix(d.Name(), ".md")
+			if !d.IsDir() && name != d.Name() && isDigits(name, 8) {
+				files = append(files, filepath.Join(m.Name(), d.Name()))
+			}
+		}
+	}
+	return files
+}
+
+func isDigits(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Store) dropNotes(rel string) {
+	prefix := filepath.ToSlash(rel) + "#"
+	for id := range s.notes {
+		if strings.HasPrefix(id, prefix) {
+			delete(s.notes, id)
+			s.index.Remove(id)
+		}
+	}
+}
+
+func (s *Store) indexNotes(rel string, mtime time.Time) error {
+	data, err := os.ReadFile(filepath.Join(s.dir, rel))
+	if err != nil {
+		return err
+	}
+	created := mtime
+	if day, err := time.ParseInLocation("20060102", strings.TrimSuffix(filepath.Base(rel), ".md"), time.Local); err == nil {
+		created = day
+	}
+	source := filepath.ToSlash(rel)
+	for i, chunk := range SplitNotes(string(data)) {
+		e := Entry{ID: fmt.Sprintf("%s#%d", source, i+1), Text: chunk, Source: source, Created: created}
+		s.notes[e.ID] = e
+		s.index.Add(e.ID, e.Text)
+	}
+	return nil
+}
+
+// SplitNotes splits markdown notes into memories: each list item, or each
+// paragraph outside lists, prefixed with the heading it falls under.
+func SplitNotes(text string) []string {
+	var (
+		chunks  []string
+		heading string
+		current []string
+	)
+	flush := func() {
+		if len(current) == 0 {
+			return
+		}
+		chunk := strings.Join(current, "\n")
+		if heading != "" {
+			chunk = heading + "\n" + chunk
+		}
+		chunks = append(chunks, chunk)
+		current = nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			flush()
+		case strings.HasPrefix(trimmed, "#"):
+			flush()
+			heading = trimmed
+		case isListItem(trimmed) && line == strings.TrimLeft(line, " \t"):
+			flush()
+			current = append(current, trimmed)
+		default:
+			current = append(current, trimmed)
+		}
+	}
+	flush()
+	return chunks
+}
+
+func isListItem(line string) bool {
+	if strings.HasPrefix(line, "- ") || strings.HasPrefix(line, "* ") || strings.HasPrefix(line, "+ ") {
+		return true
+	}
+	digits := len(line) - len(strings.TrimLeft(line, "0123456789"))
+	return digits > 0 && strings.HasPrefix(line[digits:], ". ")
+}
@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore_SaveSearchForget(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := s.Save("The user deploys with Kubernetes on weekends.", []string{"Infra", " infra "}, "telegram:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(e.Tags) != 1 || e.Tags[0] != "infra" || e.Source != "telegram:1" {
+		t.Errorf("entry = %+v", e)
+	}
+	if _, err := s.Save("Favourite colour is green.", nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	again, err := s.Save("the user deploys  with kubernetes on weekends.", []string{"ops"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != e.ID || len(again.Tags) != 2 || len(s.List()) != 2 {
+		t.Errorf("duplicate text not merged: %+v, %d entries", again, len(s.List()))
+	}
+
+	if got := s.Search("kubernetes", SearchOptions{}); len(got) != 1 || got[0].ID != e.ID {
+		t.Errorf("search = %+v", got)
+	}
+	if got := s.Search("kubernetes", SearchOptions{Tags: []string{"billing"}}); len(got) != 0 {
+		t.Errorf("tag filter not applied: %+v", got)
+	}
+	if got := s.Search("kubernets", SearchOptions{}); len(got) != 0 {
+		t.Errorf("exact search matched a typo: %+v", got)
+	}
+	if got := s.Search("kubernets", SearchOptions{Fuzzy: true}); len(got) != 1 {
+		t.Errorf("fuzzy search = %+v", got)
+	}
+
+	// A fresh store over the same directory reads the saved entries back.
+	delete(stores, s.dir)
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reopened.Search("colour", SearchOptions{}); len(got) != 1 {
+		t.Errorf("entries not persisted: %+v", got)
+	}
+
+	if err := reopened.Forget(e.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Forget(e.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Forget = %v, want ErrNotFound", err)
+	}
+	if got := reopened.Search("kubernetes", SearchOptions{}); len(got) != 0 {
+		t.Errorf("forgotten entry still found: %+v", got)
+	}
+}
+
+func TestStore_IndexesNotesFiles(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	longTerm := filepath.Join(dir, LongTermFile)
+	writeFile(t, longTerm, "# Long-term Memory\n\n## Preferences\n\n- Prefers Rust for CLIs\n- Drinks tea\n")
+	writeFile(t, filepath.Join(dir, "202610", "20261017.md"), "# 2026-10-17\n\nShipped the billing export.\n")
+
+	got := s.Search("rust", SearchOptions{})
+	if len(got) != 1 || got[0].ID != "MEMORY.md#1" || got[0].Text != "## Preferences\n- Prefers Rust for CLIs" {
+		t.Fatalf("MEMORY.md hit = %+v", got)
+	}
+	got = s.Search("billing", SearchOptions{})
+	if len(got) != 1 || got[0].Source != "202610/20261017.md" || got[0].Created.Day() != 17 {
+		t.Fatalf("daily note hit = %+v", got)
+	}
+	if err := s.Forget(got[0].ID); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Forget of a note = %v, want ErrReadOnly", err)
+	}
+
+	writeFile(t, longTerm, "- Prefers Go now\n")
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(longTerm, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Search("rust", SearchOptions{}); len(got) != 0 {
+		t.Errorf("edited note still indexed with its old text: %+v", got)
+	}
+	if got := s.Search("prefers", SearchOptions{}); len(got) != 1 {
+		t.Errorf("edited note not re-indexed: %+v", got)
+	}
+}
+
+func TestSplitNotes(t *testing.T) {
+	got := SplitNotes("# Title\n\nIntro line one\nline two\n\n## Facts\n- a\n  continued\n- b\n1. c\n")
+	want := []string{
+		"# Title\nIntro line one\nline two",
+		"## Facts\n- a\ncontinued",
+		"## Facts\n- b",
+		"## Facts\n1. c",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("SplitNotes = %q", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("chunk %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,344 @@
+// Package metrics is a small, dependency-free metrics registry that renders
+// the Prometheus text exposition format.
+//
+// Counters, gauges and histograms are created on a Registry (usually
+// Default) and identified by name plus an ordered list of label values.
+// Gauge callbacks let components such as the message bus or the cooldown
+// tracker report their state at scrape time without bookkeeping.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Default is the process-wide registry served by the gateway's /metrics.
+var Default = NewRegistry()
+
+// DefaultBuckets are latency buckets in seconds suited to LLM and tool calls.
+var DefaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+type collector interface {
+	write(w io.Writer)
+}
+
+// Registry holds metrics keyed by name.
+type Registry struct {
+	mu      sync.RWMutex
+	metrics map[string]collector
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]collector)}
+}
+
+// register stores c under name, replacing any previous metric with the
+// same name so repeated wiring (tests, restarts of a component) is safe.
+func (r *Registry) register(name string, c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics[name] = c
+}
+
+// WriteText renders all metrics in Prometheus text format, sorted by name.
+func (r *Registry) WriteText(w io.Writer) {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	collectors := make([]collector, 0, len(names))
+	slices.Sort(names)
+	for _, name := range names {
+		collectors = append(collectors, r.metrics[name])
+	}
+	r.mu.RUnlock()
+
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// Handler serves the registry in Prometheus text format.
+func (r *Registry) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
+	}
+}
+
+// vec stores one value per label combination.
+type vec[T any] struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*T
+	values map[string][]string
+}
+
+func newVec[T any](name, help string, labels []string) vec[T] {
+	return vec[T]{
+		name:   name,
+		help:   help,
+		labels: labels,
+		series: make(map[string]*T),
+		values: make(map[string][]string),
+	}
+}
+
+// get returns the series for labelValues, creating it with init.
+// Must be called with v.mu held.
+func (v *vec[T]) get(labelValues []string, init func() *T) *T {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.name, len(v.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = init()
+		v.series[key] = s
+		v.values[key] = slices.Clone(labelValues)
+	}
+	return s
+}
+
+// sortedKeys returns series keys in a stable order.
+// Must be called with v.mu held.
+func (v *vec[T]) sortedKeys() []string {
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// CounterVec is a monotonically increasing value per label combination.
+type CounterVec struct {
+	vec[float64]
+}
+
+// NewCounterVec creates and registers a counter.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{vec: newVec[float64](name, help, labels)}
+	r.register(name, c)
+	return c
+}
+
+// Inc adds one to the series identified by labelValues.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds delta (which must be non-negative) to the series.
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*c.get(labelValues, func() *float64 { return new(float64) }) += delta
+}
+
+// Value returns the current value of a series.
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.series[strings.Join(labelValues, "\xff")]; ok {
+		return *s
+	}
+	return 0
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	writeHeader(w, c.name, c.help, "counter")
+	for _, k := range c.sortedKeys() {
+		writeSample(w, c.name, c.labels, c.values[k], nil, *c.series[k])
+	}
+}
+
+// GaugeVec is a value that can go up and down per label combination.
+type GaugeVec struct {
+	vec[float64]
+}
+
+// NewGaugeVec creates and registers a gauge.
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{vec: newVec[float64](name, help, labels)}
+	r.register(name, g)
+	return g
+}
+
+// Set replaces the value of a series.
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	*g.get(labelValues, func() *float64 { return new(float64) }) = value
+}
+
+// Add adds delta to the value of a series.
+func (g *GaugeVec) Add(delta float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	*g.get(labelValues, func() *float64 { return new(float64) }) += delta
+}
+
+func (g *GaugeVec) write(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	writeHeader(w, g.name, g.help, "gauge")
+	for _, k := range g.sortedKeys() {
+		writeSample(w, g.name, g.labels, g.values[k], nil, *g.series[k])
+	}
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, non-cumulative
+	sum    float64
+	count  uint64
+}
+
+// HistogramVec tracks value distributions per label combination.
+type HistogramVec struct {
+	vec[histogram]
+	buckets []float64
+}
+
+// NewHistogramVec creates and registers a histogram. Nil buckets selects
+// DefaultBuckets.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	h := &HistogramVec{vec: newVec[histogram](name, help, labels), buckets: slices.Clone(buckets)}
+	slices.Sort(h.buckets)
+	r.register(name, h)
+	return h
+}
+
+// Observe records one value in the series identified by labelValues.
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.get(labelValues, func() *histogram {
+		return &histogram{counts: make([]uint64, len(h.buckets))}
+	})
+	if i, _ := slices.BinarySearch(h.buckets, value); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.sum += value
+	s.count++
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	writeHeader(w, h.name, h.help, "histogram")
+	for _, k := range h.sortedKeys() {
+		s := h.series[k]
+		values := h.values[k]
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			writeSample(w, h.name+"_bucket", h.labels, values,
+				[]string{"le", formatFloat(upper)}, float64(cumulative))
+		}
+		writeSample(w, h.name+"_bucket", h.labels, values, []string{"le", "+Inf"}, float64(s.count))
+		writeSample(w, h.name+"_sum", h.labels, values, nil, s.sum)
+		writeSample(w, h.name+"_count", h.labels, values, nil, float64(s.count))
+	}
+}
+
+// GaugeFunc reports values computed at scrape time.
+type GaugeFunc struct {
+	name    string
+	help    string
+	labels  []string
+	collect func(emit func(value float64, labelValues ...string))
+}
+
+// NewGaugeFunc registers a gauge whose series are produced by collect on
+// every scrape. collect calls emit once per series.
+func (r *Registry) NewGaugeFunc(
+	name, help string,
+	collect func(emit func(value float64, labelValues ...string)),
+	labels ...string,
+) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, labels: labels, collect: collect}
+	r.register(name, g)
+	return g
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	writeHeader(w, g.name, g.help, "gauge")
+	g.collect(func(value float64, labelValues ...string) {
+		if len(labelValues) != len(g.labels) {
+			return
+		}
+		writeSample(w, g.name, g.labels, labelValues, nil, value)
+	})
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+func writeSample(w io.Writer, name string, labels, values, extra []string, value float64) {
+	var sb strings.Builder
+	sb.WriteString(name)
+	if len(labels) > 0 || len(extra) > 0 {
+		sb.WriteByte('{')
+		first := true
+		pair := func(k, v string) {
+			if !first {
+				sb.WriteByte(',')
+			}
+			first = false
+			sb.WriteString(k)
+			sb.WriteString(`="`)
+			sb.WriteString(escapeLabel(v))
+			sb.WriteByte('"')
+		}
+		for i, l := range labels {
+			pair(l, values[i])
+		}
+		for i := 0; i+1 < len(extra); i += 2 {
+			pair(extra[i], extra[i+1])
+		}
+		sb.WriteByte('}')
+	}
+	sb.WriteByte(' ')
+	sb.WriteString(formatFloat(value))
+	sb.WriteByte('\n')
+	io.WriteString(w, sb.String())
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
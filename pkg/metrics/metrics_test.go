@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("test_requests_total", "Requests.", "path")
+	c.Inc("/a")
+	c.Add(2, "/a")
+	c.Inc(`/b"q`)
+
+	g := r.NewGaugeVec("test_inflight", "In flight.")
+	g.Set(3)
+	g.Add(-1)
+
+	h := r.NewHistogramVec("test_latency_seconds", "Latency.", []float64{1, 0.1}, "op")
+	h.Observe(0.05, "get")
+	h.Observe(0.5, "get")
+	h.Observe(5, "get")
+
+	r.NewGaugeFunc("test_depth", "Depth.", func(emit func(float64, ...string)) {
+		emit(7, "inbound")
+		emit(1) // wrong label count, dropped
+	}, "queue")
+
+	var sb strings.Builder
+	r.WriteText(&sb)
+	out := sb.String()
+
+	for _, want := range []string{
+		"# TYPE test_requests_total counter\n",
+		`test_requests_total{path="/a"} 3` + "\n",
+		`test_requests_total{path="/b\"q"} 1` + "\n",
+		"test_inflight 2\n",
+		"# TYPE test_latency_seconds histogram\n",
+		`test_latency_seconds_bucket{op="get",le="0.1"} 1` + "\n",
+		`test_latency_seconds_bucket{op="get",le="1"} 2` + "\n",
+		`test_latency_seconds_bucket{op="get",le="+Inf"} 3` + "\n",
+		`test_latency_seconds_sum{op="get"} 5.55` + "\n",
+		`test_latency_seconds_count{op="get"} 3` + "\n",
+		`test_depth{queue="inbound"} 7` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in output:\n%s", want, out)
+		}
+	}
+	if strings.Index(out, "test_depth") > strings.Index(out, "test_inflight") {
+		t.Error("metrics should be sorted by name")
+	}
+	if c.Value("/a") != 3 {
+		t.Errorf("Value = %v, want 3", c.Value("/a"))
+	}
+}
+
+func TestRegistry_LabelCountMismatchPanics(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("test_total", "Test.", "a", "b")
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic on wrong label count")
+		}
+	}()
+	c.Inc("only-one")
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("test_total", "Test.").Inc()
+
+	rec := httptest.NewRecorder()
+	r.Handler()(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "test_total 1\n") {
+		t.Errorf("unexpected body: %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,67 @@
+package metrics
+
+// Metrics reported by TinyClaw components. All are registered on Default.
+var (
+	// LLMRequests counts LLM calls by provider, model, agent and outcome
+	// ("success" or "error").
+	LLMRequests = Default.NewCounterVec("tinyclaw_llm_requests_total",
+		"LLM requests by provider, model, agent and outcome.",
+		"provider", "model", "agent", "outcome")
+
+	// LLMDuration observes LLM call latency in seconds.
+	LLMDuration = Default.NewHistogramVec("tinyclaw_llm_request_duration_seconds",
+		"LLM request latency in seconds.", nil,
+		"provider", "model", "agent")
+
+	// LLMTokens counts tokens reported by providers; type is "prompt" or "completion".
+	LLMTokens = Default.NewCounterVec("tinyclaw_llm_tokens_total",
+		"Tokens consumed by provider, model, agent and type.",
+		"provider", "model", "agent", "type")
+
+	// LLMCost accumulates the estimated cost of LLM calls in USD.
+	LLMCost = Default.NewCounterVec("tinyclaw_llm_cost_usd_total",
+		"Estimated LLM spend in USD by provider, model and agent.",
+		"provider", "model", "agent")
+
+	// FallbackAttempts counts fallback chain attempts; outcome is "success",
+	// "failed" or "skipped" (cooldown or client-side rate limit).
+	FallbackAttempts = Default.NewCounterVec("tinyclaw_fallback_attempts_total",
+		"Fallback chain attempts by provider, model, outcome and failover reason.",
+		"provider", "model", "outcome", "reason")
+
+	// RateLimitWait observes how long LLM calls queued on a client-side
+	// rate limit, by model_list entry.
+	RateLimitWait = Default.NewHistogramVec("tinyclaw_rate_limit_wait_seconds",
+		"Time LLM requests waited for client-side rate limits, in seconds.", nil,
+		"model")
+
+	// LBSelections counts requests sent to each load-balanced endpoint.
+	LBSelections = Default.NewCounterVec("tinyclaw_lb_selections_total",
+		"Requests routed to each load-balanced model_list endpoint.",
+		"model", "endpoint")
+
+	// ToolExecutions counts tool runs; outcome is "success", "error" or "async".
+	ToolExecutions = Default.NewCounterVec("tinyclaw_tool_executions_total",
+		"Tool executions by tool and outcome.",
+		"tool", "outcome")
+
+	// ToolDuration observes tool execution time in seconds.
+	ToolDuration = Default.NewHistogramVec("tinyclaw_tool_duration_seconds",
+		"Tool execution time in seconds.", nil,
+		"tool")
+
+	// ChannelMessages counts bus messages; direction is "inbound" or "outbound".
+	ChannelMessages = Default.NewCounterVec("tinyclaw_channel_messages_total",
+		"Messages passing through the bus by channel and direction.",
+		"channel", "direction")
+
+	// CronRuns counts cron job executions; outcome is "ok" or "error".
+	CronRuns = Default.NewCounterVec("tinyclaw_cron_runs_total",
+		"Cron job runs by job name and outcome.",
+		"job", "outcome")
+
+	// CronDuration observes cron job execution time in seconds.
+	CronDuration = Default.NewHistogramVec("tinyclaw_cron_run_duration_seconds",
+		"Cron job execution time in seconds.", nil,
+		"job")
+)
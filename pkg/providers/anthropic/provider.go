@@ -3,14 +3,18 @@ package anthropicprovider
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
 
 	"github.com/tinyland-inc/tinyclaw/pkg/providers/protocoltypes"
+	"github.com/tinyland-inc/tinyclaw/pkg/providers/transport"
 )
 
 type (
@@ -40,6 +44,7 @@ func NewProviderWithBaseURL(token, apiBase string) *Provider {
 	client := anthropic.NewClient(
 		option.WithAuthToken(token),
 		option.WithBaseURL(baseURL),
+		option.WithHTTPClient(transport.Client(0)),
 	)
 	return &Provider{
 		client:  &client,
@@ -85,12 +90,23 @@ func (p *Provider) Chat(
 		return nil, err
 	}
 
+	var httpResp *http.Response
+	opts = append(opts, option.WithResponseInto(&httpResp))
 	resp, err := p.client.Messages.New(ctx, params, opts...)
 	if err != nil {
-		return nil, fmt.Errorf("claude API call: %w", err)
+		err = fmt.Errorf("claude API call: %w", err)
+		var apiErr *anthropic.Error
+		if errors.As(err, &apiErr) {
+			return nil, protocoltypes.NewStatusError(err, apiErr.Response)
+		}
+		return nil, err
 	}
 
-	return parseResponse(resp), nil
+	out := parseResponse(resp)
+	if httpResp != nil {
+		out.RateLimit = protocoltypes.ParseRateLimitHeaders(httpResp.Header, time.Now())
+	}
+	return out, nil
 }
 
 func (p *Provider) GetDefaultModel() string {
@@ -270,14 +286,18 @@ func parseResponse(resp *anthropic.Message) *LLMResponse {
 		finishReason = "stop"
 	}
 
+	// InputTokens excludes cache reads and writes; count them as prompt tokens.
+	promptTokens := resp.Usage.InputTokens + resp.Usage.CacheReadInputTokens + resp.Usage.CacheCreationInputTokens
+
 	return &LLMResponse{
 		Content:      sb.String(),
 		ToolCalls:    toolCalls,
 		FinishReason: finishReason,
 		Usage: &UsageInfo{
-			PromptTokens:     int(resp.Usage.InputTokens),
+			PromptTokens:     int(promptTokens),
 			CompletionTokens: int(resp.Usage.OutputTokens),
-			TotalTokens:      int(resp.Usage.InputTokens + resp.Usage.OutputTokens),
+			TotalTokens:      int(promptTokens + resp.Usage.OutputTokens),
+			CachedTokens:     int(resp.Usage.CacheReadInputTokens),
 		},
 	}
 }
@@ -15,6 +15,7 @@ import (
 
 	"github.com/tinyland-inc/tinyclaw/pkg/auth"
 	"github.com/tinyland-inc/tinyclaw/pkg/logger"
+	"github.com/tinyland-inc/tinyclaw/pkg/providers/transport"
 )
 
 const (
@@ -37,9 +38,7 @@ type AntigravityProvider struct {
 func NewAntigravityProvider() *AntigravityProvider {
 	return &AntigravityProvider{
 		tokenSource: createAntigravityTokenSource(),
-		httpClient: &http.Client{
-			Timeout: 120 * time.Second,
-		},
+		httpClient:  transport.Client(120 * time.Second),
 	}
 }
 
@@ -0,0 +1,409 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+	"github.com/tinyland-inc/tinyclaw/pkg/logger"
+	"github.com/tinyland-inc/tinyclaw/pkg/metrics"
+)
+
+// latencyAlpha weights the newest sample in the EWMA latency estimate.
+const latencyAlpha = 0.3
+
+type sessionKeyCtx struct{}
+
+// WithSessionKey returns a context carrying the conversation's session key,
+// used by sticky load balancing to keep a conversation on one endpoint.
+func WithSessionKey(ctx context.Context, sessionKey string) context.Context {
+	return context.WithValue(ctx, sessionKeyCtx{}, sessionKey)
+}
+
+// SessionKeyFromContext returns the session key set by WithSessionKey.
+func SessionKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(sessionKeyCtx{}).(string)
+	return key
+}
+
+// Endpoint is one model_list entry behind a Balancer.
+type Endpoint struct {
+	Name     string // "<model_name>#<index>", also the health tracker key
+	APIBase  string
+	ModelID  string // model identifier without protocol prefix
+	Weight   int
+	Provider LLMProvider
+
+	outstanding atomic.Int64
+
+	// guarded by Balancer.mu
+	current int     // smooth weighted round-robin state
+	latency float64 // EWMA of successful call latency, seconds
+	samples int
+}
+
+// EndpointStatus is a snapshot of one endpoint's balancing state.
+type EndpointStatus struct {
+	Name        string        `json:"name"`
+	APIBase     string        `json:"api_base,omitempty"`
+	Weight      int           `json:"weight"`
+	Outstanding int64         `json:"outstanding"`
+	LatencyMS   float64       `json:"latency_ms"`
+	Healthy     bool          `json:"healthy"`
+	Cooldown    time.Duration `json:"-"`
+}
+
+// Balancer spreads requests for one model_name across its endpoints.
+// Endpoints in cooldown after retriable failures are drained until they
+// recover; if every endpoint is in cooldown, all are used.
+type Balancer struct {
+	modelName string
+	strategy  string
+	endpoints []*Endpoint
+	health    *CooldownTracker
+
+	mu sync.Mutex
+	rr uint64
+}
+
+// NewBalancer creates a balancer. An empty strategy means round-robin.
+func NewBalancer(modelName, strategy string, endpoints []*Endpoint) *Balancer {
+	if strategy == "" {
+		strategy = config.LBRoundRobin
+	}
+	for _, e := range endpoints {
+		if e.Weight <= 0 {
+			e.Weight = 1
+		}
+	}
+	return &Balancer{
+		modelName: modelName,
+		strategy:  strategy,
+		endpoints: endpoints,
+		health:    NewCooldownTracker(),
+	}
+}
+
+// UseCooldownTracker makes the balancer record endpoint health in t, the
+// tracker shared with the fallback chain, instead of its own. Call it
+// before the balancer serves requests.
+func (b *Balancer) UseCooldownTracker(t *CooldownTracker) {
+	if t != nil {
+		b.health = t
+	}
+}
+
+// Pick selects an endpoint for a request, skipping those in exclude.
+// It returns nil when every endpoint is excluded.
+func (b *Balancer) Pick(sessionKey string, exclude map[*Endpoint]bool) *Endpoint {
+	var healthy, candidates []*Endpoint
+	for _, e := range b.endpoints {
+		if exclude[e] {
+			continue
+		}
+		candidates = append(candidates, e)
+		if b.health.IsAvailable(e.Name) {
+			healthy = append(healthy, e)
+		}
+	}
+	if len(healthy) > 0 {
+		candidates = healthy
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.strategy {
+	case config.LBWeighted:
+		return b.pickWeighted(candidates)
+	case config.LBLeastOutstanding:
+		return b.pickMin(candidates, func(e *Endpoint) float64 {
+			return float64(e.outstanding.Load()) / float64(e.Weight)
+		})
+	case config.LBLatency:
+		// Endpoints without samples score the average of the measured ones:
+		// they get traffic ahead of slow endpoints without always jumping
+		// the queue.
+		unknown := averageLatency(b.endpoints)
+		return b.pickMin(candidates, func(e *Endpoint) float64 {
+			if e.samples == 0 {
+				return unknown
+			}
+			return e.latency
+		})
+	case config.LBSticky:
+		if sessionKey != "" {
+			return pickRendezvous(candidates, sessionKey)
+		}
+	}
+	b.rr++
+	return candidates[b.rr%uint64(len(candidates))]
+}
+
+// pickWeighted is nginx-style smooth weighted round-robin. Must be called
+// with b.mu held.
+func (b *Balancer) pickWeighted(candidates []*Endpoint) *Endpoint {
+	var best *Endpoint
+	total := 0
+	for _, e := range candidates {
+		e.current += e.Weight
+		total += e.Weight
+		if best == nil || e.current > best.current {
+			best = e
+		}
+	}
+	best.current -= total
+	return best
+}
+
+// pickMin returns the candidate with the lowest score, rotating among ties.
+// Must be called with b.mu held.
+func (b *Balancer) pickMin(candidates []*Endpoint, score func(*Endpoint) float64) *Endpoint {
+	b.rr++
+	n := len(candidates)
+	start := int(b.rr % uint64(n))
+	best := candidates[start]
+	for i := 1; i < n; i++ {
+		e := candidates[(start+i)%n]
+		if score(e) < score(best) {
+			best = e
+		}
+	}
+	return best
+}
+
+// averageLatency is the mean latency of the endpoints with samples, or 0
+// when none has any. Must be called with b.mu held.
+func averageLatency(endpoints []*Endpoint) float64 {
+	var sum float64
+	var n int
+	for _, e := range endpoints {
+		if e.samples > 0 {
+			sum += e.latency
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+// pickRendezvous hashes the session key against each endpoint so that
+// draining one endpoint only moves the sessions that were on it.
+func pickRendezvous(candidates []*Endpoint, sessionKey string) *Endpoint {
+	var best *Endpoint
+	var bestScore uint64
+	for _, e := range candidates {
+		h := fnv.New64a()
+		h.Write([]byte(sessionKey))
+		h.Write([]byte{0})
+		h.Write([]byte(e.Name))
+		if s := h.Sum64(); best == nil || s > bestScore {
+			best, bestScore = e, s
+		}
+	}
+	return best
+}
+
+// ModelName returns the model_name the balancer serves.
+func (b *Balancer) ModelName() string { return b.modelName }
+
+// Status returns the balancing state of every endpoint.
+func (b *Balancer) Status() []EndpointStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make([]EndpointStatus, 0, len(b.endpoints))
+	for _, e := range b.endpoints {
+		out = append(out, EndpointStatus{
+			Name:        e.Name,
+			APIBase:     e.APIBase,
+			Weight:      e.Weight,
+			Outstanding: e.outstanding.Load(),
+			LatencyMS:   e.latency * 1000,
+			Healthy:     b.health.IsAvailable(e.Name),
+			Cooldown:    b.health.CooldownRemaining(e.Name),
+		})
+	}
+	return out
+}
+
+// matches reports whether a Chat model argument addresses this balancer.
+func (b *Balancer) matches(model string) bool {
+	if model == b.modelName {
+		return true
+	}
+	for _, e := range b.endpoints {
+		if model == e.ModelID {
+			return true
+		}
+	}
+	return false
+}
+
+// call runs one request on e and feeds the outcome back into health and
+// latency tracking.
+func (b *Balancer) call(
+	ctx context.Context,
+	e *Endpoint,
+	messages []Message,
+	tools []ToolDefinition,
+	options map[string]any,
+) (*LLMResponse, error) {
+	metrics.LBSelections.Inc(b.modelName, e.Name)
+	e.outstanding.Add(1)
+	start := time.Now()
+	resp, err := e.Provider.Chat(ctx, messages, tools, e.ModelID, options)
+	elapsed := time.Since(start).Seconds()
+	e.outstanding.Add(-1)
+
+	switch {
+	case err == nil:
+		b.health.MarkSuccess(e.Name)
+		if resp != nil {
+			b.health.RecordRateLimit(e.Name, resp.RateLimit)
+		}
+		b.mu.Lock()
+		if e.samples == 0 {
+			e.latency = elapsed
+		} else {
+			e.latency = latencyAlpha*elapsed + (1-latencyAlpha)*e.latency
+		}
+		e.samples++
+		b.mu.Unlock()
+	case errors.Is(err, ErrRateLimitWait), ctx.Err() != nil:
+		// Not the endpoint's fault.
+	default:
+		if fe := ClassifyError(err, e.Name, e.ModelID); fe != nil && fe.IsRetriable() {
+			b.health.MarkFailureFor(e.Name, fe.Reason, fe.RetryAfter)
+			b.health.RecordRateLimit(e.Name, fe.RateLimit)
+		}
+	}
+	return resp, err
+}
+
+// balancedProvider sends requests for its model_name through a Balancer,
+// retrying retriable failures on the remaining endpoints. Requests for other
+// models go to the first endpoint unchanged, as a single provider would.
+type balancedProvider struct {
+	balancer *Balancer
+}
+
+// NewBalancedProvider returns a provider spreading requests for the
+// balancer's model_name across its endpoints.
+func NewBalancedProvider(b *Balancer) StatefulProvider {
+	return &balancedProvider{balancer: b}
+}
+
+func (p *balancedProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	b := p.balancer
+	if !b.matches(model) {
+		return b.endpoints[0].Provider.Chat(ctx, messages, tools, model, options)
+	}
+
+	sessionKey := SessionKeyFromContext(ctx)
+	tried := make(map[*Endpoint]bool, len(b.endpoints))
+	var lastErr error
+	for {
+		e := b.Pick(sessionKey, tried)
+		if e == nil {
+			return nil, lastErr
+		}
+		tried[e] = true
+
+		resp, err := b.call(ctx, e, messages, tools, options)
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		if fe := ClassifyError(err, e.Name, e.ModelID); fe == nil || !fe.IsRetriable() {
+			return nil, err
+		}
+		if len(tried) < len(b.endpoints) {
+			logger.WarnCF("provider", "Endpoint failed, trying another", map[string]any{
+				"model":    b.modelName,
+				"endpoint": e.Name,
+				"error":    err.Error(),
+			})
+		}
+	}
+}
+
+func (p *balancedProvider) GetDefaultModel() string {
+	return p.balancer.endpoints[0].Provider.GetDefaultModel()
+}
+
+// Close closes every stateful endpoint provider.
+func (p *balancedProvider) Close() {
+	for _, e := range p.balancer.endpoints {
+		if sp, ok := e.Provider.(StatefulProvider); ok {
+			sp.Close()
+		}
+	}
+}
+
+// BalancerOf returns the balancer behind p, or nil if p does not balance.
+func BalancerOf(p LLMProvider) *Balancer {
+	switch v := p.(type) {
+	case *balancedProvider:
+		return v.balancer
+	case *rateLimitedProvider:
+		return BalancerOf(v.LLMProvider)
+	case statefulRateLimitedProvider:
+		return BalancerOf(v.LLMProvider)
+	}
+	return nil
+}
+
+// newBalancedProviderFromConfig builds one provider per entry, each behind
+// its own limiter in limits, and balances across them.
+func newBalancedProviderFromConfig(
+	entries []config.ModelConfig, workspace string, limits *RateLimiters,
+) (LLMProvider, string, error) {
+	var strategy string
+	endpoints := make([]*Endpoint, 0, len(entries))
+	for i := range entries {
+		mc := entries[i]
+		if mc.Workspace == "" {
+			mc.Workspace = workspace
+		}
+		if strategy == "" {
+			strategy = mc.LoadBalancing
+		}
+		p, modelID, err := CreateProviderFromConfig(&mc)
+		if err != nil {
+			return nil, "", fmt.Errorf("model_list entry %d for %q: %w", i, mc.ModelName, err)
+		}
+		endpoints = append(endpoints, &Endpoint{
+			Name:     fmt.Sprintf("%s#%d", mc.ModelName, i),
+			APIBase:  mc.APIBase,
+			ModelID:  modelID,
+			Weight:   mc.Weight,
+			Provider: WithRateLimits(p, &mc, limits.only([]config.ModelConfig{mc})),
+		})
+	}
+	b := NewBalancer(entries[0].ModelName, strategy, endpoints)
+	logger.InfoCF("provider", "Load balancing model across endpoints", map[string]any{
+		"model":     entries[0].ModelName,
+		"strategy":  b.strategy,
+		"endpoints": len(endpoints),
+	})
+	return NewBalancedProvider(b), endpoints[0].ModelID, nil
+}
@@ -0,0 +1,175 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+)
+
+// endpointProvider answers with its own name, or fails while err is set.
+type endpointProvider struct {
+	name string
+
+	mu     sync.Mutex
+	err    error
+	models []string
+}
+
+func (p *endpointProvider) Chat(
+	_ context.Context, _ []Message, _ []ToolDefinition, model string, _ map[string]any,
+) (*LLMResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.models = append(p.models, model)
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &LLMResponse{Content: p.name}, nil
+}
+
+func (p *endpointProvider) GetDefaultModel() string { return p.name }
+
+func newTestBalancer(strategy string, weights ...int) (*Balancer, []*endpointProvider) {
+	var endpoints []*Endpoint
+	var backends []*endpointProvider
+	for i, w := range weights {
+		p := &endpointProvider{name: fmt.Sprintf("ep%d", i)}
+		backends = append(backends, p)
+		endpoints = append(endpoints, &Endpoint{
+			Name:     fmt.Sprintf("gpt4#%d", i),
+			ModelID:  "gpt-5.2",
+			Weight:   w,
+			Provider: p,
+		})
+	}
+	return NewBalancer("gpt4", strategy, endpoints), backends
+}
+
+func countPicks(b *Balancer, n int, sessionKey string) map[string]int {
+	counts := make(map[string]int)
+	for range n {
+		counts[b.Pick(sessionKey, nil).Name]++
+	}
+	return counts
+}
+
+func TestBalancer_Weighted(t *testing.T) {
+	b, _ := newTestBalancer(config.LBWeighted, 3, 1)
+	counts := countPicks(b, 8, "")
+	if counts["gpt4#0"] != 6 || counts["gpt4#1"] != 2 {
+		t.Errorf("picks = %v, want 6/2", counts)
+	}
+}
+
+func TestBalancer_LeastOutstanding(t *testing.T) {
+	b, _ := newTestBalancer(config.LBLeastOutstanding, 1, 1)
+	b.endpoints[0].outstanding.Add(3)
+	if got := b.Pick("", nil).Name; got != "gpt4#1" {
+		t.Errorf("picked %s, want the idle endpoint gpt4#1", got)
+	}
+}
+
+func TestBalancer_Latency(t *testing.T) {
+	b, _ := newTestBalancer(config.LBLatency, 1, 1, 1)
+	b.endpoints[0].latency, b.endpoints[0].samples = 2.0, 1
+	b.endpoints[1].latency, b.endpoints[1].samples = 0.5, 1
+	b.endpoints[2].latency, b.endpoints[2].samples = 1.0, 1
+	if got := countPicks(b, 5, ""); got["gpt4#1"] != 5 {
+		t.Errorf("picks = %v, want all on the fastest endpoint", got)
+	}
+}
+
+func TestBalancer_LatencyUnmeasuredScoresAverage(t *testing.T) {
+	b, _ := newTestBalancer(config.LBLatency, 1, 1, 1)
+	b.endpoints[0].latency, b.endpoints[0].samples = 0.5, 4
+	b.endpoints[1].latency, b.endpoints[1].samples = 3.5, 4
+	// gpt4#2 has no samples and scores 2s: behind the fast endpoint, ahead
+	// of the slow one.
+	if got := countPicks(b, 5, ""); got["gpt4#0"] != 5 {
+		t.Errorf("picks = %v, want all on the fast endpoint", got)
+	}
+	if got := b.Pick("", map[*Endpoint]bool{b.endpoints[0]: true}).Name; got != "gpt4#2" {
+		t.Errorf("without the fast endpoint picked %s, want the unmeasured gpt4#2", got)
+	}
+}
+
+func TestBalancer_SharesCooldownTracker(t *testing.T) {
+	b, _ := newTestBalancer(config.LBRoundRobin, 1, 1)
+	shared := NewCooldownTracker()
+	b.UseCooldownTracker(shared)
+	for range 3 {
+		shared.MarkFailure("gpt4#0", FailoverRateLimit)
+	}
+	if got := countPicks(b, 4, ""); got["gpt4#1"] != 4 {
+		t.Errorf("picks = %v, want the endpoint cooling down in the shared tracker drained", got)
+	}
+}
+
+func TestBalancer_StickyAndDrain(t *testing.T) {
+	b, _ := newTestBalancer(config.LBSticky, 1, 1, 1)
+
+	first := b.Pick("agent:main:telegram:42", nil)
+	if got := countPicks(b, 10, "agent:main:telegram:42"); got[first.Name] != 10 {
+		t.Fatalf("session moved between endpoints: %v", got)
+	}
+	if len(countPicks(b, 1, "")) != 1 {
+		t.Fatal("pick without a session key should still succeed")
+	}
+
+	// Drain the session's endpoint: it moves, and comes back on recovery.
+	for range 3 {
+		b.health.MarkFailure(first.Name, FailoverRateLimit)
+	}
+	if moved := b.Pick("agent:main:telegram:42", nil); moved == first {
+		t.Errorf("session stayed on drained endpoint %s", first.Name)
+	}
+	b.health.MarkSuccess(first.Name)
+	if back := b.Pick("agent:main:telegram:42", nil); back != first {
+		t.Errorf("session did not return to %s after recovery, got %s", first.Name, back.Name)
+	}
+}
+
+func TestBalancedProvider_RetriesAndDrainsFailingEndpoint(t *testing.T) {
+	b, backends := newTestBalancer(config.LBRoundRobin, 1, 1)
+	backends[0].err = errors.New("503 overloaded")
+	backends[1].err = nil
+	p := NewBalancedProvider(b)
+
+	for i := range 4 {
+		resp, err := p.Chat(context.Background(), nil, nil, "gpt4", nil)
+		if err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+		if resp.Content != "ep1" {
+			t.Errorf("call %d answered by %s, want ep1", i, resp.Content)
+		}
+	}
+	if n := len(backends[0].models); n != 1 {
+		t.Errorf("failing endpoint called %d times, want 1 before it is drained", n)
+	}
+	if backends[1].models[0] != "gpt-5.2" {
+		t.Errorf("endpoint got model %q, want its own model ID", backends[1].models[0])
+	}
+
+	// Non-retriable errors are returned without trying other endpoints.
+	backends[1].err = errors.New("invalid request format")
+	if _, err := p.Chat(context.Background(), nil, nil, "gpt4", nil); err == nil {
+		t.Error("expected the format error")
+	}
+}
+
+func TestBalancerOf(t *testing.T) {
+	b, _ := newTestBalancer(config.LBRoundRobin, 1, 1)
+	other := []config.ModelConfig{{ModelName: "other", Model: "openai/other", RPM: 10}}
+	limited := WithRateLimits(NewBalancedProvider(b), &other[0], NewRateLimiters(other))
+	if BalancerOf(limited) != b {
+		t.Error("BalancerOf should see through the rate limit wrapper")
+	}
+	if BalancerOf(&endpointProvider{}) != nil {
+		t.Error("plain provider has no balancer")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
@@ -14,6 +15,8 @@ import (
 
 	"github.com/tinyland-inc/tinyclaw/pkg/auth"
 	"github.com/tinyland-inc/tinyclaw/pkg/logger"
+	"github.com/tinyland-inc/tinyclaw/pkg/providers/protocoltypes"
+	"github.com/tinyland-inc/tinyclaw/pkg/providers/transport"
 )
 
 const (
@@ -36,6 +39,7 @@ func NewCodexProvider(token, accountID string) *CodexProvider {
 		option.WithAPIKey(token),
 		option.WithHeader("originator", "codex_cli_rs"),
 		option.WithHeader("OpenAI-Beta", "responses=experimental"),
+		option.WithHTTPClient(transport.Client(0)),
 	}
 	if accountID != "" {
 		opts = append(opts, option.WithHeader("Chatgpt-Account-Id", accountID))
@@ -99,6 +103,8 @@ func (p *CodexProvider) Chat(
 
 	params := buildCodexParams(messages, tools, resolvedModel, options, p.enableWebSearch)
 
+	var httpResp *http.Response
+	opts = append(opts, option.WithResponseInto(&httpResp))
 	stream := p.client.Responses.NewStreaming(ctx, params, opts...)
 	defer stream.Close()
 
@@ -138,6 +144,9 @@ func (p *CodexProvider) Chat(
 			}
 		}
 		logger.ErrorCF("provider.codex", "Codex API call failed", fields)
+		if apiErr != nil {
+			return nil, protocoltypes.NewStatusError(fmt.Errorf("codex API call: %w", err), apiErr.Response)
+		}
 		return nil, fmt.Errorf("codex API call: %w", err)
 	}
 	if resp == nil {
@@ -152,7 +161,11 @@ func (p *CodexProvider) Chat(
 		return nil, errors.New("codex API call: stream ended without completed response")
 	}
 
-	return parseCodexResponse(resp), nil
+	out := parseCodexResponse(resp)
+	if httpResp != nil {
+		out.RateLimit = protocoltypes.ParseRateLimitHeaders(httpResp.Header, time.Now())
+	}
+	return out, nil
 }
 
 func (p *CodexProvider) GetDefaultModel() string {
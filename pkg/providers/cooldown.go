@@ -2,12 +2,16 @@ package providers
 
 import (
 	"math"
+	"sort"
 	"sync"
 	"time"
 )
 
 const (
 	defaultFailureWindow = 24 * time.Hour
+
+	// maxRetryAfter caps cooldowns requested by providers.
+	maxRetryAfter = time.Hour
 )
 
 // CooldownTracker manages per-provider cooldown state for the fallback chain.
@@ -26,6 +30,9 @@ type cooldownEntry struct {
 	DisabledUntil  time.Time      // billing-specific disable expiry
 	DisabledReason FailoverReason // reason for disable (billing)
 	LastFailure    time.Time
+
+	RateLimit       *RateLimitInfo // latest rate-limit headers from the provider
+	RateLimitSeenAt time.Time
 }
 
 // NewCooldownTracker creates a tracker with default 24h failure window.
@@ -40,6 +47,13 @@ func NewCooldownTracker() *CooldownTracker {
 // MarkFailure records a failure for a provider and sets appropriate cooldown.
 // Resets error counts if last failure was more than failureWindow ago.
 func (ct *CooldownTracker) MarkFailure(provider string, reason FailoverReason) {
+	ct.MarkFailureFor(provider, reason, 0)
+}
+
+// MarkFailureFor is MarkFailure with the wait the provider asked for (from
+// Retry-After or rate-limit reset headers). A positive retryAfter, capped
+// at an hour, replaces the exponential schedule for non-billing failures.
+func (ct *CooldownTracker) MarkFailureFor(provider string, reason FailoverReason, retryAfter time.Duration) {
 	ct.mu.Lock()
 	defer ct.mu.Unlock()
 
@@ -60,11 +74,26 @@ func (ct *CooldownTracker) MarkFailure(provider string, reason FailoverReason) {
 		billingCount := entry.FailureCounts[FailoverBilling]
 		entry.DisabledUntil = now.Add(calculateBillingCooldown(billingCount))
 		entry.DisabledReason = FailoverBilling
+	} else if retryAfter > 0 {
+		entry.CooldownEnd = now.Add(min(retryAfter, maxRetryAfter))
 	} else {
 		entry.CooldownEnd = now.Add(calculateStandardCooldown(entry.ErrorCount))
 	}
 }
 
+// RecordRateLimit stores the latest rate-limit headers seen from a
+// provider, for status and metrics. A nil info is ignored.
+func (ct *CooldownTracker) RecordRateLimit(provider string, info *RateLimitInfo) {
+	if info == nil {
+		return
+	}
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	entry := ct.getOrCreate(provider)
+	entry.RateLimit = info
+	entry.RateLimitSeenAt = ct.nowFunc()
+}
+
 // MarkSuccess resets all counters and cooldowns for a provider.
 func (ct *CooldownTracker) MarkSuccess(provider string) {
 	ct.mu.Lock()
@@ -162,6 +191,56 @@ func (ct *CooldownTracker) FailureCount(provider string, reason FailoverReason)
 	return entry.FailureCounts[reason]
 }
 
+// CooldownState is a point-in-time view of one provider's cooldown.
+type CooldownState struct {
+	Provider   string
+	ErrorCount int
+	Remaining  time.Duration
+	// RateLimit is the latest quota reported by the provider, with reset
+	// times counted from now. Nil when it never sent rate-limit headers.
+	RateLimit *RateLimitInfo
+}
+
+// Snapshot returns the state of every provider that has recorded a failure
+// or reported its rate limits.
+func (ct *CooldownTracker) Snapshot() []CooldownState {
+	ct.mu.RLock()
+	providers := make([]string, 0, len(ct.entries))
+	for p := range ct.entries {
+		providers = append(providers, p)
+	}
+	ct.mu.RUnlock()
+	sort.Strings(providers)
+
+	states := make([]CooldownState, 0, len(providers))
+	for _, p := range providers {
+		states = append(states, CooldownState{
+			Provider:   p,
+			ErrorCount: ct.ErrorCount(p),
+			Remaining:  ct.CooldownRemaining(p),
+			RateLimit:  ct.rateLimit(p),
+		})
+	}
+	return states
+}
+
+// rateLimit returns a copy of the provider's latest rate-limit info with
+// reset times adjusted for the time since it was seen.
+func (ct *CooldownTracker) rateLimit(provider string) *RateLimitInfo {
+	ct.mu.RLock()
+	defer ct.mu.RUnlock()
+	entry := ct.entries[provider]
+	if entry == nil || entry.RateLimit == nil {
+		return nil
+	}
+	info := *entry.RateLimit
+	elapsed := ct.nowFunc().Sub(entry.RateLimitSeenAt)
+	info.RetryAfter = max(0, info.RetryAfter-elapsed)
+	info.ResetRequests = max(0, info.ResetRequests-elapsed)
+	info.ResetTokens = max(0, info.ResetTokens-elapsed)
+	return &info
+}
+
 func (ct *CooldownTracker) getOrCreate(provider string) *cooldownEntry {
 	entry := ct.entries[provider]
 	if entry == nil {
@@ -267,3 +267,68 @@ func TestCooldown_MultipleProviders(t *testing.T) {
 		t.Error("groq should be available")
 	}
 }
+
+func TestCooldown_Snapshot(t *testing.T) {
+	now := time.Now()
+	ct, _ := newTestTracker(now)
+
+	ct.MarkFailure("openai", FailoverRateLimit)
+	ct.MarkFailure("anthropic", FailoverRateLimit)
+	ct.MarkSuccess("anthropic")
+
+	snap := ct.Snapshot()
+	if len(snap) != 2 || snap[0].Provider != "anthropic" || snap[1].Provider != "openai" {
+		t.Fatalf("unexpected snapshot: %+v", snap)
+	}
+	if snap[0].ErrorCount != 0 || snap[0].Remaining != 0 {
+		t.Errorf("anthropic should be available: %+v", snap[0])
+	}
+	if snap[1].ErrorCount != 1 || snap[1].Remaining != time.Minute {
+		t.Errorf("openai should be cooling down for 1m: %+v", snap[1])
+	}
+}
+
+func TestCooldown_RetryAfterSetsPreciseCooldown(t *testing.T) {
+	now := time.Now()
+	ct, current := newTestTracker(now)
+
+	ct.MarkFailureFor("openai", FailoverRateLimit, 12*time.Second)
+	if got := ct.CooldownRemaining("openai"); got != 12*time.Second {
+		t.Errorf("cooldown = %v, want the provider's 12s", got)
+	}
+	*current = now.Add(13 * time.Second)
+	if !ct.IsAvailable("openai") {
+		t.Error("provider should be available once Retry-After has passed")
+	}
+
+	// Without a hint the exponential schedule applies (second error: 5 min).
+	ct.MarkFailureFor("openai", FailoverRateLimit, 0)
+	if got := ct.CooldownRemaining("openai"); got != 5*time.Minute {
+		t.Errorf("cooldown = %v, want 5m", got)
+	}
+
+	// Provider hints are capped.
+	ct.MarkFailureFor("anthropic", FailoverRateLimit, 48*time.Hour)
+	if got := ct.CooldownRemaining("anthropic"); got != time.Hour {
+		t.Errorf("cooldown = %v, want 1h cap", got)
+	}
+}
+
+func TestCooldown_RecordRateLimit(t *testing.T) {
+	now := time.Now()
+	ct, current := newTestTracker(now)
+	ct.RecordRateLimit("openai", &RateLimitInfo{RemainingRequests: 3, RemainingTokens: -1, ResetRequests: 10 * time.Second})
+	*current = now.Add(4 * time.Second)
+
+	states := ct.Snapshot()
+	if len(states) != 1 || states[0].RateLimit == nil {
+		t.Fatalf("snapshot = %+v", states)
+	}
+	rl := states[0].RateLimit
+	if rl.RemainingRequests != 3 || rl.ResetRequests != 6*time.Second {
+		t.Errorf("rate limit = %+v, want 3 remaining resetting in 6s", rl)
+	}
+	if !ct.IsAvailable("openai") {
+		t.Error("recording a quota must not put the provider in cooldown")
+	}
+}
@@ -97,7 +97,31 @@ var (
 
 // ClassifyError classifies an error into a FailoverError with reason.
 // Returns nil if the error is not classifiable (unknown errors should not trigger fallback).
+// When err carries a StatusError, its status code and rate-limit headers are
+// used too.
 func ClassifyError(err error, provider, model string) *FailoverError {
+	var se *StatusError
+	if !errors.As(err, &se) || errors.Is(err, context.Canceled) {
+		return classifyError(err, provider, model)
+	}
+
+	fe := classifyError(err, provider, model)
+	if fe == nil {
+		reason := classifyByStatus(se.StatusCode)
+		if reason == "" {
+			return nil
+		}
+		fe = &FailoverError{Reason: reason, Provider: provider, Model: model, Wrapped: err}
+	}
+	if fe.Status == 0 {
+		fe.Status = se.StatusCode
+	}
+	fe.RateLimit = se.RateLimit
+	fe.RetryAfter = se.RateLimit.RetryDelay()
+	return fe
+}
+
+func classifyError(err error, provider, model string) *FailoverError {
 	if err == nil {
 		return nil
 	}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 )
 
 func TestClassifyError_Nil(t *testing.T) {
@@ -335,3 +336,18 @@ func TestIsImageSizeError(t *testing.T) {
 		t.Error("should not match normal error")
 	}
 }
+
+func TestClassifyError_StatusErrorCarriesRetryAfter(t *testing.T) {
+	err := &StatusError{
+		StatusCode: 429,
+		RateLimit:  &RateLimitInfo{RetryAfter: 30 * time.Second, RemainingRequests: -1, RemainingTokens: -1},
+		Err:        errors.New("claude API call: something went wrong"),
+	}
+	fe := ClassifyError(fmt.Errorf("wrapped: %w", err), "anthropic", "claude")
+	if fe == nil {
+		t.Fatal("expected classification from the status code")
+	}
+	if fe.Reason != FailoverRateLimit || fe.Status != 429 || fe.RetryAfter != 30*time.Second {
+		t.Errorf("FailoverError = %+v", fe)
+	}
+}
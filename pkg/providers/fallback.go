@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/metrics"
 )
 
 // FallbackChain orchestrates model fallback across multiple candidates.
@@ -34,7 +36,7 @@ type FallbackAttempt struct {
 	Error    error
 	Reason   FailoverReason
 	Duration time.Duration
-	Skipped  bool // true if skipped due to cooldown
+	Skipped  bool // true if skipped due to cooldown or client-side rate limit
 }
 
 // NewFallbackChain creates a new fallback chain with the given cooldown tracker.
@@ -79,6 +81,8 @@ func ResolveCandidates(cfg ModelConfig, defaultProvider string) []FallbackCandid
 //
 // Behavior:
 //   - Candidates in cooldown are skipped (logged as skipped attempt).
+//   - Candidates whose client-side rate limit would outlast the context
+//     deadline (ErrRateLimitWait) are skipped the same way.
 //   - context.Canceled aborts immediately (user abort, no fallback).
 //   - Non-retriable errors (format) abort immediately.
 //   - Retriable errors trigger fallback to next candidate.
@@ -106,6 +110,7 @@ func (fc *FallbackChain) Execute(
 		// Check cooldown.
 		if !fc.cooldown.IsAvailable(candidate.Provider) {
 			remaining := fc.cooldown.CooldownRemaining(candidate.Provider)
+			metrics.FallbackAttempts.Inc(candidate.Provider, candidate.Model, "skipped", string(FailoverRateLimit))
 			result.Attempts = append(result.Attempts, FallbackAttempt{
 				Provider: candidate.Provider,
 				Model:    candidate.Model,
@@ -127,7 +132,11 @@ func (fc *FallbackChain) Execute(
 
 		if err == nil {
 			// Success.
+			metrics.FallbackAttempts.Inc(candidate.Provider, candidate.Model, "success", "")
 			fc.cooldown.MarkSuccess(candidate.Provider)
+			if resp != nil {
+				fc.cooldown.RecordRateLimit(candidate.Provider, resp.RateLimit)
+			}
 			result.Response = resp
 			result.Provider = candidate.Provider
 			result.Model = candidate.Model
@@ -145,8 +154,28 @@ func (fc *FallbackChain) Execute(
 			return nil, context.Canceled
 		}
 
+		// Client-side rate limit would outlast the deadline: skip to the
+		// next candidate. The provider itself is fine, so no cooldown.
+		if errors.Is(err, ErrRateLimitWait) {
+			metrics.FallbackAttempts.Inc(candidate.Provider, candidate.Model, "skipped", string(FailoverRateLimit))
+			result.Attempts = append(result.Attempts, FallbackAttempt{
+				Provider: candidate.Provider,
+				Model:    candidate.Model,
+				Skipped:  true,
+				Reason:   FailoverRateLimit,
+				Error:    err,
+				Duration: elapsed,
+			})
+			continue
+		}
+
 		// Classify the error.
 		failErr := ClassifyError(err, candidate.Provider, candidate.Model)
+		reason := ""
+		if failErr != nil {
+			reason = string(failErr.Reason)
+		}
+		metrics.FallbackAttempts.Inc(candidate.Provider, candidate.Model, "failed", reason)
 
 		if failErr == nil {
 			// Unclassifiable error: do not fallback, return immediately.
@@ -173,7 +202,9 @@ func (fc *FallbackChain) Execute(
 		}
 
 		// Retriable error: mark failure and continue to next candidate.
-		fc.cooldown.MarkFailure(candidate.Provider, failErr.Reason)
+		// A Retry-After from the provider sets the cooldown precisely.
+		fc.cooldown.MarkFailureFor(candidate.Provider, failErr.Reason, failErr.RetryAfter)
+		fc.cooldown.RecordRateLimit(candidate.Provider, failErr.RateLimit)
 		result.Attempts = append(result.Attempts, FallbackAttempt{
 			Provider: candidate.Provider,
 			Model:    candidate.Model,
@@ -278,7 +309,11 @@ func (e *FallbackExhaustedError) Error() string {
 	fmt.Fprintf(&sb, "fallback: all %d candidates failed:", len(e.Attempts))
 	for i, a := range e.Attempts {
 		if a.Skipped {
-			fmt.Fprintf(&sb, "\n  [%d] %s/%s: skipped (cooldown)", i+1, a.Provider, a.Model)
+			why := "cooldown"
+			if a.Error != nil {
+				why = a.Error.Error()
+			}
+			fmt.Fprintf(&sb, "\n  [%d] %s/%s: skipped (%s)", i+1, a.Provider, a.Model, why)
 		} else {
 			fmt.Fprintf(&sb, "\n  [%d] %s/%s: %v (reason=%s, %s)",
 				i+1, a.Provider, a.Model, a.Error, a.Reason, a.Duration.Round(time.Millisecond))
@@ -17,8 +17,20 @@ import (
 // The old providers config is automatically converted to model_list during config loading.
 // Returns the provider, the model ID to use, and any error.
 func CreateProvider(cfg *config.Config) (LLMProvider, string, error) {
-	model := cfg.Agents.Defaults.GetModelName()
+	return CreateProviderForModel(cfg, cfg.Agents.Defaults.GetModelName())
+}
+
+// CreateProviderForModel creates the provider for the model_list entries
+// named model, such as a budget's downgrade model, and returns it with the
+// model ID to use.
+func CreateProviderForModel(cfg *config.Config, model string) (LLMProvider, string, error) {
+	return CreateProviderWithRateLimits(cfg, model, nil)
+}
 
+// CreateProviderWithRateLimits is CreateProviderForModel with the limiters
+// of an existing provider, from RateLimitsOf, so that both count against the
+// same RPM, TPM and concurrency limits. nil builds them from model_list.
+func CreateProviderWithRateLimits(cfg *config.Config, model string, limits *RateLimiters) (LLMProvider, string, error) {
 	// Ensure model_list is populated (should be done by LoadConfig, but handle edge cases)
 	if len(cfg.ModelList) == 0 && cfg.HasProvidersConfig() {
 		cfg.ModelList = config.ConvertProvidersToModelList(cfg)
@@ -29,6 +41,21 @@ func CreateProvider(cfg *config.Config) (LLMProvider, string, error) {
 		return nil, "", errors.New("no providers configured. Please add entries to model_list in your config")
 	}
 
+	if limits == nil {
+		limits = NewRateLimiters(cfg.ModelList)
+	}
+
+	// Several entries under one model_name are balanced per request. Each
+	// endpoint waits for its own limits, so the balancer as a whole only
+	// waits for those of other models.
+	if entries := cfg.ModelConfigs(model); len(entries) > 1 {
+		provider, modelID, err := newBalancedProviderFromConfig(entries, cfg.WorkspacePath(), limits)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create providers for model %q: %w", model, err)
+		}
+		return WithRateLimits(provider, &entries[0], limits.without(entries)), modelID, nil
+	}
+
 	// Get model config from model_list
 	modelCfg, err := cfg.GetModelConfig(model)
 	if err != nil {
@@ -46,5 +73,5 @@ func CreateProvider(cfg *config.Config) (LLMProvider, string, error) {
 		return nil, "", fmt.Errorf("failed to create provider for model %q: %w", model, err)
 	}
 
-	return provider, modelID, nil
+	return WithRateLimits(provider, modelCfg, limits), modelID, nil
 }
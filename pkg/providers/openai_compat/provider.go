@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/tinyland-inc/tinyclaw/pkg/providers/protocoltypes"
+	"github.com/tinyland-inc/tinyclaw/pkg/providers/transport"
 )
 
 type (
@@ -40,16 +41,14 @@ func NewProvider(apiKey, apiBase, proxy string) *Provider {
 }
 
 func NewProviderWithMaxTokensField(apiKey, apiBase, proxy, maxTokensField string) *Provider {
-	client := &http.Client{
-		Timeout: 120 * time.Second,
-	}
+	client := transport.Client(120 * time.Second)
 
 	if proxy != "" {
 		parsed, err := url.Parse(proxy)
 		if err == nil {
-			client.Transport = &http.Transport{
+			client.Transport = transport.New(&http.Transport{
 				Proxy: http.ProxyURL(parsed),
-			}
+			})
 		} else {
 			log.Printf("openai_compat: invalid proxy URL %q: %v", proxy, err)
 		}
@@ -152,10 +151,16 @@ func (p *Provider) Chat(
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed:\n  Status: %d\n  Body:   %s", resp.StatusCode, string(body))
+		return nil, protocoltypes.NewStatusError(
+			fmt.Errorf("API request failed:\n  Status: %d\n  Body:   %s", resp.StatusCode, string(body)), resp)
 	}
 
-	return parseResponse(body)
+	out, err := parseResponse(body)
+	if err != nil {
+		return nil, err
+	}
+	out.RateLimit = protocoltypes.ParseRateLimitHeaders(resp.Header, time.Now())
+	return out, nil
 }
 
 func parseResponse(body []byte) (*LLMResponse, error) {
@@ -180,7 +185,12 @@ func parseResponse(body []byte) (*LLMResponse, error) {
 			} `json:"message"`
 			FinishReason string `json:"finish_reason"`
 		} `json:"choices"`
-		Usage *UsageInfo `json:"usage"`
+		Usage *struct {
+			UsageInfo
+			PromptTokensDetails *struct {
+				CachedTokens int `json:"cached_tokens"`
+			} `json:"prompt_tokens_details"`
+		} `json:"usage"`
 	}
 
 	if err := json.Unmarshal(body, &apiResponse); err != nil {
@@ -194,6 +204,15 @@ func parseResponse(body []byte) (*LLMResponse, error) {
 		}, nil
 	}
 
+	var usage *UsageInfo
+	if apiResponse.Usage != nil {
+		u := apiResponse.Usage.UsageInfo
+		if d := apiResponse.Usage.PromptTokensDetails; d != nil {
+			u.CachedTokens = d.CachedTokens
+		}
+		usage = &u
+	}
+
 	choice := apiResponse.Choices[0]
 	toolCalls := make([]ToolCall, 0, len(choice.Message.ToolCalls))
 	for _, tc := range choice.Message.ToolCalls {
@@ -240,7 +259,7 @@ func parseResponse(body []byte) (*LLMResponse, error) {
 		ReasoningContent: choice.Message.ReasoningContent,
 		ToolCalls:        toolCalls,
 		FinishReason:     choice.FinishReason,
-		Usage:            apiResponse.Usage,
+		Usage:            usage,
 	}, nil
 }
 
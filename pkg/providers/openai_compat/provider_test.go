@@ -2,10 +2,15 @@ package openai_compat
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/providers/protocoltypes"
+	providertransport "github.com/tinyland-inc/tinyclaw/pkg/providers/transport"
 )
 
 func TestProviderChat_UsesMaxCompletionTokensForGLM(t *testing.T) {
@@ -158,6 +163,41 @@ func TestProviderChat_HTTPError(t *testing.T) {
 	}
 }
 
+func TestProviderChat_RateLimitHeaders(t *testing.T) {
+	limited := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Ratelimit-Remaining-Requests", "9")
+		w.Header().Set("X-Ratelimit-Reset-Requests", "6s")
+		if limited {
+			w.Header().Set("Retry-After", "20")
+			http.Error(w, `{"error":{"message":"Rate limit reached"}}`, http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "")
+	_, err := p.Chat(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, "gpt-4o", nil)
+	var se *protocoltypes.StatusError
+	if !errors.As(err, &se) {
+		t.Fatalf("err = %v, want a StatusError", err)
+	}
+	if se.StatusCode != http.StatusTooManyRequests || se.RateLimit == nil || se.RateLimit.RetryAfter != 20*time.Second {
+		t.Errorf("StatusError = %+v, rate limit %+v", se, se.RateLimit)
+	}
+
+	limited = false
+	resp, err := p.Chat(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, "gpt-4o", nil)
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if resp.RateLimit == nil || resp.RateLimit.RemainingRequests != 9 || resp.RateLimit.ResetRequests != 6*time.Second {
+		t.Errorf("RateLimit = %+v", resp.RateLimit)
+	}
+}
+
 func TestProviderChat_StripsMoonshotPrefixAndNormalizesKimiTemperature(t *testing.T) {
 	var requestBody map[string]any
 
@@ -261,7 +301,7 @@ func TestProvider_ProxyConfigured(t *testing.T) {
 	proxyURL := "http://127.0.0.1:8080"
 	p := NewProvider("key", "https://example.com", proxyURL)
 
-	transport, ok := p.httpClient.Transport.(*http.Transport)
+	transport, ok := providertransport.Base(p.httpClient.Transport).(*http.Transport)
 	if !ok || transport == nil {
 		t.Fatalf("expected http transport with proxy, got %T", p.httpClient.Transport)
 	}
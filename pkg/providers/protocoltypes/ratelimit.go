@@ -0,0 +1,140 @@
+package protocoltypes
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimitInfo is what a provider's response headers report about its rate
+// limits. Counts are -1 when the provider did not send them.
+type RateLimitInfo struct {
+	// RetryAfter comes from Retry-After (or retry-after-ms).
+	RetryAfter time.Duration
+
+	LimitRequests     int
+	LimitTokens       int
+	RemainingRequests int
+	RemainingTokens   int
+	// ResetRequests and ResetTokens are how long until the request and
+	// token quotas are replenished.
+	ResetRequests time.Duration
+	ResetTokens   time.Duration
+}
+
+// ParseRateLimitHeaders reads Retry-After plus the OpenAI
+// (x-ratelimit-*) and Anthropic (anthropic-ratelimit-*) quota headers.
+// It returns nil when h has none of them.
+func ParseRateLimitHeaders(h http.Header, now time.Time) *RateLimitInfo {
+	if h == nil {
+		return nil
+	}
+	info := &RateLimitInfo{LimitRequests: -1, LimitTokens: -1, RemainingRequests: -1, RemainingTokens: -1}
+	found := false
+
+	if v := h.Get("Retry-After-Ms"); v != "" {
+		if ms, err := strconv.ParseFloat(v, 64); err == nil && ms >= 0 {
+			info.RetryAfter, found = time.Duration(ms*float64(time.Millisecond)), true
+		}
+	}
+	if v := h.Get("Retry-After"); v != "" && info.RetryAfter == 0 {
+		if d, ok := parseResetValue(v, now); ok {
+			info.RetryAfter, found = d, true
+		}
+	}
+
+	intHeader := func(dst *int, names ...string) {
+		for _, name := range names {
+			if n, err := strconv.Atoi(strings.TrimSpace(h.Get(name))); err == nil {
+				*dst, found = n, true
+				return
+			}
+		}
+	}
+	resetHeader := func(dst *time.Duration, names ...string) {
+		for _, name := range names {
+			if d, ok := parseResetValue(h.Get(name), now); ok {
+				*dst, found = d, true
+				return
+			}
+		}
+	}
+
+	intHeader(&info.LimitRequests, "X-Ratelimit-Limit-Requests", "Anthropic-Ratelimit-Requests-Limit")
+	intHeader(&info.LimitTokens, "X-Ratelimit-Limit-Tokens", "Anthropic-Ratelimit-Tokens-Limit")
+	intHeader(&info.RemainingRequests, "X-Ratelimit-Remaining-Requests", "Anthropic-Ratelimit-Requests-Remaining")
+	intHeader(&info.RemainingTokens, "X-Ratelimit-Remaining-Tokens", "Anthropic-Ratelimit-Tokens-Remaining")
+	resetHeader(&info.ResetRequests, "X-Ratelimit-Reset-Requests", "Anthropic-Ratelimit-Requests-Reset")
+	resetHeader(&info.ResetTokens, "X-Ratelimit-Reset-Tokens", "Anthropic-Ratelimit-Tokens-Reset")
+
+	if !found {
+		return nil
+	}
+	return info
+}
+
+// RetryDelay is how long to wait before retrying: Retry-After when given,
+// otherwise the reset time of whichever quota is exhausted.
+func (i *RateLimitInfo) RetryDelay() time.Duration {
+	if i == nil {
+		return 0
+	}
+	if i.RetryAfter > 0 {
+		return i.RetryAfter
+	}
+	var d time.Duration
+	if i.RemainingRequests == 0 {
+		d = max(d, i.ResetRequests)
+	}
+	if i.RemainingTokens == 0 {
+		d = max(d, i.ResetTokens)
+	}
+	return d
+}
+
+// parseResetValue accepts a Go duration ("6m0s", "20ms"), a number of
+// seconds, or an RFC 3339 / HTTP date.
+func parseResetValue(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+		return d, true
+	}
+	if secs, err := strconv.ParseFloat(v, 64); err == nil && secs >= 0 {
+		return time.Duration(secs * float64(time.Second)), true
+	}
+	for _, layout := range []string{time.RFC3339, http.TimeFormat} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return max(0, t.Sub(now)), true
+		}
+	}
+	return 0, false
+}
+
+// StatusError is an HTTP error from a provider API, carrying the status
+// code and the rate-limit headers of the response. Its message is that of
+// the wrapped error.
+type StatusError struct {
+	StatusCode int
+	RateLimit  *RateLimitInfo
+	Err        error
+}
+
+func (e *StatusError) Error() string { return e.Err.Error() }
+
+func (e *StatusError) Unwrap() error { return e.Err }
+
+// NewStatusError wraps err with the status and rate-limit headers of resp.
+func NewStatusError(err error, resp *http.Response) error {
+	if resp == nil {
+		return err
+	}
+	return &StatusError{
+		StatusCode: resp.StatusCode,
+		RateLimit:  ParseRateLimitHeaders(resp.Header, time.Now()),
+		Err:        err,
+	}
+}
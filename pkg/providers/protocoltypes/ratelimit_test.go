@@ -0,0 +1,67 @@
+package protocoltypes
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimitHeaders_OpenAI(t *testing.T) {
+	h := http.Header{}
+	h.Set("Retry-After", "7")
+	h.Set("X-Ratelimit-Limit-Requests", "500")
+	h.Set("X-Ratelimit-Remaining-Requests", "0")
+	h.Set("X-Ratelimit-Reset-Requests", "1m30s")
+	h.Set("X-Ratelimit-Remaining-Tokens", "12000")
+	h.Set("X-Ratelimit-Reset-Tokens", "250ms")
+
+	info := ParseRateLimitHeaders(h, time.Now())
+	if info == nil {
+		t.Fatal("expected rate-limit info")
+	}
+	want := RateLimitInfo{
+		RetryAfter:        7 * time.Second,
+		LimitRequests:     500,
+		LimitTokens:       -1,
+		RemainingRequests: 0,
+		RemainingTokens:   12000,
+		ResetRequests:     90 * time.Second,
+		ResetTokens:       250 * time.Millisecond,
+	}
+	if *info != want {
+		t.Errorf("info = %+v, want %+v", *info, want)
+	}
+	if info.RetryDelay() != 7*time.Second {
+		t.Errorf("RetryDelay = %v, want Retry-After", info.RetryDelay())
+	}
+}
+
+func TestParseRateLimitHeaders_AnthropicResetTimestamps(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	h := http.Header{}
+	h.Set("Anthropic-Ratelimit-Requests-Remaining", "40")
+	h.Set("Anthropic-Ratelimit-Tokens-Remaining", "0")
+	h.Set("Anthropic-Ratelimit-Tokens-Reset", now.Add(45*time.Second).Format(time.RFC3339))
+
+	info := ParseRateLimitHeaders(h, now)
+	if info == nil || info.RemainingRequests != 40 || info.ResetTokens != 45*time.Second {
+		t.Fatalf("info = %+v", info)
+	}
+	// Tokens are exhausted, so the delay is their reset time.
+	if info.RetryDelay() != 45*time.Second {
+		t.Errorf("RetryDelay = %v, want 45s", info.RetryDelay())
+	}
+}
+
+func TestParseRateLimitHeaders_HTTPDateAndNone(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	h := http.Header{}
+	h.Set("Retry-After", now.Add(2*time.Minute).Format(http.TimeFormat))
+	if info := ParseRateLimitHeaders(h, now); info == nil || info.RetryAfter != 2*time.Minute {
+		t.Errorf("HTTP-date Retry-After: %+v", info)
+	}
+
+	if info := ParseRateLimitHeaders(http.Header{"Content-Type": {"application/json"}}, now); info != nil {
+		t.Errorf("no rate-limit headers should give nil, got %+v", info)
+	}
+}
@@ -30,12 +30,17 @@ type LLMResponse struct {
 	ToolCalls        []ToolCall `json:"tool_calls,omitempty"`
 	FinishReason     string     `json:"finish_reason"`
 	Usage            *UsageInfo `json:"usage,omitempty"`
+	// RateLimit holds the provider's rate-limit headers, when it sent any.
+	RateLimit *RateLimitInfo `json:"-"`
 }
 
 type UsageInfo struct {
 	PromptTokens     int `json:"prompt_tokens"`
 	CompletionTokens int `json:"completion_tokens"`
 	TotalTokens      int `json:"total_tokens"`
+	// CachedTokens is the part of PromptTokens served from the provider's
+	// prompt cache, billed at a reduced rate.
+	CachedTokens int `json:"cached_tokens,omitempty"`
 }
 
 // CacheControl marks a content block for LLM-side prefix caching.
@@ -0,0 +1,367 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+	"github.com/tinyland-inc/tinyclaw/pkg/metrics"
+)
+
+// ErrRateLimitWait is returned when a client-side rate limit would keep a
+// request queued past its context deadline. The fallback chain treats it as
+// a rate-limit skip and moves on to the next candidate without putting the
+// provider in cooldown.
+var ErrRateLimitWait = errors.New("client rate limit wait exceeds deadline")
+
+// RateLimit is the client-side limit of one model_list entry. Zero fields
+// are unlimited.
+type RateLimit struct {
+	RPM           int // requests per minute
+	TPM           int // tokens per minute, charged after each call from reported usage
+	MaxConcurrent int // requests in flight
+}
+
+func (l RateLimit) isZero() bool {
+	return l.RPM <= 0 && l.TPM <= 0 && l.MaxConcurrent <= 0
+}
+
+// bucket is a token bucket refilled continuously at perSecond up to capacity.
+// level may go negative when usage is charged after the fact (TPM).
+type bucket struct {
+	capacity  float64
+	perSecond float64
+	level     float64
+	last      time.Time
+}
+
+func newBucket(perMinute int, now time.Time) *bucket {
+	return &bucket{
+		capacity:  float64(perMinute),
+		perSecond: float64(perMinute) / 60,
+		level:     float64(perMinute),
+		last:      now,
+	}
+}
+
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.level = min(b.capacity, b.level+elapsed*b.perSecond)
+	}
+	b.last = now
+}
+
+// wait returns how long until the bucket holds at least need.
+func (b *bucket) wait(need float64) time.Duration {
+	if b.level >= need {
+		return 0
+	}
+	return time.Duration((need - b.level) / b.perSecond * float64(time.Second))
+}
+
+// RateLimiter enforces a RateLimit. Callers queue in Acquire until the
+// request fits.
+type RateLimiter struct {
+	name  string
+	slots chan struct{}
+
+	mu       sync.Mutex
+	requests *bucket
+	tokens   *bucket
+	nowFunc  func() time.Time // for testing
+}
+
+// NewRateLimiter creates a limiter for the model_list entry name.
+func NewRateLimiter(name string, limit RateLimit) *RateLimiter {
+	now := time.Now()
+	l := &RateLimiter{name: name, nowFunc: time.Now}
+	if limit.RPM > 0 {
+		l.requests = newBucket(limit.RPM, now)
+	}
+	if limit.TPM > 0 {
+		l.tokens = newBucket(limit.TPM, now)
+	}
+	if limit.MaxConcurrent > 0 {
+		l.slots = make(chan struct{}, limit.MaxConcurrent)
+	}
+	return l
+}
+
+// Acquire blocks until a request may start. The returned release function
+// must be called once the request finishes, with the tokens it used.
+//
+// Acquire returns ctx.Err() when ctx is canceled, and an error wrapping
+// ErrRateLimitWait when the wait would run past ctx's deadline.
+func (l *RateLimiter) Acquire(ctx context.Context) (release func(tokens int), err error) {
+	start := l.nowFunc()
+	defer func() {
+		if err == nil {
+			metrics.RateLimitWait.Observe(l.nowFunc().Sub(start).Seconds(), l.name)
+		}
+	}()
+
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, l.ctxError(ctx, "a concurrency slot")
+		}
+	}
+
+	for {
+		l.mu.Lock()
+		now := l.nowFunc()
+		var wait time.Duration
+		if l.requests != nil {
+			l.requests.refill(now)
+			wait = l.requests.wait(1)
+		}
+		if l.tokens != nil {
+			l.tokens.refill(now)
+			wait = max(wait, l.tokens.wait(0))
+		}
+		if wait == 0 {
+			if l.requests != nil {
+				l.requests.level--
+			}
+			l.mu.Unlock()
+			break
+		}
+		l.mu.Unlock()
+
+		if deadline, ok := ctx.Deadline(); ok && now.Add(wait).After(deadline) {
+			l.releaseSlot()
+			return nil, fmt.Errorf("%w: %s needs %s", ErrRateLimitWait, l.name, wait.Round(time.Millisecond))
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			l.releaseSlot()
+			return nil, l.ctxError(ctx, "its request budget")
+		}
+	}
+
+	var once sync.Once
+	return func(tokens int) {
+		once.Do(func() {
+			if l.tokens != nil && tokens > 0 {
+				l.mu.Lock()
+				l.tokens.refill(l.nowFunc())
+				l.tokens.level -= float64(tokens)
+				l.mu.Unlock()
+			}
+			l.releaseSlot()
+		})
+	}, nil
+}
+
+func (l *RateLimiter) releaseSlot() {
+	if l.slots != nil {
+		<-l.slots
+	}
+}
+
+func (l *RateLimiter) ctxError(ctx context.Context, waitingFor string) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %s still waiting for %s", ErrRateLimitWait, l.name, waitingFor)
+	}
+	return ctx.Err()
+}
+
+// RateLimiters holds the limiters of all model_list entries with limits.
+// Each limiter belongs to one endpoint and model: the entry's protocol,
+// api_base and model ID.
+type RateLimiters struct {
+	entries []limitedEntry
+	// all is the set this one was narrowed from, or nil.
+	all *RateLimiters
+}
+
+// limitedEntry is a model_list entry with limits.
+type limitedEntry struct {
+	endpoint endpointKey
+	names    []string // model_name, full model string and model ID
+	limiter  *RateLimiter
+}
+
+// endpointKey identifies the endpoint a provider sends requests to.
+type endpointKey struct {
+	protocol string
+	apiBase  string
+}
+
+func endpointOf(mc *config.ModelConfig) endpointKey {
+	protocol, _ := ExtractProtocol(mc.Model)
+	apiBase := mc.APIBase
+	if apiBase == "" {
+		apiBase = getDefaultAPIBase(protocol)
+	}
+	return endpointKey{protocol: protocol, apiBase: strings.TrimRight(apiBase, "/")}
+}
+
+// NewRateLimiters builds limiters from the RPM, TPM and MaxConcurrent
+// fields of the model list, one per entry. Entries for the same model on
+// the same endpoint share the first one's limiter.
+func NewRateLimiters(modelList []config.ModelConfig) *RateLimiters {
+	r := &RateLimiters{}
+	for i := range modelList {
+		mc := &modelList[i]
+		limit := RateLimit{RPM: mc.RPM, TPM: mc.TPM, MaxConcurrent: mc.MaxConcurrent}
+		if limit.isZero() {
+			continue
+		}
+		endpoint := endpointOf(mc)
+		_, modelID := ExtractProtocol(mc.Model)
+		if r.lookup(endpoint, modelID) != nil {
+			continue
+		}
+		r.entries = append(r.entries, limitedEntry{
+			endpoint: endpoint,
+			names:    []string{mc.ModelName, mc.Model, modelID},
+			limiter:  NewRateLimiter(mc.ModelName, limit),
+		})
+	}
+	return r
+}
+
+// For returns the limiter for model on the endpoint of the model_list entry
+// endpoint, or nil when it is unlimited. model may be the model_name, the
+// full model string or the model ID.
+func (r *RateLimiters) For(endpoint *config.ModelConfig, model string) *RateLimiter {
+	if r == nil || endpoint == nil {
+		return nil
+	}
+	return r.lookup(endpointOf(endpoint), model)
+}
+
+func (r *RateLimiters) lookup(endpoint endpointKey, model string) *RateLimiter {
+	for _, e := range r.entries {
+		if e.endpoint == endpoint && slices.Contains(e.names, model) {
+			return e.limiter
+		}
+	}
+	return nil
+}
+
+// only returns the limiters of r used by entries, and without the others.
+// Both share their state with r.
+func (r *RateLimiters) only(entries []config.ModelConfig) *RateLimiters {
+	return r.narrow(entries, true)
+}
+
+func (r *RateLimiters) without(entries []config.ModelConfig) *RateLimiters {
+	return r.narrow(entries, false)
+}
+
+func (r *RateLimiters) narrow(entries []config.ModelConfig, used bool) *RateLimiters {
+	out := &RateLimiters{all: r.root()}
+	for _, e := range r.entries {
+		isUsed := slices.ContainsFunc(entries, func(mc config.ModelConfig) bool {
+			_, modelID := ExtractProtocol(mc.Model)
+			return r.For(&mc, modelID) == e.limiter
+		})
+		if isUsed == used {
+			out.entries = append(out.entries, e)
+		}
+	}
+	return out
+}
+
+// root returns the full set r was narrowed from.
+func (r *RateLimiters) root() *RateLimiters {
+	if r.all != nil {
+		return r.all
+	}
+	return r
+}
+
+// RateLimitsOf returns the limiters p was created with, so that providers
+// created later for the same model_list, such as a budget's downgrade
+// model, share their limits. It returns nil when p is not rate-limited.
+func RateLimitsOf(p LLMProvider) *RateLimiters {
+	switch v := p.(type) {
+	case *rateLimitedProvider:
+		return v.limits.root()
+	case statefulRateLimitedProvider:
+		return v.limits.root()
+	case *balancedProvider:
+		for _, e := range v.balancer.endpoints {
+			if limits := RateLimitsOf(e.Provider); limits != nil {
+				return limits
+			}
+		}
+	}
+	return nil
+}
+
+// Empty reports whether no model has limits.
+func (r *RateLimiters) Empty() bool {
+	return r == nil || len(r.entries) == 0
+}
+
+// rateLimitedProvider queues Chat calls on the limiter of the requested
+// model on the provider's endpoint.
+type rateLimitedProvider struct {
+	LLMProvider
+	endpoint *config.ModelConfig
+	limits   *RateLimiters
+}
+
+// statefulRateLimitedProvider keeps StatefulProvider visible through the
+// wrapper.
+type statefulRateLimitedProvider struct {
+	*rateLimitedProvider
+}
+
+func (p statefulRateLimitedProvider) Close() {
+	p.LLMProvider.(StatefulProvider).Close()
+}
+
+// WithRateLimits wraps p, created from the model_list entry endpoint, so
+// each Chat call waits for the limiter of its model on that endpoint. It
+// returns p unchanged when no model has limits.
+func WithRateLimits(p LLMProvider, endpoint *config.ModelConfig, limits *RateLimiters) LLMProvider {
+	if limits.Empty() {
+		return p
+	}
+	wrapped := &rateLimitedProvider{LLMProvider: p, endpoint: endpoint, limits: limits}
+	if _, ok := p.(StatefulProvider); ok {
+		return statefulRateLimitedProvider{wrapped}
+	}
+	return wrapped
+}
+
+func (p *rateLimitedProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	l := p.limits.For(p.endpoint, model)
+	if l == nil {
+		return p.LLMProvider.Chat(ctx, messages, tools, model, options)
+	}
+	release, err := l.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.LLMProvider.Chat(ctx, messages, tools, model, options)
+	var used int
+	if resp != nil && resp.Usage != nil {
+		used = resp.Usage.TotalTokens
+		if used == 0 {
+			used = resp.Usage.PromptTokens + resp.Usage.CompletionTokens
+		}
+	}
+	release(used)
+	return resp, err
+}
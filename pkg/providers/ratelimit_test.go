@@ -0,0 +1,217 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+)
+
+func TestRateLimiter_RPMQueuesThenRefuses(t *testing.T) {
+	// 600 RPM refills one request every 100ms after the burst of 600.
+	l := NewRateLimiter("fast", RateLimit{RPM: 600})
+	l.requests.level = 1
+
+	release, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("first Acquire: %v", err)
+	}
+	release(0)
+
+	start := time.Now()
+	release, err = l.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("second Acquire: %v", err)
+	}
+	release(0)
+	if waited := time.Since(start); waited < 50*time.Millisecond {
+		t.Errorf("second request waited %v, want about 100ms", waited)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx); !errors.Is(err, ErrRateLimitWait) {
+		t.Errorf("Acquire past deadline: err = %v, want ErrRateLimitWait", err)
+	}
+}
+
+func TestRateLimiter_TPMChargedFromUsage(t *testing.T) {
+	l := NewRateLimiter("tpm", RateLimit{TPM: 60})
+	release, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	release(120) // one minute of budget in debt
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := l.Acquire(ctx); !errors.Is(err, ErrRateLimitWait) {
+		t.Errorf("Acquire in token debt: err = %v, want ErrRateLimitWait", err)
+	}
+}
+
+func TestRateLimiter_MaxConcurrent(t *testing.T) {
+	l := NewRateLimiter("serial", RateLimit{MaxConcurrent: 1})
+	release, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx); !errors.Is(err, ErrRateLimitWait) {
+		t.Errorf("Acquire while busy: err = %v, want ErrRateLimitWait", err)
+	}
+
+	canceled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	if _, err := l.Acquire(canceled); !errors.Is(err, context.Canceled) {
+		t.Errorf("Acquire with canceled ctx: err = %v, want context.Canceled", err)
+	}
+
+	release(0)
+	release, err = l.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire after release: %v", err)
+	}
+	release(0)
+}
+
+type countingProvider struct {
+	calls atomic.Int32
+}
+
+func (p *countingProvider) Chat(
+	_ context.Context, _ []Message, _ []ToolDefinition, _ string, _ map[string]any,
+) (*LLMResponse, error) {
+	p.calls.Add(1)
+	return &LLMResponse{Content: "ok", Usage: &UsageInfo{TotalTokens: 10}}, nil
+}
+
+func (p *countingProvider) GetDefaultModel() string { return "gpt-4o" }
+
+func TestWithRateLimits_ResolvesModelNames(t *testing.T) {
+	modelList := []config.ModelConfig{
+		{ModelName: "gpt4", Model: "openai/gpt-4o", MaxConcurrent: 2},
+		{ModelName: "free", Model: "openai/gpt-4o-mini"},
+	}
+	limits := NewRateLimiters(modelList)
+	for _, name := range []string{"gpt4", "openai/gpt-4o", "gpt-4o"} {
+		if limits.For(&modelList[0], name) == nil {
+			t.Errorf("For(%q) = nil, want the gpt4 limiter", name)
+		}
+	}
+	if limits.For(&modelList[1], "gpt-4o-mini") != nil {
+		t.Error("entry without limits should be unlimited")
+	}
+
+	inner := &countingProvider{}
+	p := WithRateLimits(inner, &modelList[0], limits)
+	if _, err := p.Chat(context.Background(), nil, nil, "gpt-4o", nil); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if inner.calls.Load() != 1 {
+		t.Errorf("inner calls = %d, want 1", inner.calls.Load())
+	}
+	if got := WithRateLimits(inner, &modelList[0], NewRateLimiters(nil)); got != LLMProvider(inner) {
+		t.Error("WithRateLimits without limits should return the provider unchanged")
+	}
+}
+
+func TestRateLimiters_KeyedPerEndpoint(t *testing.T) {
+	// One model name served by two endpoints with different limits, and a
+	// model on a third endpoint whose model ID collides with the first.
+	modelList := []config.ModelConfig{
+		{ModelName: "gpt4", Model: "openai/gpt-4o", RPM: 10},
+		{ModelName: "gpt4", Model: "openai/gpt-4o", APIBase: "https://eu.example.com/v1/", RPM: 100},
+		{ModelName: "local", Model: "vllm/gpt-4o", APIBase: "http://gpu:8000/v1", MaxConcurrent: 1},
+	}
+	limits := NewRateLimiters(modelList)
+
+	primary := limits.For(&modelList[0], "gpt4")
+	eu := limits.For(&modelList[1], "gpt4")
+	local := limits.For(&modelList[2], "gpt-4o")
+	if primary == nil || eu == nil || local == nil {
+		t.Fatalf("limiters = %v, %v, %v", primary, eu, local)
+	}
+	if primary == eu || primary == local || eu == local {
+		t.Error("entries on different endpoints share a limiter")
+	}
+	// The default api_base and a trailing slash name the same endpoint.
+	sameAsPrimary := config.ModelConfig{Model: "openai/gpt-4o", APIBase: "https://api.openai.com/v1/"}
+	if limits.For(&sameAsPrimary, "gpt-4o") != primary {
+		t.Error("explicit default api_base should use the primary limiter")
+	}
+	if limits.For(&modelList[2], "gpt4") != nil {
+		t.Error("a model_name from another endpoint should not apply")
+	}
+}
+
+func TestFallback_RateLimitWaitSkipsWithoutCooldown(t *testing.T) {
+	ct := NewCooldownTracker()
+	fc := NewFallbackChain(ct)
+	candidates := []FallbackCandidate{
+		makeCandidate("openai", "gpt-4"),
+		makeCandidate("anthropic", "claude"),
+	}
+
+	run := func(ctx context.Context, provider, model string) (*LLMResponse, error) {
+		if provider == "openai" {
+			return nil, ErrRateLimitWait
+		}
+		return &LLMResponse{Content: "from claude"}, nil
+	}
+	result, err := fc.Execute(context.Background(), candidates, run)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if result.Provider != "anthropic" {
+		t.Errorf("provider = %q, want anthropic", result.Provider)
+	}
+	if len(result.Attempts) != 1 || !result.Attempts[0].Skipped || result.Attempts[0].Reason != FailoverRateLimit {
+		t.Errorf("attempts = %+v, want one rate-limit skip", result.Attempts)
+	}
+	if !ct.IsAvailable("openai") {
+		t.Error("client-side rate limit should not put the provider in cooldown")
+	}
+}
+
+func TestCreateProviderWithRateLimits_SharesLimiters(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.ModelList = []config.ModelConfig{
+		{ModelName: "gpt4", Model: "openai/gpt-4o", APIKey: "k", RPM: 10},
+		{ModelName: "gpt4", Model: "openai/gpt-4o", APIKey: "k", APIBase: "https://eu.example.com/v1", RPM: 10},
+		{ModelName: "cheap", Model: "openai/gpt-4o-mini", APIKey: "k", MaxConcurrent: 1},
+	}
+
+	primary, _, err := CreateProviderForModel(cfg, "gpt4")
+	if err != nil {
+		t.Fatalf("CreateProviderForModel: %v", err)
+	}
+	limits := RateLimitsOf(primary)
+	if limits == nil {
+		t.Fatal("RateLimitsOf(balanced provider) = nil")
+	}
+	want := limits.For(&cfg.ModelList[2], "cheap")
+	if want == nil {
+		t.Fatal("main provider's limiters have no entry for cheap")
+	}
+
+	downgrade, _, err := CreateProviderWithRateLimits(cfg, "cheap", limits)
+	if err != nil {
+		t.Fatalf("CreateProviderWithRateLimits: %v", err)
+	}
+	if got := RateLimitsOf(downgrade).For(&cfg.ModelList[2], "cheap"); got != want {
+		t.Error("downgrade provider should share the main provider's limiter")
+	}
+	fresh, _, err := CreateProviderForModel(cfg, "cheap")
+	if err != nil {
+		t.Fatalf("CreateProviderForModel: %v", err)
+	}
+	if RateLimitsOf(fresh).For(&cfg.ModelList[2], "cheap") == want {
+		t.Error("a provider built without limits should get its own limiters")
+	}
+}
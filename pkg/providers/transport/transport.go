@@ -0,0 +1,62 @@
+// Package transport lets the gateway wrap the HTTP transport of every LLM
+// provider, for example to route calls through the Aperture proxy.
+//
+// Providers build their HTTP clients with New. The wrapper is looked up on
+// every request, so one installed after the providers were created still
+// applies.
+package transport
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Wrapper decorates a provider's base transport.
+type Wrapper func(http.RoundTripper) http.RoundTripper
+
+var current atomic.Pointer[Wrapper]
+
+// SetWrapper installs w for all provider requests. Nil removes it.
+func SetWrapper(w Wrapper) {
+	if w == nil {
+		current.Store(nil)
+		return
+	}
+	current.Store(&w)
+}
+
+// New returns a RoundTripper that sends requests through base
+// (http.DefaultTransport when nil), wrapped by the installed Wrapper.
+func New(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &dynamic{base: base}
+}
+
+// Client returns an *http.Client using New(nil) with the given timeout.
+func Client(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: New(nil)}
+}
+
+// Base returns the transport rt sends through before any wrapper, or rt
+// itself when it was not created by New.
+func Base(rt http.RoundTripper) http.RoundTripper {
+	if d, ok := rt.(*dynamic); ok {
+		return d.base
+	}
+	return rt
+}
+
+type dynamic struct {
+	base http.RoundTripper
+}
+
+func (d *dynamic) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt := d.base
+	if w := current.Load(); w != nil {
+		rt = (*w)(rt)
+	}
+	return rt.RoundTrip(req)
+}
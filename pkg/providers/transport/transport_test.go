@@ -0,0 +1,43 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type headerTransport struct {
+	inner http.RoundTripper
+}
+
+func (t headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("X-Wrapped", "yes")
+	return t.inner.RoundTrip(req)
+}
+
+func TestNew_AppliesWrapperInstalledLater(t *testing.T) {
+	var wrapped []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wrapped = append(wrapped, r.Header.Get("X-Wrapped"))
+	}))
+	defer srv.Close()
+
+	client := Client(0)
+	get := func() {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	get()
+	SetWrapper(func(inner http.RoundTripper) http.RoundTripper { return headerTransport{inner} })
+	defer SetWrapper(nil)
+	get()
+
+	if len(wrapped) != 2 || wrapped[0] != "" || wrapped[1] != "yes" {
+		t.Errorf("X-Wrapped per request = %q, want [\"\" \"yes\"]", wrapped)
+	}
+}
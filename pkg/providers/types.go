@@ -3,6 +3,7 @@ package providers
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/tinyland-inc/tinyclaw/pkg/providers/protocoltypes"
 )
@@ -19,6 +20,8 @@ type (
 	GoogleExtra            = protocoltypes.GoogleExtra
 	ContentBlock           = protocoltypes.ContentBlock
 	CacheControl           = protocoltypes.CacheControl
+	RateLimitInfo          = protocoltypes.RateLimitInfo
+	StatusError            = protocoltypes.StatusError
 )
 
 type LLMProvider interface {
@@ -57,6 +60,11 @@ type FailoverError struct {
 	Model    string
 	Status   int
 	Wrapped  error
+	// RetryAfter is how long the provider asked us to wait, from Retry-After
+	// or its rate-limit reset headers. Zero when it did not say.
+	RetryAfter time.Duration
+	// RateLimit holds the provider's rate-limit headers, when it sent any.
+	RateLimit *RateLimitInfo
 }
 
 func (e *FailoverError) Error() string {
@@ -2,6 +2,7 @@ package routing
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 )
 
@@ -140,6 +141,44 @@ func IsSubagentSessionKey(sessionKey string) bool {
 	return strings.HasPrefix(strings.ToLower(parsed.Rest), "subagent:")
 }
 
+// IsDirectSessionOf reports whether sessionKey is the direct-message session
+// of peerID on channel under a per-peer DM scope. A key built from the
+// peer's linked identity matches on any channel.
+func IsDirectSessionOf(sessionKey, channel, peerID string, identityLinks map[string][]string) bool {
+	parsed := ParseAgentSessionKey(sessionKey)
+	peerID = strings.ToLower(strings.TrimSpace(peerID))
+	if parsed == nil || peerID == "" {
+		return false
+	}
+	// "direct:<peer>", "<channel>:direct:<peer>" or
+	// "<channel>:<account>:direct:<peer>".
+	parts := strings.Split(strings.ToLower(parsed.Rest), ":")
+	i := slices.Index(parts, "direct")
+	if i < 0 || i > 2 || i+1 >= len(parts) {
+		return false
+	}
+	keyPeer := strings.Join(parts[i+1:], ":")
+
+	if linked := resolveLinkedPeerID(identityLinks, channel, peerID); linked != "" {
+		return keyPeer == strings.ToLower(linked)
+	}
+	if keyPeer != peerID || isLinkedIdentity(identityLinks, keyPeer) {
+		return false
+	}
+	return i == 0 || parts[0] == normalizeChannel(channel)
+}
+
+// isLinkedIdentity reports whether name is a canonical identity of
+// identityLinks, which only its linked peers resolve to.
+func isLinkedIdentity(identityLinks map[string][]string, name string) bool {
+	for canonical := range identityLinks {
+		if strings.EqualFold(strings.TrimSpace(canonical), name) {
+			return true
+		}
+	}
+	return false
+}
+
 func normalizeChannel(channel string) string {
 	c := strings.TrimSpace(strings.ToLower(channel))
 	if c == "" {
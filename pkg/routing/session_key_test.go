@@ -160,3 +160,31 @@ func TestIsSubagentSessionKey(t *testing.T) {
 		}
 	}
 }
+
+func TestIsDirectSessionOf(t *testing.T) {
+	links := map[string][]string{
+		"john": {"telegram:user123", "discord:john#1234"},
+	}
+	tests := []struct {
+		key, channel, peer string
+		want               bool
+	}{
+		{"agent:main:telegram:direct:user9", "telegram", "user9", true},
+		{"agent:main:telegram:default:direct:user9", "telegram", "USER9", true},
+		{"agent:main:direct:user9", "telegram", "user9", true},
+		{"agent:main:discord:direct:user9", "telegram", "user9", false},
+		{"agent:main:telegram:direct:user456", "telegram", "user9", false},
+		{"agent:main:telegram:group:user9", "telegram", "user9", false},
+		{"agent:main:main", "telegram", "user9", false},
+		{"agent:main:direct:john", "discord", "john#1234", true},
+		{"agent:main:direct:john", "telegram", "user123", true},
+		{"agent:main:telegram:direct:user123", "telegram", "user123", false},
+		{"agent:main:direct:john", "slack", "john", false},
+		{"agent:main:telegram:direct:user9", "telegram", "", false},
+	}
+	for _, tt := range tests {
+		if got := IsDirectSessionOf(tt.key, tt.channel, tt.peer, links); got != tt.want {
+			t.Errorf("IsDirectSessionOf(%q, %q, %q) = %v, want %v", tt.key, tt.channel, tt.peer, got, tt.want)
+		}
+	}
+}
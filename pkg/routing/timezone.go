@@ -0,0 +1,31 @@
+package routing
+
+import (
+	"strings"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+)
+
+// UserTimezone returns the IANA zone of a peer: its entry in the session's
+// UserTimezones, looked up by linked identity, "channel:peer" and peer ID in
+// that order, or else the session's default Timezone. Empty means the
+// server's zone.
+func UserTimezone(cfg config.SessionConfig, channel, peerID string) string {
+	if len(cfg.UserTimezones) > 0 && peerID != "" {
+		zones := make(map[string]string, len(cfg.UserTimezones))
+		for key, tz := range cfg.UserTimezones {
+			zones[strings.ToLower(strings.TrimSpace(key))] = tz
+		}
+		candidates := []string{
+			resolveLinkedPeerID(cfg.IdentityLinks, channel, peerID),
+			strings.ToLower(strings.TrimSpace(channel)) + ":" + strings.ToLower(strings.TrimSpace(peerID)),
+			strings.ToLower(strings.TrimSpace(peerID)),
+		}
+		for _, key := range candidates {
+			if tz, ok := zones[strings.ToLower(key)]; ok && key != "" {
+				return tz
+			}
+		}
+	}
+	return cfg.Timezone
+}
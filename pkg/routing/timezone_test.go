@@ -0,0 +1,34 @@
+package routing
+
+import (
+	"testing"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+)
+
+func TestUserTimezone(t *testing.T) {
+	cfg := config.SessionConfig{
+		Timezone:      "UTC",
+		IdentityLinks: map[string][]string{"alice": {"telegram:123", "discord:alice#1"}},
+		UserTimezones: map[string]string{
+			"alice":       "Europe/Berlin",
+			"slack:U42":   "America/New_York",
+			"matrix-user": "Asia/Tokyo",
+		},
+	}
+	tests := []struct {
+		channel, peer, want string
+	}{
+		{"telegram", "123", "Europe/Berlin"},
+		{"discord", "alice#1", "Europe/Berlin"},
+		{"slack", "U42", "America/New_York"},
+		{"matrix", "matrix-user", "Asia/Tokyo"},
+		{"telegram", "999", "UTC"},
+		{"telegram", "", "UTC"},
+	}
+	for _, tt := range tests {
+		if got := UserTimezone(cfg, tt.channel, tt.peer); got != tt.want {
+			t.Errorf("UserTimezone(%q, %q) = %q, want %q", tt.channel, tt.peer, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+// Package search provides an in-memory BM25 full-text index with optional
+// trigram fuzzy matching.
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// BM25 parameters: term frequency saturation and length normalization.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Hit is a matching document and its relevance score.
+type Hit struct {
+	ID    string
+	Score float64
+}
+
+// Index is an inverted index of documents ranked with Okapi BM25. Documents
+// can be added and removed at any time; it is safe for concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	docs     map[string]*document
+	postings map[string]map[string]int // term -> document ID -> frequency
+	totalLen int
+}
+
+type document struct {
+	length int
+	terms  []string // distinct terms, to unlink the document on removal
+}
+
+// NewIndex creates an empty index.
+func NewIndex() *Index {
+	return &Index{
+		docs:     make(map[string]*document),
+		postings: make(map[string]map[string]int),
+	}
+}
+
+// Add indexes text under id, replacing any document with the same id.
+func (x *Index) Add(id, text string) {
+	tokens := Tokenize(text)
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.remove(id)
+	if len(tokens) == 0 {
+		return
+	}
+	freq := make(map[string]int)
+	for _, t := range tokens {
+		freq[t]++
+	}
+	doc := &document{length: len(tokens), terms: make([]string, 0, len(freq))}
+	for term, n := range freq {
+		p, ok := x.postings[term]
+		if !ok {
+			p = make(map[string]int)
+			x.postings[term] = p
+		}
+		p[id] = n
+		doc.terms = append(doc.terms, term)
+	}
+	x.docs[id] = doc
+	x.totalLen += doc.length
+}
+
+// Remove drops the document id, if indexed.
+func (x *Index) Remove(id string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.remove(id)
+}
+
+func (x *Index) remove(id string) {
+	doc, ok := x.docs[id]
+	if !ok {
+		return
+	}
+	for _, term := range doc.terms {
+		p := x.postings[term]
+		delete(p, id)
+		if len(p) == 0 {
+			delete(x.postings, term)
+		}
+	}
+	x.totalLen -= doc.length
+	delete(x.docs, id)
+}
+
+// Len returns the number of indexed documents.
+func (x *Index) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.docs)
+}
+
+// Search returns up to limit documents matching any term of query, best
+// first. A limit <= 0 returns every match. Documents for which keep returns
+// false are skipped; a nil keep keeps all.
+func (x *Index) Search(query string, limit int, keep func(id string) bool) []Hit {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	weights := make(map[string]float64)
+	for _, term := range uniqueTerms(Tokenize(query)) {
+		weights[term] = 1
+	}
+	return x.search(weights, limit, keep)
+}
+
+// SearchFuzzy is Search that also matches indexed terms spelled like a query
+// term, e.g. with a typo. Such terms are found by trigram similarity and
+// weighted by it.
+func (x *Index) SearchFuzzy(query string, limit int, keep func(id string) bool) []Hit {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	weights := make(map[string]float64)
+	for _, term := range uniqueTerms(Tokenize(query)) {
+		weights[term] = 1
+		grams := trigrams(term)
+		for indexed := range x.postings {
+			if indexed == term {
+				continue
+			}
+			if sim := similarity(grams, trigrams(indexed)); sim >= minSimilarity && sim > weights[indexed] {
+				weights[indexed] = sim
+			}
+		}
+	}
+	return x.search(weights, limit, keep)
+}
+
+// search scores documents by the BM25 sum of the weighted terms. Must be
+// called with x.mu held.
+func (x *Index) search(weights map[string]float64, limit int, keep func(id string) bool) []Hit {
+	n := float64(len(x.docs))
+	if n == 0 || len(weights) == 0 {
+		return nil
+	}
+	avgLen := float64(x.totalLen) / n
+
+	scores := make(map[string]float64)
+	for term, weight := range weights {
+		p := x.postings[term]
+		if len(p) == 0 {
+			continue
+		}
+		df := float64(len(p))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for id, tf := range p {
+			if keep != nil && !keep(id) {
+				continue
+			}
+			f := float64(tf)
+			norm := k1 * (1 - b + b*float64(x.docs[id].length)/avgLen)
+			scores[id] += weight * idf * f * (k1 + 1) / (f + norm)
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// minSimilarity is the trigram similarity from which a term counts as a
+// fuzzy match.
+const minSimilarity = 0.4
+
+// trigrams returns the distinct three-rune substrings of term padded with
+// spaces, so that short terms and word boundaries count.
+func trigrams(term string) map[string]struct{} {
+	r := []rune(" " + term + " ")
+	grams := make(map[string]struct{}, len(r))
+	for i := 0; i+3 <= len(r); i++ {
+		grams[string(r[i:i+3])] = struct{}{}
+	}
+	return grams
+}
+
+// similarity is the Jaccard index of two trigram sets.
+func similarity(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for g := range a {
+		if _, ok := b[g]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// Tokenize splits text into lower-cased terms of letters and digits.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func uniqueTerms(tokens []string) []string {
+	seen := make(map[string]bool, len(tokens))
+	out := tokens[:0:0]
+	for _, t := range tokens {
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// Snippet returns about width runes of text around the first occurrence of
+// a query term, marking cut ends with an ellipsis. Without a match it
+// returns the start of text.
+func Snippet(text, query string, width int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= width {
+		return text
+	}
+
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	if len(lower) != len(runes) {
+		// Lower-casing changed the rune count; match on the original.
+		lower = runes
+	}
+	at := -1
+	for _, term := range uniqueTerms(Tokenize(query)) {
+		if i := runeIndex(lower, []rune(term)); i >= 0 && (at < 0 || i < at) {
+			at = i
+		}
+	}
+
+	start := 0
+	if at > width/3 {
+		start = at - width/3
+	}
+	end := min(start+width, len(runes))
+	start = max(end-width, 0)
+
+	snippet := strings.TrimSpace(string(runes[start:end]))
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(runes) {
+		snippet += "…"
+	}
+	return snippet
+}
+
+func runeIndex(s, sub []rune) int {
+	for i := 0; i+len(sub) <= len(s); i++ {
+		match := true
+		for j := range sub {
+			if s[i+j] != sub[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
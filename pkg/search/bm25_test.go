@@ -0,0 +1,86 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIndex_RanksByRelevance(t *testing.T) {
+	x := NewIndex()
+	x.Add("a", "We decided to use Postgres for the billing service.")
+	x.Add("b", "Lunch options: pizza or sushi.")
+	x.Add("c", "Postgres, Postgres, Postgres: the billing database decision is final.")
+	x.Add("d", "Unrelated chatter about the weather.")
+
+	hits := x.Search("postgres billing", 0, nil)
+	if len(hits) != 2 {
+		t.Fatalf("hits = %+v, want a and c", hits)
+	}
+	if hits[0].ID != "c" || hits[1].ID != "a" {
+		t.Errorf("order = %s, %s; want c, a", hits[0].ID, hits[1].ID)
+	}
+
+	if got := x.Search("POSTGRES", 1, nil); len(got) != 1 {
+		t.Errorf("limit 1 returned %d hits", len(got))
+	}
+	kept := x.Search("postgres", 0, func(id string) bool { return id != "c" })
+	if len(kept) != 1 || kept[0].ID != "a" {
+		t.Errorf("filtered hits = %+v", kept)
+	}
+	if got := x.Search("   ", 0, nil); got != nil {
+		t.Errorf("empty query returned %+v", got)
+	}
+}
+
+func TestIndex_ReplaceAndRemove(t *testing.T) {
+	x := NewIndex()
+	x.Add("a", "alpha beta")
+	x.Add("a", "gamma")
+	if hits := x.Search("alpha", 0, nil); len(hits) != 0 {
+		t.Errorf("replaced document still matches old text: %+v", hits)
+	}
+	if hits := x.Search("gamma", 0, nil); len(hits) != 1 {
+		t.Errorf("replaced document does not match new text")
+	}
+
+	x.Remove("a")
+	x.Remove("missing")
+	if x.Len() != 0 || len(x.postings) != 0 || x.totalLen != 0 {
+		t.Errorf("index not empty after removal: %d docs, %d terms, len %d", x.Len(), len(x.postings), x.totalLen)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	long := strings.Repeat("filler words here ", 20) + "the deadline is Friday " + strings.Repeat("more text ", 20)
+	s := Snippet(long, "deadline", 60)
+	if !strings.Contains(s, "deadline is Friday") {
+		t.Errorf("snippet %q misses the match", s)
+	}
+	if !strings.HasPrefix(s, "…") || !strings.HasSuffix(s, "…") {
+		t.Errorf("snippet %q should be cut on both ends", s)
+	}
+	if got := Snippet("short  text\nhere", "x", 60); got != "short text here" {
+		t.Errorf("short snippet = %q", got)
+	}
+}
+
+func TestIndex_SearchFuzzy(t *testing.T) {
+	x := NewIndex()
+	x.Add("a", "The user prefers kubernetes for deployments.")
+	x.Add("b", "Favourite colour is green.")
+
+	if hits := x.Search("kubernetis", 0, nil); len(hits) != 0 {
+		t.Errorf("exact search matched a misspelling: %+v", hits)
+	}
+	hits := x.SearchFuzzy("kubernetis deploymnts", 0, nil)
+	if len(hits) != 1 || hits[0].ID != "a" {
+		t.Fatalf("fuzzy hits = %+v", hits)
+	}
+	exact := x.SearchFuzzy("kubernetes", 0, nil)
+	if len(exact) != 1 || exact[0].Score <= hits[0].Score/2 {
+		t.Errorf("exact term should score at least as high as misspellings: %+v vs %+v", exact, hits)
+	}
+	if hits := x.SearchFuzzy("zzz", 0, nil); len(hits) != 0 {
+		t.Errorf("unrelated query matched: %+v", hits)
+	}
+}
@@ -0,0 +1,384 @@
+package session
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+)
+
+var (
+	sessionsBucket = []byte("sessions")
+	updatedBucket  = []byte("updated")
+	metaKey        = []byte("meta")
+	messagesBucket = []byte("messages")
+)
+
+// boltMeta is the stored header of a session.
+type boltMeta struct {
+	Key      string    `json:"key"`
+	Channel  string    `json:"channel,omitempty"`
+	Summary  string    `json:"summary,omitempty"`
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+	Messages int       `json:"messages"`
+	Inactive []Node    `json:"inactive,omitempty"`
+	Pinned   []string  `json:"pinned,omitempty"`
+	LastID   int       `json:"last_id,omitempty"`
+}
+
+// boltMessage is a stored message of the active branch.
+type boltMessage struct {
+	ID string `json:"id,omitempty"`
+	providers.Message
+}
+
+func (m *boltMeta) info() SessionInfo {
+	return SessionInfo{
+		Key:          m.Key,
+		Channel:      m.Channel,
+		MessageCount: m.Messages,
+		HasSummary:   m.Summary != "",
+		Created:      m.Created,
+		Updated:      m.Updated,
+	}
+}
+
+// BoltStore keeps sessions in an embedded bbolt database. Each session is a
+// bucket holding its header and its messages keyed by sequence number, so
+// new messages are appended without rewriting the conversation; a second
+// bucket indexes sessions by update time.
+//
+// Stores opened on the same file within a process share one database
+// handle, since bbolt locks the file exclusively.
+type BoltStore struct {
+	path   string
+	db     *bolt.DB
+	closed sync.Once
+}
+
+var (
+	boltMu  sync.Mutex
+	boltDBs = map[string]*sharedBolt{}
+)
+
+type sharedBolt struct {
+	db   *bolt.DB
+	refs int
+}
+
+// OpenBoltStore opens (creating if needed) a bolt session database. It fails
+// after a few seconds if another process holds the file.
+func OpenBoltStore(path string) (*BoltStore, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	boltMu.Lock()
+	defer boltMu.Unlock()
+
+	if shared, ok := boltDBs[abs]; ok {
+		shared.refs++
+		return &BoltStore{path: abs, db: shared.db}, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(abs, 0o600, &bolt.Options{Timeout: 3 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", abs, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{sessionsBucket, updatedBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	boltDBs[abs] = &sharedBolt{db: db, refs: 1}
+	return &BoltStore{path: abs, db: db}, nil
+}
+
+// updatedIndexKey orders sessions by update time, then key.
+func updatedIndexKey(m *boltMeta) []byte {
+	var nanos uint64
+	if !m.Updated.IsZero() && m.Updated.UnixNano() > 0 {
+		nanos = uint64(m.Updated.UnixNano())
+	}
+	k := make([]byte, 8, 8+len(m.Key))
+	binary.BigEndian.PutUint64(k, nanos)
+	return append(k, m.Key...)
+}
+
+func seqKey(i int) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, uint64(i))
+	return k
+}
+
+func readMeta(b *bolt.Bucket) (*boltMeta, error) {
+	data := b.Get(metaKey)
+	if data == nil {
+		return nil, errors.New("session header missing")
+	}
+	var m boltMeta
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// sessionBucket returns the bucket of key, or nil.
+func sessionBucket(tx *bolt.Tx, key string) *bolt.Bucket {
+	if key == "" {
+		return nil
+	}
+	return tx.Bucket(sessionsBucket).Bucket([]byte(key))
+}
+
+func (s *BoltStore) Load(key string) (*Session, error) {
+	sess, _, err := s.Page(key, 0, 0)
+	return sess, err
+}
+
+// readMessages returns the messages in [offset, offset+limit) and their IDs.
+func readMessages(b *bolt.Bucket, offset, limit, total int) ([]providers.Message, []string, error) {
+	n := max(total-offset, 0)
+	if limit > 0 {
+		n = min(n, limit)
+	}
+	msgs := make([]providers.Message, 0, n)
+	ids := make([]string, 0, n)
+	mb := b.Bucket(messagesBucket)
+	if mb == nil || n == 0 {
+		return msgs, nil, nil
+	}
+	c := mb.Cursor()
+	for k, v := c.Seek(seqKey(offset)); k != nil && len(msgs) < n; k, v = c.Next() {
+		var msg boltMessage
+		if err := json.Unmarshal(v, &msg); err != nil {
+			return nil, nil, err
+		}
+		msgs = append(msgs, msg.Message)
+		ids = append(ids, msg.ID)
+	}
+	if slices.Contains(ids, "") {
+		// Stored before message IDs existed.
+		ids = nil
+	}
+	return msgs, ids, nil
+}
+
+func (s *BoltStore) Info(key string) (SessionInfo, error) {
+	var info SessionInfo
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := sessionBucket(tx, key)
+		if b == nil {
+			return ErrNotFound
+		}
+		m, err := readMeta(b)
+		if err != nil {
+			return err
+		}
+		info = m.info()
+		return nil
+	})
+	return info, err
+}
+
+func (s *BoltStore) Save(sess *Session) error {
+	return s.write(sess, -1)
+}
+
+// Append writes the header and the messages after from. When the stored
+// message count differs from from, the session is rewritten instead.
+func (s *BoltStore) Append(sess *Session, from int) error {
+	return s.write(sess, from)
+}
+
+// write stores sess, appending its messages after from, or rewriting all of
+// them when from is negative.
+func (s *BoltStore) write(sess *Session, from int) error {
+	if sess.Key == "" {
+		return os.ErrInvalid
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(sessionsBucket).CreateBucketIfNotExists([]byte(sess.Key))
+		if err != nil {
+			return err
+		}
+		index := tx.Bucket(updatedBucket)
+
+		if old, err := readMeta(b); err == nil {
+			if err := index.Delete(updatedIndexKey(old)); err != nil {
+				return err
+			}
+			if old.Messages != from || from > len(sess.Messages) {
+				from = -1
+			}
+		} else {
+			from = -1
+		}
+
+		if from < 0 {
+			if b.Bucket(messagesBucket) != nil {
+				if err := b.DeleteBucket(messagesBucket); err != nil {
+					return err
+				}
+			}
+			from = 0
+		}
+		mb, err := b.CreateBucketIfNotExists(messagesBucket)
+		if err != nil {
+			return err
+		}
+		for i := from; i < len(sess.Messages); i++ {
+			msg := boltMessage{Message: sess.Messages[i]}
+			if len(sess.MessageIDs) == len(sess.Messages) {
+				msg.ID = sess.MessageIDs[i]
+			}
+			data, err := json.Marshal(msg)
+			if err != nil {
+				return err
+			}
+			if err := mb.Put(seqKey(i), data); err != nil {
+				return err
+			}
+		}
+
+		m := &boltMeta{
+			Key:      sess.Key,
+			Channel:  sess.Channel,
+			Summary:  sess.Summary,
+			Created:  sess.Created,
+			Updated:  sess.Updated,
+			Messages: len(sess.Messages),
+			Inactive: sess.Inactive,
+			Pinned:   sess.Pinned,
+			LastID:   sess.LastID,
+		}
+		data, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		if err := b.Put(metaKey, data); err != nil {
+			return err
+		}
+		return index.Put(updatedIndexKey(m), nil)
+	})
+}
+
+// Page reads the header and only the requested range of messages.
+func (s *BoltStore) Page(key string, offset, limit int) (*Session, int, error) {
+	var sess *Session
+	var total int
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := sessionBucket(tx, key)
+		if b == nil {
+			return ErrNotFound
+		}
+		m, err := readMeta(b)
+		if err != nil {
+			return err
+		}
+		msgs, ids, err := readMessages(b, max(offset, 0), limit, m.Messages)
+		if err != nil {
+			return err
+		}
+		total = m.Messages
+		sess = &Session{
+			Key:        m.Key,
+			Channel:    m.Channel,
+			Messages:   msgs,
+			MessageIDs: ids,
+			Inactive:   m.Inactive,
+			Pinned:     m.Pinned,
+			LastID:     m.LastID,
+			Summary:    m.Summary,
+			Created:    m.Created,
+			Updated:    m.Updated,
+		}
+		return nil
+	})
+	return sess, total, err
+}
+
+// List walks the update-time index backwards, so the most recent sessions
+// are read without scanning the rest.
+func (s *BoltStore) List(limit int) ([]SessionInfo, error) {
+	infos := make([]SessionInfo, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		sessions := tx.Bucket(sessionsBucket)
+		c := tx.Bucket(updatedBucket).Cursor()
+		for k, _ := c.Last(); k != nil; k, _ = c.Prev() {
+			if limit > 0 && len(infos) >= limit {
+				break
+			}
+			if len(k) <= 8 {
+				continue
+			}
+			b := sessions.Bucket(bytes.Clone(k[8:]))
+			if b == nil {
+				continue
+			}
+			m, err := readMeta(b)
+			if err != nil {
+				continue
+			}
+			infos = append(infos, m.info())
+		}
+		return nil
+	})
+	return infos, err
+}
+
+func (s *BoltStore) Delete(key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := sessionBucket(tx, key)
+		if b == nil {
+			return nil
+		}
+		if m, err := readMeta(b); err == nil {
+			if err := tx.Bucket(updatedBucket).Delete(updatedIndexKey(m)); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(sessionsBucket).DeleteBucket([]byte(key))
+	})
+}
+
+// Close releases the store's reference to the database, closing it when no
+// other store on the same file remains open.
+func (s *BoltStore) Close() error {
+	var err error
+	s.closed.Do(func() {
+		boltMu.Lock()
+		defer boltMu.Unlock()
+		shared, ok := boltDBs[s.path]
+		if !ok {
+			return
+		}
+		if shared.refs--; shared.refs == 0 {
+			delete(boltDBs, s.path)
+			err = shared.db.Close()
+		}
+	})
+	return err
+}
@@ -0,0 +1,177 @@
+package session
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+)
+
+// TurnStarts returns the positions of the user messages that start each
+// turn of history. Splitting a history only at a turn start never separates
+// a tool call from its result.
+func TurnStarts(history []providers.Message) []int {
+	var starts []int
+	for i, m := range history {
+		if m.Role == "user" {
+			starts = append(starts, i)
+		}
+	}
+	return starts
+}
+
+// IsPinned reports whether the message id is pinned.
+func (s *Session) IsPinned(id string) bool {
+	return slices.Contains(s.Pinned, id)
+}
+
+// PinnedKept returns the positions in [from, through] of the active branch
+// that compaction keeps: pinned messages and, for a pinned tool call or
+// result, the whole tool exchange and the user message starting its turn,
+// so the kept messages still form a valid conversation.
+func (s *Session) PinnedKept(from, through int) map[int]bool {
+	keep := make(map[int]bool)
+	for i := from; i <= through; i++ {
+		if !s.IsPinned(s.MessageIDs[i]) {
+			continue
+		}
+		m := s.Messages[i]
+		if m.Role != "tool" && len(m.ToolCalls) == 0 {
+			keep[i] = true
+			continue
+		}
+
+		// Widen to the assistant message making the calls and its results.
+		start := i
+		for start > 0 && s.Messages[start].Role == "tool" {
+			start--
+		}
+		end := start + 1
+		for end < len(s.Messages) && s.Messages[end].Role == "tool" {
+			end++
+		}
+		for j := start; j < end; j++ {
+			keep[j] = true
+		}
+		for j := start; j >= 0; j-- {
+			if s.Messages[j].Role == "user" {
+				keep[j] = true
+				break
+			}
+		}
+	}
+	for i := range keep {
+		if i < from || i > through {
+			delete(keep, i)
+		}
+	}
+	return keep
+}
+
+// compact removes the active messages from fromID through throughID,
+// except pinned ones, and returns how many were removed.
+func (s *Session) compact(fromID, throughID string) (int, error) {
+	from, through := s.activeIndex(fromID), s.activeIndex(throughID)
+	if from < 0 || through < 0 {
+		return 0, fmt.Errorf("%w: compaction range %s-%s is not on the active branch", ErrMessageNotFound, fromID, throughID)
+	}
+	if from > through {
+		return 0, fmt.Errorf("compaction range %s-%s is reversed", fromID, throughID)
+	}
+
+	keep := s.PinnedKept(from, through)
+	msgs := make([]providers.Message, 0, len(s.Messages))
+	ids := make([]string, 0, len(s.MessageIDs))
+	for i := range s.Messages {
+		if i >= from && i <= through && !keep[i] {
+			continue
+		}
+		msgs = append(msgs, s.Messages[i])
+		ids = append(ids, s.MessageIDs[i])
+	}
+	removed := len(s.Messages) - len(msgs)
+	s.Messages, s.MessageIDs = msgs, ids
+	s.pruneOrphans()
+	return removed, nil
+}
+
+// Snapshot returns a copy of the session with message IDs assigned,
+// loading it into memory if needed.
+func (sm *SessionManager) Snapshot(key string) (Session, bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	c := sm.lookup(key, false)
+	if c == nil {
+		return Session{}, false
+	}
+	return c.clone(), true
+}
+
+// Compact replaces the active messages from fromID through throughID with
+// summary, keeping pinned messages verbatim. Messages added after the range
+// are untouched, so a summary computed from a snapshot can be applied while
+// the conversation goes on. It returns how many messages were removed.
+func (sm *SessionManager) Compact(key, fromID, throughID, summary string) (int, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	c := sm.lookup(key, false)
+	if c == nil {
+		return 0, ErrNotFound
+	}
+	n, err := c.compact(fromID, throughID)
+	if err != nil {
+		return 0, err
+	}
+	c.Summary = summary
+	c.Updated = time.Now()
+	c.rewrite, c.dirty = true, true
+	sm.reindex(c.Session)
+	return n, nil
+}
+
+// Pin marks a message of the active branch to be kept verbatim by
+// compaction.
+func (sm *SessionManager) Pin(key, messageID string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	c := sm.lookup(key, false)
+	if c == nil {
+		return ErrNotFound
+	}
+	if c.activeIndex(messageID) < 0 {
+		return fmt.Errorf("%w: %s is not on the active branch", ErrMessageNotFound, messageID)
+	}
+	if c.IsPinned(messageID) {
+		return nil
+	}
+	c.Pinned = append(c.Pinned, messageID)
+	c.Updated = time.Now()
+	c.dirty = true
+	return nil
+}
+
+// Unpin lets compaction summarize a pinned message again.
+func (sm *SessionManager) Unpin(key, messageID string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	c := sm.lookup(key, false)
+	if c == nil {
+		return ErrNotFound
+	}
+	i := slices.Index(c.Pinned, messageID)
+	if i < 0 {
+		return fmt.Errorf("%w: %s is not pinned", ErrMessageNotFound, messageID)
+	}
+	c.Pinned = slices.Delete(c.Pinned, i, i+1)
+	if len(c.Pinned) == 0 {
+		c.Pinned = nil
+	}
+	c.Updated = time.Now()
+	c.dirty = true
+	return nil
+}
@@ -0,0 +1,99 @@
+package session
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+)
+
+// toolTurn adds a user request answered through one tool call.
+func toolTurn(sm *SessionManager, key, request, result, answer string) {
+	sm.AddMessage(key, "user", request)
+	sm.AddFullMessage(key, providers.Message{
+		Role:      "assistant",
+		ToolCalls: []providers.ToolCall{{ID: "call-" + request, Name: "exec"}},
+	})
+	sm.AddFullMessage(key, providers.Message{Role: "tool", Content: result, ToolCallID: "call-" + request})
+	sm.AddMessage(key, "assistant", answer)
+}
+
+func TestTurnStarts(t *testing.T) {
+	sm := NewSessionManager("")
+	toolTurn(sm, "k", "q1", "r1", "a1")
+	sm.AddMessage("k", "user", "q2")
+	if got := TurnStarts(sm.GetHistory("k")); len(got) != 2 || got[0] != 0 || got[1] != 4 {
+		t.Errorf("TurnStarts = %v", got)
+	}
+}
+
+func TestCompact_KeepsPinnedMessages(t *testing.T) {
+	store, err := OpenStore(config.SessionStoreBolt, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm := NewSessionManagerWithStore(store)
+	defer sm.Close()
+
+	sm.AddMessage("k", "user", "always answer in French") // 1
+	sm.AddMessage("k", "assistant", "d'accord")           // 2
+	toolTurn(sm, "k", "q2", "deployed build 42", "done")  // 3-6
+	toolTurn(sm, "k", "q3", "r3", "a3")                   // 7-10
+	sm.AddMessage("k", "user", "q4")                      // 11
+
+	if err := sm.Pin("k", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.Pin("k", "5"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.Pin("k", "99"); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("Pin of an unknown message = %v", err)
+	}
+
+	removed, err := sm.Compact("k", "1", "10", "summary")
+	if err != nil || removed != 6 {
+		t.Fatalf("Compact = %d, %v", removed, err)
+	}
+	want := []string{"always answer in French", "q2", "", "deployed build 42", "q4"}
+	if got := contents(sm.GetHistory("k")); !equal(got, want) {
+		t.Errorf("history = %q, want %q", got, want)
+	}
+	if err := sm.Save("k"); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := NewSessionManagerWithStore(store)
+	s, ok := reopened.Snapshot("k")
+	if !ok || s.Summary != "summary" || len(s.Pinned) != 2 || !s.IsPinned("5") {
+		t.Errorf("stored session = %+v", s)
+	}
+	if err := reopened.Unpin("k", "5"); err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Unpin("k", "5"); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("second Unpin = %v", err)
+	}
+
+	// Pins of messages that leave the session are dropped.
+	reopened.TruncateHistory("k", 1)
+	if s, _ := reopened.Snapshot("k"); len(s.Pinned) != 0 {
+		t.Errorf("pins after truncation = %v", s.Pinned)
+	}
+}
+
+func TestCompact_RejectsUnknownRange(t *testing.T) {
+	sm := NewSessionManager("")
+	sm.AddMessage("k", "user", "q1")
+	sm.AddMessage("k", "assistant", "a1")
+	if _, err := sm.Compact("k", "1", "7", ""); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("Compact past the end = %v", err)
+	}
+	if _, err := sm.Compact("k", "2", "1", ""); err == nil {
+		t.Error("reversed range should fail")
+	}
+	if _, err := sm.Compact("missing", "1", "1", ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Compact of a missing session = %v", err)
+	}
+}
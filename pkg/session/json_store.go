@@ -0,0 +1,236 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+)
+
+// JSONStore keeps one JSON file per session in a directory. It indexes the
+// sessions' metadata at open; messages are read from disk on demand.
+type JSONStore struct {
+	dir string
+
+	mu    sync.RWMutex
+	infos map[string]SessionInfo
+}
+
+// OpenJSONStore opens (creating if needed) a JSON session directory.
+func OpenJSONStore(dir string) (*JSONStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &JSONStore{dir: dir, infos: make(map[string]SessionInfo)}
+	if err := s.index(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *JSONStore) index() error {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+
+		if filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+
+		sess, err := readSessionFile(filepath.Join(s.dir, file.Name()))
+		if err != nil {
+			continue
+		}
+
+		s.infos[sess.Key] = infoOf(sess)
+	}
+
+	return nil
+}
+
+func readSessionFile(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sess Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, err
+	}
+	if sess.Messages == nil {
+		sess.Messages = []providers.Message{}
+	}
+	return &sess, nil
+}
+
+// sanitizeFilename converts a session key into a cross-platform safe filename.
+// Session keys use "channel:chatID" (e.g. "telegram:123456") but ':' is the
+// volume separator on Windows, so filepath.Base would misinterpret the key.
+// We replace it with '_'. The original key is preserved inside the JSON file,
+// so the index still maps back to the right key.
+func sanitizeFilename(key string) string {
+	return strings.ReplaceAll(key, ":", "_")
+}
+
+// filePath returns the storage path for a session key.
+func (s *JSONStore) filePath(key string) (string, error) {
+	filename := sanitizeFilename(key)
+
+	// filepath.IsLocal rejects empty names, "..", absolute paths, and
+	// OS-reserved device names (NUL, COM1 … on Windows).
+	// The extra checks reject "." and any directory separators so that
+	// the session file is always written directly inside the directory.
+	if filename == "." || !filepath.IsLocal(filename) || strings.ContainsAny(filename, `/\`) {
+		return "", os.ErrInvalid
+	}
+	return filepath.Join(s.dir, filename+".json"), nil
+}
+
+func (s *JSONStore) Load(key string) (*Session, error) {
+	path, err := s.filePath(key)
+	if err != nil {
+		return nil, err
+	}
+	sess, err := readSessionFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Distinct keys can sanitize to the same file name.
+	if sess.Key != key {
+		return nil, ErrNotFound
+	}
+	return sess, nil
+}
+
+func (s *JSONStore) Info(key string) (SessionInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	info, ok := s.infos[key]
+	if !ok {
+		return SessionInfo{}, ErrNotFound
+	}
+	return info, nil
+}
+
+// Save writes the session to a temporary file and renames it into place.
+func (s *JSONStore) Save(sess *Session) error {
+	sessionPath, err := s.filePath(sess.Key)
+	if err != nil {
+		return err
+	}
+
+	snapshot := *sess
+	if snapshot.Messages == nil {
+		snapshot.Messages = []providers.Message{}
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := os.CreateTemp(s.dir, "session-*.tmp")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := tmpFile.Name()
+	cleanup := true
+	defer func() {
+		if cleanup {
+			_ = os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmpFile.Write(data); err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Chmod(0o644); err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, sessionPath); err != nil {
+		return err
+	}
+	cleanup = false
+
+	s.mu.Lock()
+	s.infos[sess.Key] = infoOf(sess)
+	s.mu.Unlock()
+	return nil
+}
+
+// Append rewrites the whole file; JSON sessions cannot be appended to.
+func (s *JSONStore) Append(sess *Session, _ int) error {
+	return s.Save(sess)
+}
+
+func (s *JSONStore) Page(key string, offset, limit int) (*Session, int, error) {
+	sess, err := s.Load(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	total := len(sess.Messages)
+	sess.Messages = pageOf(sess.Messages, offset, limit)
+	if len(sess.MessageIDs) == total {
+		sess.MessageIDs = pageOf(sess.MessageIDs, offset, limit)
+	} else {
+		sess.MessageIDs = nil
+	}
+	return sess, total, nil
+}
+
+func (s *JSONStore) List(limit int) ([]SessionInfo, error) {
+	s.mu.RLock()
+	infos := make([]SessionInfo, 0, len(s.infos))
+	for _, info := range s.infos {
+		infos = append(infos, info)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Updated.After(infos[j].Updated)
+	})
+	if limit > 0 && len(infos) > limit {
+		infos = infos[:limit]
+	}
+	return infos, nil
+}
+
+func (s *JSONStore) Delete(key string) error {
+	sessionPath, err := s.filePath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(sessionPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	s.mu.Lock()
+	delete(s.infos, key)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *JSONStore) Close() error { return nil }
@@ -1,62 +1,186 @@
 package session
 
 import (
-	"encoding/json"
+	"errors"
 	"os"
-	"path/filepath"
-	"strings"
+	"slices"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/tinyland-inc/tinyclaw/pkg/logger"
 	"github.com/tinyland-inc/tinyclaw/pkg/providers"
 )
 
 type Session struct {
-	Key      string              `json:"key"`
+	Key     string `json:"key"`
+	Channel string `json:"channel,omitempty"`
+	// Messages is the active branch of the conversation.
 	Messages []providers.Message `json:"messages"`
-	Summary  string              `json:"summary,omitempty"`
-	Created  time.Time           `json:"created"`
-	Updated  time.Time           `json:"updated"`
+	// MessageIDs identifies Messages; each message's parent is the one
+	// before it.
+	MessageIDs []string `json:"message_ids,omitempty"`
+	// Inactive holds the messages of other branches.
+	Inactive []Node `json:"inactive,omitempty"`
+	// Pinned lists the IDs of messages compaction keeps verbatim.
+	Pinned  []string  `json:"pinned,omitempty"`
+	LastID  int       `json:"last_id,omitempty"`
+	Summary string    `json:"summary,omitempty"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
 }
 
+// SessionInfo is a lightweight description of a stored session.
+type SessionInfo struct {
+	Key          string    `json:"key"`
+	Channel      string    `json:"channel,omitempty"`
+	MessageCount int       `json:"message_count"`
+	HasSummary   bool      `json:"has_summary"`
+	Created      time.Time `json:"created"`
+	Updated      time.Time `json:"updated"`
+}
+
+// clone returns a deep copy of the session's history.
+func (s *Session) clone() Session {
+	c := *s
+	c.Messages = slices.Clone(s.Messages)
+	if c.Messages == nil {
+		c.Messages = []providers.Message{}
+	}
+	c.MessageIDs = slices.Clone(s.MessageIDs)
+	c.Inactive = slices.Clone(s.Inactive)
+	c.Pinned = slices.Clone(s.Pinned)
+	return c
+}
+
+// maxCachedSessions bounds how many saved, unmodified sessions a manager
+// keeps in memory. Others are loaded from the store when next used.
+const maxCachedSessions = 256
+
+// cachedSession is a session held in memory with its persistence state.
+type cachedSession struct {
+	*Session
+	persisted int  // leading messages already in the store
+	rewrite   bool // history changed other than by appending
+	dirty     bool // changed since the last save
+	used      time.Time
+}
+
+// SessionManager holds the active sessions of an agent in memory on top of
+// a SessionStore. Sessions are loaded on first use; saved sessions that
+// have not been used recently are dropped from memory.
 type SessionManager struct {
-	sessions map[string]*Session
-	mu       sync.RWMutex
-	storage  string
+	sessions map[string]*cachedSession
+	mu       sync.Mutex
+	store    SessionStore // nil keeps sessions in memory only
+
+	saveMu sync.Mutex // orders writes so appends see a consistent store
+
+	index   *SearchIndex // nil when not searchable
+	agentID string
 }
 
+// NewSessionManager creates a manager backed by the JSON session directory
+// storage. An empty storage keeps sessions in memory only.
 func NewSessionManager(storage string) *SessionManager {
-	sm := &SessionManager{
-		sessions: make(map[string]*Session),
-		storage:  storage,
+	if storage == "" {
+		return NewSessionManagerWithStore(nil)
 	}
-
-	if storage != "" {
-		os.MkdirAll(storage, 0o755)
-		sm.loadSessions()
+	store, err := OpenJSONStore(storage)
+	if err != nil {
+		logger.ErrorCF("session", "Failed to open session directory, sessions will not be saved", map[string]any{
+			"path":  storage,
+			"error": err.Error(),
+		})
+		return NewSessionManagerWithStore(nil)
 	}
+	return NewSessionManagerWithStore(store)
+}
 
-	return sm
+// NewSessionManagerWithStore creates a manager persisting to store, which
+// may be nil to keep sessions in memory only.
+func NewSessionManagerWithStore(store SessionStore) *SessionManager {
+	return &SessionManager{
+		sessions: make(map[string]*cachedSession),
+		store:    store,
+	}
 }
 
-func (sm *SessionManager) GetOrCreate(key string) *Session {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
+// lookup returns the cached session, loading it from the store if needed.
+// With create, a missing session is created. Must be called with sm.mu held.
+func (sm *SessionManager) lookup(key string, create bool) *cachedSession {
+	now := time.Now()
+	if c, ok := sm.sessions[key]; ok {
+		c.used = now
+		return c
+	}
 
-	session, ok := sm.sessions[key]
-	if ok {
-		return session
+	if sm.store != nil {
+		stored, err := sm.store.Load(key)
+		if err == nil {
+			c := &cachedSession{Session: stored, persisted: len(stored.Messages), used: now}
+			if len(stored.MessageIDs) != len(stored.Messages) {
+				stored.ensureIDs()
+				c.rewrite, c.dirty = true, true
+			}
+			sm.sessions[key] = c
+			sm.evict()
+			return c
+		}
+		if !errors.Is(err, ErrNotFound) && !errors.Is(err, os.ErrInvalid) {
+			logger.WarnCF("session", "Failed to load session", map[string]any{
+				"session_key": key,
+				"error":       err.Error(),
+			})
+		}
+	}
+
+	if !create {
+		return nil
+	}
+	c := &cachedSession{
+		Session: &Session{
+			Key:      key,
+			Messages: []providers.Message{},
+			Created:  now,
+			Updated:  now,
+		},
+		rewrite: true,
+		dirty:   true,
+		used:    now,
 	}
+	sm.sessions[key] = c
+	return c
+}
 
-	session = &Session{
-		Key:      key,
-		Messages: []providers.Message{},
-		Created:  time.Now(),
-		Updated:  time.Now(),
+// evict drops the least recently used saved sessions beyond
+// maxCachedSessions. Must be called with sm.mu held.
+func (sm *SessionManager) evict() {
+	if sm.store == nil {
+		return
+	}
+	for len(sm.sessions) > maxCachedSessions {
+		var oldest string
+		var oldestUsed time.Time
+		for key, c := range sm.sessions {
+			if !c.dirty && (oldest == "" || c.used.Before(oldestUsed)) {
+				oldest, oldestUsed = key, c.used
+			}
+		}
+		if oldest == "" {
+			return
+		}
+		delete(sm.sessions, oldest)
 	}
-	sm.sessions[key] = session
+}
+
+// GetOrCreate returns the session with the given key, creating it if
+// needed. The returned session may be dropped from memory once saved.
+func (sm *SessionManager) GetOrCreate(key string) *Session {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
 
-	return session
+	return sm.lookup(key, true).Session
 }
 
 func (sm *SessionManager) AddMessage(sessionKey, role, content string) {
@@ -72,197 +196,250 @@ func (sm *SessionManager) AddFullMessage(sessionKey string, msg providers.Messag
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	session, ok := sm.sessions[sessionKey]
-	if !ok {
-		session = &Session{
-			Key:      sessionKey,
-			Messages: []providers.Message{},
-			Created:  time.Now(),
-		}
-		sm.sessions[sessionKey] = session
+	c := sm.lookup(sessionKey, true)
+	c.appendMessage(msg)
+	c.Updated = time.Now()
+	c.dirty = true
+	if sm.index != nil {
+		sm.index.indexMessage(sm.agentID, c.Session, len(c.Messages)-1)
 	}
+}
 
-	session.Messages = append(session.Messages, msg)
-	session.Updated = time.Now()
+// reindex refreshes the search index after a history change other than an
+// append. Must be called with sm.mu held.
+func (sm *SessionManager) reindex(s *Session) {
+	if sm.index != nil {
+		sm.index.indexSession(sm.agentID, s)
+	}
 }
 
 func (sm *SessionManager) GetHistory(key string) []providers.Message {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
 
-	session, ok := sm.sessions[key]
-	if !ok {
+	c := sm.lookup(key, false)
+	if c == nil {
 		return []providers.Message{}
 	}
 
-	history := make([]providers.Message, len(session.Messages))
-	copy(history, session.Messages)
+	history := make([]providers.Message, len(c.Messages))
+	copy(history, c.Messages)
 	return history
 }
 
 func (sm *SessionManager) GetSummary(key string) string {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
 
-	session, ok := sm.sessions[key]
-	if !ok {
+	c := sm.lookup(key, false)
+	if c == nil {
 		return ""
 	}
-	return session.Summary
+	return c.Summary
 }
 
 func (sm *SessionManager) SetSummary(key string, summary string) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	session, ok := sm.sessions[key]
-	if ok {
-		session.Summary = summary
-		session.Updated = time.Now()
+	if c := sm.lookup(key, false); c != nil {
+		c.Summary = summary
+		c.Updated = time.Now()
+		c.dirty = true
 	}
 }
 
-func (sm *SessionManager) TruncateHistory(key string, keepLast int) {
+// SetChannel records the channel a session's messages arrive on.
+func (sm *SessionManager) SetChannel(key, channel string) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	session, ok := sm.sessions[key]
-	if !ok {
-		return
+	if c := sm.lookup(key, false); c != nil && channel != "" && c.Channel != channel {
+		c.Channel = channel
+		c.dirty = true
 	}
+}
 
-	if keepLast <= 0 {
-		session.Messages = []providers.Message{}
-		session.Updated = time.Now()
-		return
+// List returns a description of every session, most recently updated first.
+func (sm *SessionManager) List() []SessionInfo {
+	byKey := make(map[string]SessionInfo)
+	if sm.store != nil {
+		stored, err := sm.store.List(0)
+		if err != nil {
+			logger.WarnCF("session", "Failed to list sessions", map[string]any{"error": err.Error()})
+		}
+		for _, info := range stored {
+			byKey[info.Key] = info
+		}
 	}
 
-	if len(session.Messages) <= keepLast {
-		return
+	sm.mu.Lock()
+	for key, c := range sm.sessions {
+		byKey[key] = infoOf(c.Session)
 	}
+	sm.mu.Unlock()
 
-	session.Messages = session.Messages[len(session.Messages)-keepLast:]
-	session.Updated = time.Now()
+	infos := make([]SessionInfo, 0, len(byKey))
+	for _, info := range byKey {
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Updated.After(infos[j].Updated)
+	})
+	return infos
 }
 
-// sanitizeFilename converts a session key into a cross-platform safe filename.
-// Session keys use "channel:chatID" (e.g. "telegram:123456") but ':' is the
-// volume separator on Windows, so filepath.Base would misinterpret the key.
-// We replace it with '_'. The original key is preserved inside the JSON file,
-// so loadSessions still maps back to the right in-memory key.
-func sanitizeFilename(key string) string {
-	return strings.ReplaceAll(key, ":", "_")
+// Has reports whether a session with the given key exists.
+func (sm *SessionManager) Has(key string) bool {
+	sm.mu.Lock()
+	_, ok := sm.sessions[key]
+	sm.mu.Unlock()
+	if ok || sm.store == nil {
+		return ok
+	}
+	_, err := sm.store.Info(key)
+	return err == nil
 }
 
-//nolint:funlen // session save: serializes all message types and writes to storage
-func (sm *SessionManager) Save(key string) error {
-	if sm.storage == "" {
-		return nil
+// Get returns a copy of the session with the given key. Sessions not in
+// memory are read from the store without being cached.
+func (sm *SessionManager) Get(key string) (Session, bool) {
+	sm.mu.Lock()
+	c, ok := sm.sessions[key]
+	if ok {
+		snapshot := c.clone()
+		sm.mu.Unlock()
+		return snapshot, true
 	}
+	sm.mu.Unlock()
 
-	filename := sanitizeFilename(key)
-
-	// filepath.IsLocal rejects empty names, "..", absolute paths, and
-	// OS-reserved device names (NUL, COM1 … on Windows).
-	// The extra checks reject "." and any directory separators so that
-	// the session file is always written directly inside sm.storage.
-	if filename == "." || !filepath.IsLocal(filename) || strings.ContainsAny(filename, `/\`) {
-		return os.ErrInvalid
+	if sm.store == nil {
+		return Session{}, false
 	}
-
-	// Snapshot under read lock, then perform slow file I/O after unlock.
-	sm.mu.RLock()
-	stored, ok := sm.sessions[key]
-	if !ok {
-		sm.mu.RUnlock()
-		return nil
+	stored, err := sm.store.Load(key)
+	if err != nil {
+		return Session{}, false
 	}
+	return *stored, true
+}
 
-	snapshot := Session{
-		Key:     stored.Key,
-		Summary: stored.Summary,
-		Created: stored.Created,
-		Updated: stored.Updated,
-	}
-	if len(stored.Messages) > 0 {
-		snapshot.Messages = make([]providers.Message, len(stored.Messages))
-		copy(snapshot.Messages, stored.Messages)
-	} else {
-		snapshot.Messages = []providers.Message{}
+// GetPage returns the session with only up to limit of its messages,
+// starting at offset, and its total message count. A limit <= 0 returns all
+// messages. Stored sessions are paged without loading the full history.
+func (sm *SessionManager) GetPage(key string, offset, limit int) (Session, int, bool) {
+	sm.mu.Lock()
+	if c, ok := sm.sessions[key]; ok {
+		page := c.clone()
+		page.Messages = pageOf(c.Messages, offset, limit)
+		page.MessageIDs = pageOf(c.MessageIDs, offset, limit)
+		total := len(c.Messages)
+		sm.mu.Unlock()
+		return page, total, true
 	}
-	sm.mu.RUnlock()
+	sm.mu.Unlock()
 
-	data, err := json.MarshalIndent(snapshot, "", "  ")
-	if err != nil {
-		return err
+	if sm.store == nil {
+		return Session{}, 0, false
 	}
-
-	sessionPath := filepath.Join(sm.storage, filename+".json")
-	tmpFile, err := os.CreateTemp(sm.storage, "session-*.tmp")
+	page, total, err := sm.store.Page(key, offset, limit)
 	if err != nil {
-		return err
+		return Session{}, 0, false
 	}
+	return *page, total, true
+}
 
-	tmpPath := tmpFile.Name()
-	cleanup := true
-	defer func() {
-		if cleanup {
-			_ = os.Remove(tmpPath)
-		}
-	}()
+// Delete removes a session from memory and storage. It reports whether
+// the session existed.
+func (sm *SessionManager) Delete(key string) (bool, error) {
+	sm.mu.Lock()
+	_, ok := sm.sessions[key]
+	delete(sm.sessions, key)
+	if sm.index != nil {
+		sm.index.removeSession(sm.agentID, key)
+	}
+	sm.mu.Unlock()
 
-	if _, err := tmpFile.Write(data); err != nil {
-		_ = tmpFile.Close()
-		return err
+	if sm.store == nil {
+		return ok, nil
 	}
-	if err := tmpFile.Chmod(0o644); err != nil {
-		_ = tmpFile.Close()
-		return err
+	if _, err := sm.store.Info(key); err == nil {
+		ok = true
 	}
-	if err := tmpFile.Sync(); err != nil {
-		_ = tmpFile.Close()
-		return err
+	if !ok {
+		return false, nil
 	}
-	if err := tmpFile.Close(); err != nil {
-		return err
+	if err := sm.store.Delete(key); err != nil && !errors.Is(err, os.ErrInvalid) {
+		return ok, err
 	}
+	return ok, nil
+}
 
-	if err := os.Rename(tmpPath, sessionPath); err != nil {
-		return err
+func (sm *SessionManager) TruncateHistory(key string, keepLast int) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	c := sm.lookup(key, false)
+	if c == nil {
+		return
 	}
-	cleanup = false
-	return nil
-}
 
-func (sm *SessionManager) loadSessions() error {
-	files, err := os.ReadDir(sm.storage)
-	if err != nil {
-		return err
+	if keepLast <= 0 {
+		c.keepLast(0)
+		c.Updated = time.Now()
+		c.rewrite, c.dirty = true, true
+		sm.reindex(c.Session)
+		return
 	}
 
-	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
+	if len(c.Messages) <= keepLast {
+		return
+	}
 
-		if filepath.Ext(file.Name()) != ".json" {
-			continue
-		}
+	c.keepLast(keepLast)
+	c.Updated = time.Now()
+	c.rewrite, c.dirty = true, true
+	sm.reindex(c.Session)
+}
 
-		sessionPath := filepath.Join(sm.storage, file.Name())
-		data, err := os.ReadFile(sessionPath)
-		if err != nil {
-			continue
-		}
+// Save persists a session's changes. New messages are appended when the
+// store supports it; other changes to the history rewrite the session.
+func (sm *SessionManager) Save(key string) error {
+	if sm.store == nil {
+		return nil
+	}
 
-		var session Session
-		if err := json.Unmarshal(data, &session); err != nil {
-			continue
-		}
+	sm.saveMu.Lock()
+	defer sm.saveMu.Unlock()
 
-		sm.sessions[session.Key] = &session
+	// Snapshot under the lock, then perform slow I/O after unlock.
+	sm.mu.Lock()
+	c, ok := sm.sessions[key]
+	if !ok || !c.dirty {
+		sm.mu.Unlock()
+		return nil
+	}
+	snapshot := c.clone()
+	from := c.persisted
+	if c.rewrite {
+		from = -1
+	}
+	c.persisted, c.rewrite, c.dirty = len(snapshot.Messages), false, false
+	sm.mu.Unlock()
+
+	var err error
+	if from < 0 {
+		err = sm.store.Save(&snapshot)
+	} else {
+		err = sm.store.Append(&snapshot, from)
 	}
 
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if err != nil {
+		c.rewrite, c.dirty = true, true
+		return err
+	}
+	sm.evict()
 	return nil
 }
 
@@ -271,13 +448,20 @@ func (sm *SessionManager) SetHistory(key string, history []providers.Message) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	session, ok := sm.sessions[key]
-	if ok {
-		// Create a deep copy to strictly isolate internal state
-		// from the caller's slice.
-		msgs := make([]providers.Message, len(history))
-		copy(msgs, history)
-		session.Messages = msgs
-		session.Updated = time.Now()
+	if c := sm.lookup(key, false); c != nil {
+		// replaceHistory copies history to strictly isolate internal
+		// state from the caller's slice.
+		c.replaceHistory(history)
+		c.Updated = time.Now()
+		c.rewrite, c.dirty = true, true
+		sm.reindex(c.Session)
+	}
+}
+
+// Close closes the underlying store.
+func (sm *SessionManager) Close() error {
+	if sm.store == nil {
+		return nil
 	}
+	return sm.store.Close()
 }
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestSanitizeFilename(t *testing.T) {
@@ -72,3 +73,50 @@ func TestSave_RejectsPathTraversal(t *testing.T) {
 		}
 	}
 }
+
+func TestListGetDelete(t *testing.T) {
+	tmpDir := t.TempDir()
+	sm := NewSessionManager(tmpDir)
+
+	sm.AddMessage("telegram:1", "user", "older")
+	sm.SetChannel("telegram:1", "telegram")
+	time.Sleep(time.Millisecond)
+	sm.AddMessage("api:2", "user", "newer")
+	sm.SetSummary("api:2", "a summary")
+	for _, key := range []string{"telegram:1", "api:2"} {
+		if err := sm.Save(key); err != nil {
+			t.Fatalf("Save(%q): %v", key, err)
+		}
+	}
+
+	infos := sm.List()
+	if len(infos) != 2 || infos[0].Key != "api:2" {
+		t.Fatalf("expected newest session first, got %+v", infos)
+	}
+	if !infos[0].HasSummary || infos[1].Channel != "telegram" || infos[1].MessageCount != 1 {
+		t.Errorf("unexpected session info: %+v", infos)
+	}
+
+	sess, ok := sm.Get("telegram:1")
+	if !ok || len(sess.Messages) != 1 {
+		t.Fatalf("Get returned %+v, %v", sess, ok)
+	}
+	sess.Messages[0].Content = "mutated"
+	if sm.GetHistory("telegram:1")[0].Content != "older" {
+		t.Error("Get should return a copy")
+	}
+
+	existed, err := sm.Delete("telegram:1")
+	if err != nil || !existed {
+		t.Fatalf("Delete: existed=%v err=%v", existed, err)
+	}
+	if sm.Has("telegram:1") {
+		t.Error("session still present after Delete")
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "telegram_1.json")); !os.IsNotExist(err) {
+		t.Error("session file not removed")
+	}
+	if existed, _ := sm.Delete("missing"); existed {
+		t.Error("Delete of unknown key should report false")
+	}
+}
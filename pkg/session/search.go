@@ -0,0 +1,293 @@
+package session
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/logger"
+	"github.com/tinyland-inc/tinyclaw/pkg/search"
+)
+
+// snippetWidth is the length in runes of search result snippets.
+const snippetWidth = 200
+
+// SearchHit is a message matching a session search.
+type SearchHit struct {
+	AgentID    string `json:"agent_id"`
+	SessionKey string `json:"session_key"`
+	MessageID  string `json:"message_id"`
+	Role       string `json:"role"`
+	Snippet    string `json:"snippet"`
+	// Time is when the message was added, or the session's last update for
+	// messages indexed from storage.
+	Time  time.Time `json:"time"`
+	Score float64   `json:"score"`
+}
+
+// SearchOptions narrows a session search.
+type SearchOptions struct {
+	// Limit caps the number of hits; <= 0 uses a default of 10.
+	Limit int
+	// Allow reports whether a session may appear in the results. Nil
+	// allows every session.
+	Allow func(agentID, key string) bool
+}
+
+type sessionRef struct {
+	agentID string
+	key     string
+}
+
+type indexedMessage struct {
+	sessionRef
+	id   string
+	pos  int
+	role string
+	time time.Time
+}
+
+// SearchIndex is a full-text index over the user and assistant messages of
+// the sessions of one or more managers, typically one per agent. Sessions
+// already stored are indexed on the first search; managers keep the index
+// current as messages are added and histories change.
+type SearchIndex struct {
+	index *search.Index
+	built sync.Once
+
+	mu       sync.Mutex
+	managers map[string]*SessionManager
+	docs     map[string]indexedMessage
+	sessions map[sessionRef][]string
+}
+
+// NewSearchIndex creates an empty index.
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		index:    search.NewIndex(),
+		managers: make(map[string]*SessionManager),
+		docs:     make(map[string]indexedMessage),
+		sessions: make(map[sessionRef][]string),
+	}
+}
+
+// Attach indexes the sessions of sm under agentID.
+func (x *SearchIndex) Attach(agentID string, sm *SessionManager) {
+	x.mu.Lock()
+	x.managers[agentID] = sm
+	x.mu.Unlock()
+
+	sm.mu.Lock()
+	sm.index, sm.agentID = x, agentID
+	sm.mu.Unlock()
+}
+
+func docID(ref sessionRef, messageID string) string {
+	return ref.agentID + "\x00" + ref.key + "\x00" + messageID
+}
+
+func searchable(role, content string) bool {
+	return (role == "user" || role == "assistant") && strings.TrimSpace(content) != ""
+}
+
+// indexSession replaces the indexed messages of s. Messages that stay in
+// the session keep their indexing time.
+func (x *SearchIndex) indexSession(agentID string, s *Session) {
+	ref := sessionRef{agentID: agentID, key: s.Key}
+	ids := s.MessageIDs
+	if len(ids) != len(s.Messages) {
+		// Stored before message IDs existed; number them like a load does.
+		legacy := &Session{Messages: s.Messages}
+		legacy.ensureIDs()
+		ids = legacy.MessageIDs
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	old := make(map[string]indexedMessage, len(x.sessions[ref]))
+	for _, id := range x.sessions[ref] {
+		old[id] = x.docs[id]
+		delete(x.docs, id)
+		x.index.Remove(id)
+	}
+
+	docs := make([]string, 0, len(s.Messages))
+	for i, msg := range s.Messages {
+		if !searchable(msg.Role, msg.Content) {
+			continue
+		}
+		id := docID(ref, ids[i])
+		at := s.Updated
+		if prev, ok := old[id]; ok {
+			at = prev.time
+		}
+		x.docs[id] = indexedMessage{sessionRef: ref, id: ids[i], pos: i, role: msg.Role, time: at}
+		x.index.Add(id, msg.Content)
+		docs = append(docs, id)
+	}
+	x.sessions[ref] = docs
+}
+
+// indexMessage adds the message at pos of s, indexing the whole session if
+// it has not been yet.
+func (x *SearchIndex) indexMessage(agentID string, s *Session, pos int) {
+	ref := sessionRef{agentID: agentID, key: s.Key}
+	x.mu.Lock()
+	_, known := x.sessions[ref]
+	x.mu.Unlock()
+	if !known {
+		x.indexSession(agentID, s)
+		return
+	}
+
+	msg := s.Messages[pos]
+	if !searchable(msg.Role, msg.Content) || pos >= len(s.MessageIDs) {
+		return
+	}
+	id := docID(ref, s.MessageIDs[pos])
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.docs[id] = indexedMessage{sessionRef: ref, id: s.MessageIDs[pos], pos: pos, role: msg.Role, time: time.Now()}
+	x.index.Add(id, msg.Content)
+	x.sessions[ref] = append(x.sessions[ref], id)
+}
+
+// removeSession drops the messages of a deleted session.
+func (x *SearchIndex) removeSession(agentID, key string) {
+	ref := sessionRef{agentID: agentID, key: key}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for _, id := range x.sessions[ref] {
+		delete(x.docs, id)
+		x.index.Remove(id)
+	}
+	delete(x.sessions, ref)
+}
+
+// build indexes the stored sessions that have not been indexed yet.
+func (x *SearchIndex) build() {
+	x.mu.Lock()
+	managers := make(map[string]*SessionManager, len(x.managers))
+	for agentID, sm := range x.managers {
+		managers[agentID] = sm
+	}
+	x.mu.Unlock()
+
+	for agentID, sm := range managers {
+		for _, info := range sm.List() {
+			x.buildSession(agentID, sm, info.Key)
+		}
+	}
+}
+
+func (x *SearchIndex) buildSession(agentID string, sm *SessionManager, key string) {
+	ref := sessionRef{agentID: agentID, key: key}
+	x.mu.Lock()
+	_, known := x.sessions[ref]
+	x.mu.Unlock()
+	if known {
+		return
+	}
+
+	var stored *Session
+	if sm.store != nil {
+		sm.mu.Lock()
+		_, cached := sm.sessions[key]
+		sm.mu.Unlock()
+		if !cached {
+			var err error
+			if stored, err = sm.store.Load(key); err != nil {
+				logger.WarnCF("session", "Failed to index session", map[string]any{
+					"session_key": key,
+					"error":       err.Error(),
+				})
+				return
+			}
+		}
+	}
+
+	// Index under the manager's lock so concurrent appends are not lost.
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if c, ok := sm.sessions[key]; ok {
+		stored = c.Session
+	}
+	x.mu.Lock()
+	_, known = x.sessions[ref]
+	x.mu.Unlock()
+	if stored != nil && !known {
+		x.indexSession(agentID, stored)
+	}
+}
+
+// Search returns the messages best matching query.
+func (x *SearchIndex) Search(ctx context.Context, query string, opts SearchOptions) ([]SearchHit, error) {
+	x.built.Do(x.build)
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+
+	x.mu.Lock()
+	var matches []indexedMessage
+	var scores []float64
+	for _, hit := range x.index.Search(query, limit, func(id string) bool {
+		doc, ok := x.docs[id]
+		return ok && (opts.Allow == nil || opts.Allow(doc.agentID, doc.key))
+	}) {
+		matches = append(matches, x.docs[hit.ID])
+		scores = append(scores, hit.Score)
+	}
+	managers := make(map[string]*SessionManager, len(x.managers))
+	for agentID, sm := range x.managers {
+		managers[agentID] = sm
+	}
+	x.mu.Unlock()
+
+	hits := make([]SearchHit, 0, len(matches))
+	for i, m := range matches {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		sm := managers[m.agentID]
+		if sm == nil {
+			continue
+		}
+		content, ok := sm.messageContent(m.key, m.id, m.pos)
+		if !ok {
+			continue
+		}
+		hits = append(hits, SearchHit{
+			AgentID:    m.agentID,
+			SessionKey: m.key,
+			MessageID:  m.id,
+			Role:       m.role,
+			Snippet:    search.Snippet(content, query, snippetWidth),
+			Time:       m.time,
+			Score:      scores[i],
+		})
+	}
+	return hits, nil
+}
+
+// messageContent returns the content of the active message id, expected at
+// position pos.
+func (sm *SessionManager) messageContent(key, id string, pos int) (string, bool) {
+	page, _, ok := sm.GetPage(key, pos, 1)
+	if ok && len(page.Messages) == 1 && (len(page.MessageIDs) == 0 || page.MessageIDs[0] == id) {
+		return page.Messages[0].Content, true
+	}
+	full, ok := sm.Get(key)
+	if !ok {
+		return "", false
+	}
+	if i := slices.Index(full.MessageIDs, id); i >= 0 {
+		return full.Messages[i].Content, true
+	}
+	return "", false
+}
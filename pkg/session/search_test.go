@@ -0,0 +1,99 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+)
+
+func searchKeys(t *testing.T, x *SearchIndex, query string, opts SearchOptions) []string {
+	t.Helper()
+	hits, err := x.Search(context.Background(), query, opts)
+	if err != nil {
+		t.Fatalf("Search(%q): %v", query, err)
+	}
+	keys := make([]string, len(hits))
+	for i, h := range hits {
+		keys[i] = h.SessionKey + "#" + h.MessageID
+	}
+	return keys
+}
+
+func TestSearchIndex_BuildsFromStoreAndFollowsAppends(t *testing.T) {
+	dir := t.TempDir()
+	old := NewSessionManager(dir)
+	old.AddMessage("telegram:1", "user", "what did we decide about the kubernetes migration?")
+	old.AddMessage("telegram:1", "assistant", "We postponed the kubernetes migration to Q3.")
+	old.AddFullMessage("telegram:1", providers.Message{Role: "tool", Content: "kubernetes tool output"})
+	if err := old.Save("telegram:1"); err != nil {
+		t.Fatal(err)
+	}
+
+	sm := NewSessionManager(dir)
+	x := NewSearchIndex()
+	x.Attach("main", sm)
+
+	keys := searchKeys(t, x, "kubernetes", SearchOptions{})
+	if len(keys) != 2 {
+		t.Fatalf("stored session hits = %v, want the user and assistant messages", keys)
+	}
+
+	sm.AddMessage("slack:2", "user", "kubernetes kubernetes budget")
+	hits, _ := x.Search(context.Background(), "kubernetes", SearchOptions{Limit: 1})
+	if len(hits) != 1 || hits[0].SessionKey != "slack:2" || hits[0].AgentID != "main" {
+		t.Fatalf("appended message not ranked first: %+v", hits)
+	}
+	if hits[0].Snippet != "kubernetes kubernetes budget" || time.Since(hits[0].Time) > time.Minute {
+		t.Errorf("hit = %+v", hits[0])
+	}
+
+	only := SearchOptions{Allow: func(_, key string) bool { return key == "telegram:1" }}
+	for _, k := range searchKeys(t, x, "kubernetes", only) {
+		if k[:10] != "telegram:1" {
+			t.Errorf("Allow not applied: %v", k)
+		}
+	}
+}
+
+func TestSearchIndex_FollowsHistoryChanges(t *testing.T) {
+	store, err := OpenStore(config.SessionStoreBolt, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm := NewSessionManagerWithStore(store)
+	defer sm.Close()
+	x := NewSearchIndex()
+	x.Attach("main", sm)
+
+	sm.AddMessage("k", "user", "first apple")
+	sm.AddMessage("k", "assistant", "second banana")
+	sm.AddMessage("k", "user", "third cherry")
+
+	if _, err := sm.Rewind("k", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if keys := searchKeys(t, x, "banana cherry", SearchOptions{}); len(keys) != 0 {
+		t.Errorf("rewound messages still found: %v", keys)
+	}
+	if keys := searchKeys(t, x, "apple", SearchOptions{}); len(keys) != 1 || keys[0] != "k#1" {
+		t.Errorf("kept message = %v", keys)
+	}
+
+	if err := sm.Fork("k", "k2", ""); err != nil {
+		t.Fatal(err)
+	}
+	if keys := searchKeys(t, x, "apple", SearchOptions{}); len(keys) != 2 {
+		t.Errorf("fork not indexed: %v", keys)
+	}
+
+	sm.TruncateHistory("k", 0)
+	if _, err := sm.Delete("k2"); err != nil {
+		t.Fatal(err)
+	}
+	if keys := searchKeys(t, x, "apple", SearchOptions{}); len(keys) != 0 {
+		t.Errorf("truncated or deleted messages still found: %v", keys)
+	}
+}
@@ -0,0 +1,100 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+)
+
+// ErrNotFound is returned by a SessionStore for a key it does not hold.
+var ErrNotFound = errors.New("session not found")
+
+// BoltFile is the database file of the bolt store inside a sessions directory.
+const BoltFile = "sessions.db"
+
+// SessionStore persists sessions for a SessionManager. Implementations must
+// be safe for concurrent use.
+type SessionStore interface {
+	// Load returns the stored session, or ErrNotFound.
+	Load(key string) (*Session, error)
+	// Info describes the stored session without loading its messages, or
+	// returns ErrNotFound.
+	Info(key string) (SessionInfo, error)
+	// Save replaces the stored session with s.
+	Save(s *Session) error
+	// Append persists s given that its first from messages are already
+	// stored. Stores that cannot append rewrite the whole session.
+	Append(s *Session, from int) error
+	// Page returns the session holding only up to limit of its messages,
+	// starting at offset, and its total number of messages. A limit <= 0
+	// returns all of them.
+	Page(key string, offset, limit int) (*Session, int, error)
+	// List describes up to limit stored sessions, most recently updated
+	// first. A limit <= 0 lists all of them.
+	List(limit int) ([]SessionInfo, error)
+	// Delete removes a session. Deleting a missing session is not an error.
+	Delete(key string) error
+	Close() error
+}
+
+// OpenStore opens the session store of the given backend in dir. An empty
+// backend means the JSON directory store.
+func OpenStore(backend, dir string) (SessionStore, error) {
+	switch backend {
+	case "", config.SessionStoreJSON:
+		return OpenJSONStore(dir)
+	case config.SessionStoreBolt:
+		return OpenBoltStore(filepath.Join(dir, BoltFile))
+	default:
+		return nil, fmt.Errorf("unknown session store %q", backend)
+	}
+}
+
+// Migrate copies every session in src to dst and returns how many were
+// copied. Sessions already in dst with the same key are replaced.
+func Migrate(src, dst SessionStore) (int, error) {
+	infos, err := src.List(0)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, info := range infos {
+		s, err := src.Load(info.Key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return n, fmt.Errorf("load %s: %w", info.Key, err)
+		}
+		if err := dst.Save(s); err != nil {
+			return n, fmt.Errorf("save %s: %w", info.Key, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+func infoOf(s *Session) SessionInfo {
+	return SessionInfo{
+		Key:          s.Key,
+		Channel:      s.Channel,
+		MessageCount: len(s.Messages),
+		HasSummary:   s.Summary != "",
+		Created:      s.Created,
+		Updated:      s.Updated,
+	}
+}
+
+// pageOf returns a copy of items[offset:offset+limit], clamped.
+func pageOf[T any](items []T, offset, limit int) []T {
+	start := min(max(offset, 0), len(items))
+	end := len(items)
+	if limit > 0 {
+		end = min(start+limit, end)
+	}
+	page := make([]T, end-start)
+	copy(page, items[start:end])
+	return page
+}
@@ -0,0 +1,223 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+)
+
+func openStores(t *testing.T) map[string]SessionStore {
+	t.Helper()
+	stores := make(map[string]SessionStore)
+	for _, backend := range []string{config.SessionStoreJSON, config.SessionStoreBolt} {
+		store, err := OpenStore(backend, t.TempDir())
+		if err != nil {
+			t.Fatalf("OpenStore(%s): %v", backend, err)
+		}
+		t.Cleanup(func() { store.Close() })
+		stores[backend] = store
+	}
+	return stores
+}
+
+func testSession(key string, n int, updated time.Time) *Session {
+	s := &Session{Key: key, Channel: "cli", Created: updated, Updated: updated}
+	for i := range n {
+		s.Messages = append(s.Messages, providers.Message{Role: "user", Content: fmt.Sprintf("m%d", i)})
+	}
+	return s
+}
+
+func TestStores_SaveAppendPage(t *testing.T) {
+	for backend, store := range openStores(t) {
+		t.Run(backend, func(t *testing.T) {
+			now := time.Now()
+			s := testSession("telegram:1", 3, now)
+			if err := store.Save(s); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+
+			s.Messages = append(s.Messages, providers.Message{Role: "assistant", Content: "m3"})
+			s.Summary = "summary"
+			if err := store.Append(s, 3); err != nil {
+				t.Fatalf("Append: %v", err)
+			}
+
+			page, total, err := store.Page("telegram:1", 1, 2)
+			if err != nil {
+				t.Fatalf("Page: %v", err)
+			}
+			if total != 4 || len(page.Messages) != 2 || page.Messages[0].Content != "m1" || page.Messages[1].Content != "m2" {
+				t.Errorf("Page = %d %+v", total, page.Messages)
+			}
+			if page.Summary != "summary" || page.Channel != "cli" {
+				t.Errorf("Page header = %+v", page)
+			}
+
+			// A stale append offset must not duplicate messages.
+			s.Messages = s.Messages[:2]
+			if err := store.Append(s, 1); err != nil {
+				t.Fatalf("Append: %v", err)
+			}
+			loaded, err := store.Load("telegram:1")
+			if err != nil || len(loaded.Messages) != 2 {
+				t.Fatalf("Load after rewrite = %+v, %v", loaded, err)
+			}
+
+			info, err := store.Info("telegram:1")
+			if err != nil || info.MessageCount != 2 || !info.HasSummary {
+				t.Errorf("Info = %+v, %v", info, err)
+			}
+			if _, err := store.Load("missing"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Load(missing) err = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestStores_ListByUpdated(t *testing.T) {
+	for backend, store := range openStores(t) {
+		t.Run(backend, func(t *testing.T) {
+			base := time.Now()
+			for i, key := range []string{"a", "b", "c"} {
+				if err := store.Save(testSession(key, 1, base.Add(time.Duration(i)*time.Second))); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// Touching "a" moves it to the front.
+			if err := store.Append(testSession("a", 2, base.Add(time.Minute)), 1); err != nil {
+				t.Fatal(err)
+			}
+
+			infos, err := store.List(2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(infos) != 2 || infos[0].Key != "a" || infos[1].Key != "c" {
+				t.Fatalf("List(2) = %+v", infos)
+			}
+
+			if err := store.Delete("a"); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Delete("a"); err != nil {
+				t.Errorf("deleting a missing session: %v", err)
+			}
+			infos, _ = store.List(0)
+			if len(infos) != 2 || infos[0].Key != "c" {
+				t.Errorf("List after Delete = %+v", infos)
+			}
+		})
+	}
+}
+
+func TestBoltStore_ReopenAndShare(t *testing.T) {
+	path := filepath.Join(t.TempDir(), BoltFile)
+	first, err := OpenBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := OpenBoltStore(path)
+	if err != nil {
+		t.Fatalf("second open in the same process: %v", err)
+	}
+	if err := first.Save(testSession("k", 2, time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	first.Close()
+	if _, err := second.Load("k"); err != nil {
+		t.Fatalf("Load through shared handle: %v", err)
+	}
+	second.Close()
+
+	reopened, err := OpenBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	sess, err := reopened.Load("k")
+	if err != nil || len(sess.Messages) != 2 {
+		t.Fatalf("Load after reopen = %+v, %v", sess, err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	dir := t.TempDir()
+	src, err := OpenJSONStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"telegram:1", "api:2"} {
+		if err := src.Save(testSession(key, 3, time.Now())); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dst, err := OpenStore(config.SessionStoreBolt, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	n, err := Migrate(src, dst)
+	if err != nil || n != 2 {
+		t.Fatalf("Migrate = %d, %v", n, err)
+	}
+	infos, _ := dst.List(0)
+	if len(infos) != 2 {
+		t.Fatalf("migrated sessions = %+v", infos)
+	}
+	if _, err := OpenStore("sqlite", dir); err == nil {
+		t.Error("OpenStore should reject unknown backends")
+	}
+}
+
+func TestSessionManager_BoltAppendsAndEvicts(t *testing.T) {
+	store, err := OpenStore(config.SessionStoreBolt, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm := NewSessionManagerWithStore(store)
+	defer sm.Close()
+
+	for i := range maxCachedSessions + 10 {
+		key := fmt.Sprintf("s%d", i)
+		sm.AddMessage(key, "user", "hello")
+		if err := sm.Save(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sm.mu.Lock()
+	cached := len(sm.sessions)
+	sm.mu.Unlock()
+	if cached > maxCachedSessions {
+		t.Errorf("cached %d sessions, want at most %d", cached, maxCachedSessions)
+	}
+	if got := len(sm.List()); got != maxCachedSessions+10 {
+		t.Errorf("List returned %d sessions", got)
+	}
+
+	// An evicted session is reloaded on use and appended to.
+	sm.AddMessage("s0", "assistant", "again")
+	if err := sm.Save("s0"); err != nil {
+		t.Fatal(err)
+	}
+	page, total, ok := sm.GetPage("s0", 1, 10)
+	if !ok || total != 2 || len(page.Messages) != 1 || page.Messages[0].Content != "again" {
+		t.Errorf("GetPage = %+v, %d, %v", page.Messages, total, ok)
+	}
+
+	// Truncation rewrites the stored history.
+	sm.TruncateHistory("s0", 1)
+	if err := sm.Save("s0"); err != nil {
+		t.Fatal(err)
+	}
+	sess, err := store.Load("s0")
+	if err != nil || len(sess.Messages) != 1 || sess.Messages[0].Content != "again" {
+		t.Errorf("stored after truncate = %+v, %v", sess, err)
+	}
+}
@@ -0,0 +1,377 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+)
+
+// ErrExists is returned when forking into a session key already in use.
+var ErrExists = errors.New("session already exists")
+
+// ErrMessageNotFound is returned for a message ID that is not in the
+// session's history.
+var ErrMessageNotFound = errors.New("message not found")
+
+// Node is a message off the active branch. Its parent is either another
+// inactive node or a message of the active branch; an empty ParentID
+// means the branch starts at the beginning of the conversation.
+type Node struct {
+	ID       string            `json:"id"`
+	ParentID string            `json:"parent_id,omitempty"`
+	Message  providers.Message `json:"message"`
+}
+
+// Branch describes one path from the start of a session to a leaf.
+type Branch struct {
+	LeafID string `json:"leaf_id"`
+	// ForkID is the last message the branch shares with the active branch;
+	// empty when the branch diverges at the start.
+	ForkID   string `json:"fork_id,omitempty"`
+	Messages int    `json:"messages"`
+	Active   bool   `json:"active"`
+	// Preview is the start of the leaf's content.
+	Preview string `json:"preview"`
+}
+
+// nextID allocates a message ID.
+func (s *Session) nextID() string {
+	s.LastID++
+	return strconv.Itoa(s.LastID)
+}
+
+// ensureIDs gives every active message an ID, numbering sessions stored
+// before message IDs existed.
+func (s *Session) ensureIDs() {
+	if len(s.MessageIDs) == len(s.Messages) {
+		return
+	}
+	s.MessageIDs = make([]string, len(s.Messages))
+	for i := range s.Messages {
+		s.MessageIDs[i] = s.nextID()
+	}
+	s.pruneOrphans()
+}
+
+// appendMessage adds msg to the active branch.
+func (s *Session) appendMessage(msg providers.Message) {
+	s.Messages = append(s.Messages, msg)
+	s.MessageIDs = append(s.MessageIDs, s.nextID())
+}
+
+func sameMessage(a, b providers.Message) bool {
+	return a.Role == b.Role && a.Content == b.Content && a.ToolCallID == b.ToolCallID &&
+		len(a.ToolCalls) == len(b.ToolCalls)
+}
+
+// replaceHistory sets the active branch to history. Messages kept at the
+// end of the old history keep their IDs, so branches forking from them
+// survive compaction.
+func (s *Session) replaceHistory(history []providers.Message) {
+	ids := make([]string, len(history))
+	i, j := len(s.Messages)-1, len(history)-1
+	for ; i >= 0 && j >= 0 && sameMessage(s.Messages[i], history[j]); i, j = i-1, j-1 {
+		ids[j] = s.MessageIDs[i]
+	}
+	for ; j >= 0; j-- {
+		ids[j] = ""
+	}
+	for k := range ids {
+		if ids[k] == "" {
+			ids[k] = s.nextID()
+		}
+	}
+
+	msgs := make([]providers.Message, len(history))
+	copy(msgs, history)
+	s.Messages, s.MessageIDs = msgs, ids
+	s.pruneOrphans()
+}
+
+// keepLast trims the active branch to its last n messages.
+func (s *Session) keepLast(n int) {
+	n = min(max(n, 0), len(s.Messages))
+	s.Messages = slices.Clone(s.Messages[len(s.Messages)-n:])
+	s.MessageIDs = slices.Clone(s.MessageIDs[len(s.MessageIDs)-n:])
+	s.pruneOrphans()
+}
+
+// activeIndex returns the position of id on the active branch, or -1.
+func (s *Session) activeIndex(id string) int {
+	return slices.Index(s.MessageIDs, id)
+}
+
+// detach moves the active messages after index keep to Inactive.
+func (s *Session) detach(keep int) int {
+	moved := len(s.Messages) - (keep + 1)
+	for i := keep + 1; i < len(s.Messages); i++ {
+		parent := ""
+		if i > 0 {
+			parent = s.MessageIDs[i-1]
+		}
+		s.Inactive = append(s.Inactive, Node{ID: s.MessageIDs[i], ParentID: parent, Message: s.Messages[i]})
+	}
+	s.Messages = s.Messages[:keep+1]
+	s.MessageIDs = s.MessageIDs[:keep+1]
+	return moved
+}
+
+// rewind makes the message id the head of the active branch, or empties the
+// branch when id is empty. The later messages stay reachable as a branch.
+func (s *Session) rewind(id string) (int, error) {
+	keep := -1
+	if id != "" {
+		if keep = s.activeIndex(id); keep < 0 {
+			return 0, fmt.Errorf("%w: %s is not on the active branch", ErrMessageNotFound, id)
+		}
+	}
+	return s.detach(keep), nil
+}
+
+// rewindTurns rewinds to just before the turns-th last user message.
+func (s *Session) rewindTurns(turns int) (int, error) {
+	if turns <= 0 {
+		return 0, errors.New("turns must be positive")
+	}
+	for i := len(s.Messages) - 1; i >= 0; i-- {
+		if s.Messages[i].Role != "user" {
+			continue
+		}
+		if turns--; turns == 0 {
+			return s.detach(i - 1), nil
+		}
+	}
+	return 0, errors.New("not that many turns in the active branch")
+}
+
+// checkout makes the branch ending at the inactive message id active. The
+// current branch's messages past the fork point become inactive.
+func (s *Session) checkout(id string) error {
+	nodes := make(map[string]int, len(s.Inactive))
+	for i, n := range s.Inactive {
+		nodes[n.ID] = i
+	}
+
+	var path []Node
+	fork := -1
+	for cur := id; ; {
+		i, ok := nodes[cur]
+		if !ok {
+			if s.activeIndex(id) >= 0 {
+				return fmt.Errorf("%s is already on the active branch", id)
+			}
+			return fmt.Errorf("%w: %s", ErrMessageNotFound, cur)
+		}
+		n := s.Inactive[i]
+		path = append(path, n)
+		if n.ParentID == "" {
+			break
+		}
+		if fork = s.activeIndex(n.ParentID); fork >= 0 {
+			break
+		}
+		cur = n.ParentID
+	}
+	slices.Reverse(path)
+
+	s.detach(fork)
+	onPath := make(map[string]bool, len(path))
+	for _, n := range path {
+		onPath[n.ID] = true
+		s.Messages = append(s.Messages, n.Message)
+		s.MessageIDs = append(s.MessageIDs, n.ID)
+	}
+	s.Inactive = slices.DeleteFunc(s.Inactive, func(n Node) bool { return onPath[n.ID] })
+	return nil
+}
+
+// branches lists the active branch first, then every inactive leaf.
+func (s *Session) branches() []Branch {
+	var out []Branch
+	if n := len(s.Messages); n > 0 {
+		out = append(out, Branch{
+			LeafID:   s.MessageIDs[n-1],
+			Messages: n,
+			Active:   true,
+			Preview:  preview(s.Messages[n-1].Content),
+		})
+	}
+
+	nodes := make(map[string]Node, len(s.Inactive))
+	isParent := make(map[string]bool, len(s.Inactive))
+	for _, n := range s.Inactive {
+		nodes[n.ID] = n
+		isParent[n.ParentID] = true
+	}
+	for _, leaf := range s.Inactive {
+		if isParent[leaf.ID] {
+			continue
+		}
+		b := Branch{LeafID: leaf.ID, Preview: preview(leaf.Message.Content)}
+		for cur := leaf; ; {
+			b.Messages++
+			if cur.ParentID == "" {
+				break
+			}
+			if i := s.activeIndex(cur.ParentID); i >= 0 {
+				b.ForkID = cur.ParentID
+				b.Messages += i + 1
+				break
+			}
+			cur = nodes[cur.ParentID]
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// prefix returns the active branch up to and including id, or all of it
+// when id is empty.
+func (s *Session) prefix(id string) ([]providers.Message, []string, error) {
+	end := len(s.Messages)
+	if id != "" {
+		i := s.activeIndex(id)
+		if i < 0 {
+			return nil, nil, fmt.Errorf("%w: %s is not on the active branch", ErrMessageNotFound, id)
+		}
+		end = i + 1
+	}
+	return slices.Clone(s.Messages[:end]), slices.Clone(s.MessageIDs[:end]), nil
+}
+
+// pruneOrphans drops inactive messages whose fork point is no longer in the
+// history, e.g. after compaction removed it.
+func (s *Session) pruneOrphans() {
+	defer s.prunePins()
+	if len(s.Inactive) == 0 {
+		return
+	}
+	parents := make(map[string]string, len(s.Inactive))
+	for _, n := range s.Inactive {
+		parents[n.ID] = n.ParentID
+	}
+	rooted := make(map[string]bool, len(s.Inactive))
+	var isRooted func(id string, depth int) bool
+	isRooted = func(id string, depth int) bool {
+		if id == "" || s.activeIndex(id) >= 0 {
+			return true
+		}
+		if ok, seen := rooted[id]; seen {
+			return ok
+		}
+		parent, ok := parents[id]
+		ok = ok && depth < len(s.Inactive) && isRooted(parent, depth+1)
+		rooted[id] = ok
+		return ok
+	}
+	s.Inactive = slices.DeleteFunc(s.Inactive, func(n Node) bool { return !isRooted(n.ID, 0) })
+	if len(s.Inactive) == 0 {
+		s.Inactive = nil
+	}
+}
+
+// prunePins drops the pins of messages no longer in the session.
+func (s *Session) prunePins() {
+	s.Pinned = slices.DeleteFunc(s.Pinned, func(id string) bool {
+		return s.activeIndex(id) < 0 && !slices.ContainsFunc(s.Inactive, func(n Node) bool { return n.ID == id })
+	})
+	if len(s.Pinned) == 0 {
+		s.Pinned = nil
+	}
+}
+
+func preview(content string) string {
+	const maxRunes = 60
+	r := []rune(content)
+	if len(r) <= maxRunes {
+		return content
+	}
+	return string(r[:maxRunes]) + "…"
+}
+
+// Branches lists the branches of a session, the active one first.
+func (sm *SessionManager) Branches(key string) ([]Branch, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	c := sm.lookup(key, false)
+	if c == nil {
+		return nil, ErrNotFound
+	}
+	return c.branches(), nil
+}
+
+// Rewind makes messageID the last message of the active branch; an empty
+// messageID rewinds to the start. The messages after it stay in the
+// session as an inactive branch. It returns how many messages were moved.
+func (sm *SessionManager) Rewind(key, messageID string) (int, error) {
+	return sm.editBranch(key, func(s *Session) (int, error) { return s.rewind(messageID) })
+}
+
+// RewindTurns rewinds the active branch to just before its turns-th last
+// user message, so the conversation can continue from there.
+func (sm *SessionManager) RewindTurns(key string, turns int) (int, error) {
+	return sm.editBranch(key, func(s *Session) (int, error) { return s.rewindTurns(turns) })
+}
+
+// Checkout makes the inactive branch ending at messageID the active one.
+func (sm *SessionManager) Checkout(key, messageID string) error {
+	_, err := sm.editBranch(key, func(s *Session) (int, error) { return 0, s.checkout(messageID) })
+	return err
+}
+
+func (sm *SessionManager) editBranch(key string, edit func(*Session) (int, error)) (int, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	c := sm.lookup(key, false)
+	if c == nil {
+		return 0, ErrNotFound
+	}
+	n, err := edit(c.Session)
+	if err != nil {
+		return 0, err
+	}
+	c.Updated = time.Now()
+	c.rewrite, c.dirty = true, true
+	sm.reindex(c.Session)
+	return n, nil
+}
+
+// Fork copies the active branch of a session up to and including messageID
+// (all of it when messageID is empty) into a new session newKey.
+func (sm *SessionManager) Fork(key, newKey, messageID string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	src := sm.lookup(key, false)
+	if src == nil {
+		return ErrNotFound
+	}
+	if newKey == "" || newKey == key {
+		return errors.New("fork needs a new session key")
+	}
+	if sm.lookup(newKey, false) != nil {
+		return fmt.Errorf("%w: %s", ErrExists, newKey)
+	}
+	msgs, ids, err := src.prefix(messageID)
+	if err != nil {
+		return err
+	}
+
+	dst := sm.lookup(newKey, true)
+	dst.Channel = src.Channel
+	dst.Summary = src.Summary
+	dst.Messages, dst.MessageIDs, dst.LastID = msgs, ids, src.LastID
+	for _, id := range src.Pinned {
+		if slices.Contains(ids, id) {
+			dst.Pinned = append(dst.Pinned, id)
+		}
+	}
+	sm.reindex(dst.Session)
+	return nil
+}
@@ -0,0 +1,197 @@
+package session
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+)
+
+func contents(msgs []providers.Message) []string {
+	out := make([]string, len(msgs))
+	for i, m := range msgs {
+		out[i] = m.Content
+	}
+	return out
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRewindCheckoutBranches(t *testing.T) {
+	sm := NewSessionManager("")
+	for _, m := range []struct{ role, content string }{
+		{"user", "q1"}, {"assistant", "a1"}, {"user", "q2"}, {"assistant", "a2"},
+	} {
+		sm.AddMessage("k", m.role, m.content)
+	}
+
+	moved, err := sm.RewindTurns("k", 1)
+	if err != nil || moved != 2 {
+		t.Fatalf("RewindTurns = %d, %v", moved, err)
+	}
+	sm.AddMessage("k", "user", "q2'")
+	sm.AddMessage("k", "assistant", "a2'")
+	if got := contents(sm.GetHistory("k")); !equal(got, []string{"q1", "a1", "q2'", "a2'"}) {
+		t.Fatalf("active branch = %v", got)
+	}
+
+	branches, err := sm.Branches("k")
+	if err != nil || len(branches) != 2 {
+		t.Fatalf("Branches = %+v, %v", branches, err)
+	}
+	if !branches[0].Active || branches[0].LeafID != "6" {
+		t.Errorf("active branch = %+v", branches[0])
+	}
+	if branches[1].LeafID != "4" || branches[1].ForkID != "2" || branches[1].Messages != 4 || branches[1].Preview != "a2" {
+		t.Errorf("inactive branch = %+v", branches[1])
+	}
+
+	if err := sm.Checkout("k", "4"); err != nil {
+		t.Fatalf("Checkout: %v", err)
+	}
+	if got := contents(sm.GetHistory("k")); !equal(got, []string{"q1", "a1", "q2", "a2"}) {
+		t.Fatalf("after checkout = %v", got)
+	}
+	if err := sm.Checkout("k", "4"); err == nil {
+		t.Error("checking out the active branch should fail")
+	}
+	if _, err := sm.Rewind("k", "99"); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("Rewind to unknown message: %v", err)
+	}
+
+	// Rewinding to the start keeps the whole thread as a branch.
+	if moved, _ := sm.Rewind("k", ""); moved != 4 || len(sm.GetHistory("k")) != 0 {
+		t.Errorf("Rewind to start moved %d", moved)
+	}
+	if err := sm.Checkout("k", "6"); err != nil {
+		t.Fatalf("Checkout across the start: %v", err)
+	}
+	if got := contents(sm.GetHistory("k")); !equal(got, []string{"q1", "a1", "q2'", "a2'"}) {
+		t.Errorf("after checkout from start = %v", got)
+	}
+}
+
+func TestFork(t *testing.T) {
+	sm := NewSessionManager("")
+	sm.AddMessage("k", "user", "q1")
+	sm.AddMessage("k", "assistant", "a1")
+	sm.AddMessage("k", "user", "q2")
+
+	if err := sm.Fork("k", "k2", "2"); err != nil {
+		t.Fatalf("Fork: %v", err)
+	}
+	if got := contents(sm.GetHistory("k2")); !equal(got, []string{"q1", "a1"}) {
+		t.Errorf("forked history = %v", got)
+	}
+	sm.AddMessage("k2", "user", "other")
+	if got := contents(sm.GetHistory("k")); len(got) != 3 {
+		t.Errorf("source changed by fork: %v", got)
+	}
+	if err := sm.Fork("k", "k2", ""); !errors.Is(err, ErrExists) {
+		t.Errorf("Fork into existing key: %v", err)
+	}
+}
+
+func TestCompactionKeepsBranchesOnKeptMessages(t *testing.T) {
+	sm := NewSessionManager("")
+	for _, c := range []string{"q1", "a1", "q2", "a2", "q3", "a3"} {
+		role := "user"
+		if c[0] == 'a' {
+			role = "assistant"
+		}
+		sm.AddMessage("k", role, c)
+	}
+	sm.Rewind("k", "4") // q3, a3 become a branch forked after a2
+	sm.Rewind("k", "2") // q2, a2 become a branch forked after a1
+	sm.Checkout("k", "4")
+	sm.Checkout("k", "6")
+
+	// Compaction drops the oldest messages but keeps the tail.
+	history := sm.GetHistory("k")
+	sm.SetHistory("k", history[2:])
+	branches, _ := sm.Branches("k")
+	if len(branches) != 1 {
+		t.Errorf("branches after compaction = %+v", branches)
+	}
+	if ids := sm.sessions["k"].MessageIDs; ids[len(ids)-1] != "6" {
+		t.Errorf("kept messages lost their IDs: %v", ids)
+	}
+
+	sm.Rewind("k", "4")
+	sm.TruncateHistory("k", 1)
+	if branches, _ := sm.Branches("k"); len(branches) != 2 {
+		t.Errorf("branch forked from a kept message was pruned: %+v", branches)
+	}
+	sm.TruncateHistory("k", 0)
+	if branches, _ := sm.Branches("k"); len(branches) != 0 {
+		t.Errorf("orphaned branches kept: %+v", branches)
+	}
+}
+
+func TestBranchesPersist(t *testing.T) {
+	for _, backend := range []string{config.SessionStoreJSON, config.SessionStoreBolt} {
+		t.Run(backend, func(t *testing.T) {
+			dir := t.TempDir()
+			store, err := OpenStore(backend, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sm := NewSessionManagerWithStore(store)
+			sm.AddMessage("k", "user", "q1")
+			sm.AddMessage("k", "assistant", "a1")
+			sm.Rewind("k", "1")
+			sm.AddMessage("k", "assistant", "a1'")
+			if err := sm.Save("k"); err != nil {
+				t.Fatal(err)
+			}
+			sm.Close()
+
+			store, err = OpenStore(backend, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sm = NewSessionManagerWithStore(store)
+			defer sm.Close()
+			branches, err := sm.Branches("k")
+			if err != nil || len(branches) != 2 {
+				t.Fatalf("Branches after reload = %+v, %v", branches, err)
+			}
+			if err := sm.Checkout("k", "2"); err != nil {
+				t.Fatal(err)
+			}
+			sm.AddMessage("k", "user", "q2")
+			if ids := sm.sessions["k"].MessageIDs; !equal(ids, []string{"1", "2", "4"}) {
+				t.Errorf("IDs after reload = %v", ids)
+			}
+		})
+	}
+}
+
+func TestLegacySessionGetsIDs(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := OpenJSONStore(dir)
+	legacy := testSession("old", 3, time.Now())
+	if err := store.Save(legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	sm := NewSessionManagerWithStore(store)
+	if _, err := sm.Rewind("old", "2"); err != nil {
+		t.Fatalf("Rewind on a legacy session: %v", err)
+	}
+	if got := len(sm.GetHistory("old")); got != 2 {
+		t.Errorf("history after rewind = %d messages", got)
+	}
+}
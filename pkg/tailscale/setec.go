@@ -25,6 +25,13 @@ type SetecClient struct {
 	mu     sync.RWMutex
 }
 
+// SetecAvailable reports whether SetecClient can reach a Setec server. It
+// is false until the tailscale.com/setec client is linked in; until then
+// Get and Put always fail.
+func SetecAvailable() bool {
+	return false
+}
+
 // NewSetecClient creates a new Setec client.
 func NewSetecClient(cfg SetecConfig) *SetecClient {
 	if cfg.Prefix == "" {
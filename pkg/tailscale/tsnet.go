@@ -109,6 +109,34 @@ func (s *Server) HTTPClient() *http.Client {
 	return &http.Client{}
 }
 
+// ErrWhoIsUnavailable is returned by WhoIs until tsnet is linked in.
+var ErrWhoIsUnavailable = errors.New("tsnet WhoIs requires the tailscale.com/tsnet dependency")
+
+// WhoIsAvailable reports whether WhoIs can identify tailnet peers. It is
+// false until tsnet is linked in, so configurations that rely on tailnet
+// identities can be refused instead of rejecting every request.
+func WhoIsAvailable() bool {
+	return false
+}
+
+// WhoIs returns the tailnet login name of the peer at remoteAddr.
+// When tsnet is fully integrated, this uses the tsnet LocalClient's WhoIs.
+// Currently it always returns ErrWhoIsUnavailable.
+func (s *Server) WhoIs(_ context.Context, remoteAddr string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.running {
+		return "", errors.New("tsnet server not running")
+	}
+	// Placeholder: when tsnet is linked, this would be:
+	//   lc, _ := srv.LocalClient()
+	//   who, err := lc.WhoIs(ctx, remoteAddr)
+	//   return who.UserProfile.LoginName, err
+	_ = remoteAddr
+	return "", ErrWhoIsUnavailable
+}
+
 // IsRunning returns whether the tsnet server is active.
 func (s *Server) IsRunning() bool {
 	s.mu.Lock()
@@ -0,0 +1,88 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// AskAgentFunc runs a message through an agent and returns its reply.
+// It matches the signature of AgentLoop.ProcessDirectWithChannel.
+type AskAgentFunc func(ctx context.Context, content, sessionKey, channel, chatID string) (string, error)
+
+// AskAgentTool lets an external caller (e.g. an MCP client) send a message
+// to a configured agent and receive the agent's final response, including
+// any tool use and memory the agent applies along the way.
+type AskAgentTool struct {
+	agentID string
+	ask     AskAgentFunc
+	channel string
+	chatID  string
+}
+
+// NewAskAgentTool creates an ask_agent tool bound to agentID.
+func NewAskAgentTool(agentID string, ask AskAgentFunc) *AskAgentTool {
+	return &AskAgentTool{
+		agentID: agentID,
+		ask:     ask,
+		channel: "mcp",
+		chatID:  "mcp",
+	}
+}
+
+func (t *AskAgentTool) Name() string {
+	return "ask_agent"
+}
+
+func (t *AskAgentTool) Description() string {
+	return fmt.Sprintf(
+		"Ask the TinyClaw agent %q a question. The agent can use its own tools and conversation memory before answering.",
+		t.agentID,
+	)
+}
+
+func (t *AskAgentTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"message": map[string]any{
+				"type":        "string",
+				"description": "The message to send to the agent",
+			},
+			"session": map[string]any{
+				"type":        "string",
+				"description": "Optional: conversation name to continue (default: mcp)",
+			},
+		},
+		"required": []string{"message"},
+	}
+}
+
+func (t *AskAgentTool) SetContext(channel, chatID string) {
+	t.channel = channel
+	t.chatID = chatID
+}
+
+func (t *AskAgentTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	message, _ := args["message"].(string)
+	if strings.TrimSpace(message) == "" {
+		return ErrorResult("message is required")
+	}
+	if t.ask == nil {
+		return ErrorResult("agent dispatch not configured")
+	}
+
+	session, _ := args["session"].(string)
+	session = strings.TrimSpace(session)
+	if session == "" {
+		session = "mcp"
+	}
+	// Agent-scoped keys select the bound agent regardless of channel bindings.
+	sessionKey := fmt.Sprintf("agent:%s:%s", t.agentID, session)
+
+	response, err := t.ask(ctx, message, sessionKey, t.channel, t.chatID)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("agent %q failed: %v", t.agentID, err)).WithError(err)
+	}
+	return NewToolResult(response)
+}
@@ -17,6 +17,14 @@ type ContextualTool interface {
 	SetContext(channel, chatID string)
 }
 
+// SenderAwareTool is an optional interface for tools that depend on who
+// sent the current message, not only where it came from. In group chats the
+// sender differs from the chat ID.
+type SenderAwareTool interface {
+	Tool
+	SetSender(senderID string)
+}
+
 // AsyncCallback is a function type that async tools use to notify completion.
 // When an async tool finishes its work, it calls this callback with the result.
 //
@@ -2,6 +2,7 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
@@ -10,6 +11,7 @@ import (
 	"github.com/tinyland-inc/tinyclaw/pkg/bus"
 	"github.com/tinyland-inc/tinyclaw/pkg/config"
 	"github.com/tinyland-inc/tinyclaw/pkg/cron"
+	"github.com/tinyland-inc/tinyclaw/pkg/routing"
 	"github.com/tinyland-inc/tinyclaw/pkg/utils"
 )
 
@@ -18,14 +20,19 @@ type JobExecutor interface {
 	ProcessDirectWithChannel(ctx context.Context, content, sessionKey, channel, chatID string) (string, error)
 }
 
+// previewRuns is how many fire times the tool shows for a schedule.
+const previewRuns = 3
+
 // CronTool provides scheduling capabilities for the agent
 type CronTool struct {
 	cronService *cron.CronService
 	executor    JobExecutor
 	msgBus      *bus.MessageBus
 	execTool    *ExecTool
+	session     config.SessionConfig
 	channel     string
 	chatID      string
+	senderID    string
 	mu          sync.RWMutex
 }
 
@@ -37,12 +44,16 @@ func NewCronTool(
 ) *CronTool {
 	execTool := NewExecToolWithConfig(workspace, restrict, config)
 	execTool.SetTimeout(execTimeout)
-	return &CronTool{
+	t := &CronTool{
 		cronService: cronService,
 		executor:    executor,
 		msgBus:      msgBus,
 		execTool:    execTool,
 	}
+	if config != nil {
+		t.session = config.Session
+	}
+	return t
 }
 
 // Name returns the tool name
@@ -52,7 +63,7 @@ func (t *CronTool) Name() string {
 
 // Description returns the tool description
 func (t *CronTool) Description() string {
-	return "Schedule reminders, tasks, or system commands. IMPORTANT: When user asks to be reminded or scheduled, you MUST call this tool. Use 'at_seconds' for one-time reminders (e.g., 'remind me in 10 minutes' → at_seconds=600). Use 'every_seconds' ONLY for recurring tasks (e.g., 'every 2 hours' → every_seconds=7200). Use 'cron_expr' for complex recurring schedules. Use 'command' to execute shell commands directly."
+	return "Schedule reminders, tasks, or system commands. IMPORTANT: When user asks to be reminded or scheduled, you MUST call this tool. Use 'at_seconds' for one-time reminders (e.g., 'remind me in 10 minutes' → at_seconds=600). Use 'every_seconds' ONLY for recurring tasks (e.g., 'every 2 hours' → every_seconds=7200). Use 'cron_expr' for complex recurring schedules, or 'schedule' for phrases like 'every weekday at 9am' or 'tomorrow 18:30'; times are in the user's timezone. Use 'preview' to check the next fire times before adding. Use 'command' to execute shell commands directly."
 }
 
 // Parameters returns the tool parameters schema
@@ -62,7 +73,7 @@ func (t *CronTool) Parameters() map[string]any {
 		"properties": map[string]any{
 			"action": map[string]any{
 				"type":        "string",
-				"enum":        []string{"add", "list", "remove", "enable", "disable"},
+				"enum":        []string{"add", "list", "remove", "enable", "disable", "history"},
 				"description": "Action to perform. Use 'add' when user wants to schedule a reminder or task.",
 			},
 			"message": map[string]any{
@@ -85,9 +96,34 @@ func (t *CronTool) Parameters() map[string]any {
 				"type":        "string",
 				"description": "Cron expression for complex recurring schedules (e.g., '0 9 * * *' for daily at 9am). Use this for complex recurring schedules.",
 			},
+			"schedule": map[string]any{
+				"type":        "string",
+				"description": "Schedule as a phrase, e.g. 'in 2 hours', 'tomorrow 18:30', 'every 15 minutes', 'every weekday at 9am', 'every monday and friday at 17:30'. Used when at_seconds, every_seconds and cron_expr are not given.",
+			},
+			"timezone": map[string]any{
+				"type":        "string",
+				"description": "Optional: IANA timezone for cron_expr and schedule (e.g. 'Europe/Berlin'). Defaults to the user's timezone.",
+			},
+			"preview": map[string]any{
+				"type":        "boolean",
+				"description": "If true with action 'add', only show the next fire times without adding the job.",
+			},
 			"job_id": map[string]any{
 				"type":        "string",
-				"description": "Job ID (for remove/enable/disable)",
+				"description": "Job ID (for remove/enable/disable/history)",
+			},
+			"max_attempts": map[string]any{
+				"type":        "integer",
+				"description": "Optional: attempts per run before the run counts as failed (default 1, no retries)",
+			},
+			"retry_backoff_seconds": map[string]any{
+				"type":        "integer",
+				"description": "Optional: seconds before the first retry, doubling for later retries (default 30)",
+			},
+			"overlap": map[string]any{
+				"type":        "string",
+				"enum":        []string{cron.OverlapSkip, cron.OverlapQueue, cron.OverlapAllow},
+				"description": "Optional: what to do when the job is due while its last run is still going (default skip)",
 			},
 			"deliver": map[string]any{
 				"type":        "boolean",
@@ -106,6 +142,14 @@ func (t *CronTool) SetContext(channel, chatID string) {
 	t.chatID = chatID
 }
 
+// SetSender sets the sender of the current message, whose timezone new
+// jobs default to.
+func (t *CronTool) SetSender(senderID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.senderID = senderID
+}
+
 // Execute runs the tool with the given arguments
 func (t *CronTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
 	action, ok := args["action"].(string)
@@ -124,6 +168,8 @@ func (t *CronTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 		return t.enableJob(args, true)
 	case "disable":
 		return t.enableJob(args, false)
+	case "history":
+		return t.jobHistory(args)
 	default:
 		return ErrorResult("unknown action: " + action)
 	}
@@ -133,6 +179,7 @@ func (t *CronTool) addJob(args map[string]any) *ToolResult {
 	t.mu.RLock()
 	channel := t.channel
 	chatID := t.chatID
+	senderID := t.senderID
 	t.mu.RUnlock()
 
 	if channel == "" || chatID == "" {
@@ -146,12 +193,27 @@ func (t *CronTool) addJob(args map[string]any) *ToolResult {
 
 	var schedule cron.CronSchedule
 
-	// Check for at_seconds (one-time), every_seconds (recurring), or cron_expr
+	tz, _ := args["timezone"].(string)
+	if tz == "" {
+		// Without a known sender, as in direct calls, the chat is the peer.
+		peer := senderID
+		if peer == "" {
+			peer = chatID
+		}
+		tz = routing.UserTimezone(t.session, channel, peer)
+	}
+	loc, err := (cron.CronSchedule{TZ: tz}).Location()
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+
+	// Check for at_seconds (one-time), every_seconds (recurring), cron_expr or a schedule phrase
 	atSeconds, hasAt := args["at_seconds"].(float64)
 	everySeconds, hasEvery := args["every_seconds"].(float64)
 	cronExpr, hasCron := args["cron_expr"].(string)
+	phrase, hasPhrase := args["schedule"].(string)
 
-	// Priority: at_seconds > every_seconds > cron_expr
+	// Priority: at_seconds > every_seconds > cron_expr > schedule
 	switch {
 	case hasAt:
 		atMS := time.Now().UnixMilli() + int64(atSeconds)*1000
@@ -169,9 +231,26 @@ func (t *CronTool) addJob(args map[string]any) *ToolResult {
 		schedule = cron.CronSchedule{
 			Kind: "cron",
 			Expr: cronExpr,
+			TZ:   tz,
+		}
+	case hasPhrase:
+		schedule, err = cron.ParseSchedule(phrase, time.Now(), loc)
+		if err != nil {
+			return ErrorResult(err.Error())
 		}
 	default:
-		return ErrorResult("one of at_seconds, every_seconds, or cron_expr is required")
+		return ErrorResult("one of at_seconds, every_seconds, cron_expr or schedule is required")
+	}
+
+	runs, err := cron.NextRuns(schedule, time.Now(), previewRuns)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("Invalid schedule: %v", err))
+	}
+	if len(runs) == 0 {
+		return ErrorResult("the schedule never fires; pick a time in the future")
+	}
+	if preview, _ := args["preview"].(bool); preview {
+		return SilentResult("Next runs (not added yet):\n" + formatRuns(runs))
 	}
 
 	// Read deliver parameter, default to true
@@ -180,6 +259,21 @@ func (t *CronTool) addJob(args map[string]any) *ToolResult {
 		deliver = d
 	}
 
+	overlap, _ := args["overlap"].(string)
+	if !cron.ValidOverlap(overlap) {
+		return ErrorResult(fmt.Sprintf("overlap must be %s, %s or %s", cron.OverlapSkip, cron.OverlapQueue, cron.OverlapAllow))
+	}
+	var retry cron.RetryPolicy
+	if n, ok := args["max_attempts"].(float64); ok {
+		retry.MaxAttempts = int(n)
+	}
+	if n, ok := args["retry_backoff_seconds"].(float64); ok {
+		retry.BackoffMS = int64(n) * 1000
+	}
+	if retry.MaxAttempts < 0 || retry.BackoffMS < 0 {
+		return ErrorResult("max_attempts and retry_backoff_seconds must not be negative")
+	}
+
 	command, _ := args["command"].(string)
 	if command != "" {
 		// Commands must be processed by agent/exec tool, so deliver must be false (or handled specifically)
@@ -204,13 +298,23 @@ func (t *CronTool) addJob(args map[string]any) *ToolResult {
 		return ErrorResult(fmt.Sprintf("Error adding job: %v", err))
 	}
 
-	if command != "" {
+	if command != "" || overlap != "" || retry != (cron.RetryPolicy{}) {
 		job.Payload.Command = command
+		job.Retry = retry
+		job.Overlap = overlap
 		// Need to save the updated payload
 		t.cronService.UpdateJob(job)
 	}
 
-	return SilentResult(fmt.Sprintf("Cron job added: %s (id: %s)", job.Name, job.ID))
+	return SilentResult(fmt.Sprintf("Cron job added: %s (id: %s)\nNext runs:\n%s", job.Name, job.ID, formatRuns(runs)))
+}
+
+func formatRuns(runs []time.Time) string {
+	var sb strings.Builder
+	for _, r := range runs {
+		fmt.Fprintf(&sb, "- %s\n", r.Format("Mon 2006-01-02 15:04 MST"))
+	}
+	return sb.String()
 }
 
 func (t *CronTool) listJobs() *ToolResult {
@@ -227,6 +331,8 @@ func (t *CronTool) listJobs() *ToolResult {
 		switch {
 		case j.Schedule.Kind == "every" && j.Schedule.EveryMS != nil:
 			scheduleInfo = fmt.Sprintf("every %ds", *j.Schedule.EveryMS/1000)
+		case j.Schedule.Kind == "cron" && j.Schedule.TZ != "":
+			scheduleInfo = j.Schedule.Expr + " " + j.Schedule.TZ
 		case j.Schedule.Kind == "cron":
 			scheduleInfo = j.Schedule.Expr
 		case j.Schedule.Kind == "at":
@@ -270,8 +376,36 @@ func (t *CronTool) enableJob(args map[string]any, enable bool) *ToolResult {
 	return SilentResult(fmt.Sprintf("Cron job '%s' %s", job.Name, status))
 }
 
-// ExecuteJob executes a cron job through the agent
-func (t *CronTool) ExecuteJob(ctx context.Context, job *cron.CronJob) string {
+func (t *CronTool) jobHistory(args map[string]any) *ToolResult {
+	jobID, ok := args["job_id"].(string)
+	if !ok || jobID == "" {
+		return ErrorResult("job_id is required for history")
+	}
+
+	runs, err := t.cronService.History(jobID, 10)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("Error reading history: %v", err))
+	}
+	if len(runs) == 0 {
+		return SilentResult(fmt.Sprintf("No runs recorded for job %s", jobID))
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Recent runs of %s:\n", runs[0].JobName)
+	for _, r := range runs {
+		fmt.Fprintf(&sb, "- %s %s (%dms, %d attempt(s))",
+			time.UnixMilli(r.StartedAtMS).Format(time.DateTime), r.Status, r.DurationMS, r.Attempts)
+		if r.Error != "" {
+			fmt.Fprintf(&sb, ": %s", r.Error)
+		}
+		sb.WriteString("\n")
+	}
+	return SilentResult(sb.String())
+}
+
+// ExecuteJob executes a cron job through the agent and returns its output.
+// The error reports a failed command or agent run.
+func (t *CronTool) ExecuteJob(ctx context.Context, job *cron.CronJob) (string, error) {
 	// Get channel/chatID from job payload
 	channel := job.Payload.Channel
 	chatID := job.Payload.To
@@ -303,7 +437,10 @@ func (t *CronTool) ExecuteJob(ctx context.Context, job *cron.CronJob) string {
 			ChatID:  chatID,
 			Content: output,
 		})
-		return "ok"
+		if result.IsError {
+			return result.ForLLM, errors.New(utils.Truncate(result.ForLLM, 200))
+		}
+		return result.ForLLM, nil
 	}
 
 	// If deliver=true, send message directly without agent processing
@@ -313,7 +450,7 @@ func (t *CronTool) ExecuteJob(ctx context.Context, job *cron.CronJob) string {
 			ChatID:  chatID,
 			Content: job.Payload.Message,
 		})
-		return "ok"
+		return job.Payload.Message, nil
 	}
 
 	// For deliver=false, process through agent (for complex tasks)
@@ -328,10 +465,9 @@ func (t *CronTool) ExecuteJob(ctx context.Context, job *cron.CronJob) string {
 		chatID,
 	)
 	if err != nil {
-		return fmt.Sprintf("Error: %v", err)
+		return response, err
 	}
 
 	// Response is automatically sent via MessageBus by AgentLoop
-	_ = response // Will be sent by AgentLoop
-	return "ok"
+	return response, nil
 }
@@ -0,0 +1,48 @@
+package tools
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/config"
+	"github.com/tinyland-inc/tinyclaw/pkg/cron"
+)
+
+func TestCronTool_DefaultsToSenderTimezone(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Session.Timezone = "UTC"
+	cfg.Session.UserTimezones = map[string]string{
+		"telegram:alice": "Asia/Tokyo",
+		"telegram:group": "Europe/Berlin",
+	}
+	cs := cron.NewCronService(filepath.Join(t.TempDir(), "jobs.json"), nil)
+	tool := NewCronTool(cs, nil, nil, t.TempDir(), true, 0, cfg)
+
+	add := func() string {
+		t.Helper()
+		result := tool.Execute(context.Background(), map[string]any{
+			"action":    "add",
+			"message":   "standup",
+			"cron_expr": "0 9 * * 1-5",
+		})
+		if result.IsError {
+			t.Fatalf("add: %s", result.ForLLM)
+		}
+		jobs := cs.ListJobs(true)
+		return jobs[len(jobs)-1].Schedule.TZ
+	}
+
+	// A message from alice in a group chat uses alice's zone.
+	tool.SetContext("telegram", "group")
+	tool.SetSender("alice")
+	if tz := add(); tz != "Asia/Tokyo" {
+		t.Errorf("timezone = %q, want the sender's", tz)
+	}
+
+	// Without a sender, the chat is the peer.
+	tool.SetSender("")
+	if tz := add(); tz != "Europe/Berlin" {
+		t.Errorf("timezone = %q, want the chat's", tz)
+	}
+}
@@ -0,0 +1,279 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/logger"
+)
+
+// mcpProtocolVersion is the MCP protocol revision spoken by both the client
+// (see MCPClientTool.Start) and the server.
+const mcpProtocolVersion = "2024-11-05"
+
+// JSON-RPC 2.0 error codes used by the MCP server.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+)
+
+// ToolPolicyFunc authorizes a tool call before it is executed.
+// A non-nil error denies the call; the error text is returned to the caller.
+type ToolPolicyFunc func(ctx context.Context, toolName string) error
+
+// MCPServer exposes a ToolRegistry to MCP clients over JSON-RPC 2.0.
+// It is the inverse of MCPClientTool: tools/list publishes the registry's
+// definitions and tools/call executes them through ExecuteWithContext, so the
+// same workspace restrictions apply as when the agent calls them itself.
+type MCPServer struct {
+	name     string
+	version  string
+	registry *ToolRegistry
+	policy   ToolPolicyFunc
+	channel  string
+	chatID   string
+}
+
+// mcpServerRequest is an incoming JSON-RPC 2.0 request or notification.
+// The ID is kept raw because clients may use either numbers or strings.
+type mcpServerRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// mcpServerResponse is an outgoing JSON-RPC 2.0 response.
+type mcpServerResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *jsonRPCError   `json:"error,omitempty"`
+}
+
+// mcpContentBlock is a single content entry in a tools/call result.
+type mcpContentBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// mcpCallResult is the tools/call result payload.
+type mcpCallResult struct {
+	Content []mcpContentBlock `json:"content"`
+	IsError bool              `json:"isError"`
+}
+
+// NewMCPServer creates an MCP server publishing the tools in registry.
+func NewMCPServer(name, version string, registry *ToolRegistry) *MCPServer {
+	return &MCPServer{
+		name:     name,
+		version:  version,
+		registry: registry,
+		channel:  "mcp",
+		chatID:   "mcp",
+	}
+}
+
+// SetToolPolicy installs an authorization check run before every tools/call.
+func (s *MCPServer) SetToolPolicy(policy ToolPolicyFunc) {
+	s.policy = policy
+}
+
+// SetContext sets the channel/chatID passed to contextual tools.
+func (s *MCPServer) SetContext(channel, chatID string) {
+	s.channel = channel
+	s.chatID = chatID
+}
+
+// HandleMessage processes one JSON-RPC message and returns the encoded
+// response. It returns nil for notifications, which expect no reply.
+func (s *MCPServer) HandleMessage(ctx context.Context, data []byte) []byte {
+	var req mcpServerRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return encodeRPCResponse(mcpServerResponse{
+			ID:    json.RawMessage("null"),
+			Error: &jsonRPCError{Code: rpcParseError, Message: "parse error"},
+		})
+	}
+
+	if len(req.ID) == 0 {
+		// Notification (e.g. notifications/initialized): nothing to send back.
+		logger.DebugCF("mcp", "MCP notification received", map[string]any{"method": req.Method})
+		return nil
+	}
+
+	resp := mcpServerResponse{ID: req.ID}
+	result, rpcErr := s.dispatch(ctx, req)
+	if rpcErr != nil {
+		resp.Error = rpcErr
+	} else {
+		resp.Result = result
+	}
+	return encodeRPCResponse(resp)
+}
+
+func (s *MCPServer) dispatch(ctx context.Context, req mcpServerRequest) (any, *jsonRPCError) {
+	switch req.Method {
+	case "initialize":
+		return map[string]any{
+			"protocolVersion": mcpProtocolVersion,
+			"capabilities": map[string]any{
+				"tools": map[string]any{},
+			},
+			"serverInfo": map[string]any{
+				"name":    s.name,
+				"version": s.version,
+			},
+		}, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		return map[string]any{"tools": s.listTools()}, nil
+	case "tools/call":
+		return s.callTool(ctx, req.Params)
+	case "":
+		return nil, &jsonRPCError{Code: rpcInvalidRequest, Message: "method is required"}
+	default:
+		return nil, &jsonRPCError{Code: rpcMethodNotFound, Message: "method not found: " + req.Method}
+	}
+}
+
+func (s *MCPServer) listTools() []MCPToolInfo {
+	defs := s.registry.ToProviderDefs()
+	infos := make([]MCPToolInfo, 0, len(defs))
+	for _, def := range defs {
+		schema := def.Function.Parameters
+		if schema == nil {
+			schema = map[string]any{"type": "object", "properties": map[string]any{}}
+		}
+		infos = append(infos, MCPToolInfo{
+			Name:        def.Function.Name,
+			Description: def.Function.Description,
+			InputSchema: schema,
+		})
+	}
+	return infos
+}
+
+func (s *MCPServer) callTool(ctx context.Context, raw json.RawMessage) (any, *jsonRPCError) {
+	var params struct {
+		Name      string         `json:"name"`
+		Arguments map[string]any `json:"arguments"`
+	}
+	if len(raw) == 0 {
+		return nil, &jsonRPCError{Code: rpcInvalidParams, Message: "params are required"}
+	}
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, &jsonRPCError{Code: rpcInvalidParams, Message: "invalid params: " + err.Error()}
+	}
+	if params.Name == "" {
+		return nil, &jsonRPCError{Code: rpcInvalidParams, Message: "name is required"}
+	}
+	if _, ok := s.registry.Get(params.Name); !ok {
+		return nil, &jsonRPCError{Code: rpcInvalidParams, Message: fmt.Sprintf("unknown tool %q", params.Name)}
+	}
+	if params.Arguments == nil {
+		params.Arguments = map[string]any{}
+	}
+
+	if s.policy != nil {
+		if err := s.policy(ctx, params.Name); err != nil {
+			logger.WarnCF("mcp", "MCP tool call denied by policy", map[string]any{
+				"tool":  params.Name,
+				"error": err.Error(),
+			})
+			return mcpCallResult{
+				Content: []mcpContentBlock{{Type: "text", Text: "tool call denied: " + err.Error()}},
+				IsError: true,
+			}, nil
+		}
+	}
+
+	result := s.registry.ExecuteWithContext(ctx, params.Name, params.Arguments, s.channel, s.chatID, nil)
+
+	text := result.ForLLM
+	if text == "" && result.Err != nil {
+		text = result.Err.Error()
+	}
+	return mcpCallResult{
+		Content: []mcpContentBlock{{Type: "text", Text: text}},
+		IsError: result.IsError,
+	}, nil
+}
+
+// ServeStdio reads newline-delimited JSON-RPC messages from r and writes
+// responses to w until r is exhausted or ctx is canceled.
+func (s *MCPServer) ServeStdio(ctx context.Context, r io.Reader, w io.Writer) error {
+	reader := bufio.NewReader(r)
+	var writeMu sync.Mutex
+
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if resp := s.HandleMessage(ctx, line); resp != nil {
+				writeMu.Lock()
+				_, writeErr := w.Write(append(resp, '\n'))
+				writeMu.Unlock()
+				if writeErr != nil {
+					return fmt.Errorf("write response: %w", writeErr)
+				}
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("read request: %w", err)
+		}
+	}
+}
+
+// ServeHTTP implements http.Handler for JSON-RPC over HTTP POST, one
+// message per request.
+func (s *MCPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	resp := s.HandleMessage(r.Context(), body)
+	if resp == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(resp)
+}
+
+func encodeRPCResponse(resp mcpServerResponse) []byte {
+	resp.JSONRPC = "2.0"
+	data, err := json.Marshal(resp)
+	if err != nil {
+		data, _ = json.Marshal(mcpServerResponse{
+			JSONRPC: "2.0",
+			ID:      resp.ID,
+			Error:   &jsonRPCError{Code: rpcParseError, Message: "failed to encode response"},
+		})
+	}
+	return data
+}
@@ -0,0 +1,270 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestMCPServer() (*MCPServer, *mockCtxTool) {
+	reg := NewToolRegistry()
+	echo := &mockCtxTool{mockRegistryTool: mockRegistryTool{
+		name:   "echo",
+		desc:   "Echo tool",
+		params: map[string]any{"type": "object", "properties": map[string]any{}},
+		result: NewToolResult("echoed"),
+	}}
+	reg.Register(echo)
+	reg.Register(&mockRegistryTool{
+		name:   "broken",
+		desc:   "Always fails",
+		params: map[string]any{"type": "object"},
+		result: ErrorResult("boom"),
+	})
+	return NewMCPServer("tinyclaw-test", "1.0.0", reg), echo
+}
+
+func decodeRPC(t *testing.T, data []byte) mcpServerResponse {
+	t.Helper()
+	var resp struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Result  json.RawMessage `json:"result"`
+		Error   *jsonRPCError   `json:"error"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.Fatalf("decode response %q: %v", data, err)
+	}
+	return mcpServerResponse{JSONRPC: resp.JSONRPC, ID: resp.ID, Result: resp.Result, Error: resp.Error}
+}
+
+func TestMCPServer_Initialize(t *testing.T) {
+	s, _ := newTestMCPServer()
+
+	out := s.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`))
+	resp := decodeRPC(t, out)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %+v", resp.Error)
+	}
+	if string(resp.ID) != "1" {
+		t.Errorf("id = %s, want 1", resp.ID)
+	}
+
+	var result struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		ServerInfo      struct {
+			Name string `json:"name"`
+		} `json:"serverInfo"`
+	}
+	if err := json.Unmarshal(resp.Result.(json.RawMessage), &result); err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	if result.ProtocolVersion != mcpProtocolVersion {
+		t.Errorf("protocolVersion = %q, want %q", result.ProtocolVersion, mcpProtocolVersion)
+	}
+	if result.ServerInfo.Name != "tinyclaw-test" {
+		t.Errorf("serverInfo.name = %q", result.ServerInfo.Name)
+	}
+}
+
+func TestMCPServer_NotificationHasNoResponse(t *testing.T) {
+	s, _ := newTestMCPServer()
+
+	out := s.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`))
+	if out != nil {
+		t.Errorf("expected no response for notification, got %s", out)
+	}
+}
+
+func TestMCPServer_ToolsList(t *testing.T) {
+	s, _ := newTestMCPServer()
+
+	out := s.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":"a","method":"tools/list"}`))
+	resp := decodeRPC(t, out)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %+v", resp.Error)
+	}
+
+	var result struct {
+		Tools []MCPToolInfo `json:"tools"`
+	}
+	if err := json.Unmarshal(resp.Result.(json.RawMessage), &result); err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	if len(result.Tools) != 2 {
+		t.Fatalf("expected 2 tools, got %d", len(result.Tools))
+	}
+	if result.Tools[0].Name != "broken" || result.Tools[1].Name != "echo" {
+		t.Errorf("tools not sorted: %+v", result.Tools)
+	}
+	if result.Tools[1].InputSchema["type"] != "object" {
+		t.Errorf("inputSchema = %v", result.Tools[1].InputSchema)
+	}
+}
+
+func TestMCPServer_ToolsCall(t *testing.T) {
+	s, echo := newTestMCPServer()
+	s.SetContext("mcp", "main")
+
+	out := s.HandleMessage(context.Background(),
+		[]byte(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"echo","arguments":{}}}`))
+	resp := decodeRPC(t, out)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %+v", resp.Error)
+	}
+
+	var result mcpCallResult
+	if err := json.Unmarshal(resp.Result.(json.RawMessage), &result); err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	if result.IsError || len(result.Content) != 1 || result.Content[0].Text != "echoed" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if echo.channel != "mcp" || echo.chatID != "main" {
+		t.Errorf("context not applied: %s/%s", echo.channel, echo.chatID)
+	}
+}
+
+func TestMCPServer_ToolsCallError(t *testing.T) {
+	s, _ := newTestMCPServer()
+
+	out := s.HandleMessage(context.Background(),
+		[]byte(`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"broken"}}`))
+	resp := decodeRPC(t, out)
+
+	var result mcpCallResult
+	if err := json.Unmarshal(resp.Result.(json.RawMessage), &result); err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	if !result.IsError {
+		t.Error("expected isError for failing tool")
+	}
+}
+
+func TestMCPServer_ToolsCallUnknownTool(t *testing.T) {
+	s, _ := newTestMCPServer()
+
+	out := s.HandleMessage(context.Background(),
+		[]byte(`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"nope"}}`))
+	resp := decodeRPC(t, out)
+	if resp.Error == nil || resp.Error.Code != rpcInvalidParams {
+		t.Fatalf("expected invalid params error, got %+v", resp.Error)
+	}
+}
+
+func TestMCPServer_PolicyDenies(t *testing.T) {
+	s, _ := newTestMCPServer()
+	s.SetToolPolicy(func(_ context.Context, toolName string) error {
+		if toolName == "echo" {
+			return errors.New("not allowed")
+		}
+		return nil
+	})
+
+	out := s.HandleMessage(context.Background(),
+		[]byte(`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"echo"}}`))
+	resp := decodeRPC(t, out)
+
+	var result mcpCallResult
+	if err := json.Unmarshal(resp.Result.(json.RawMessage), &result); err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	if !result.IsError || !strings.Contains(result.Content[0].Text, "not allowed") {
+		t.Errorf("expected policy denial, got %+v", result)
+	}
+}
+
+func TestMCPServer_UnknownMethodAndParseError(t *testing.T) {
+	s, _ := newTestMCPServer()
+
+	resp := decodeRPC(t, s.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":6,"method":"resources/list"}`)))
+	if resp.Error == nil || resp.Error.Code != rpcMethodNotFound {
+		t.Errorf("expected method not found, got %+v", resp.Error)
+	}
+
+	resp = decodeRPC(t, s.HandleMessage(context.Background(), []byte(`{not json`)))
+	if resp.Error == nil || resp.Error.Code != rpcParseError {
+		t.Errorf("expected parse error, got %+v", resp.Error)
+	}
+}
+
+func TestMCPServer_ServeStdio(t *testing.T) {
+	s, _ := newTestMCPServer()
+
+	in := strings.NewReader(
+		`{"jsonrpc":"2.0","id":1,"method":"initialize"}` + "\n" +
+			`{"jsonrpc":"2.0","method":"notifications/initialized"}` + "\n" +
+			`{"jsonrpc":"2.0","id":2,"method":"tools/list"}` + "\n")
+	var out bytes.Buffer
+
+	if err := s.ServeStdio(context.Background(), in, &out); err != nil {
+		t.Fatalf("ServeStdio: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 responses, got %d: %q", len(lines), out.String())
+	}
+}
+
+func TestMCPServer_ServeHTTP(t *testing.T) {
+	s, _ := newTestMCPServer()
+
+	req := httptest.NewRequest(http.MethodPost, "/mcp",
+		strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}`))
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/mcp", nil)
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rec.Code)
+	}
+}
+
+func TestAskAgentTool_Execute(t *testing.T) {
+	var gotKey, gotChannel string
+	tool := NewAskAgentTool("research", func(_ context.Context, content, sessionKey, channel, _ string) (string, error) {
+		gotKey = sessionKey
+		gotChannel = channel
+		return "answer to " + content, nil
+	})
+
+	result := tool.Execute(context.Background(), map[string]any{"message": "hi", "session": "ide"})
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", result.ForLLM)
+	}
+	if result.ForLLM != "answer to hi" {
+		t.Errorf("ForLLM = %q", result.ForLLM)
+	}
+	if gotKey != "agent:research:ide" {
+		t.Errorf("session key = %q", gotKey)
+	}
+	if gotChannel != "mcp" {
+		t.Errorf("channel = %q", gotChannel)
+	}
+
+	if r := tool.Execute(context.Background(), map[string]any{}); !r.IsError {
+		t.Error("expected error when message is missing")
+	}
+}
+
+func TestAskAgentTool_PropagatesError(t *testing.T) {
+	tool := NewAskAgentTool("main", func(context.Context, string, string, string, string) (string, error) {
+		return "", errors.New("provider down")
+	})
+
+	result := tool.Execute(context.Background(), map[string]any{"message": "hi"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "provider down") {
+		t.Errorf("expected wrapped error, got %+v", result)
+	}
+}
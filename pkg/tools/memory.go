@@ -0,0 +1,202 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/memory"
+	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+)
+
+// MemorySaveTool stores a long-term memory.
+type MemorySaveTool struct {
+	store *memory.Store
+}
+
+// NewMemorySaveTool creates a memory_save tool writing to store.
+func NewMemorySaveTool(store *memory.Store) *MemorySaveTool {
+	return &MemorySaveTool{store: store}
+}
+
+func (t *MemorySaveTool) Name() string {
+	return "memory_save"
+}
+
+func (t *MemorySaveTool) Description() string {
+	return "Save a fact worth remembering across conversations (user preferences, decisions, names, recurring context). Keep each memory to one self-contained fact. Relevant memories are recalled automatically in later conversations."
+}
+
+func (t *MemorySaveTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"text": map[string]any{
+				"type":        "string",
+				"description": "The fact to remember, written so it makes sense on its own",
+			},
+			"tags": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Optional tags to group memories (e.g., 'preferences', 'project-x')",
+			},
+		},
+		"required": []string{"text"},
+	}
+}
+
+func (t *MemorySaveTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	text, _ := args["text"].(string)
+	if strings.TrimSpace(text) == "" {
+		return ErrorResult("text is required and must be a non-empty string")
+	}
+
+	e, err := t.store.Save(text, stringList(args["tags"]), providers.SessionKeyFromContext(ctx))
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("saving memory failed: %v", err)).WithError(err)
+	}
+	return SilentResult(fmt.Sprintf("Saved memory %s", e.ID))
+}
+
+// MemorySearchTool searches long-term memories.
+type MemorySearchTool struct {
+	store *memory.Store
+	fuzzy bool
+}
+
+// NewMemorySearchTool creates a memory_search tool over store. With fuzzy,
+// misspelled terms match too.
+func NewMemorySearchTool(store *memory.Store, fuzzy bool) *MemorySearchTool {
+	return &MemorySearchTool{store: store, fuzzy: fuzzy}
+}
+
+func (t *MemorySearchTool) Name() string {
+	return "memory_search"
+}
+
+func (t *MemorySearchTool) Description() string {
+	return "Search long-term memories: facts saved with memory_save, MEMORY.md and daily notes. Returns matching memories with their ID, date and tags."
+}
+
+func (t *MemorySearchTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"query": map[string]any{
+				"type":        "string",
+				"description": "Words to look for (e.g., 'preferred programming language')",
+			},
+			"tags": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Only return memories carrying all of these tags",
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": "Maximum number of results to return (1-20, default 5)",
+				"minimum":     1.0,
+				"maximum":     20.0,
+			},
+		},
+		"required": []string{"query"},
+	}
+}
+
+func (t *MemorySearchTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	query, _ := args["query"].(string)
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return ErrorResult("query is required and must be a non-empty string")
+	}
+
+	limit := 5
+	if l, ok := args["limit"].(float64); ok {
+		if li := int(l); li >= 1 && li <= 20 {
+			limit = li
+		}
+	}
+
+	results := t.store.Search(query, memory.SearchOptions{
+		Limit: limit,
+		Tags:  stringList(args["tags"]),
+		Fuzzy: t.fuzzy,
+	})
+	if len(results) == 0 {
+		return SilentResult(fmt.Sprintf("No memories found for %q", query))
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Found %d memories for %q:\n\n", len(results), query)
+	for i, r := range results {
+		fmt.Fprintf(&sb, "%d. %s\n\n", i+1, FormatMemory(r.Entry))
+	}
+	return SilentResult(strings.TrimRight(sb.String(), "\n"))
+}
+
+// MemoryForgetTool deletes a saved memory.
+type MemoryForgetTool struct {
+	store *memory.Store
+}
+
+// NewMemoryForgetTool creates a memory_forget tool deleting from store.
+func NewMemoryForgetTool(store *memory.Store) *MemoryForgetTool {
+	return &MemoryForgetTool{store: store}
+}
+
+func (t *MemoryForgetTool) Name() string {
+	return "memory_forget"
+}
+
+func (t *MemoryForgetTool) Description() string {
+	return "Delete a memory saved with memory_save that is wrong or outdated, by the ID shown by memory_search. Memories from MEMORY.md or daily notes are removed by editing those files."
+}
+
+func (t *MemoryForgetTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"id": map[string]any{
+				"type":        "string",
+				"description": "ID of the memory to delete",
+			},
+		},
+		"required": []string{"id"},
+	}
+}
+
+func (t *MemoryForgetTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	id, _ := args["id"].(string)
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return ErrorResult("id is required")
+	}
+
+	if err := t.store.Forget(id); err != nil {
+		if errors.Is(err, memory.ErrNotFound) || errors.Is(err, memory.ErrReadOnly) {
+			return ErrorResult(err.Error())
+		}
+		return ErrorResult(fmt.Sprintf("forgetting memory failed: %v", err)).WithError(err)
+	}
+	return SilentResult(fmt.Sprintf("Forgot memory %s", id))
+}
+
+// FormatMemory renders a memory as "[id] (date; tags) text".
+func FormatMemory(e memory.Entry) string {
+	meta := e.Created.Format("2006-01-02")
+	if len(e.Tags) > 0 {
+		meta += "; " + strings.Join(e.Tags, ", ")
+	}
+	return fmt.Sprintf("[%s] (%s) %s", e.ID, meta, e.Text)
+}
+
+func stringList(v any) []string {
+	raw, _ := v.([]any)
+	var out []string
+	for _, item := range raw {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
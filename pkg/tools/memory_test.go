@@ -0,0 +1,51 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/memory"
+	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+)
+
+func TestMemoryTools(t *testing.T) {
+	store, err := memory.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	save := NewMemorySaveTool(store)
+	find := NewMemorySearchTool(store, true)
+	forget := NewMemoryForgetTool(store)
+
+	ctx := providers.WithSessionKey(context.Background(), "agent:main:telegram:direct:42")
+	result := save.Execute(ctx, map[string]any{
+		"text": "User's favourite editor is Helix",
+		"tags": []any{"Preferences"},
+	})
+	if result.IsError || !result.Silent {
+		t.Fatalf("memory_save = %+v", result)
+	}
+	saved := store.List()
+	if len(saved) != 1 || saved[0].Source != "agent:main:telegram:direct:42" {
+		t.Fatalf("saved = %+v", saved)
+	}
+
+	result = find.Execute(ctx, map[string]any{"query": "favorite", "tags": []any{"preferences"}})
+	if result.IsError || !strings.Contains(result.ForLLM, saved[0].ID) || !strings.Contains(result.ForLLM, "Helix") {
+		t.Errorf("memory_search = %+v", result)
+	}
+	if result := find.Execute(ctx, map[string]any{"query": "editor", "tags": []any{"work"}}); !strings.Contains(result.ForLLM, "No memories") {
+		t.Errorf("tag filter ignored: %s", result.ForLLM)
+	}
+
+	if result := forget.Execute(ctx, map[string]any{"id": saved[0].ID}); result.IsError {
+		t.Fatalf("memory_forget = %+v", result)
+	}
+	if result := forget.Execute(ctx, map[string]any{"id": saved[0].ID}); !result.IsError {
+		t.Error("forgetting an unknown memory should fail")
+	}
+	if result := save.Execute(ctx, map[string]any{"text": "  "}); !result.IsError {
+		t.Error("empty memory should fail")
+	}
+}
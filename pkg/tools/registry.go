@@ -9,7 +9,10 @@ import (
 	"time"
 
 	"github.com/tinyland-inc/tinyclaw/pkg/logger"
+	"github.com/tinyland-inc/tinyclaw/pkg/metrics"
 	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+	"github.com/tinyland-inc/tinyclaw/pkg/tracing"
+	"github.com/tinyland-inc/tinyclaw/pkg/utils"
 )
 
 type ToolRegistry struct {
@@ -79,10 +82,30 @@ func (r *ToolRegistry) ExecuteWithContext(
 			})
 	}
 
+	ctx, span := tracing.Start(ctx, "tool.execute", map[string]any{
+		"tool":    name,
+		"channel": channel,
+	})
+	defer span.End()
+
 	start := time.Now()
 	result := tool.Execute(ctx, args)
 	duration := time.Since(start)
 
+	outcome := "success"
+	switch {
+	case result.IsError:
+		outcome = "error"
+	case result.Async:
+		outcome = "async"
+	}
+	metrics.ToolExecutions.Inc(name, outcome)
+	metrics.ToolDuration.Observe(duration.Seconds(), name)
+	span.SetAttr("outcome", outcome)
+	if result.IsError {
+		span.RecordError(errors.New(utils.Truncate(result.ForLLM, 200)))
+	}
+
 	// Log based on result type
 	switch {
 	case result.IsError:
@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/session"
+)
+
+// SessionSearchFunc searches the past conversations visible to the
+// conversation the tool is called from.
+type SessionSearchFunc func(ctx context.Context, query string, limit int) ([]session.SearchHit, error)
+
+// SessionSearchTool lets the agent search its past conversations.
+type SessionSearchTool struct {
+	search SessionSearchFunc
+}
+
+// NewSessionSearchTool creates a session_search tool backed by search.
+func NewSessionSearchTool(search SessionSearchFunc) *SessionSearchTool {
+	return &SessionSearchTool{search: search}
+}
+
+func (t *SessionSearchTool) Name() string {
+	return "session_search"
+}
+
+func (t *SessionSearchTool) Description() string {
+	return "Full-text search over past conversations, including ones no longer in your context. Returns matching message snippets with their session key and time. Use it to recall earlier decisions, facts or requests."
+}
+
+func (t *SessionSearchTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"query": map[string]any{
+				"type":        "string",
+				"description": "Words to look for (e.g., 'database migration decision')",
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": "Maximum number of results to return (1-20, default 5)",
+				"minimum":     1.0,
+				"maximum":     20.0,
+			},
+		},
+		"required": []string{"query"},
+	}
+}
+
+func (t *SessionSearchTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	query, _ := args["query"].(string)
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return ErrorResult("query is required and must be a non-empty string")
+	}
+
+	limit := 5
+	if l, ok := args["limit"].(float64); ok {
+		if li := int(l); li >= 1 && li <= 20 {
+			limit = li
+		}
+	}
+
+	hits, err := t.search(ctx, query, limit)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("session search failed: %v", err)).WithError(err)
+	}
+	return SilentResult(formatSessionHits(query, hits))
+}
+
+func formatSessionHits(query string, hits []session.SearchHit) string {
+	if len(hits) == 0 {
+		return fmt.Sprintf("No past messages found for %q", query)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Found %d past messages for %q:\n\n", len(hits), query)
+	for i, h := range hits {
+		fmt.Fprintf(&sb, "%d. [%s] session %s, %s message %s\n", i+1,
+			h.Time.Format("2006-01-02 15:04"), h.SessionKey, h.Role, h.MessageID)
+		fmt.Fprintf(&sb, "   %s\n\n", h.Snippet)
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
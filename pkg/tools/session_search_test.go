@@ -0,0 +1,58 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/session"
+)
+
+func TestSessionSearchTool_Execute(t *testing.T) {
+	var gotQuery string
+	var gotLimit int
+	tool := NewSessionSearchTool(func(_ context.Context, query string, limit int) ([]session.SearchHit, error) {
+		gotQuery, gotLimit = query, limit
+		return []session.SearchHit{{
+			SessionKey: "agent:main:telegram:direct:42",
+			MessageID:  "7",
+			Role:       "user",
+			Snippet:    "let's go with Postgres",
+			Time:       time.Date(2026, 9, 3, 14, 5, 0, 0, time.UTC),
+		}}, nil
+	})
+
+	result := tool.Execute(context.Background(), map[string]any{"query": " postgres ", "limit": 3.0})
+	if result.IsError || !result.Silent {
+		t.Fatalf("result = %+v", result)
+	}
+	if gotQuery != "postgres" || gotLimit != 3 {
+		t.Errorf("search called with %q, %d", gotQuery, gotLimit)
+	}
+	for _, want := range []string{"agent:main:telegram:direct:42", "2026-09-03 14:05", "let's go with Postgres"} {
+		if !strings.Contains(result.ForLLM, want) {
+			t.Errorf("ForLLM %q missing %q", result.ForLLM, want)
+		}
+	}
+}
+
+func TestSessionSearchTool_Errors(t *testing.T) {
+	tool := NewSessionSearchTool(func(context.Context, string, int) ([]session.SearchHit, error) {
+		return nil, errors.New("boom")
+	})
+	if result := tool.Execute(context.Background(), map[string]any{}); !result.IsError {
+		t.Error("missing query should fail")
+	}
+	if result := tool.Execute(context.Background(), map[string]any{"query": "x"}); !result.IsError || result.Err == nil {
+		t.Errorf("search error not reported: %+v", result)
+	}
+
+	empty := NewSessionSearchTool(func(context.Context, string, int) ([]session.SearchHit, error) {
+		return nil, nil
+	})
+	if result := empty.Execute(context.Background(), map[string]any{"query": "x"}); !strings.Contains(result.ForLLM, "No past messages") {
+		t.Errorf("ForLLM = %q", result.ForLLM)
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/tinyland-inc/tinyclaw/pkg/bus"
 	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+	"github.com/tinyland-inc/tinyclaw/pkg/tracing"
 )
 
 type SubagentTask struct {
@@ -114,6 +115,14 @@ func (sm *SubagentManager) Spawn(
 }
 
 func (sm *SubagentManager) runTask(ctx context.Context, task *SubagentTask, callback AsyncCallback) {
+	ctx, span := tracing.Start(ctx, "subagent.run", map[string]any{
+		"task_id":  task.ID,
+		"label":    task.Label,
+		"agent_id": task.AgentID,
+		"model":    sm.defaultModel,
+	})
+	defer span.End()
+
 	task.Status = "running"
 	task.Created = time.Now().UnixMilli()
 
@@ -184,6 +193,7 @@ After completing the task, provide a clear summary of what was done.`
 	}()
 
 	if err != nil {
+		span.RecordError(err)
 		task.Status = "failed"
 		task.Result = fmt.Sprintf("Error: %v", err)
 		// Check if it was canceled
@@ -219,13 +229,18 @@ After completing the task, provide a clear summary of what was done.`
 	// Send announce message back to main agent
 	if sm.bus != nil {
 		announceContent := fmt.Sprintf("Task '%s' completed.\n\nResult:\n%s", task.Label, task.Result)
-		sm.bus.PublishInbound(bus.InboundMessage{
+		msg := bus.InboundMessage{
 			Channel:  "system",
 			SenderID: "subagent:" + task.ID,
 			// Format: "original_channel:original_chat_id" for routing back
 			ChatID:  fmt.Sprintf("%s:%s", task.OriginChannel, task.OriginChatID),
 			Content: announceContent,
-		})
+		}
+		// Carry the trace so the main agent's follow-up joins it.
+		if sc := span.SpanContext(); sc.IsValid() {
+			msg.Metadata = map[string]string{"traceparent": sc.Traceparent()}
+		}
+		sm.bus.PublishInbound(msg)
 	}
 }
 
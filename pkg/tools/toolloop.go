@@ -13,6 +13,7 @@ import (
 
 	"github.com/tinyland-inc/tinyclaw/pkg/logger"
 	"github.com/tinyland-inc/tinyclaw/pkg/providers"
+	"github.com/tinyland-inc/tinyclaw/pkg/tracing"
 	"github.com/tinyland-inc/tinyclaw/pkg/utils"
 )
 
@@ -65,7 +66,19 @@ func RunToolLoop(
 			llmOpts = map[string]any{}
 		}
 		// 3. Call LLM
-		response, err := config.Provider.Chat(ctx, messages, providerToolDefs, config.Model, llmOpts)
+		llmCtx, span := tracing.Start(ctx, "llm.chat", map[string]any{
+			"model":     config.Model,
+			"iteration": iteration,
+		})
+		response, err := config.Provider.Chat(llmCtx, messages, providerToolDefs, config.Model, llmOpts)
+		if response != nil && response.Usage != nil {
+			span.SetAttrs(map[string]any{
+				"prompt_tokens":     response.Usage.PromptTokens,
+				"completion_tokens": response.Usage.CompletionTokens,
+			})
+		}
+		span.RecordError(err)
+		span.End()
 		if err != nil {
 			logger.ErrorCF("toolloop", "LLM call failed",
 				map[string]any{
@@ -0,0 +1,411 @@
+// Package tracing provides lightweight, OpenTelemetry-compatible distributed
+// tracing for TinyClaw.
+//
+// A span is started with Start and finished with End. Spans nest through
+// context.Context, so a span started from a context that already carries
+// one becomes its child and shares its trace ID. Finished spans are batched
+// and handed to an Exporter (OTLP/HTTP or a local JSONL file).
+//
+// Tracing is off until SetDefault installs a Tracer. While off, Start
+// returns a nil *Span and every Span method is a no-op, so call sites never
+// need to check whether tracing is enabled.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tinyland-inc/tinyclaw/pkg/logger"
+)
+
+const (
+	batchSize     = 64
+	queueSize     = 2048
+	flushInterval = 5 * time.Second
+)
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+// IsValid reports whether the ID is non-zero.
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+
+// IsValid reports whether the ID is non-zero.
+func (s SpanID) IsValid() bool { return s != SpanID{} }
+
+// SpanContext is the propagated identity of a span.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+}
+
+// IsValid reports whether both IDs are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Traceparent formats the span context as a W3C traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-01", sc.TraceID, sc.SpanID)
+}
+
+// ParseTraceparent parses a W3C traceparent header value.
+func ParseTraceparent(v string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return SpanContext{}, false
+	}
+	var sc SpanContext
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, false
+	}
+	return sc, sc.IsValid()
+}
+
+// Status codes follow OpenTelemetry: unset, ok and error.
+const (
+	StatusUnset = 0
+	StatusOK    = 1
+	StatusError = 2
+)
+
+// SpanData is the immutable record of a finished span handed to exporters.
+type SpanData struct {
+	TraceID       string         `json:"trace_id"`
+	SpanID        string         `json:"span_id"`
+	ParentSpanID  string         `json:"parent_span_id,omitempty"`
+	Name          string         `json:"name"`
+	Start         time.Time      `json:"start"`
+	End           time.Time      `json:"end"`
+	DurationMS    float64        `json:"duration_ms"`
+	Attributes    map[string]any `json:"attributes,omitempty"`
+	Status        int            `json:"status"`
+	StatusMessage string         `json:"status_message,omitempty"`
+}
+
+// Span is an in-flight operation. A nil *Span is valid and ignores all calls.
+type Span struct {
+	tracer *Tracer
+	sc     SpanContext
+	parent SpanID
+	name   string
+	start  time.Time
+
+	mu        sync.Mutex
+	attrs     map[string]any
+	status    int
+	statusMsg string
+	ended     bool
+}
+
+// SpanContext returns the span's propagated identity.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetAttr sets one attribute. Attributes set after End are ignored.
+func (s *Span) SetAttr(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.attrs[key] = value
+	}
+}
+
+// SetAttrs sets several attributes.
+func (s *Span) SetAttrs(attrs map[string]any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	for k, v := range attrs {
+		s.attrs[k] = v
+	}
+}
+
+// RecordError marks the span as failed. A nil error is ignored.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = StatusError
+	s.statusMsg = err.Error()
+}
+
+// End finishes the span and queues it for export. Calls after the first
+// are ignored.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	end := time.Now()
+
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	if s.status == StatusUnset {
+		s.status = StatusOK
+	}
+	data := SpanData{
+		TraceID:       s.sc.TraceID.String(),
+		SpanID:        s.sc.SpanID.String(),
+		Name:          s.name,
+		Start:         s.start,
+		End:           end,
+		DurationMS:    float64(end.Sub(s.start).Microseconds()) / 1000,
+		Attributes:    s.attrs,
+		Status:        s.status,
+		StatusMessage: s.statusMsg,
+	}
+	if s.parent.IsValid() {
+		data.ParentSpanID = s.parent.String()
+	}
+	s.mu.Unlock()
+
+	s.tracer.enqueue(data)
+}
+
+type spanKey struct{}
+
+type remoteKey struct{}
+
+// SpanFromContext returns the active span, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// ContextWithRemoteParent makes sc the parent of spans started from the
+// returned context. It is used to continue a trace received from elsewhere,
+// such as a traceparent carried in message metadata.
+func ContextWithRemoteParent(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// SpanContextFromContext returns the identity of the active span, falling
+// back to a remote parent.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if s := SpanFromContext(ctx); s != nil {
+		return s.sc
+	}
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
+
+// TraceIDFromContext returns the active trace ID in hex, or "" when the
+// context is not traced.
+func TraceIDFromContext(ctx context.Context) string {
+	if sc := SpanContextFromContext(ctx); sc.IsValid() {
+		return sc.TraceID.String()
+	}
+	return ""
+}
+
+// Exporter ships finished spans to a backend.
+type Exporter interface {
+	ExportSpans(ctx context.Context, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+// Tracer creates spans and exports them in batches from a background
+// goroutine. Spans are dropped, never blocked on, when the queue is full.
+type Tracer struct {
+	exporter Exporter
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan SpanData
+	flush  chan chan struct{}
+	done   chan struct{}
+}
+
+// NewTracer creates a tracer exporting through e.
+func NewTracer(e Exporter) *Tracer {
+	t := &Tracer{
+		exporter: e,
+		queue:    make(chan SpanData, queueSize),
+		flush:    make(chan chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// Start begins a span named name. The span is a child of the span (or
+// remote parent) in ctx, or the root of a new trace otherwise.
+func (t *Tracer) Start(ctx context.Context, name string, attrs map[string]any) (context.Context, *Span) {
+	s := &Span{
+		tracer: t,
+		name:   name,
+		start:  time.Now(),
+		attrs:  make(map[string]any, len(attrs)),
+	}
+	for k, v := range attrs {
+		s.attrs[k] = v
+	}
+
+	if parent := SpanContextFromContext(ctx); parent.IsValid() {
+		s.sc.TraceID = parent.TraceID
+		s.parent = parent.SpanID
+	} else {
+		_, _ = rand.Read(s.sc.TraceID[:])
+	}
+	_, _ = rand.Read(s.sc.SpanID[:])
+
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// Flush exports all queued spans and waits for the export to finish.
+func (t *Tracer) Flush() {
+	t.mu.RLock()
+	if t.closed {
+		t.mu.RUnlock()
+		return
+	}
+	ack := make(chan struct{})
+	t.flush <- ack
+	t.mu.RUnlock()
+	<-ack
+}
+
+// Shutdown flushes queued spans and shuts down the exporter.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	close(t.queue)
+	t.mu.Unlock()
+
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return t.exporter.Shutdown(ctx)
+}
+
+func (t *Tracer) enqueue(data SpanData) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.closed {
+		return
+	}
+	select {
+	case t.queue <- data:
+	default:
+		logger.DebugCF("tracing", "Span queue full, dropping span", map[string]any{"span": data.Name})
+	}
+}
+
+func (t *Tracer) run() {
+	defer close(t.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, batchSize)
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := t.exporter.ExportSpans(ctx, batch); err != nil {
+			logger.WarnCF("tracing", "Failed to export spans", map[string]any{
+				"spans": len(batch),
+				"error": err.Error(),
+			})
+		}
+		cancel()
+		batch = make([]SpanData, 0, batchSize)
+	}
+
+	for {
+		select {
+		case data, ok := <-t.queue:
+			if !ok {
+				export()
+				return
+			}
+			batch = append(batch, data)
+			if len(batch) >= batchSize {
+				export()
+			}
+		case ack := <-t.flush:
+			for drained := false; !drained; {
+				select {
+				case data, ok := <-t.queue:
+					if !ok {
+						drained = true
+						break
+					}
+					batch = append(batch, data)
+				default:
+					drained = true
+				}
+			}
+			export()
+			close(ack)
+		case <-ticker.C:
+			export()
+		}
+	}
+}
+
+var defaultTracer atomic.Pointer[Tracer]
+
+// SetDefault installs t as the process-wide tracer. Passing nil disables
+// tracing.
+func SetDefault(t *Tracer) {
+	defaultTracer.Store(t)
+}
+
+// Default returns the process-wide tracer, or nil when tracing is off.
+func Default() *Tracer {
+	return defaultTracer.Load()
+}
+
+// Start begins a span on the default tracer. It returns ctx unchanged and a
+// nil span when tracing is off.
+func Start(ctx context.Context, name string, attrs map[string]any) (context.Context, *Span) {
+	t := defaultTracer.Load()
+	if t == nil {
+		return ctx, nil
+	}
+	return t.Start(ctx, name, attrs)
+}
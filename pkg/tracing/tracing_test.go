@@ -0,0 +1,171 @@
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// memoryExporter collects exported spans.
+type memoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func (e *memoryExporter) ExportSpans(_ context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *memoryExporter) Shutdown(context.Context) error { return nil }
+
+func TestStart_ParentChild(t *testing.T) {
+	exp := &memoryExporter{}
+	tr := NewTracer(exp)
+
+	ctx, root := tr.Start(context.Background(), "root", map[string]any{"a": 1})
+	_, child := tr.Start(ctx, "child", nil)
+	child.RecordError(errors.New("boom"))
+	child.End()
+	root.End()
+	root.End() // second End is ignored
+
+	if err := tr.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if len(exp.spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(exp.spans))
+	}
+
+	c, r := exp.spans[0], exp.spans[1]
+	if c.TraceID != r.TraceID {
+		t.Error("child should share the root's trace ID")
+	}
+	if c.ParentSpanID != r.SpanID || r.ParentSpanID != "" {
+		t.Errorf("unexpected parentage: child.parent=%q root=%q root.parent=%q", c.ParentSpanID, r.SpanID, r.ParentSpanID)
+	}
+	if c.Status != StatusError || c.StatusMessage != "boom" || r.Status != StatusOK {
+		t.Errorf("unexpected status: child=%d %q root=%d", c.Status, c.StatusMessage, r.Status)
+	}
+	if r.Attributes["a"] != 1 {
+		t.Errorf("root attributes = %v", r.Attributes)
+	}
+}
+
+func TestStart_DisabledIsNoop(t *testing.T) {
+	SetDefault(nil)
+	ctx, span := Start(context.Background(), "noop", nil)
+	span.SetAttr("k", "v")
+	span.RecordError(errors.New("ignored"))
+	span.End()
+	if span != nil || TraceIDFromContext(ctx) != "" {
+		t.Error("expected no span while tracing is off")
+	}
+}
+
+func TestTraceparent_RoundTrip(t *testing.T) {
+	tr := NewTracer(&memoryExporter{})
+	defer tr.Shutdown(context.Background())
+
+	_, span := tr.Start(context.Background(), "s", nil)
+	header := span.SpanContext().Traceparent()
+
+	sc, ok := ParseTraceparent(header)
+	if !ok || sc != span.SpanContext() {
+		t.Fatalf("ParseTraceparent(%q) = %v, %v", header, sc, ok)
+	}
+
+	ctx := ContextWithRemoteParent(context.Background(), sc)
+	_, child := tr.Start(ctx, "remote-child", nil)
+	if child.SpanContext().TraceID != sc.TraceID {
+		t.Error("remote child should continue the trace")
+	}
+
+	for _, bad := range []string{"", "00-abc-def-01", "00-" + string(make([]byte, 32)) + "-0000000000000000-01"} {
+		if _, ok := ParseTraceparent(bad); ok {
+			t.Errorf("ParseTraceparent(%q) should fail", bad)
+		}
+	}
+}
+
+func TestJSONLExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces", "spans.jsonl")
+	exp, err := NewJSONLExporter(path)
+	if err != nil {
+		t.Fatalf("NewJSONLExporter: %v", err)
+	}
+	tr := NewTracer(exp)
+	_, span := tr.Start(context.Background(), "op", map[string]any{"tool": "exec"})
+	span.End()
+	tr.Flush()
+	if err := tr.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+
+	var lines []SpanData
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var d SpanData
+		if err := json.Unmarshal(sc.Bytes(), &d); err != nil {
+			t.Fatalf("bad line %q: %v", sc.Text(), err)
+		}
+		lines = append(lines, d)
+	}
+	if len(lines) != 1 || lines[0].Name != "op" || lines[0].Attributes["tool"] != "exec" {
+		t.Errorf("unexpected spans: %+v", lines)
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var got otlpTraces
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		_ = json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	exp := NewOTLPExporter(OTLPConfig{
+		Endpoint: srv.URL,
+		Headers:  map[string]string{"Authorization": "Bearer t"},
+	})
+	tr := NewTracer(exp)
+	_, span := tr.Start(context.Background(), "llm.chat", map[string]any{"model": "m", "prompt_tokens": 12})
+	span.End()
+	if err := tr.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	if auth != "Bearer t" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	if len(got.ResourceSpans) != 1 || len(got.ResourceSpans[0].ScopeSpans[0].Spans) != 1 {
+		t.Fatalf("unexpected payload: %+v", got)
+	}
+	s := got.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	if s.Name != "llm.chat" || len(s.TraceID) != 32 || len(s.SpanID) != 16 || s.Status.Code != StatusOK {
+		t.Errorf("unexpected span: %+v", s)
+	}
+	if len(s.Attributes) != 2 || s.Attributes[1].Key != "prompt_tokens" || s.Attributes[1].Value["intValue"] != "12" {
+		t.Errorf("unexpected attributes: %+v", s.Attributes)
+	}
+	service := got.ResourceSpans[0].Resource.Attributes[0]
+	if service.Key != "service.name" || service.Value["stringValue"] != "tinyclaw" {
+		t.Errorf("unexpected resource: %+v", service)
+	}
+}